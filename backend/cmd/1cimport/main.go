@@ -0,0 +1,118 @@
+// Команда 1cimport создает заготовки OrderFlow по выгрузке конфигурации 1С в
+// XML: модели, миграцию Postgres и репозитории для справочников, документов
+// с табличными частями и регистров накопления.
+//
+//	1cimport -dump ./conf -objects Справочник.Склады
+//
+// Запускается из каталога backend (или с -out). Существующие файлы не
+// перезаписываются без -force; -dry-run печатает разобранные объекты и
+// файлы, ничего не записывая.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/metadata"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/migrate"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/scaffold"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("1cimport: ")
+
+	dump := flag.String("dump", "", "directory of the 1C configuration dump (required)")
+	out := flag.String("out", ".", "backend directory to write the files to")
+	objects := flag.String("objects", "", "comma-separated objects to generate, e.g. Справочник.Склады; default: all not implemented by OrderFlow")
+	name := flag.String("name", "", "migration name; default: 1c_<table> for one object, 1c_import otherwise")
+	force := flag.Bool("force", false, "overwrite existing files")
+	dryRun := flag.Bool("dry-run", false, "print the parsed objects and the files without writing them")
+	flag.Parse()
+
+	if *dump == "" || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config, err := metadata.Read(os.DirFS(*dump))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, w := range config.Warnings() {
+		log.Printf("warning: %s", w)
+	}
+
+	latest, err := migrate.Latest(os.DirFS(filepath.Join(*out, scaffold.MigrationsDir)))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal(err)
+	}
+
+	opts := scaffold.Options{Migration: latest + 1, MigrationName: *name}
+	for _, o := range strings.Split(*objects, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			opts.Objects = append(opts.Objects, o)
+		}
+	}
+
+	files, err := scaffold.Generate(config, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *dryRun {
+		printConfiguration(config)
+		for _, f := range files {
+			fmt.Println(filepath.Join(*out, f.Path))
+		}
+		return
+	}
+
+	if !*force {
+		for _, f := range files {
+			if _, err := os.Stat(filepath.Join(*out, f.Path)); err == nil {
+				log.Fatalf("%s already exists; use -force to overwrite", f.Path)
+			}
+		}
+	}
+	for _, f := range files {
+		path := filepath.Join(*out, f.Path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(path, f.Content, 0o644); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("wrote", path)
+	}
+	fmt.Println("register the new repositories in repository.Repositories and apply the migration with: server migrate up")
+}
+
+// printConfiguration печатает промежуточное представление выгрузки
+func printConfiguration(config *metadata.Configuration) {
+	printFields := func(indent, title string, fields []metadata.Field) {
+		for _, f := range fields {
+			fmt.Printf("%s%s %s: %s\n", indent, title, f.Name, f.Type)
+		}
+	}
+	for _, o := range config.Objects {
+		fmt.Println(o.FullName())
+		printFields("  ", "attribute", o.Attributes)
+		printFields("  ", "dimension", o.Dimensions)
+		printFields("  ", "resource", o.Resources)
+		for _, ts := range o.TabularSections {
+			fmt.Printf("  tabular section %s\n", ts.Name)
+			printFields("    ", "attribute", ts.Fields)
+		}
+		for _, reg := range o.Registers {
+			fmt.Printf("  records %s\n", reg)
+		}
+	}
+	fmt.Println()
+}
@@ -1,59 +1,217 @@
 package main
 
 import (
-	"log"
-
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/1C-Migration-Lab/OrderFlow/internal/api"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/config"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
 	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository/memory"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository/sqlite"
 	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 func main() {
-	// Загрузка .env файла
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
+	// Загрузка .env файла, если он есть
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Error loading .env file: %v", err)
 	}
 
-	dbPass := os.Getenv("DB_PASSWORD")
-	dbURL := fmt.Sprintf("postgres://postgres.hhdqekkbomdofkinrajy:%s@aws-0-eu-central-1.pooler.supabase.com:6543/postgres", dbPass)
-	db, err := repository.NewDB(dbURL)
+	cfg, args, err := config.Load(os.Args[1:])
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if cfg.PrintConfig {
+		fmt.Print(cfg)
+		return
 	}
-	defer db.Close()
+	logger := newLogger(cfg.Log.Level)
+
+	rounding, _ := money.ParseRoundingMode(cfg.Money.Rounding) // проверено в config.Validate
+	money.SetRounding(rounding)
+
+	// Подкоманда управления схемой: server migrate up|down N|status|force V
+	if len(args) > 0 && args[0] == "migrate" {
+		runMigrate(cfg, args[1:])
+		return
+	}
+	// Спецификация OpenAPI без запуска сервера: server openapi
+	if len(args) > 0 && args[0] == "openapi" {
+		runOpenAPI(cfg)
+		return
+	}
+
+	logger.Info("effective configuration", "config", cfg)
 
 	// Initialize repositories
-	repos := repository.NewPostgresRepository(db)
+	var repos *repository.Repositories
+	switch cfg.Storage {
+	case "memory":
+		logger.Warn("using in-memory storage, data will be lost on exit")
+		repos = memory.NewRepositories(cfg.Numbering.Orders)
+	case "postgres":
+		db, err := openPostgres(cfg.Database)
+		if err != nil {
+			fatal(logger, "failed to connect to database", err)
+		}
+		defer db.Close()
+
+		if cfg.Database.RequireMigrations {
+			if err := checkSchema(db); err != nil {
+				fatal(logger, "schema check failed", err)
+			}
+		}
+
+		repos = repository.NewPostgresRepository(db, cfg.Numbering.Orders)
+	case "sqlite":
+		db, err := sqlite.NewDB(cfg.Database.SQLitePath)
+		if err != nil {
+			fatal(logger, "failed to open SQLite database "+cfg.Database.SQLitePath, err)
+		}
+		defer db.Close()
+
+		repos = sqlite.NewRepositories(db, cfg.Numbering.Orders)
+	}
 
 	// Initialize services
-	services := service.NewServices(repos)
+	services := service.NewServices(repos, service.AuthConfig{
+		Enabled:    cfg.Auth.Enabled,
+		Secret:     []byte(cfg.Auth.Secret),
+		AccessTTL:  time.Duration(cfg.Auth.AccessTTL),
+		RefreshTTL: time.Duration(cfg.Auth.RefreshTTL),
+	}, service.ExchangeConfig{
+		Dir: cfg.Exchange.Dir,
+	})
+
+	if cfg.Auth.Enabled && cfg.Auth.AdminPassword != "" {
+		ctx := service.WithActor(context.Background(), service.Actor{Name: "system", Roles: []service.Role{service.RoleAdmin}})
+		ctx = tenant.WithUser(tenant.WithID(ctx, tenant.Default), "system")
+		if err := services.User.EnsureAdmin(ctx, cfg.Auth.AdminLogin, cfg.Auth.AdminPassword); err != nil {
+			fatal(logger, "failed to create administrator "+cfg.Auth.AdminLogin, err)
+		}
+	}
 
 	// Initialize router
 	router := gin.Default()
 
-	// Enable CORS
-	router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+	// Enable CORS and API keys for integrations
+	router.Use(corsMiddleware(cfg.CORS.AllowedOrigins))
+	router.Use(api.APIKeys(services.APIKey))
+
+	// Initialize API handlers
+	if _, err := api.RegisterRoutes(router, services); err != nil {
+		fatal(logger, "failed to register routes", err)
+	}
+
+	// Start server
+	srv := &http.Server{
+		Addr:         cfg.Server.ListenAddr,
+		Handler:      router,
+		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		logger.Info("listening", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal(logger, "failed to start server", err)
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("graceful shutdown failed", "error", err)
+	}
+}
+
+func openPostgres(cfg config.DatabaseConfig) (*sql.DB, error) {
+	db, err := repository.NewDB(cfg.PostgresDSN())
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime))
+	return db, nil
+}
+
+// corsMiddleware разрешает запросы с перечисленных источников; "*" разрешает любой
+func corsMiddleware(origins []string) gin.HandlerFunc {
+	allowAll := false
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		if o == "*" {
+			allowAll = true
+		}
+		allowed[o] = true
+	}
+
+	return func(c *gin.Context) {
+		if allowAll {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		} else if origin := c.GetHeader("Origin"); allowed[origin] {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Add("Vary", "Origin")
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match, X-Request-ID, X-Change-Reason, X-API-Key, X-Organization-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag, X-Request-ID, Retry-After")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
 		}
 		c.Next()
-	})
+	}
+}
 
-	// Initialize API handlers
-	api.RegisterRoutes(router, services)
+// newLogger создает журнал приложения с заданным уровнем и настраивает режим gin
+func newLogger(level string) *slog.Logger {
+	var l slog.Level
+	switch level {
+	case "debug":
+		l = slog.LevelDebug
+	case "warn":
+		l = slog.LevelWarn
+	case "error":
+		l = slog.LevelError
+	default:
+		l = slog.LevelInfo
+	}
 
-	// Start server
-	if err := router.Run(":8080"); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	if level == "debug" {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
 	}
+
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: l}))
+}
+
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/config"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/migrate"
+	"github.com/1C-Migration-Lab/OrderFlow/migrations"
+)
+
+const migrateUsage = `usage: server migrate <command>
+
+commands:
+  up         apply all pending migrations
+  down N     roll back the last N applied migrations (default 1)
+  status     list migrations and whether they are applied
+  force V    mark migrations up to version V as applied without running them`
+
+func runMigrate(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	if cfg.Storage != "postgres" {
+		log.Fatalf("migrations apply to postgres storage only, configured storage is %s", cfg.Storage)
+	}
+
+	db, err := openPostgres(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	m, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		done, err := m.Up(ctx)
+		for _, mig := range done {
+			fmt.Printf("applied %06d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(done) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				log.Fatalf("invalid migration count %q", args[1])
+			}
+		}
+		done, err := m.Down(ctx, n)
+		for _, mig := range done {
+			fmt.Printf("rolled back %06d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%06d_%-40s %s\n", s.Version, s.Name, applied)
+		}
+	case "force":
+		if len(args) < 2 {
+			log.Fatal("force requires a version")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			log.Fatalf("invalid version %q", args[1])
+		}
+		if err := m.Force(ctx, version); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
+
+// checkSchema возвращает ошибку, если в базе есть неприменённые миграции
+func checkSchema(db *sql.DB) error {
+	m, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	pending, err := m.Pending(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("schema is behind by %d migration(s), first pending is %06d_%s; run `server migrate up`",
+			len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/api"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/config"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository/memory"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// runOpenAPI — подкоманда server openapi. Она регистрирует маршруты так же,
+// как сервер, но без базы данных, и печатает спецификацию OpenAPI.
+func runOpenAPI(cfg *config.Config) {
+	gin.SetMode(gin.ReleaseMode)
+	services := service.NewServices(memory.NewRepositories(cfg.Numbering.Orders), service.AuthConfig{}, service.ExchangeConfig{})
+
+	doc, err := api.RegisterRoutes(gin.New(), services)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader — заголовок с ключом API для интеграций
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys проверяет ключ из заголовка X-API-Key и выполняет запрос от имени
+// ключа с его областями доступа. Запросы без ключа проходят дальше к
+// проверке токена (Authenticate). Регистрируется вместе с CORS раньше
+// маршрутов, поэтому ошибку отдает сама.
+func APIKeys(keys service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := c.GetHeader(APIKeyHeader)
+		if secret == "" {
+			c.Next()
+			return
+		}
+
+		actor, err := keys.Authenticate(c.Request.Context(), secret)
+		if err != nil {
+			var limited *service.RateLimitError
+			if errors.As(err, &limited) {
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+			}
+			AbortWithError(c, err)
+			return
+		}
+
+		c.Request = c.Request.WithContext(service.WithActor(c.Request.Context(), actor))
+		c.Next()
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/api/handlers"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Authenticate проверяет токен из заголовка Authorization и передает
+// его владельца сервисам. Запрос, уже опознанный по ключу API (APIKeys),
+// пропускается. Если аутентификация выключена, запрос выполняется от имени
+// service.Anonymous.
+func Authenticate(auth service.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.Enabled() || service.ActorFromContext(c.Request.Context()).KeyID != 0 {
+			c.Next()
+			return
+		}
+
+		token, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			unauthorized(c, service.ErrUnauthorized)
+			return
+		}
+
+		actor, err := auth.Authenticate(c.Request.Context(), token)
+		if err != nil {
+			unauthorized(c, err)
+			return
+		}
+
+		c.Request = c.Request.WithContext(service.WithActor(c.Request.Context(), actor))
+		c.Next()
+	}
+}
+
+// ExchangeSession передает Authenticate токен из cookie сеанса обмена 1С
+// (handlers.ExchangeCookie): узел 1С возвращает cookie из ответа checkauth,
+// но не умеет передавать заголовок Authorization
+func ExchangeSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if token, err := c.Cookie(handlers.ExchangeCookie); err == nil && token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireAdmin пропускает запрос администратора развертывания
+// (service.Actor.IsAdmin). Ключам API и администраторам организаций
+// такие методы недоступны.
+func RequireAdmin() gin.HandlerFunc {
+	return permit(service.Actor.IsAdmin)
+}
+
+// Require пропускает запрос ключа API с областью доступа scope или
+// пользователя с одной из ролей roles; без ролей — любого пользователя
+func Require(scope service.Scope, roles ...service.Role) gin.HandlerFunc {
+	return permit(func(a service.Actor) bool { return a.Can(scope, roles...) })
+}
+
+func permit(allowed func(a service.Actor) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !allowed(service.ActorFromContext(c.Request.Context())) {
+			_ = c.Error(service.ErrForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+func unauthorized(c *gin.Context, err error) {
+	c.Header("WWW-Authenticate", `Bearer realm="orderflow"`)
+	_ = c.Error(err)
+	c.Abort()
+}
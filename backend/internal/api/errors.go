@@ -0,0 +1,47 @@
+package api
+
+import (
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorResponse — тело ответа с ошибкой, общее для всех методов API
+type ErrorResponse struct {
+	Code    string              `json:"code"`
+	Message string              `json:"message"`
+	Details []apperr.FieldError `json:"details"`
+	// Current — текущее состояние записи при ответе 412 Precondition Failed
+	Current interface{} `json:"current,omitempty"`
+}
+
+// ErrorHandler формирует ответ по последней ошибке, добавленной обработчиком
+// через c.Error. Текст внутренних ошибок клиенту не передается: он попадает
+// в журнал запросов gin вместе с остальными ошибками контекста.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		writeError(c, c.Errors.Last().Err)
+	}
+}
+
+// AbortWithError прерывает запрос и сразу формирует ответ с ошибкой. Нужен
+// middleware, которые выполняются раньше ErrorHandler.
+func AbortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+	writeError(c, err)
+}
+
+func writeError(c *gin.Context, err error) {
+	e := apperr.From(err)
+	resp := ErrorResponse{Code: e.Code, Message: e.Message, Details: e.Details, Current: e.Current}
+	if resp.Details == nil {
+		resp.Details = []apperr.FieldError{}
+	}
+	c.JSON(e.Kind.Status(), resp)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// TestErrorHandler проверяет статус и тело ответа для ошибок каждого вида
+func TestErrorHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		code    string
+		message string
+		details []apperr.FieldError
+	}{
+		{"bad request", apperr.ErrBadRequest.WithMessage("invalid id format"),
+			http.StatusBadRequest, "bad_request", "invalid id format", nil},
+		{"unauthorized", service.ErrInvalidToken, http.StatusUnauthorized, "invalid_token", "token is invalid or expired", nil},
+		{"forbidden", service.ErrForbidden, http.StatusForbidden, "forbidden", "action is not allowed for the current user", nil},
+		{"not found", service.ErrNotFound.WithMessage("client not found"), http.StatusNotFound, "not_found", "client not found", nil},
+		{"conflict", service.ErrClientHasOrders, http.StatusConflict, "client_has_orders", "client has associated orders", nil},
+		{"too large", service.ErrExchangeFileTooLarge, http.StatusRequestEntityTooLarge, "file_too_large",
+			service.ErrExchangeFileTooLarge.Message, nil},
+		{"validation", service.ErrValidation.WithDetails(apperr.Field("name", "is required")),
+			http.StatusUnprocessableEntity, "validation_failed", "validation error", []apperr.FieldError{{Field: "name", Message: "is required"}}},
+		{"precondition failed", service.ErrVersionMismatch, http.StatusPreconditionFailed, "version_mismatch",
+			"record has been modified by another request", nil},
+		{"too many requests", service.ErrRateLimited, http.StatusTooManyRequests, "rate_limited", "API key rate limit exceeded", nil},
+		{"wrapped", fmt.Errorf("row 3: %w", service.ErrOrderNotEditable), http.StatusConflict, "order_not_editable",
+			"only draft orders can be edited", nil},
+		// Текст внутренней ошибки клиенту не передается
+		{"internal", errors.New("pq: password authentication failed for user orderflow"),
+			http.StatusInternalServerError, "internal", "internal server error", nil},
+		{"internal with cause", apperr.ErrInternal.Wrap(errors.New("disk full")),
+			http.StatusInternalServerError, "internal", "internal server error", nil},
+	}
+
+	for _, tt := range tests {
+		r := gin.New()
+		r.Use(ErrorHandler())
+		r.GET("/", func(c *gin.Context) { _ = c.Error(tt.err) })
+		w := serve(r, http.MethodGet, "/", "")
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.status)
+		}
+		var resp ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Errorf("%s: body %s: %v", tt.name, w.Body, err)
+			continue
+		}
+		if resp.Code != tt.code || resp.Message != tt.message {
+			t.Errorf("%s: body = %s/%q, want %s/%q", tt.name, resp.Code, resp.Message, tt.code, tt.message)
+		}
+		want := tt.details
+		if want == nil {
+			want = []apperr.FieldError{}
+		}
+		if !reflect.DeepEqual(resp.Details, want) {
+			t.Errorf("%s: details = %+v, want %+v", tt.name, resp.Details, want)
+		}
+		if !strings.Contains(w.Body.String(), `"details":[`) {
+			t.Errorf("%s: details are not an array: %s", tt.name, w.Body)
+		}
+		if strings.Contains(w.Body.String(), `"current":`) {
+			t.Errorf("%s: body has current without a record: %s", tt.name, w.Body)
+		}
+	}
+}
+
+func TestErrorHandlerCurrent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.GET("/conflict", func(c *gin.Context) {
+		_ = c.Error(service.ErrVersionMismatch.WithCurrent(map[string]int{"version": 3}))
+	})
+	// Ответ, начатый обработчиком, не заменяется
+	r.GET("/written", func(c *gin.Context) {
+		c.String(http.StatusOK, "partial")
+		_ = c.Error(errors.New("stream broken"))
+	})
+
+	w := serve(r, http.MethodGet, "/conflict", "")
+	if w.Code != http.StatusPreconditionFailed || !strings.Contains(w.Body.String(), `"current":{"version":3}`) {
+		t.Errorf("conflict: status %d, body %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/written", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Errorf("written response: status %d, body %q", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// TestClientETag проверяет условные запросы к клиенту: ETag — версия записи,
+// If-Match защищает от перезаписи чужих изменений
+func TestClientETag(t *testing.T) {
+	r := newTestRouter(t)
+
+	w := serve(r, http.MethodPost, "/api/clients", `{"name": "ООО Ромашка"}`)
+	if w.Code != http.StatusCreated || w.Header().Get("ETag") != `"1"` {
+		t.Fatalf("create: status %d, ETag %s: %s", w.Code, w.Header().Get("ETag"), w.Body)
+	}
+	var created struct{ ID int64 }
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	path := "/api/clients/" + strconv.FormatInt(created.ID, 10)
+
+	if w := serve(r, http.MethodGet, path, "", "If-None-Match", `"1"`); w.Code != http.StatusNotModified {
+		t.Errorf("GET with current If-None-Match: status %d, want 304", w.Code)
+	}
+
+	// Каждый шаг меняет запись, если ожидает успеха, поэтому версия растет
+	tests := []struct {
+		name    string
+		body    string
+		ifMatch string
+		status  int
+		etag    string
+	}{
+		{"matching If-Match", `{"name": "ООО Ромашка 2"}`, `"1"`, http.StatusOK, `"2"`},
+		{"stale If-Match", `{"name": "ООО Ромашка 3"}`, `"1"`, http.StatusPreconditionFailed, `"2"`},
+		{"weak ETag", `{"name": "ООО Ромашка 3"}`, `W/"2"`, http.StatusPreconditionFailed, `"2"`},
+		{"one of several ETags", `{"name": "ООО Ромашка 3"}`, `"1", "2"`, http.StatusOK, `"3"`},
+		{"stale version in body", `{"name": "ООО Ромашка 4", "version": 2}`, "", http.StatusPreconditionFailed, `"3"`},
+		{"If-Match overrides the body", `{"name": "ООО Ромашка 4", "version": 2}`, `"3"`, http.StatusOK, `"4"`},
+		{"no If-Match and no version", `{"name": "ООО Ромашка 5"}`, "", http.StatusOK, `"5"`},
+		{"any version", `{"name": "ООО Ромашка 6"}`, "*", http.StatusOK, `"6"`},
+	}
+	for _, tt := range tests {
+		var header []string
+		if tt.ifMatch != "" {
+			header = []string{"If-Match", tt.ifMatch}
+		}
+		w := serve(r, http.MethodPut, path, tt.body, header...)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.status, w.Body)
+			continue
+		}
+		if got := w.Header().Get("ETag"); got != tt.etag {
+			t.Errorf("%s: ETag = %s, want %s", tt.name, got, tt.etag)
+		}
+		if tt.status != http.StatusPreconditionFailed {
+			continue
+		}
+
+		// Ответ 412 содержит текущее состояние записи
+		var resp ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		current, _ := resp.Current.(map[string]interface{})
+		if version, _ := current["version"].(float64); `"`+strconv.Itoa(int(version))+`"` != tt.etag {
+			t.Errorf("%s: current = %v, want the record at ETag %s", tt.name, resp.Current, tt.etag)
+		}
+	}
+
+	w = serve(r, http.MethodGet, path, "", "If-None-Match", `"1"`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"6"` {
+		t.Errorf("GET with stale If-None-Match: status %d, ETag %s", w.Code, w.Header().Get("ETag"))
+	}
+	if w := serve(r, http.MethodPut, "/api/clients/999", `{"name": "x"}`, "If-Match", `"1"`); w.Code != http.StatusNotFound {
+		t.Errorf("PUT missing client: status %d, want 404", w.Code)
+	}
+}
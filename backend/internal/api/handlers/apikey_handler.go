@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyRequest — тело запроса POST /api/api-keys. Без rate_limit действует
+// service.DefaultAPIKeyRateLimit, без expires_at ключ бессрочный, без
+// organization_id ключ выпускается для организации запроса.
+type APIKeyRequest struct {
+	Name           string     `json:"name" binding:"required,max=100"`
+	Scopes         []string   `json:"scopes" binding:"required"`
+	OrganizationID int64      `json:"organization_id" binding:"gte=0"`
+	RateLimit      int        `json:"rate_limit" binding:"gte=0"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+}
+
+// CreatedAPIKey — ответ на выпуск ключа. Значение Key показывается только здесь.
+type CreatedAPIKey struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+func GetAPIKeys(s service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys, err := s.List(c.Request.Context())
+		if err != nil {
+			fail(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, keys)
+	}
+}
+
+func GetAPIKeyByID(s service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			fail(c, errInvalidID)
+			return
+		}
+
+		key, err := s.GetByID(c.Request.Context(), id)
+		if err != nil {
+			failFor(c, err, "API key")
+			return
+		}
+
+		c.JSON(http.StatusOK, key)
+	}
+}
+
+func CreateAPIKey(s service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req APIKeyRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		key := models.APIKey{
+			Name: req.Name, Scopes: req.Scopes, OrganizationID: req.OrganizationID,
+			RateLimit: req.RateLimit, ExpiresAt: req.ExpiresAt,
+		}
+		secret, err := s.Create(c.Request.Context(), &key)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, CreatedAPIKey{APIKey: key, Key: secret})
+	}
+}
+
+// RevokeAPIKey отзывает ключ. Ключ остается в списке с временем отзыва.
+func RevokeAPIKey(s service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			fail(c, errInvalidID)
+			return
+		}
+
+		key, err := s.Revoke(c.Request.Context(), id)
+		if err != nil {
+			failFor(c, err, "API key")
+			return
+		}
+
+		c.JSON(http.StatusOK, key)
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// LoginRequest — тело запроса POST /api/auth/login
+type LoginRequest struct {
+	Login    string `json:"login" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// RefreshRequest — тело запроса POST /api/auth/refresh
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Login выдает пару токенов по логину и паролю
+func Login(s service.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req LoginRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		tokens, err := s.Login(c.Request.Context(), req.Login, req.Password)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// RefreshToken выдает новую пару токенов по токену обновления
+func RefreshToken(s service.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RefreshRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		tokens, err := s.Refresh(c.Request.Context(), req.RefreshToken)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// GetCurrentUser возвращает исполнителя запроса и его роли
+func GetCurrentUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, service.ActorFromContext(c.Request.Context()))
+	}
+}
@@ -5,18 +5,60 @@ import (
 	"strconv"
 
 	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
 	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
 	"github.com/gin-gonic/gin"
 )
 
+// ClientRequest — тело запросов POST и PUT /api/clients. ИНН необязателен;
+// version — ожидаемая версия при изменении без заголовка If-Match.
+type ClientRequest struct {
+	Name    string `json:"name" binding:"required,max=255"`
+	INN     string `json:"inn" binding:"inn"`
+	Version int64  `json:"version" binding:"gte=0"`
+}
+
+func (r ClientRequest) model(id int64) models.Client {
+	return models.Client{ID: id, Name: r.Name, INN: r.INN, Version: r.Version}
+}
+
+// UpsertClientRequest — тело запроса PUT /api/clients/by-external/:system/:externalId;
+// external_code — код клиента во внешней системе
+type UpsertClientRequest struct {
+	Name         string `json:"name" binding:"required,max=255"`
+	INN          string `json:"inn" binding:"inn"`
+	ExternalCode string `json:"external_code" binding:"max=50"`
+}
+
+// ClientResponse — клиент в ответах API
+type ClientResponse struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	INN     string `json:"inn"`
+	Version int64  `json:"version"`
+}
+
+func newClientResponse(c *models.Client) ClientResponse {
+	return ClientResponse{ID: c.ID, Name: c.Name, INN: c.INN, Version: c.Version}
+}
+
 func GetClients(s service.ClientService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clients, err := s.GetAll(c.Request.Context())
+		params, err := parseListParams(c)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+
+		clients, err := s.List(c.Request.Context(), repository.ClientQuery{
+			ListParams: params,
+			Search:     c.Query("search"),
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			fail(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, clients)
+		c.JSON(http.StatusOK, mapPage(clients, newClientResponse))
 	}
 }
 
@@ -24,38 +66,39 @@ func GetClientByID(s service.ClientService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+			fail(c, errInvalidID)
 			return
 		}
 
 		client, err := s.GetByID(c.Request.Context(), id)
-		if err == service.ErrNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "client not found"})
-			return
-		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			failFor(c, err, "client")
 			return
 		}
 
-		c.JSON(http.StatusOK, client)
+		if notModified(c, client.Version) {
+			return
+		}
+		setETag(c, client.Version)
+		c.JSON(http.StatusOK, newClientResponse(client))
 	}
 }
 
 func CreateClient(s service.ClientService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var client models.Client
-		if err := c.ShouldBindJSON(&client); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		var req ClientRequest
+		if !bindJSON(c, &req) {
 			return
 		}
 
+		client := req.model(0)
 		if err := s.Create(c.Request.Context(), &client); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			fail(c, err)
 			return
 		}
 
-		c.JSON(http.StatusCreated, client)
+		setETag(c, client.Version)
+		c.JSON(http.StatusCreated, newClientResponse(&client))
 	}
 }
 
@@ -63,26 +106,29 @@ func UpdateClient(s service.ClientService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+			fail(c, errInvalidID)
 			return
 		}
 
-		var client models.Client
-		if err := c.ShouldBindJSON(&client); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		var req ClientRequest
+		if !bindJSON(c, &req) {
 			return
 		}
-		client.ID = id
+		client := req.model(id)
 
-		if err := s.Update(c.Request.Context(), &client); err == service.ErrNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "client not found"})
+		current := func() (*models.Client, error) { return s.GetByID(c.Request.Context(), id) }
+		if client.Version, err = ifMatch(c, client.Version, current, clientVersion); err != nil {
+			failFor(c, withCurrent(c, err, current, clientVersion, newClientResponse), "client")
 			return
-		} else if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+
+		if err := s.Update(c.Request.Context(), &client); err != nil {
+			failFor(c, withCurrent(c, err, current, clientVersion, newClientResponse), "client")
 			return
 		}
 
-		c.JSON(http.StatusOK, client)
+		setETag(c, client.Version)
+		c.JSON(http.StatusOK, newClientResponse(&client))
 	}
 }
 
@@ -90,18 +136,12 @@ func DeleteClient(s service.ClientService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+			fail(c, errInvalidID)
 			return
 		}
 
-		if err := s.Delete(c.Request.Context(), id); err == service.ErrNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "client not found"})
-			return
-		} else if err == service.ErrClientHasOrders {
-			c.JSON(http.StatusConflict, gin.H{"error": "client has associated orders"})
-			return
-		} else if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := s.Delete(c.Request.Context(), id); err != nil {
+			failFor(c, err, "client")
 			return
 		}
 
@@ -113,20 +153,72 @@ func GetClientOrders(s service.ClientService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+			fail(c, errInvalidID)
 			return
 		}
 
 		orders, err := s.GetClientOrders(c.Request.Context(), id)
-		if err == service.ErrNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "client not found"})
+		if err != nil {
+			failFor(c, err, "client")
+			return
+		}
+
+		c.JSON(http.StatusOK, mapSlice(orders, newOrderResponse))
+	}
+}
+
+func GetClientHistory(s service.ClientService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			fail(c, errInvalidID)
+			return
+		}
+
+		history, err := s.GetHistory(c.Request.Context(), id)
+		if err != nil {
+			failFor(c, err, "client")
 			return
 		}
+
+		c.JSON(http.StatusOK, history)
+	}
+}
+
+func GetClientByExternalID(s service.ClientService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client, err := s.GetByExternalID(c.Request.Context(), c.Param("system"), c.Param("externalId"))
+		if err != nil {
+			failFor(c, err, "client")
+			return
+		}
+
+		if notModified(c, client.Version) {
+			return
+		}
+		setETag(c, client.Version)
+		c.JSON(http.StatusOK, newClientResponse(client))
+	}
+}
+
+// UpsertClientByExternalID изменяет клиента с внешним идентификатором или
+// создает его; повторный запрос с теми же данными ничего не меняет
+func UpsertClientByExternalID(s service.ClientService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UpsertClientRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		ref := externalRef(c, req.ExternalCode)
+		client := models.Client{Name: req.Name, INN: req.INN}
+		created, err := s.Upsert(c.Request.Context(), &ref, &client)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			fail(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, orders)
+		setETag(c, client.Version)
+		c.JSON(upsertStatus(created), newClientResponse(&client))
 	}
 }
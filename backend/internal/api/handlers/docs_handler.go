@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/api/openapi"
+	"github.com/gin-gonic/gin"
+)
+
+// docsPage — страница Redoc, которая отображает /api/openapi.json
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>OrderFlow API</title>
+</head>
+<body>
+  <redoc spec-url="/api/openapi.json"></redoc>
+  <script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+// GetOpenAPI отдает спецификацию API. doc заполняется после регистрации
+// всех маршрутов, поэтому передается указателем.
+func GetOpenAPI(doc *openapi.Document) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+// GetDocs отдает страницу документации API
+func GetDocs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+	}
+}
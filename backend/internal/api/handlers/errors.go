@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+var errInvalidID = apperr.ErrBadRequest.WithMessage("invalid id format")
+
+// fail передает ошибку middleware api.ErrorHandler, который формирует ответ
+func fail(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// failFor как fail, но уточняет текст "не найдено" названием сущности
+func failFor(c *gin.Context, err error, entity string) {
+	if errors.Is(err, service.ErrNotFound) {
+		err = apperr.From(err).WithMessage(entity + " not found")
+	}
+	fail(c, err)
+}
+
+// bindJSON разбирает тело запроса. Ошибки тегов binding становятся ошибками
+// валидации с деталями по полям, остальные — ошибкой формата запроса.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		fail(c, service.ErrValidation.WithDetails(fieldErrors(verrs)...))
+		return false
+	}
+
+	// Число вне DECIMAL(15,n) разобрано, но недопустимо, как и нарушение тегов
+	if errors.Is(err, money.ErrOutOfRange) {
+		fail(c, service.ErrValidation.WithMessage(err.Error()).Wrap(err))
+		return false
+	}
+
+	fail(c, apperr.ErrBadRequest.WithMessage("malformed request body: "+err.Error()))
+	return false
+}
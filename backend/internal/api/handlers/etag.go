@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ETag записи — ее версия в кавычках: "3". Версия меняется при каждом
+// изменении записи, поэтому ETag сильный.
+
+func clientVersion(c *models.Client) int64   { return c.Version }
+func productVersion(p *models.Product) int64 { return p.Version }
+func orderVersion(o *models.Order) int64     { return o.Version }
+func userVersion(u *models.User) int64       { return u.Version }
+
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+func setETag(c *gin.Context, version int64) {
+	c.Header("ETag", etag(version))
+}
+
+// notModified отвечает 304, если If-None-Match совпадает с версией записи
+func notModified(c *gin.Context, version int64) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag(version) {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatch возвращает версию, которую клиент ожидает изменить: из заголовка
+// If-Match, а без него — из поля version тела запроса. Ноль означает изменение
+// без проверки версии (If-Match: * или старый клиент без версии). Если в заголовке
+// несколько ETag, ожидаемой считается текущая версия записи из их числа.
+func ifMatch[T any](c *gin.Context, bodyVersion int64, get func() (*T, error), version func(*T) int64) (int64, error) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return bodyVersion, nil
+	}
+
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return 0, nil
+		}
+		// Слабые и чужие ETag при If-Match никогда не совпадают
+		v, err := strconv.ParseInt(strings.Trim(tag, `"`), 10, 64)
+		if err != nil || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || v <= 0 {
+			continue
+		}
+		versions = append(versions, v)
+	}
+
+	switch len(versions) {
+	case 0:
+		return 0, service.ErrVersionMismatch
+	case 1:
+		return versions[0], nil
+	}
+
+	current, err := get()
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range versions {
+		if v == version(current) {
+			return v, nil
+		}
+	}
+	return 0, service.ErrVersionMismatch
+}
+
+// withCurrent дополняет ошибку конфликта версий текущим состоянием записи
+// в виде ответа view и его ETag, чтобы клиент мог показать изменения и
+// повторить запрос
+func withCurrent[T, R any](c *gin.Context, err error, get func() (*T, error), version func(*T) int64, view func(*T) R) error {
+	if !errors.Is(err, service.ErrVersionMismatch) {
+		return err
+	}
+	current, gerr := get()
+	if gerr != nil {
+		return err
+	}
+	setETag(c, version(current))
+	return apperr.From(err).WithCurrent(view(current))
+}
+
+// asIs отдает запись в ответе без преобразования
+func asIs[T any](v *T) *T { return v }
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ExchangeCookie — cookie сеанса обмена 1С со значением токена доступа,
+// которую узел 1С получает в ответе checkauth и передает в остальных запросах
+const ExchangeCookie = "orderflow_exchange"
+
+// exchangeFileLimit — размер части файла, которым 1С передает файлы в режиме file
+const exchangeFileLimit = 10 << 20
+
+// ImportCommerceML загружает документ CommerceML из тела запроса: каталог
+// import.xml или документы orders.xml
+func ImportCommerceML(s service.ExchangeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report, err := s.Import(c.Request.Context(), exchangeBody(c))
+		if err != nil {
+			fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// ExportCommerceMLCatalog выгружает клиентов и товары как import.xml
+func ExportCommerceMLCatalog(s service.ExchangeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := s.ExportCatalog(c.Request.Context(), &xmlFile{c: c, name: "import.xml"})
+		if err != nil {
+			fail(c, err)
+		}
+	}
+}
+
+// ExportCommerceMLOrders выгружает заказы с отбором списка заказов как orders.xml
+func ExportCommerceMLOrders(s service.ExchangeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q, err := parseOrderQuery(c)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+		if err := s.ExportOrders(c.Request.Context(), q, &xmlFile{c: c, name: "orders.xml"}); err != nil {
+			fail(c, err)
+		}
+	}
+}
+
+// CommerceMLCheckAuth начинает сеанс протокола обмена 1С (mode=checkauth):
+// проверяет логин и пароль из Basic-аутентификации и отвечает cookie
+// ExchangeCookie с токеном доступа. Регистрируется перед аутентификацией
+// запросов: остальные режимы пропускаются дальше.
+func CommerceMLCheckAuth(auth service.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Query("mode") != "checkauth" {
+			c.Next()
+			return
+		}
+		c.Abort()
+
+		// Без аутентификации значение cookie не проверяется
+		token := "anonymous"
+		if auth.Enabled() {
+			login, password, ok := c.Request.BasicAuth()
+			if !ok {
+				c.Header("WWW-Authenticate", `Basic realm="orderflow"`)
+				exchangeFail(c, service.ErrUnauthorized)
+				return
+			}
+			tokens, err := auth.Login(c.Request.Context(), login, password)
+			if err != nil {
+				exchangeFail(c, err)
+				return
+			}
+			token = tokens.AccessToken
+		}
+		c.String(http.StatusOK, "success\n%s\n%s\n", ExchangeCookie, token)
+	}
+}
+
+// CommerceMLExchange реализует протокол обмена 1С с сайтом для type=catalog
+// (выгрузка каталога из 1С) и type=sale (обмен заказами):
+//   - init — начало сеанса, ответ с параметрами передачи файлов;
+//   - file — прием файла filename, части файла дописываются; заказы
+//     (type=sale) загружаются сразу после приема;
+//   - import — загрузка принятого файла filename (type=catalog);
+//   - query — выгрузка для 1С заказов после черновика, которые она еще
+//     не получила или которые изменились после получения (type=sale);
+//   - success — подтверждение получения заказов последней выгрузки query.
+//
+// Ответ — текст: success или failure и причина на следующей строке.
+func CommerceMLExchange(s service.ExchangeService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		typ, mode := c.Query("type"), c.Query("mode")
+		if typ != "catalog" && typ != "sale" {
+			exchangeFail(c, service.ErrValidation.WithDetails(apperr.Field("type", "must be one of: catalog, sale")))
+			return
+		}
+
+		switch {
+		case mode == "init":
+			if err := s.StartSession(ctx); err != nil {
+				exchangeFail(c, err)
+				return
+			}
+			c.String(http.StatusOK, "zip=no\nfile_limit=%d\n", exchangeFileLimit)
+		case mode == "file":
+			name := c.Query("filename")
+			if err := s.ReceiveFile(ctx, name, exchangeBody(c)); err != nil {
+				exchangeFail(c, err)
+				return
+			}
+			if typ == "sale" {
+				if _, err := s.ImportFile(ctx, name); err != nil {
+					exchangeFail(c, err)
+					return
+				}
+			}
+			c.String(http.StatusOK, "success\n")
+		case mode == "import" && typ == "catalog":
+			if _, err := s.ImportFile(ctx, c.Query("filename")); err != nil {
+				exchangeFail(c, err)
+				return
+			}
+			c.String(http.StatusOK, "success\n")
+		case mode == "query" && typ == "sale":
+			if err := s.QueryOrders(ctx, &xmlFile{c: c}); err != nil {
+				exchangeFail(c, err)
+			}
+		case mode == "success" && typ == "sale":
+			if err := s.ConfirmOrders(ctx); err != nil {
+				exchangeFail(c, err)
+				return
+			}
+			c.String(http.StatusOK, "success\n")
+		default:
+			exchangeFail(c, service.ErrValidation.WithDetails(apperr.Field("mode", fmt.Sprintf("mode %q is not supported for type %s", mode, typ))))
+		}
+	}
+}
+
+// exchangeFail отвечает 1С отказом с причиной. Ответ начатой выгрузки уже
+// не изменить: ошибка только попадает в журнал.
+func exchangeFail(c *gin.Context, err error) {
+	_ = c.Error(err)
+	if c.Writer.Written() {
+		return
+	}
+	e := apperr.From(err)
+	lines := []string{"failure", e.Message}
+	for _, d := range e.Details {
+		lines = append(lines, d.Field+": "+d.Message)
+	}
+	c.String(http.StatusOK, strings.Join(lines, "\n")+"\n")
+}
+
+// exchangeBody ограничивает тело запроса размером документа обмена
+func exchangeBody(c *gin.Context) io.Reader {
+	return limitedBody{http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxExchangeFileSize)}
+}
+
+// limitedBody заменяет ошибку http.MaxBytesReader ошибкой приложения
+type limitedBody struct {
+	io.Reader
+}
+
+func (b limitedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	var tooBig *http.MaxBytesError
+	if errors.As(err, &tooBig) {
+		err = service.ErrExchangeFileTooLarge
+	}
+	return n, err
+}
+
+// xmlFile начинает ответ с документом XML при первой записи: пока
+// сервис готовит выгрузку, в ответе еще можно вернуть ошибку. Без name
+// документ отдается без Content-Disposition.
+type xmlFile struct {
+	c    *gin.Context
+	name string
+}
+
+func (f *xmlFile) Write(p []byte) (int, error) {
+	if !f.c.Writer.Written() {
+		if f.name != "" {
+			f.c.Header("Content-Disposition", `attachment; filename="`+f.name+`"`)
+		}
+		f.c.Header("Content-Type", "application/xml; charset=utf-8")
+		f.c.Status(http.StatusOK)
+	}
+	return f.c.Writer.Write(p)
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/xlsx"
+	"github.com/gin-gonic/gin"
+)
+
+// Языки заголовков выгрузок
+const (
+	LangRU = "ru"
+	LangEN = "en"
+)
+
+// exportColumn — заголовок колонки выгрузки на поддерживаемых языках
+type exportColumn struct {
+	ru, en string
+}
+
+func (c exportColumn) title(lang string) string {
+	if lang == LangEN {
+		return c.en
+	}
+	return c.ru
+}
+
+var (
+	clientExportColumns = []exportColumn{
+		{"ID", "ID"}, {"Наименование", "Name"}, {"ИНН", "INN"},
+	}
+	productExportColumns = []exportColumn{
+		{"ID", "ID"}, {"Наименование", "Name"}, {"Единица измерения", "Unit"},
+	}
+	// orderExportColumns — строка выгрузки на каждую позицию заказа с
+	// повторением шапки; заказ без позиций дает одну строку
+	orderExportColumns = []exportColumn{
+		{"ID заказа", "Order ID"}, {"Номер", "Number"}, {"Дата", "Date"}, {"Статус", "Status"},
+		{"Клиент", "Client"}, {"ИНН клиента", "Client INN"}, {"Сумма заказа", "Order total"},
+		{"Товар", "Product"}, {"Ед. изм.", "Unit"}, {"Количество", "Quantity"},
+		{"Цена", "Price"}, {"Сумма строки", "Line amount"},
+	}
+	ordersByClientExportColumns = []exportColumn{
+		{"ID клиента", "Client ID"}, {"Клиент", "Client"}, {"ИНН", "INN"}, {"Сумма заказов", "Orders total"},
+	}
+
+	orderStatusTitles = map[models.OrderStatus]exportColumn{
+		models.OrderStatusDraft:        {"Черновик", "Draft"},
+		models.OrderStatusConfirmed:    {"Подтвержден", "Confirmed"},
+		models.OrderStatusInFulfilment: {"В исполнении", "In fulfilment"},
+		models.OrderStatusShipped:      {"Отгружен", "Shipped"},
+		models.OrderStatusClosed:       {"Закрыт", "Closed"},
+		models.OrderStatusCancelled:    {"Отменен", "Cancelled"},
+	}
+)
+
+// ExportClients выгружает клиентов с отбором и сортировкой списка клиентов
+func ExportClients(s service.ExportService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		params, err := parseListParams(c)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+		q := repository.ClientQuery{ListParams: params, Search: c.Query("search")}
+		export(c, "clients", clientExportColumns, func(t *exportTable) error {
+			return s.Clients(c.Request.Context(), q, func(client models.Client) error {
+				return t.write(xlsx.Int(client.ID), xlsx.String(client.Name), xlsx.String(client.INN))
+			})
+		})
+	}
+}
+
+// ExportProducts выгружает товары с отбором и сортировкой списка товаров
+func ExportProducts(s service.ExportService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		params, err := parseListParams(c)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+		q := repository.ProductQuery{ListParams: params, Search: c.Query("search")}
+		export(c, "products", productExportColumns, func(t *exportTable) error {
+			return s.Products(c.Request.Context(), q, func(p models.Product) error {
+				return t.write(xlsx.Int(p.ID), xlsx.String(p.Name), xlsx.String(p.Unit))
+			})
+		})
+	}
+}
+
+// ExportOrders выгружает заказы с позициями с отбором и сортировкой списка заказов
+func ExportOrders(s service.ExportService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q, err := parseOrderQuery(c)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+		export(c, "orders", orderExportColumns, func(t *exportTable) error {
+			return s.Orders(c.Request.Context(), q, func(o models.Order) error {
+				head := []xlsx.Cell{
+					xlsx.Int(o.ID), xlsx.String(o.Number), xlsx.Date(o.Date),
+					xlsx.String(orderStatusTitles[o.Status].title(t.lang)),
+					xlsx.String(o.Client.Name), xlsx.String(o.Client.INN),
+					xlsx.Number(o.TotalAmount.String(), 2),
+				}
+				if len(o.Items) == 0 {
+					return t.write(head...)
+				}
+				for _, item := range o.Items {
+					err := t.write(append(head[:len(head):len(head)],
+						xlsx.String(item.Product.Name), xlsx.String(item.Product.Unit),
+						xlsx.Number(item.Quantity.String(), 3), xlsx.Number(item.Price.String(), 2),
+						xlsx.Number(item.LineAmount.String(), 2),
+					)...)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		})
+	}
+}
+
+// ExportOrdersByClient выгружает текущие суммы заказов по клиентам
+func ExportOrdersByClient(s service.ExportService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		export(c, "orders-by-client", ordersByClientExportColumns, func(t *exportTable) error {
+			return s.OrdersByClient(c.Request.Context(), func(b models.OrdersByClient) error {
+				return t.write(xlsx.Int(b.ClientID), xlsx.String(b.Client.Name), xlsx.String(b.Client.INN),
+					xlsx.Number(b.OrdersSum.String(), 2))
+			})
+		})
+	}
+}
+
+// export отдает файл выгрузки в формате из параметра format (csv или xlsx,
+// по умолчанию xlsx) с заголовками на языке из параметра lang или
+// Accept-Language. Файл начинает передаваться с первой записью, поэтому
+// ошибки проверки отбора еще возвращаются обычным ответом с ошибкой, а
+// ошибка чтения посреди выгрузки обрывает передачу.
+func export(c *gin.Context, name string, columns []exportColumn, rows func(t *exportTable) error) {
+	format := c.DefaultQuery("format", FormatXLSX)
+	if format != FormatCSV && format != FormatXLSX {
+		fail(c, service.ErrValidation.WithDetails(apperr.Field("format", "must be one of: csv, xlsx")))
+		return
+	}
+	lang, err := exportLang(c)
+	if err != nil {
+		fail(c, err)
+		return
+	}
+
+	t := &exportTable{c: c, name: name, format: format, lang: lang, columns: columns}
+	if err := rows(t); err != nil {
+		fail(c, err)
+		return
+	}
+	// Пустая выгрузка — файл с одними заголовками
+	if err := t.close(); err != nil {
+		fail(c, err)
+	}
+}
+
+// exportLang выбирает язык заголовков: параметр lang, затем первый
+// поддерживаемый язык из Accept-Language, по умолчанию русский
+func exportLang(c *gin.Context) (string, error) {
+	switch lang := c.Query("lang"); lang {
+	case LangRU, LangEN:
+		return lang, nil
+	case "":
+	default:
+		return "", service.ErrValidation.WithDetails(apperr.Field("lang", "must be one of: ru, en"))
+	}
+	for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if base == LangRU || base == LangEN {
+			return base, nil
+		}
+	}
+	return LangRU, nil
+}
+
+// tableWriter записывает строки выгрузки в файл одного из форматов
+type tableWriter interface {
+	WriteHeader(titles []string) error
+	WriteRow(cells []xlsx.Cell) error
+	Close() error
+}
+
+// exportWriteTimeout — сколько выгрузка может передаваться без новых строк.
+// Общий WriteTimeout сервера ограничивает весь ответ и оборвал бы большую
+// выгрузку, поэтому срок записи продлевается по мере передачи строк.
+const exportWriteTimeout = 30 * time.Second
+
+// exportTable открывает файл выгрузки при записи первой строки: до этого
+// ответ не начат и в нем еще можно вернуть ошибку
+type exportTable struct {
+	c       *gin.Context
+	name    string
+	format  string
+	lang    string
+	columns []exportColumn
+	w       tableWriter
+	// extended — когда срок записи продлевался в последний раз
+	extended time.Time
+}
+
+// extendDeadline переносит срок записи ответа на exportWriteTimeout вперед,
+// но не чаще раза в секунду. Ответ без поддержки сроков (например,
+// httptest.ResponseRecorder) пишется без ограничения.
+func (t *exportTable) extendDeadline() {
+	now := time.Now()
+	if now.Sub(t.extended) < time.Second {
+		return
+	}
+	t.extended = now
+	_ = http.NewResponseController(t.c.Writer).SetWriteDeadline(now.Add(exportWriteTimeout))
+}
+
+func (t *exportTable) open() error {
+	filename := t.name + "-" + time.Now().Format("2006-01-02") + "." + t.format
+	t.c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	t.c.Status(http.StatusOK)
+
+	var err error
+	if t.format == FormatXLSX {
+		t.c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		t.w, err = xlsx.NewWriter(t.c.Writer, t.name)
+	} else {
+		t.c.Header("Content-Type", "text/csv; charset=utf-8")
+		t.w, err = newCSVTable(t.c.Writer, t.lang)
+	}
+	if err != nil {
+		return err
+	}
+
+	titles := make([]string, len(t.columns))
+	for i, col := range t.columns {
+		titles[i] = col.title(t.lang)
+	}
+	return t.w.WriteHeader(titles)
+}
+
+func (t *exportTable) write(cells ...xlsx.Cell) error {
+	t.extendDeadline()
+	if t.w == nil {
+		if err := t.open(); err != nil {
+			return err
+		}
+	}
+	return t.w.WriteRow(cells)
+}
+
+func (t *exportTable) close() error {
+	t.extendDeadline()
+	if t.w == nil {
+		if err := t.open(); err != nil {
+			return err
+		}
+	}
+	return t.w.Close()
+}
+
+// csvTable пишет CSV в UTF-8 с BOM, чтобы Excel распознал кодировку.
+// Для русских заголовков разделитель ";", а в числах десятичная запятая —
+// их ожидает Excel с русскими региональными настройками.
+type csvTable struct {
+	buf *bufio.Writer
+	w   *csv.Writer
+	// decimalComma заменяет десятичную точку в числах запятой
+	decimalComma bool
+}
+
+func newCSVTable(out io.Writer, lang string) (*csvTable, error) {
+	buf := bufio.NewWriter(out)
+	if _, err := buf.WriteString("\xef\xbb\xbf"); err != nil {
+		return nil, err
+	}
+	t := &csvTable{buf: buf, w: csv.NewWriter(buf)}
+	if lang == LangRU {
+		t.w.Comma, t.decimalComma = ';', true
+	}
+	return t, nil
+}
+
+func (t *csvTable) WriteHeader(titles []string) error {
+	return t.w.Write(titles)
+}
+
+func (t *csvTable) WriteRow(cells []xlsx.Cell) error {
+	record := make([]string, len(cells))
+	for i, c := range cells {
+		record[i] = c.Text()
+		if t.decimalComma && c.IsNumber() {
+			record[i] = strings.Replace(record[i], ".", ",", 1)
+		}
+	}
+	return t.w.Write(record)
+}
+
+func (t *csvTable) Close() error {
+	t.w.Flush()
+	if err := t.w.Error(); err != nil {
+		return err
+	}
+	return t.buf.Flush()
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// testExport отдает выгрузкам заданные заказы и остатки; суммы по
+// клиентам — с паузой delay перед каждой
+type testExport struct {
+	orders   []models.Order
+	balances []models.OrdersByClient
+	delay    time.Duration
+}
+
+func (e testExport) Clients(context.Context, repository.ClientQuery, func(models.Client) error) error {
+	return nil
+}
+
+func (e testExport) Products(context.Context, repository.ProductQuery, func(models.Product) error) error {
+	return nil
+}
+
+func (e testExport) Orders(_ context.Context, _ repository.OrderQuery, fn func(models.Order) error) error {
+	for _, o := range e.orders {
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e testExport) OrdersByClient(_ context.Context, fn func(models.OrdersByClient) error) error {
+	for _, b := range e.balances {
+		time.Sleep(e.delay)
+		if err := fn(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExportCSV(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	client := models.Client{ID: 7, Name: `ООО "Ромашка"; филиал`, INN: "7707083893"}
+	s := testExport{
+		orders: []models.Order{{
+			ID: 1, Number: "ЗК-001", Date: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+			Status: models.OrderStatusConfirmed, Client: client, TotalAmount: money.MustParseMoney("3127.25"),
+			Items: []models.OrderItem{
+				{Product: models.Product{Name: "Гвозди", Unit: "кг"}, Quantity: money.MustParseQuantity("2.5"),
+					Price: money.MustParseMoney("1250.5"), LineAmount: money.MustParseMoney("3126.25")},
+				{Product: models.Product{Name: "Шайба", Unit: "шт"}, Quantity: money.MustParseQuantity("1"),
+					Price: money.MustParseMoney("1"), LineAmount: money.MustParseMoney("1")},
+			},
+		}, {
+			ID: 2, Number: "ЗК-002", Date: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+			Status: models.OrderStatusDraft, Client: client,
+		}},
+		balances: []models.OrdersByClient{{ClientID: 7, Client: client, OrdersSum: money.MustParseMoney("-0.5")}},
+	}
+	r := gin.New()
+	r.GET("/orders", ExportOrders(s))
+	r.GET("/orders-by-client", ExportOrdersByClient(s))
+
+	tests := []struct {
+		url   string
+		comma rune
+		want  [][]string
+	}{
+		{"/orders?format=csv", ';', [][]string{
+			{"ID заказа", "Номер", "Дата", "Статус", "Клиент", "ИНН клиента", "Сумма заказа",
+				"Товар", "Ед. изм.", "Количество", "Цена", "Сумма строки"},
+			{"1", "ЗК-001", "2024-03-01 09:30:00", "Подтвержден", client.Name, "7707083893", "3127,25",
+				"Гвозди", "кг", "2,500", "1250,50", "3126,25"},
+			{"1", "ЗК-001", "2024-03-01 09:30:00", "Подтвержден", client.Name, "7707083893", "3127,25",
+				"Шайба", "шт", "1,000", "1,00", "1,00"},
+			{"2", "ЗК-002", "2024-03-02 00:00:00", "Черновик", client.Name, "7707083893", "0,00"},
+		}},
+		{"/orders-by-client?format=csv", ';', [][]string{
+			{"ID клиента", "Клиент", "ИНН", "Сумма заказов"},
+			{"7", client.Name, "7707083893", "-0,50"},
+		}},
+		{"/orders-by-client?format=csv&lang=en", ',', [][]string{
+			{"Client ID", "Client", "INN", "Orders total"},
+			{"7", client.Name, "7707083893", "-0.50"},
+		}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status %d: %s", tt.url, w.Code, w.Body)
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+			t.Errorf("%s: Content-Type = %s", tt.url, ct)
+		}
+
+		body, ok := strings.CutPrefix(w.Body.String(), "\xef\xbb\xbf")
+		if !ok {
+			t.Errorf("%s: no UTF-8 BOM", tt.url)
+		}
+		cr := csv.NewReader(strings.NewReader(body))
+		cr.Comma = tt.comma
+		cr.FieldsPerRecord = -1
+		got, err := cr.ReadAll()
+		if err != nil {
+			t.Errorf("%s: %v", tt.url, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: rows = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestExportEmptyAndInvalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/orders-by-client", ExportOrdersByClient(testExport{}))
+
+	// Пустая выгрузка — одни заголовки на языке из Accept-Language
+	req := httptest.NewRequest(http.MethodGet, "/orders-by-client?format=csv", nil)
+	req.Header.Set("Accept-Language", "de-DE, en-US;q=0.8")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if want := "\xef\xbb\xbfClient ID,Client,INN,Orders total\n"; w.Body.String() != want {
+		t.Errorf("empty export = %q, want %q", w.Body, want)
+	}
+
+	for _, url := range []string{"/orders-by-client?format=pdf", "/orders-by-client?lang=fr"} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, url, nil)
+		ExportOrdersByClient(testExport{})(c)
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			t.Errorf("%s: want validation error before the file starts", url)
+		}
+	}
+}
+
+// TestExportWriteTimeout проверяет, что выгрузка дольше WriteTimeout сервера
+// не обрывается, пока строки передаются
+func TestExportWriteTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := testExport{delay: 50 * time.Millisecond}
+	for i := 0; i < 6; i++ {
+		s.balances = append(s.balances, models.OrdersByClient{ClientID: int64(i + 1), OrdersSum: money.MustParseMoney("1")})
+	}
+	r := gin.New()
+	r.GET("/orders-by-client", ExportOrdersByClient(s))
+
+	srv := httptest.NewUnstartedServer(r)
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/orders-by-client?format=csv&lang=en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("export cut off after %d bytes: %v", len(body), err)
+	}
+	if lines := strings.Count(string(body), "\n"); lines != len(s.balances)+1 {
+		t.Errorf("export has %d lines, want %d", lines, len(s.balances)+1)
+	}
+}
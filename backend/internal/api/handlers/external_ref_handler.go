@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ExternalRefRequest — тело запроса PUT .../:id/external-refs/:system
+type ExternalRefRequest struct {
+	ExternalID string `json:"external_id" binding:"required,max=100"`
+	Code       string `json:"code" binding:"max=50"`
+}
+
+// Методы внешних идентификаторов общие для клиентов, товаров и заказов:
+// entity — сущность из models.AuditEntity*, она же название в тексте ошибок.
+
+// GetExternalRefs возвращает идентификаторы записи во внешних системах
+func GetExternalRefs(s service.ExternalRefService, entity string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			fail(c, errInvalidID)
+			return
+		}
+
+		refs, err := s.List(c.Request.Context(), entity, id)
+		if err != nil {
+			failFor(c, err, entity)
+			return
+		}
+
+		c.JSON(http.StatusOK, refs)
+	}
+}
+
+// PutExternalRef сопоставляет запись с идентификатором в системе :system
+func PutExternalRef(s service.ExternalRefService, entity string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			fail(c, errInvalidID)
+			return
+		}
+
+		var req ExternalRefRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		ref := models.ExternalRef{Entity: entity, EntityID: id, System: c.Param("system"), ExternalID: req.ExternalID, Code: req.Code}
+		if err := s.Link(c.Request.Context(), &ref); err != nil {
+			failFor(c, err, entity)
+			return
+		}
+
+		c.JSON(http.StatusOK, ref)
+	}
+}
+
+// DeleteExternalRef удаляет идентификатор записи в системе :system
+func DeleteExternalRef(s service.ExternalRefService, entity string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			fail(c, errInvalidID)
+			return
+		}
+
+		if err := s.Unlink(c.Request.Context(), entity, id, c.Param("system")); err != nil {
+			failFor(c, err, "external ref")
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// externalRef — внешний идентификатор из пути .../by-external/:system/:externalId
+func externalRef(c *gin.Context, code string) models.ExternalRef {
+	return models.ExternalRef{System: c.Param("system"), ExternalID: c.Param("externalId"), Code: code}
+}
+
+// upsertStatus — 201, если запись создана, иначе 200
+func upsertStatus(created bool) int {
+	if created {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/xlsx"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// MaxImportFileSize — наибольший размер загружаемого файла
+const MaxImportFileSize = 10 << 20
+
+// Форматы файлов импорта и выгрузки
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+type importFunc func(ctx context.Context, rows [][]string, opts service.ImportOptions) (*service.ImportReport, error)
+
+// ImportClients загружает клиентов из файла CSV или XLSX
+func ImportClients(s service.ImportService) gin.HandlerFunc {
+	return importFile(s.ImportClients)
+}
+
+// ImportProducts загружает товары из файла CSV или XLSX
+func ImportProducts(s service.ImportService) gin.HandlerFunc {
+	return importFile(s.ImportProducts)
+}
+
+// importFile принимает файл в поле file формы multipart/form-data.
+// Параметры (в форме или в строке запроса):
+//   - columns — JSON {"поле": "заголовок или номер колонки"};
+//   - format — csv или xlsx, по умолчанию по расширению и содержимому файла;
+//   - dry_run=true — только проверка;
+//   - mode — atomic (по умолчанию, все строки или ничего) или partial.
+//
+// Ошибки в строках не меняют статус ответа: они перечислены в отчете.
+func importFile(run importFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxImportFileSize+1<<20)
+		if err := c.Request.ParseMultipartForm(MaxImportFileSize); err != nil {
+			var tooBig *http.MaxBytesError
+			switch {
+			case errors.As(err, &tooBig):
+				fail(c, errImportTooLarge)
+			case errors.Is(err, http.ErrNotMultipart):
+				fail(c, apperr.ErrBadRequest.WithMessage("request body must be multipart/form-data"))
+			default:
+				fail(c, apperr.ErrBadRequest.WithMessage("malformed request body: "+err.Error()))
+			}
+			return
+		}
+
+		opts, err := importOptions(c)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+
+		fh, err := c.FormFile("file")
+		if err != nil {
+			fail(c, service.ErrValidation.WithDetails(apperr.Field("file", "is required")))
+			return
+		}
+		if fh.Size > MaxImportFileSize {
+			fail(c, errImportTooLarge)
+			return
+		}
+		f, err := fh.Open()
+		if err != nil {
+			fail(c, err)
+			return
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+
+		format := strings.ToLower(c.Request.FormValue("format"))
+		if format == "" {
+			format = detectFormat(fh.Filename, data)
+		}
+		var rows [][]string
+		switch format {
+		case FormatCSV:
+			rows, err = readCSV(data)
+		case FormatXLSX:
+			rows, err = xlsx.ReadRows(bytes.NewReader(data), int64(len(data)))
+		default:
+			fail(c, service.ErrValidation.WithDetails(apperr.Field("format", "must be one of: csv, xlsx")))
+			return
+		}
+		if err != nil {
+			fail(c, service.ErrValidation.WithDetails(apperr.Field("file", "cannot be read as "+format+": "+err.Error())))
+			return
+		}
+
+		report, err := run(c.Request.Context(), rows, opts)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+var errImportTooLarge = apperr.New(apperr.KindTooLarge, "file_too_large",
+	"file must be at most "+strconv.Itoa(MaxImportFileSize>>20)+" MB")
+
+func importOptions(c *gin.Context) (service.ImportOptions, error) {
+	var opts service.ImportOptions
+	var details []apperr.FieldError
+
+	if raw := c.Request.FormValue("columns"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &opts.Columns); err != nil {
+			details = append(details, apperr.Field("columns", `must be a JSON object like {"name": "Наименование"}`))
+		}
+	}
+
+	if raw := c.Request.FormValue("dry_run"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			details = append(details, apperr.Field("dry_run", "must be true or false"))
+		}
+		opts.DryRun = v
+	}
+
+	switch c.Request.FormValue("mode") {
+	case "", "atomic":
+		opts.Atomic = true
+	case "partial":
+	default:
+		details = append(details, apperr.Field("mode", "must be one of: atomic, partial"))
+	}
+
+	if len(details) > 0 {
+		return opts, service.ErrValidation.WithDetails(details...)
+	}
+	return opts, nil
+}
+
+// detectFormat определяет формат по расширению файла, а без него по
+// содержимому: книга XLSX — это архив ZIP
+func detectFormat(name string, data []byte) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv", ".txt":
+		return FormatCSV
+	case ".xlsx":
+		return FormatXLSX
+	}
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return FormatXLSX
+	}
+	return FormatCSV
+}
+
+// readCSV разбирает CSV в том виде, в каком его сохраняют Excel и 1С:
+// в UTF-8 с BOM или в Windows-1251, с разделителем ";", "," или табуляцией.
+// Как и в xlsx.ReadRows, индекс записи — номер ее строки в файле минус один.
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		decoded, err := charmap.Windows1251.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, err
+		}
+		data = decoded
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = csvDelimiter(data)
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	var rows [][]string
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Reader пропускает пустые строки, номера в отчете должны совпадать с файлом
+		line, _ := r.FieldPos(0)
+		for len(rows) < line-1 {
+			rows = append(rows, nil)
+		}
+		rows = append(rows, record)
+	}
+}
+
+// csvDelimiter выбирает разделитель, который чаще встречается в первой строке
+func csvDelimiter(data []byte) rune {
+	line := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		line = data[:i]
+	}
+	best, count := ',', 0
+	for _, d := range []rune{';', ',', '\t'} {
+		if n := bytes.Count(line, []byte(string(d))); n > count {
+			best, count = d, n
+		}
+	}
+	return best
+}
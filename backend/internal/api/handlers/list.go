@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// parseListParams разбирает общие параметры списков:
+// limit, offset, sort (поле, с "-" — по убыванию) и cursor из next_cursor
+func parseListParams(c *gin.Context) (repository.ListParams, error) {
+	var p repository.ListParams
+	var err error
+
+	if v := c.Query("limit"); v != "" {
+		if p.Limit, err = strconv.Atoi(v); err != nil {
+			return p, apperr.ErrBadRequest.WithMessage("invalid limit format")
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		if p.Offset, err = strconv.Atoi(v); err != nil {
+			return p, apperr.ErrBadRequest.WithMessage("invalid offset format")
+		}
+	}
+	if v := c.Query("sort"); v != "" {
+		p.Sort, p.Desc = strings.TrimPrefix(v, "-"), strings.HasPrefix(v, "-")
+	}
+	if v := c.Query("cursor"); v != "" {
+		if p.Cursor, err = repository.DecodeCursor(v); err != nil {
+			return p, apperr.ErrBadRequest.WithMessage("invalid cursor format")
+		}
+	}
+	return p, nil
+}
+
+// parseMoneyQuery разбирает необязательный параметр-сумму
+func parseMoneyQuery(c *gin.Context, name string) (*money.Money, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	m, err := money.ParseMoney(v)
+	if err != nil {
+		return nil, apperr.ErrBadRequest.WithMessage("invalid " + name + " format")
+	}
+	return &m, nil
+}
+
+// mapSlice переводит записи в ответы API; пустой список отдается как []
+func mapSlice[T, R any](items []T, view func(*T) R) []R {
+	out := make([]R, len(items))
+	for i := range items {
+		out[i] = view(&items[i])
+	}
+	return out
+}
+
+// mapPage переводит записи страницы списка в ответы API
+func mapPage[T, R any](page repository.Page[T], view func(*T) R) repository.Page[R] {
+	return repository.Page[R]{Items: mapSlice(page.Items, view), Total: page.Total, NextCursor: page.NextCursor}
+}
@@ -3,59 +3,217 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
 	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
 	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
 	"github.com/gin-gonic/gin"
 )
 
+// CreateOrderRequest — тело запроса POST /api/orders. Если номер не указан,
+// он присваивается по правилу нумерации заказов. Дата, статус и суммы
+// заказа рассчитываются сервером.
+type CreateOrderRequest struct {
+	ClientID int64              `json:"client_id" binding:"required,gt=0"`
+	Number   string             `json:"number" binding:"max=50"`
+	Items    []OrderItemRequest `json:"items" binding:"required,dive"`
+}
+
+// UpdateOrderRequest — тело запроса PUT /api/orders/:id. Пустой номер
+// оставляет номер заказа прежним; version — ожидаемая версия при изменении
+// без заголовка If-Match. Позиции заменяются переданными целиком, поэтому
+// items обязателен, как при создании.
+type UpdateOrderRequest struct {
+	ClientID int64              `json:"client_id" binding:"required,gt=0"`
+	Number   string             `json:"number" binding:"max=50"`
+	Version  int64              `json:"version" binding:"gte=0"`
+	Items    []OrderItemRequest `json:"items" binding:"required,dive"`
+}
+
+// UpsertOrderRequest — тело запроса PUT /api/orders/by-external/:system/:externalId.
+// Пустой номер у нового заказа присваивается по правилу нумерации, у
+// существующего — оставляет номер прежним; external_code — номер документа
+// во внешней системе.
+type UpsertOrderRequest struct {
+	ClientID     int64              `json:"client_id" binding:"required,gt=0"`
+	Number       string             `json:"number" binding:"max=50"`
+	Items        []OrderItemRequest `json:"items" binding:"required,dive"`
+	ExternalCode string             `json:"external_code" binding:"max=50"`
+}
+
+// OrderItemRequest — позиция заказа в запросе. Количество и цена — десятичные
+// строки или числа. Границы выбраны так, чтобы сумма строки всегда
+// помещалась в DECIMAL(15,2); сумму заказа проверяет сервис.
+type OrderItemRequest struct {
+	ProductID int64          `json:"product_id" binding:"required,gt=0"`
+	Quantity  money.Quantity `json:"quantity" binding:"gt=0,max_decimal=999999.999"`
+	Price     money.Money    `json:"price" binding:"gt=0,max_decimal=9999999.99"`
+}
+
+func (r CreateOrderRequest) model() models.Order {
+	return models.Order{ClientID: r.ClientID, Number: r.Number, Items: orderItems(r.Items)}
+}
+
+func (r UpdateOrderRequest) model(id int64) models.Order {
+	return models.Order{ID: id, ClientID: r.ClientID, Number: r.Number, Version: r.Version, Items: orderItems(r.Items)}
+}
+
+func (r UpsertOrderRequest) model() models.Order {
+	return models.Order{ClientID: r.ClientID, Number: r.Number, Items: orderItems(r.Items)}
+}
+
+func orderItems(items []OrderItemRequest) []models.OrderItem {
+	out := make([]models.OrderItem, len(items))
+	for i, item := range items {
+		out[i] = models.OrderItem{ProductID: item.ProductID, Quantity: item.Quantity, Price: item.Price}
+	}
+	return out
+}
+
+// OrderResponse — заказ в ответах API
+type OrderResponse struct {
+	ID          int64               `json:"id"`
+	ClientID    int64               `json:"client_id"`
+	Client      ClientResponse      `json:"client"`
+	Date        time.Time           `json:"date"`
+	Number      string              `json:"number"`
+	TotalAmount money.Money         `json:"total_amount"`
+	Status      models.OrderStatus  `json:"status"`
+	CreatedAt   time.Time           `json:"created_at"`
+	Version     int64               `json:"version"`
+	Items       []OrderItemResponse `json:"items"`
+}
+
+// OrderItemResponse — позиция заказа в ответах API
+type OrderItemResponse struct {
+	ID         int64           `json:"id"`
+	OrderID    int64           `json:"order_id"`
+	ProductID  int64           `json:"product_id"`
+	Product    ProductResponse `json:"product"`
+	Quantity   money.Quantity  `json:"quantity"`
+	Price      money.Money     `json:"price"`
+	LineAmount money.Money     `json:"line_amount"`
+}
+
+func newOrderResponse(o *models.Order) OrderResponse {
+	return OrderResponse{
+		ID:          o.ID,
+		ClientID:    o.ClientID,
+		Client:      newClientResponse(&o.Client),
+		Date:        o.Date,
+		Number:      o.Number,
+		TotalAmount: o.TotalAmount,
+		Status:      o.Status,
+		CreatedAt:   o.CreatedAt,
+		Version:     o.Version,
+		Items:       mapSlice(o.Items, newOrderItemResponse),
+	}
+}
+
+func newOrderItemResponse(i *models.OrderItem) OrderItemResponse {
+	return OrderItemResponse{
+		ID:         i.ID,
+		OrderID:    i.OrderID,
+		ProductID:  i.ProductID,
+		Product:    newProductResponse(&i.Product),
+		Quantity:   i.Quantity,
+		Price:      i.Price,
+		LineAmount: i.LineAmount,
+	}
+}
+
 func GetOrders(s service.OrderService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		orders, err := s.GetAll(c.Request.Context())
+		q, err := parseOrderQuery(c)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+
+		orders, err := s.List(c.Request.Context(), q)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			fail(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, orders)
+		c.JSON(http.StatusOK, mapPage(orders, newOrderResponse))
 	}
 }
 
+// parseOrderQuery разбирает параметры списка и отбора заказов:
+// client_id, from, to, status (через запятую), min_amount, max_amount
+func parseOrderQuery(c *gin.Context) (repository.OrderQuery, error) {
+	var q repository.OrderQuery
+	var err error
+
+	if q.ListParams, err = parseListParams(c); err != nil {
+		return q, err
+	}
+	if v := c.Query("client_id"); v != "" {
+		if q.ClientID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return q, apperr.ErrBadRequest.WithMessage("invalid client_id format")
+		}
+	}
+	if q.From, err = parseTimeQuery(c, "from"); err != nil {
+		return q, apperr.ErrBadRequest.WithMessage("invalid from format")
+	}
+	if q.To, err = parseTimeQuery(c, "to"); err != nil {
+		return q, apperr.ErrBadRequest.WithMessage("invalid to format")
+	}
+	if v := c.Query("status"); v != "" {
+		for _, st := range strings.Split(v, ",") {
+			q.Statuses = append(q.Statuses, models.OrderStatus(strings.TrimSpace(st)))
+		}
+	}
+	if q.MinAmount, err = parseMoneyQuery(c, "min_amount"); err != nil {
+		return q, err
+	}
+	if q.MaxAmount, err = parseMoneyQuery(c, "max_amount"); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
 func GetOrderByID(s service.OrderService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+			fail(c, errInvalidID)
 			return
 		}
 
 		order, err := s.GetByID(c.Request.Context(), id)
-		if err == service.ErrNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
-			return
-		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			failFor(c, err, "order")
 			return
 		}
 
-		c.JSON(http.StatusOK, order)
+		if notModified(c, order.Version) {
+			return
+		}
+		setETag(c, order.Version)
+		c.JSON(http.StatusOK, newOrderResponse(order))
 	}
 }
 
 func CreateOrder(s service.OrderService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var order models.Order
-		if err := c.ShouldBindJSON(&order); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		var req CreateOrderRequest
+		if !bindJSON(c, &req) {
 			return
 		}
 
+		order := req.model()
 		if err := s.Create(c.Request.Context(), &order, order.Items); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			fail(c, err)
 			return
 		}
 
-		c.JSON(http.StatusCreated, order)
+		setETag(c, order.Version)
+		c.JSON(http.StatusCreated, newOrderResponse(&order))
 	}
 }
 
@@ -63,23 +221,29 @@ func UpdateOrder(s service.OrderService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+			fail(c, errInvalidID)
+			return
+		}
+
+		var req UpdateOrderRequest
+		if !bindJSON(c, &req) {
 			return
 		}
+		order := req.model(id)
 
-		var order models.Order
-		if err := c.ShouldBindJSON(&order); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		current := func() (*models.Order, error) { return s.GetByID(c.Request.Context(), id) }
+		if order.Version, err = ifMatch(c, order.Version, current, orderVersion); err != nil {
+			failFor(c, withCurrent(c, err, current, orderVersion, newOrderResponse), "order")
 			return
 		}
-		order.ID = id
 
 		if err := s.Update(c.Request.Context(), &order, order.Items); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			failFor(c, withCurrent(c, err, current, orderVersion, newOrderResponse), "order")
 			return
 		}
 
-		c.JSON(http.StatusOK, order)
+		setETag(c, order.Version)
+		c.JSON(http.StatusOK, newOrderResponse(&order))
 	}
 }
 
@@ -87,12 +251,12 @@ func DeleteOrder(s service.OrderService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+			fail(c, errInvalidID)
 			return
 		}
 
 		if err := s.Delete(c.Request.Context(), id); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			failFor(c, err, "order")
 			return
 		}
 
@@ -100,19 +264,152 @@ func DeleteOrder(s service.OrderService) gin.HandlerFunc {
 	}
 }
 
+// TransitionRequest — тело запроса POST /api/orders/:id/transitions
+type TransitionRequest struct {
+	Transition string `json:"transition" binding:"required"`
+}
+
+// PostOrderTransition выполняет переход статуса заказа и возвращает заказ
+func PostOrderTransition(s service.OrderService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			fail(c, errInvalidID)
+			return
+		}
+
+		var req TransitionRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		transition(c, s, id, req.Transition)
+	}
+}
+
+// GetOrderTransitions возвращает переходы, доступные текущему пользователю
+func GetOrderTransitions(s service.OrderService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			fail(c, errInvalidID)
+			return
+		}
+
+		transitions, err := s.Transitions(c.Request.Context(), id)
+		if err != nil {
+			failFor(c, err, "order")
+			return
+		}
+
+		c.JSON(http.StatusOK, transitions)
+	}
+}
+
+// ConfirmOrder и UnconfirmOrder — прежние методы API, равносильные переходам
+// confirm и unconfirm
 func ConfirmOrder(s service.OrderService) gin.HandlerFunc {
+	return namedTransition(s, models.OrderActionConfirm)
+}
+
+func UnconfirmOrder(s service.OrderService) gin.HandlerFunc {
+	return namedTransition(s, models.OrderActionUnconfirm)
+}
+
+func namedTransition(s service.OrderService, name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			fail(c, errInvalidID)
+			return
+		}
+
+		transition(c, s, id, name)
+	}
+}
+
+func transition(c *gin.Context, s service.OrderService, id int64, name string) {
+	order, err := s.Transition(c.Request.Context(), id, name)
+	if err != nil {
+		failFor(c, err, "order")
+		return
+	}
+
+	setETag(c, order.Version)
+	c.JSON(http.StatusOK, newOrderResponse(order))
+}
+
+func GetOrderHistory(s service.OrderService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			fail(c, errInvalidID)
+			return
+		}
+
+		history, err := s.GetHistory(c.Request.Context(), id)
+		if err != nil {
+			failFor(c, err, "order")
+			return
+		}
+
+		c.JSON(http.StatusOK, history)
+	}
+}
+
+func GetOrderStatusHistory(s service.OrderService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+			fail(c, errInvalidID)
+			return
+		}
+
+		history, err := s.GetStatusHistory(c.Request.Context(), id)
+		if err != nil {
+			failFor(c, err, "order")
+			return
+		}
+
+		c.JSON(http.StatusOK, history)
+	}
+}
+
+func GetOrderByExternalID(s service.OrderService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		order, err := s.GetByExternalID(c.Request.Context(), c.Param("system"), c.Param("externalId"))
+		if err != nil {
+			failFor(c, err, "order")
+			return
+		}
+
+		if notModified(c, order.Version) {
+			return
+		}
+		setETag(c, order.Version)
+		c.JSON(http.StatusOK, newOrderResponse(order))
+	}
+}
+
+// UpsertOrderByExternalID изменяет заказ с внешним идентификатором или
+// создает его; повторный запрос с теми же данными ничего не меняет, в том
+// числе у заказа после черновика
+func UpsertOrderByExternalID(s service.OrderService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UpsertOrderRequest
+		if !bindJSON(c, &req) {
 			return
 		}
 
-		if err := s.Confirm(c.Request.Context(), id); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ref := externalRef(c, req.ExternalCode)
+		order := req.model()
+		created, err := s.Upsert(c.Request.Context(), &ref, &order, order.Items)
+		if err != nil {
+			fail(c, err)
 			return
 		}
 
-		c.Status(http.StatusOK)
+		setETag(c, order.Version)
+		c.JSON(upsertStatus(created), newOrderResponse(&order))
 	}
 }
@@ -3,7 +3,10 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
 	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
 	"github.com/gin-gonic/gin"
 )
@@ -12,7 +15,7 @@ func GetOrdersByClient(s service.OrdersByClientService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		orders, err := s.GetAll(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			fail(c, err)
 			return
 		}
 		c.JSON(http.StatusOK, orders)
@@ -23,20 +26,113 @@ func GetOrdersByClientID(s service.OrdersByClientService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("clientId"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+			fail(c, errInvalidID)
 			return
 		}
 
 		orders, err := s.GetByID(c.Request.Context(), id)
-		if err == service.ErrNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "client not found"})
+		if err != nil {
+			failFor(c, err, "client")
 			return
 		}
+
+		c.JSON(http.StatusOK, orders)
+	}
+}
+
+func GetOrdersByClientMovements(s service.OrdersByClientService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var filter repository.MovementFilter
+		var err error
+
+		if v := c.Query("recorder_id"); v != "" {
+			if filter.RecorderID, err = strconv.ParseInt(v, 10, 64); err != nil {
+				fail(c, apperr.ErrBadRequest.WithMessage("invalid recorder_id format"))
+				return
+			}
+		}
+		if v := c.Query("client_id"); v != "" {
+			if filter.ClientID, err = strconv.ParseInt(v, 10, 64); err != nil {
+				fail(c, apperr.ErrBadRequest.WithMessage("invalid client_id format"))
+				return
+			}
+		}
+		if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+			fail(c, apperr.ErrBadRequest.WithMessage("invalid from format"))
+			return
+		}
+		if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+			fail(c, apperr.ErrBadRequest.WithMessage("invalid to format"))
+			return
+		}
+
+		movements, err := s.GetMovements(c.Request.Context(), filter)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			fail(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, orders)
+		c.JSON(http.StatusOK, movements)
+	}
+}
+
+func GetOrdersByClientBalance(s service.OrdersByClientService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		at, err := parseTimeQuery(c, "at")
+		if err != nil {
+			fail(c, apperr.ErrBadRequest.WithMessage("invalid at format"))
+			return
+		}
+
+		balance, err := s.GetBalance(c.Request.Context(), at)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, balance)
+	}
+}
+
+func GetOrdersByClientTurnovers(s service.OrdersByClientService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		from, err := parseTimeQuery(c, "from")
+		if err != nil {
+			fail(c, apperr.ErrBadRequest.WithMessage("invalid from format"))
+			return
+		}
+		to, err := parseTimeQuery(c, "to")
+		if err != nil {
+			fail(c, apperr.ErrBadRequest.WithMessage("invalid to format"))
+			return
+		}
+
+		turnovers, err := s.GetTurnovers(c.Request.Context(), from, to)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, turnovers)
+	}
+}
+
+// parseTimeQuery разбирает параметр запроса в формате RFC 3339 или YYYY-MM-DD.
+// Дата без времени для параметров "to" и "at" трактуется как конец дня.
+func parseTimeQuery(c *gin.Context, name string) (time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if name == "to" || name == "at" {
+		t = t.Add(24*time.Hour - time.Nanosecond)
 	}
+	return t, nil
 }
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// OrganizationRequest — тело запроса POST /api/organizations
+type OrganizationRequest struct {
+	Code string `json:"code" binding:"required,max=50"`
+	Name string `json:"name" binding:"required,max=255"`
+}
+
+func GetOrganizations(s service.OrganizationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgs, err := s.List(c.Request.Context())
+		if err != nil {
+			fail(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, orgs)
+	}
+}
+
+func GetOrganizationByID(s service.OrganizationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			fail(c, errInvalidID)
+			return
+		}
+
+		org, err := s.GetByID(c.Request.Context(), id)
+		if err != nil {
+			failFor(c, err, "organization")
+			return
+		}
+
+		c.JSON(http.StatusOK, org)
+	}
+}
+
+func CreateOrganization(s service.OrganizationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req OrganizationRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		org := models.Organization{Code: req.Code, Name: req.Name}
+		if err := s.Create(c.Request.Context(), &org); err != nil {
+			fail(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, org)
+	}
+}
@@ -5,18 +5,60 @@ import (
 	"strconv"
 
 	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
 	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
 	"github.com/gin-gonic/gin"
 )
 
+// ProductRequest — тело запросов POST и PUT /api/products; version — ожидаемая
+// версия при изменении без заголовка If-Match
+type ProductRequest struct {
+	Name    string `json:"name" binding:"required,max=255"`
+	Unit    string `json:"unit" binding:"required,max=50"`
+	Version int64  `json:"version" binding:"gte=0"`
+}
+
+func (r ProductRequest) model(id int64) models.Product {
+	return models.Product{ID: id, Name: r.Name, Unit: r.Unit, Version: r.Version}
+}
+
+// UpsertProductRequest — тело запроса PUT /api/products/by-external/:system/:externalId;
+// external_code — код товара во внешней системе
+type UpsertProductRequest struct {
+	Name         string `json:"name" binding:"required,max=255"`
+	Unit         string `json:"unit" binding:"required,max=50"`
+	ExternalCode string `json:"external_code" binding:"max=50"`
+}
+
+// ProductResponse — товар в ответах API
+type ProductResponse struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Unit    string `json:"unit"`
+	Version int64  `json:"version"`
+}
+
+func newProductResponse(p *models.Product) ProductResponse {
+	return ProductResponse{ID: p.ID, Name: p.Name, Unit: p.Unit, Version: p.Version}
+}
+
 func GetProducts(s service.ProductService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		products, err := s.GetAll(c.Request.Context())
+		params, err := parseListParams(c)
+		if err != nil {
+			fail(c, err)
+			return
+		}
+
+		products, err := s.List(c.Request.Context(), repository.ProductQuery{
+			ListParams: params,
+			Search:     c.Query("search"),
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			fail(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, products)
+		c.JSON(http.StatusOK, mapPage(products, newProductResponse))
 	}
 }
 
@@ -24,38 +66,39 @@ func GetProductByID(s service.ProductService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+			fail(c, errInvalidID)
 			return
 		}
 
 		product, err := s.GetByID(c.Request.Context(), id)
-		if err == service.ErrNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
-			return
-		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			failFor(c, err, "product")
 			return
 		}
 
-		c.JSON(http.StatusOK, product)
+		if notModified(c, product.Version) {
+			return
+		}
+		setETag(c, product.Version)
+		c.JSON(http.StatusOK, newProductResponse(product))
 	}
 }
 
 func CreateProduct(s service.ProductService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var product models.Product
-		if err := c.ShouldBindJSON(&product); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		var req ProductRequest
+		if !bindJSON(c, &req) {
 			return
 		}
 
+		product := req.model(0)
 		if err := s.Create(c.Request.Context(), &product); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			fail(c, err)
 			return
 		}
 
-		c.JSON(http.StatusCreated, product)
+		setETag(c, product.Version)
+		c.JSON(http.StatusCreated, newProductResponse(&product))
 	}
 }
 
@@ -63,26 +106,29 @@ func UpdateProduct(s service.ProductService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+			fail(c, errInvalidID)
 			return
 		}
 
-		var product models.Product
-		if err := c.ShouldBindJSON(&product); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		var req ProductRequest
+		if !bindJSON(c, &req) {
 			return
 		}
-		product.ID = id
+		product := req.model(id)
 
-		if err := s.Update(c.Request.Context(), &product); err == service.ErrNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		current := func() (*models.Product, error) { return s.GetByID(c.Request.Context(), id) }
+		if product.Version, err = ifMatch(c, product.Version, current, productVersion); err != nil {
+			failFor(c, withCurrent(c, err, current, productVersion, newProductResponse), "product")
 			return
-		} else if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+
+		if err := s.Update(c.Request.Context(), &product); err != nil {
+			failFor(c, withCurrent(c, err, current, productVersion, newProductResponse), "product")
 			return
 		}
 
-		c.JSON(http.StatusOK, product)
+		setETag(c, product.Version)
+		c.JSON(http.StatusOK, newProductResponse(&product))
 	}
 }
 
@@ -90,18 +136,12 @@ func DeleteProduct(s service.ProductService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+			fail(c, errInvalidID)
 			return
 		}
 
-		if err := s.Delete(c.Request.Context(), id); err == service.ErrNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
-			return
-		} else if err == service.ErrProductHasOrders {
-			c.JSON(http.StatusConflict, gin.H{"error": "product has associated orders"})
-			return
-		} else if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := s.Delete(c.Request.Context(), id); err != nil {
+			failFor(c, err, "product")
 			return
 		}
 
@@ -113,20 +153,72 @@ func GetProductOrderItems(s service.ProductService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id format"})
+			fail(c, errInvalidID)
 			return
 		}
 
 		items, err := s.GetProductOrderItems(c.Request.Context(), id)
-		if err == service.ErrNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		if err != nil {
+			failFor(c, err, "product")
+			return
+		}
+
+		c.JSON(http.StatusOK, mapSlice(items, newOrderItemResponse))
+	}
+}
+
+func GetProductHistory(s service.ProductService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			fail(c, errInvalidID)
+			return
+		}
+
+		history, err := s.GetHistory(c.Request.Context(), id)
+		if err != nil {
+			failFor(c, err, "product")
 			return
 		}
+
+		c.JSON(http.StatusOK, history)
+	}
+}
+
+func GetProductByExternalID(s service.ProductService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		product, err := s.GetByExternalID(c.Request.Context(), c.Param("system"), c.Param("externalId"))
+		if err != nil {
+			failFor(c, err, "product")
+			return
+		}
+
+		if notModified(c, product.Version) {
+			return
+		}
+		setETag(c, product.Version)
+		c.JSON(http.StatusOK, newProductResponse(product))
+	}
+}
+
+// UpsertProductByExternalID изменяет товар с внешним идентификатором или
+// создает его; повторный запрос с теми же данными ничего не меняет
+func UpsertProductByExternalID(s service.ProductService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UpsertProductRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		ref := externalRef(c, req.ExternalCode)
+		product := models.Product{Name: req.Name, Unit: req.Unit}
+		created, err := s.Upsert(c.Request.Context(), &ref, &product)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			fail(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, items)
+		setETag(c, product.Version)
+		c.JSON(upsertStatus(created), newProductResponse(&product))
 	}
 }
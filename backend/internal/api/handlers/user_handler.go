@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// UserRequest — тело запросов создания и изменения пользователя. При изменении
+// пустой пароль оставляет прежний, а не указанный Active — прежнюю активность.
+// Без organization_id работает с данными любой организации только
+// администратор; остальные пользователи привязываются к организации запроса.
+type UserRequest struct {
+	Login          string   `json:"login" binding:"required,max=100"`
+	Password       string   `json:"password"`
+	Roles          []string `json:"roles"`
+	OrganizationID *int64   `json:"organization_id" binding:"omitempty,gt=0"`
+	Active         *bool    `json:"active"`
+	Version        int64    `json:"version" binding:"gte=0"`
+}
+
+func GetUsers(s service.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		users, err := s.List(c.Request.Context())
+		if err != nil {
+			fail(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, users)
+	}
+}
+
+func GetUserByID(s service.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			fail(c, errInvalidID)
+			return
+		}
+
+		user, err := s.GetByID(c.Request.Context(), id)
+		if err != nil {
+			failFor(c, err, "user")
+			return
+		}
+
+		setETag(c, user.Version)
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+func CreateUser(s service.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UserRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		user := models.User{Login: req.Login, Roles: req.Roles, OrganizationID: req.OrganizationID, Active: true}
+		if req.Active != nil {
+			user.Active = *req.Active
+		}
+		if err := s.Create(c.Request.Context(), &user, req.Password); err != nil {
+			fail(c, err)
+			return
+		}
+
+		setETag(c, user.Version)
+		c.JSON(http.StatusCreated, user)
+	}
+}
+
+func UpdateUser(s service.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			fail(c, errInvalidID)
+			return
+		}
+
+		var req UserRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		current := func() (*models.User, error) { return s.GetByID(c.Request.Context(), id) }
+		version, err := ifMatch(c, req.Version, current, userVersion)
+		if err != nil {
+			failFor(c, withCurrent(c, err, current, userVersion, asIs[models.User]), "user")
+			return
+		}
+
+		user := models.User{
+			ID: id, Login: req.Login, Roles: req.Roles, OrganizationID: req.OrganizationID,
+			Version: version, Active: true,
+		}
+		if req.Active != nil {
+			user.Active = *req.Active
+		} else if prev, err := current(); err == nil {
+			user.Active = prev.Active
+		}
+
+		if err := s.Update(c.Request.Context(), &user, req.Password); err != nil {
+			failFor(c, withCurrent(c, err, current, userVersion, asIs[models.User]), "user")
+			return
+		}
+
+		setETag(c, user.Version)
+		c.JSON(http.StatusOK, user)
+	}
+}
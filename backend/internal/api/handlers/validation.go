@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Правила тегов binding сверх стандартных правил validator:
+//   - inn — ИНН с верными контрольными цифрами (models.ValidINN); пустое
+//     значение допустимо, обязательность задается правилом required.
+//   - max_decimal — верхняя граница money.Money или money.Quantity,
+//     заданная десятичной строкой: max_decimal=999999.999.
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	// Поля в ошибках называются так же, как в JSON
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	_ = v.RegisterValidation("inn", func(fl validator.FieldLevel) bool {
+		return fl.Field().String() == "" || models.ValidINN(fl.Field().String())
+	})
+	_ = v.RegisterValidation("max_decimal", maxDecimal)
+}
+
+// maxDecimal проверяет правило max_decimal. Граница, которую нельзя
+// разобрать в тип поля, — ошибка в теге, и значение не проходит проверку.
+func maxDecimal(fl validator.FieldLevel) bool {
+	switch v := fl.Field().Interface().(type) {
+	case money.Money:
+		limit, err := money.ParseMoney(fl.Param())
+		return err == nil && v <= limit
+	case money.Quantity:
+		limit, err := money.ParseQuantity(fl.Param())
+		return err == nil && v <= limit
+	}
+	return false
+}
+
+// fieldErrors переводит ошибки validator в детали ошибки API: путь поля
+// в JSON (items[0].quantity) и понятное сообщение
+func fieldErrors(verrs validator.ValidationErrors) []apperr.FieldError {
+	details := make([]apperr.FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		// Namespace начинается с имени типа запроса: CreateOrderRequest.items[0].quantity
+		_, field, _ := strings.Cut(fe.Namespace(), ".")
+		details = append(details, apperr.Field(field, fieldMessage(fe)))
+	}
+	return details
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	kind := fe.Kind()
+	if kind == reflect.Pointer {
+		kind = fe.Type().Elem().Kind()
+	}
+	unit := "characters"
+	if kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map {
+		unit = "items"
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "inn":
+		return "must be 10 or 12 digits with valid check digits"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "gt":
+		if fe.Param() == "0" {
+			return "must be positive"
+		}
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be at least " + fe.Param()
+	case "lte", "max_decimal":
+		return "must be at most " + fe.Param()
+	case "min", "max":
+		bound := "at least"
+		if fe.Tag() == "max" {
+			bound = "at most"
+		}
+		if kind == reflect.String || unit == "items" {
+			return fmt.Sprintf("must be %s %s %s", bound, fe.Param(), unit)
+		}
+		return fmt.Sprintf("must be %s %s", bound, fe.Param())
+	}
+	return "failed on " + fe.Tag()
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/gin-gonic/gin"
+)
+
+func TestOrderItemLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name, item string
+		field      string
+	}{
+		{"at the limits", `{"product_id": 1, "quantity": "999999.999", "price": "9999999.99"}`, ""},
+		{"quantity over the limit", `{"product_id": 1, "quantity": "1000000", "price": "1"}`, "items[0].quantity"},
+		{"price over the limit", `{"product_id": 1, "quantity": 1, "price": 10000000}`, "items[0].price"},
+		{"zero quantity", `{"product_id": 1, "quantity": "0", "price": "1"}`, "items[0].quantity"},
+		{"overflowing line", `{"product_id": 1, "quantity": "999999999999.999", "price": "9999999999999.99"}`, "items[0].quantity"},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		body := `{"client_id": 1, "items": [` + tt.item + `]}`
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		var req CreateOrderRequest
+		ok := bindJSON(c, &req)
+		if tt.field == "" {
+			if !ok {
+				t.Errorf("%s: rejected: %v", tt.name, c.Errors.Last())
+			}
+			continue
+		}
+		if ok {
+			t.Errorf("%s: accepted", tt.name)
+			continue
+		}
+
+		e := apperr.From(c.Errors.Last().Err)
+		if e.Kind.Status() != http.StatusUnprocessableEntity {
+			t.Errorf("%s: status = %d, want 422", tt.name, e.Kind.Status())
+		}
+		if len(e.Details) == 0 || e.Details[0].Field != tt.field {
+			t.Errorf("%s: details = %v, want field %s", tt.name, e.Details, tt.field)
+		}
+	}
+}
+
+// TestOrderItemsRequired проверяет, что запрос без items отклоняется, а не
+// заменяет позиции заказа пустым набором
+func TestOrderItemsRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	requests := map[string]func() interface{}{
+		"create": func() interface{} { return &CreateOrderRequest{} },
+		"update": func() interface{} { return &UpdateOrderRequest{} },
+		"upsert": func() interface{} { return &UpsertOrderRequest{} },
+	}
+	for name, req := range requests {
+		for _, body := range []string{`{"client_id": 1}`, `{"client_id": 1, "items": null}`} {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPut, "/api/orders/1", strings.NewReader(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			if bindJSON(c, req()) {
+				t.Errorf("%s %s: accepted", name, body)
+				continue
+			}
+			if e := apperr.From(c.Errors.Last().Err); len(e.Details) == 0 || e.Details[0].Field != "items" {
+				t.Errorf("%s %s: details = %v, want field items", name, body, e.Details)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+// TestListParams проверяет ответы на неверные параметры страницы списка
+func TestListParams(t *testing.T) {
+	r := newTestRouter(t)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"defaults", "", http.StatusOK},
+		{"zero limit is the default", "limit=0", http.StatusOK},
+		{"largest limit", "limit=500", http.StatusOK},
+		{"limit over the maximum", "limit=501", http.StatusUnprocessableEntity},
+		{"negative limit", "limit=-1", http.StatusUnprocessableEntity},
+		{"malformed limit", "limit=ten", http.StatusBadRequest},
+		{"garbage cursor", "cursor=garbage!", http.StatusBadRequest},
+		{"cursor is not JSON", "cursor=bm90IGpzb24", http.StatusBadRequest},
+		{"cursor with unknown sort", "cursor=" + repository.Cursor{Sort: "password", Value: "x", ID: 1}.Encode(),
+			http.StatusUnprocessableEntity},
+		{"cursor with malformed value", "cursor=" + repository.Cursor{Sort: "total_amount", Value: "lots", ID: 1}.Encode(),
+			http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		path := "/api/orders"
+		if tt.query != "" {
+			path += "?" + tt.query
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: GET %s: status %d, want %d: %s", tt.name, path, w.Code, tt.status, w.Body)
+		}
+	}
+}
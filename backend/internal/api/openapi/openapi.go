@@ -0,0 +1,346 @@
+// Package openapi формирует документ OpenAPI 3 по описаниям методов API и
+// структурам их запросов и ответов. Схемы строятся по тегам json и binding,
+// поэтому документ не расходится с тем, что на самом деле принимают и отдают
+// обработчики.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const Version = "3.0.3"
+
+// Document — документ OpenAPI
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem — операции пути по методам HTTP в нижнем регистре
+type PathItem map[string]*operation
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Schema — схема значения. Заполняются только используемые поля.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Example              interface{}        `json:"example,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+// Param — параметр запроса или заголовок метода
+type Param struct {
+	Name        string
+	Description string
+	// Schema — схема значения; по умолчанию строка
+	Schema   *Schema
+	Required bool
+}
+
+// Operation описывает метод API
+type Operation struct {
+	Method string
+	// Path — путь в синтаксисе gin, например /api/orders/:id
+	Path        string
+	Tag         string
+	Summary     string
+	Description string
+	// PathParams — параметры пути, которые не являются целыми id
+	PathParams []Param
+	Query      []Param
+	Headers    []Param
+	// Body — значение типа тела запроса; nil — метод без тела
+	Body interface{}
+	// Form — поля тела multipart/form-data для методов с загрузкой файлов
+	Form []Param
+	// BodyFiles — типы содержимого тела-файла вместо JSON из Body
+	BodyFiles []string
+	// Response — значение типа тела успешного ответа; nil — ответ без тела
+	Response interface{}
+	// Files — типы содержимого ответа-файла вместо JSON из Response
+	Files []string
+	// Status — код успешного ответа, по умолчанию 200
+	Status int
+	// Public — метод не требует аутентификации
+	Public bool
+}
+
+// Spec — исходные данные документа
+type Spec struct {
+	Info Info
+	Tags []Tag
+	// Error — значение типа тела ответа с ошибкой, общего для всех методов
+	Error interface{}
+	// Types задает схемы типов, которые сериализуются не по полям,
+	// например денежных сумм
+	Types map[reflect.Type]Schema
+	// SecuritySchemes — способы аутентификации; методы без Public требуют любой из них
+	SecuritySchemes map[string]SecurityScheme
+	// Headers — заголовки, общие для всех методов с аутентификацией
+	Headers    []Param
+	Operations []Operation
+}
+
+// Build формирует документ по описаниям методов
+func (s Spec) Build() (*Document, error) {
+	g := newGenerator(s.Types)
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    s.Info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas:         g.schemas,
+			SecuritySchemes: s.SecuritySchemes,
+		},
+		Tags: s.Tags,
+	}
+	for name := range s.SecuritySchemes {
+		doc.Security = append(doc.Security, map[string][]string{name: {}})
+	}
+	sort.Slice(doc.Security, func(i, j int) bool { return firstKey(doc.Security[i]) < firstKey(doc.Security[j]) })
+
+	var errorSchema *Schema
+	if s.Error != nil {
+		errorSchema = g.schemaOf(reflect.TypeOf(s.Error))
+	}
+
+	for _, op := range s.Operations {
+		path, pathParams := convertPath(op.Path)
+		item := doc.Paths[path]
+		if item == nil {
+			item = PathItem{}
+			doc.Paths[path] = item
+		}
+
+		o := &operation{
+			Tags:        nonEmpty(op.Tag),
+			Summary:     op.Summary,
+			Description: op.Description,
+			OperationID: operationID(op.Method, op.Path),
+			Responses:   map[string]*response{},
+		}
+		for _, name := range pathParams {
+			p := Param{Name: name, Schema: &Schema{Type: "integer", Format: "int64"}}
+			for _, pp := range op.PathParams {
+				if pp.Name == name {
+					p = pp
+				}
+			}
+			p.Required = true
+			o.Parameters = append(o.Parameters, newParameter(p, "path"))
+		}
+		for _, p := range op.Query {
+			o.Parameters = append(o.Parameters, newParameter(p, "query"))
+		}
+		headers := op.Headers
+		if !op.Public {
+			headers = append(append([]Param{}, s.Headers...), op.Headers...)
+		} else {
+			o.Security = []map[string][]string{}
+		}
+		for _, p := range headers {
+			o.Parameters = append(o.Parameters, newParameter(p, "header"))
+		}
+
+		if op.Body != nil {
+			o.RequestBody = &requestBody{
+				Required: true,
+				Content:  jsonContent(g.schemaOf(reflect.TypeOf(op.Body))),
+			}
+		}
+		if len(op.Form) > 0 {
+			o.RequestBody = &requestBody{Required: true, Content: formContent(op.Form)}
+		}
+		if len(op.BodyFiles) > 0 {
+			o.RequestBody = &requestBody{Required: true, Content: fileContent(op.BodyFiles)}
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		resp := &response{Description: http.StatusText(status)}
+		if op.Response != nil {
+			resp.Content = jsonContent(g.schemaOf(reflect.TypeOf(op.Response)))
+		}
+		if len(op.Files) > 0 {
+			resp.Content = fileContent(op.Files)
+		}
+		o.Responses[fmt.Sprint(status)] = resp
+		if errorSchema != nil {
+			o.Responses["default"] = &response{Description: "Error", Content: jsonContent(errorSchema)}
+		}
+
+		item[strings.ToLower(op.Method)] = o
+	}
+
+	if g.err != nil {
+		return nil, g.err
+	}
+	return doc, nil
+}
+
+type operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+func jsonContent(s *Schema) map[string]mediaType {
+	return map[string]mediaType{"application/json": {Schema: s}}
+}
+
+func formContent(fields []Param) map[string]mediaType {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, f := range fields {
+		field := *newParameter(f, "").Schema
+		field.Description = f.Description
+		schema.Properties[f.Name] = &field
+		if f.Required {
+			schema.Required = append(schema.Required, f.Name)
+		}
+	}
+	return map[string]mediaType{"multipart/form-data": {Schema: schema}}
+}
+
+// fileContent описывает тело-файл каждого из типов содержимого types
+func fileContent(types []string) map[string]mediaType {
+	content := map[string]mediaType{}
+	for _, typ := range types {
+		content[typ] = mediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+	}
+	return content
+}
+
+func newParameter(p Param, in string) parameter {
+	schema := p.Schema
+	if schema == nil {
+		schema = &Schema{Type: "string"}
+	}
+	return parameter{Name: p.Name, In: in, Description: p.Description, Required: p.Required, Schema: schema}
+}
+
+// convertPath переводит путь gin (/orders/:id) в путь OpenAPI (/orders/{id})
+// и возвращает имена параметров пути
+func convertPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var params []string
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			name := seg[1:]
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID строит идентификатор операции из метода и пути:
+// GET /api/orders/:id → getOrdersById
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, seg := range strings.Split(strings.TrimPrefix(path, "/api"), "/") {
+		if seg == "" {
+			continue
+		}
+		if strings.HasPrefix(seg, ":") {
+			b.WriteString("By")
+			seg = seg[1:]
+		}
+		for _, word := range strings.FieldsFunc(seg, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
+
+func firstKey(m map[string][]string) string {
+	for k := range m {
+		return k
+	}
+	return ""
+}
@@ -0,0 +1,220 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// generator строит схемы типов. Именованные структуры попадают в
+// components/schemas и подставляются ссылкой.
+type generator struct {
+	types   map[reflect.Type]Schema
+	schemas map[string]*Schema
+	names   map[string]reflect.Type
+	err     error
+}
+
+func newGenerator(types map[reflect.Type]Schema) *generator {
+	return &generator{types: types, schemas: map[string]*Schema{}, names: map[string]reflect.Type{}}
+}
+
+func (g *generator) schemaOf(t reflect.Type) *Schema {
+	if s, ok := g.types[t]; ok {
+		return &s
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schemaOf(t.Elem())
+		if s.Ref != "" {
+			return &Schema{AllOf: []*Schema{s}, Nullable: true}
+		}
+		s.Nullable = true
+		return s
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Slice, reflect.Array:
+		if t == rawMessageType {
+			return &Schema{Description: "Arbitrary JSON value"}
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := g.nameOf(t)
+		if _, ok := g.schemas[name]; !ok {
+			// Заглушка до построения схемы защищает от бесконечной рекурсии
+			g.schemas[name] = &Schema{}
+			*g.schemas[name] = *g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	}
+
+	g.fail(fmt.Errorf("openapi: unsupported type %s", t))
+	return &Schema{}
+}
+
+// nameOf возвращает имя схемы структуры. Для обобщенных типов к имени
+// добавляются имена аргументов: repository.Page[models.Order] → OrderPage.
+func (g *generator) nameOf(t reflect.Type) string {
+	name := t.Name()
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		var args string
+		for _, arg := range strings.Split(name[i+1:len(name)-1], ",") {
+			args += arg[strings.LastIndexByte(arg, '.')+1:]
+		}
+		name = args + name[:i]
+	}
+
+	if prev, ok := g.names[name]; ok && prev != t {
+		g.fail(fmt.Errorf("openapi: types %s and %s have the same schema name %s", prev, t, name))
+	}
+	g.names[name] = t
+	return name
+}
+
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.addFields(s, t)
+	return s
+}
+
+// addFields добавляет в схему поля структуры так, как их сериализует
+// encoding/json: поля встроенных структур без тега поднимаются наверх
+func (g *generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := g.schemaOf(f.Type)
+		if g.applyBinding(prop, f.Type, f.Tag.Get("binding")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// applyBinding переносит в схему правила тега binding и сообщает,
+// обязательно ли поле. Правила после dive относятся к элементам и пропускаются.
+func (g *generator) applyBinding(s *Schema, t reflect.Type, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	_, custom := g.types[t]
+
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "min", "max", "gt", "gte", "lt", "lte":
+			if !custom && s.Ref == "" {
+				applyLimit(s, t, name, arg)
+			}
+		case "oneof":
+			for _, v := range strings.Fields(arg) {
+				s.Enum = append(s.Enum, v)
+			}
+		}
+	}
+	return required
+}
+
+// applyLimit переносит ограничение validator: для строк — на длину, для
+// массивов — на число элементов, для чисел — на значение
+func applyLimit(s *Schema, t reflect.Type, rule, arg string) {
+	n, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return
+	}
+	lower := rule == "min" || rule == "gt" || rule == "gte"
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		length := int(n)
+		if rule == "gt" {
+			length++
+		} else if rule == "lt" {
+			length--
+		}
+		target := &s.MaxLength
+		switch {
+		case t.Kind() != reflect.String && lower:
+			target = &s.MinItems
+		case t.Kind() != reflect.String:
+			target = &s.MaxItems
+		case lower:
+			target = &s.MinLength
+		}
+		*target = &length
+	default:
+		if lower {
+			s.Minimum = &n
+			s.ExclusiveMinimum = rule == "gt"
+		} else {
+			s.Maximum = &n
+			s.ExclusiveMaximum = rule == "lt"
+		}
+	}
+}
+
+func (g *generator) fail(err error) {
+	if g.err == nil {
+		g.err = err
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// RequestIDHeader — идентификатор запроса. Если клиент его не передал,
+	// сервер формирует свой; в обоих случаях он возвращается в ответе.
+	RequestIDHeader = "X-Request-ID"
+	// ChangeReasonHeader — причина изменения для журнала аудита. Не ASCII-текст
+	// передается в URL-кодировке.
+	ChangeReasonHeader = "X-Change-Reason"
+)
+
+// maxRequestIDLength — наибольшая длина идентификатора запроса клиента; он
+// записывается в audit_log.request_id VARCHAR(100)
+const maxRequestIDLength = 64
+
+// RequestContext передает сервисам идентификатор запроса и причину изменения
+func RequestContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+
+		reason := c.GetHeader(ChangeReasonHeader)
+		if decoded, err := url.PathUnescape(reason); err == nil {
+			reason = decoded
+		}
+
+		ctx := service.WithRequestInfo(c.Request.Context(), service.RequestInfo{ID: id, Reason: reason})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// validRequestID принимает идентификатор клиента из не более чем
+// maxRequestIDLength символов [A-Za-z0-9._-]; иначе сервер формирует свой
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
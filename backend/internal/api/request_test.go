@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestContextID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var seen string
+	r := gin.New()
+	r.Use(RequestContext())
+	r.GET("/", func(c *gin.Context) {
+		seen = service.RequestInfoFromContext(c.Request.Context()).ID
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"valid", "req-1.A_b", true},
+		{"longest", strings.Repeat("a", maxRequestIDLength), true},
+		{"missing", "", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"over the column", strings.Repeat("x", 101), false},
+		{"space", "req 1", false},
+		{"non-ASCII", "запрос", false},
+		{"header injection", "a\"b;c", false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			req.Header.Set(RequestIDHeader, tt.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		got := w.Header().Get(RequestIDHeader)
+		if got != seen {
+			t.Errorf("%s: response ID %q, service sees %q", tt.name, got, seen)
+		}
+		if tt.keep && got != tt.header {
+			t.Errorf("%s: ID = %q, want the client's %q", tt.name, got, tt.header)
+		}
+		if !tt.keep && (got == tt.header || !validRequestID(got)) {
+			t.Errorf("%s: ID = %q, want a generated one", tt.name, got)
+		}
+	}
+}
@@ -2,36 +2,141 @@ package api
 
 import (
 	"github.com/1C-Migration-Lab/OrderFlow/internal/api/handlers"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/api/openapi"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
 	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(r *gin.Engine, services *service.Services) {
+// RegisterRoutes регистрирует методы API и строит спецификацию OpenAPI по
+// Spec. Соответствие маршрутов описанию проверяет TestRoutesMatchSpec.
+func RegisterRoutes(r *gin.Engine, services *service.Services) (*openapi.Document, error) {
+	r.Use(RequestContext(), ErrorHandler())
+
+	// Docs
+	doc := &openapi.Document{}
+	r.GET("/api/openapi.json", handlers.GetOpenAPI(doc))
+	r.GET("/api/docs", handlers.GetDocs())
+
+	// Auth
+	r.POST("/api/auth/login", handlers.Login(services.Auth))
+	r.POST("/api/auth/refresh", handlers.RefreshToken(services.Auth))
+
+	// Остальные методы требуют токен или ключ API (middleware APIKeys).
+	// Читать может любой пользователь, изменять справочники и заказы —
+	// менеджеры и руководитель продаж; ключу API нужна область доступа.
+	// Переходы статусов заказа проверяют роли и области в сервисе.
+	// Данные учета видны только в организации запроса (Tenant).
+	auth := r.Group("", Authenticate(services.Auth), Tenant(services.Organization))
+	sales := []service.Role{service.RoleManager, service.RoleHeadOfSales}
+	admin := RequireAdmin()
+
+	readClients := Require(service.ScopeReadClients)
+	writeClients := Require(service.ScopeWriteClients, sales...)
+	readProducts := Require(service.ScopeReadProducts)
+	writeProducts := Require(service.ScopeWriteProducts, sales...)
+	readOrders := Require(service.ScopeReadOrders)
+	writeOrders := Require(service.ScopeWriteOrders, sales...)
+	readRegister := Require(service.ScopeReadRegister)
+
+	auth.GET("/api/auth/me", handlers.GetCurrentUser())
+
+	// Organizations
+	auth.GET("/api/organizations", admin, handlers.GetOrganizations(services.Organization))
+	auth.GET("/api/organizations/:id", admin, handlers.GetOrganizationByID(services.Organization))
+	auth.POST("/api/organizations", admin, handlers.CreateOrganization(services.Organization))
+
+	// Users
+	auth.GET("/api/users", admin, handlers.GetUsers(services.User))
+	auth.GET("/api/users/:id", admin, handlers.GetUserByID(services.User))
+	auth.POST("/api/users", admin, handlers.CreateUser(services.User))
+	auth.PUT("/api/users/:id", admin, handlers.UpdateUser(services.User))
+
+	// API keys
+	auth.GET("/api/api-keys", admin, handlers.GetAPIKeys(services.APIKey))
+	auth.GET("/api/api-keys/:id", admin, handlers.GetAPIKeyByID(services.APIKey))
+	auth.POST("/api/api-keys", admin, handlers.CreateAPIKey(services.APIKey))
+	auth.DELETE("/api/api-keys/:id", admin, handlers.RevokeAPIKey(services.APIKey))
+
 	// Clients
-	r.GET("/api/clients", handlers.GetClients(services.Client))
-	r.GET("/api/clients/:id", handlers.GetClientByID(services.Client))
-	r.POST("/api/clients", handlers.CreateClient(services.Client))
-	r.PUT("/api/clients/:id", handlers.UpdateClient(services.Client))
-	r.DELETE("/api/clients/:id", handlers.DeleteClient(services.Client))
-	r.GET("/api/clients/:id/orders", handlers.GetClientOrders(services.Client))
+	auth.GET("/api/clients", readClients, handlers.GetClients(services.Client))
+	auth.GET("/api/clients/:id", readClients, handlers.GetClientByID(services.Client))
+	auth.POST("/api/clients", writeClients, handlers.CreateClient(services.Client))
+	auth.PUT("/api/clients/:id", writeClients, handlers.UpdateClient(services.Client))
+	auth.DELETE("/api/clients/:id", writeClients, handlers.DeleteClient(services.Client))
+	auth.GET("/api/clients/:id/orders", readClients, handlers.GetClientOrders(services.Client))
+	auth.GET("/api/clients/:id/history", readClients, handlers.GetClientHistory(services.Client))
+	auth.GET("/api/clients/:id/external-refs", readClients, handlers.GetExternalRefs(services.ExternalRef, models.AuditEntityClient))
+	auth.PUT("/api/clients/:id/external-refs/:system", writeClients, handlers.PutExternalRef(services.ExternalRef, models.AuditEntityClient))
+	auth.DELETE("/api/clients/:id/external-refs/:system", writeClients, handlers.DeleteExternalRef(services.ExternalRef, models.AuditEntityClient))
+	auth.GET("/api/clients/by-external/:system/:externalId", readClients, handlers.GetClientByExternalID(services.Client))
+	auth.PUT("/api/clients/by-external/:system/:externalId", writeClients, handlers.UpsertClientByExternalID(services.Client))
 
 	// Products
-	r.GET("/api/products", handlers.GetProducts(services.Product))
-	r.GET("/api/products/:id", handlers.GetProductByID(services.Product))
-	r.POST("/api/products", handlers.CreateProduct(services.Product))
-	r.PUT("/api/products/:id", handlers.UpdateProduct(services.Product))
-	r.DELETE("/api/products/:id", handlers.DeleteProduct(services.Product))
-	r.GET("/api/products/:id/order-items", handlers.GetProductOrderItems(services.Product))
+	auth.GET("/api/products", readProducts, handlers.GetProducts(services.Product))
+	auth.GET("/api/products/:id", readProducts, handlers.GetProductByID(services.Product))
+	auth.POST("/api/products", writeProducts, handlers.CreateProduct(services.Product))
+	auth.PUT("/api/products/:id", writeProducts, handlers.UpdateProduct(services.Product))
+	auth.DELETE("/api/products/:id", writeProducts, handlers.DeleteProduct(services.Product))
+	auth.GET("/api/products/:id/order-items", readProducts, handlers.GetProductOrderItems(services.Product))
+	auth.GET("/api/products/:id/history", readProducts, handlers.GetProductHistory(services.Product))
+	auth.GET("/api/products/:id/external-refs", readProducts, handlers.GetExternalRefs(services.ExternalRef, models.AuditEntityProduct))
+	auth.PUT("/api/products/:id/external-refs/:system", writeProducts, handlers.PutExternalRef(services.ExternalRef, models.AuditEntityProduct))
+	auth.DELETE("/api/products/:id/external-refs/:system", writeProducts, handlers.DeleteExternalRef(services.ExternalRef, models.AuditEntityProduct))
+	auth.GET("/api/products/by-external/:system/:externalId", readProducts, handlers.GetProductByExternalID(services.Product))
+	auth.PUT("/api/products/by-external/:system/:externalId", writeProducts, handlers.UpsertProductByExternalID(services.Product))
+
+	// Import
+	auth.POST("/api/import/clients", writeClients, handlers.ImportClients(services.Import))
+	auth.POST("/api/import/products", writeProducts, handlers.ImportProducts(services.Import))
+
+	// Export
+	auth.GET("/api/export/clients", readClients, handlers.ExportClients(services.Export))
+	auth.GET("/api/export/products", readProducts, handlers.ExportProducts(services.Export))
+	auth.GET("/api/export/orders", readOrders, handlers.ExportOrders(services.Export))
+	auth.GET("/api/export/orders-by-client", readRegister, handlers.ExportOrdersByClient(services.Export))
+
+	// Exchange
+	auth.POST("/api/exchange/commerceml", writeClients, writeProducts, writeOrders, handlers.ImportCommerceML(services.Exchange))
+	auth.GET("/api/exchange/commerceml/import.xml", readClients, readProducts, handlers.ExportCommerceMLCatalog(services.Exchange))
+	auth.GET("/api/exchange/commerceml/orders.xml", readOrders, handlers.ExportCommerceMLOrders(services.Exchange))
+
+	// Протокол обмена 1С с сайтом: checkauth выдает токен по логину и
+	// паролю, остальные режимы передают его в cookie сеанса обмена
+	exchange := r.Group("/api/1c_exchange", handlers.CommerceMLCheckAuth(services.Auth), ExchangeSession(),
+		Authenticate(services.Auth), Tenant(services.Organization), writeClients, writeProducts, writeOrders)
+	exchange.GET("", handlers.CommerceMLExchange(services.Exchange))
+	exchange.POST("", handlers.CommerceMLExchange(services.Exchange))
 
 	// Orders
-	r.GET("/api/orders", handlers.GetOrders(services.Order))
-	r.GET("/api/orders/:id", handlers.GetOrderByID(services.Order))
-	r.POST("/api/orders", handlers.CreateOrder(services.Order))
-	r.PUT("/api/orders/:id", handlers.UpdateOrder(services.Order))
-	r.DELETE("/api/orders/:id", handlers.DeleteOrder(services.Order))
-	r.POST("/api/orders/:id/confirm", handlers.ConfirmOrder(services.Order))
+	auth.GET("/api/orders", readOrders, handlers.GetOrders(services.Order))
+	auth.GET("/api/orders/:id", readOrders, handlers.GetOrderByID(services.Order))
+	auth.POST("/api/orders", writeOrders, handlers.CreateOrder(services.Order))
+	auth.PUT("/api/orders/:id", writeOrders, handlers.UpdateOrder(services.Order))
+	auth.DELETE("/api/orders/:id", writeOrders, handlers.DeleteOrder(services.Order))
+	auth.POST("/api/orders/:id/confirm", handlers.ConfirmOrder(services.Order))
+	auth.POST("/api/orders/:id/unconfirm", handlers.UnconfirmOrder(services.Order))
+	auth.GET("/api/orders/:id/history", readOrders, handlers.GetOrderHistory(services.Order))
+	auth.GET("/api/orders/:id/status-history", readOrders, handlers.GetOrderStatusHistory(services.Order))
+	auth.GET("/api/orders/:id/transitions", readOrders, handlers.GetOrderTransitions(services.Order))
+	auth.POST("/api/orders/:id/transitions", handlers.PostOrderTransition(services.Order))
+	auth.GET("/api/orders/:id/external-refs", readOrders, handlers.GetExternalRefs(services.ExternalRef, models.AuditEntityOrder))
+	auth.PUT("/api/orders/:id/external-refs/:system", writeOrders, handlers.PutExternalRef(services.ExternalRef, models.AuditEntityOrder))
+	auth.DELETE("/api/orders/:id/external-refs/:system", writeOrders, handlers.DeleteExternalRef(services.ExternalRef, models.AuditEntityOrder))
+	auth.GET("/api/orders/by-external/:system/:externalId", readOrders, handlers.GetOrderByExternalID(services.Order))
+	auth.PUT("/api/orders/by-external/:system/:externalId", writeOrders, handlers.UpsertOrderByExternalID(services.Order))
 
 	// OrdersByClient
-	r.GET("/api/orders-by-client", handlers.GetOrdersByClient(services.OrdersByClient))
-	r.GET("/api/orders-by-client/:clientId", handlers.GetOrdersByClientID(services.OrdersByClient))
+	auth.GET("/api/orders-by-client", readRegister, handlers.GetOrdersByClient(services.OrdersByClient))
+	auth.GET("/api/orders-by-client/movements", readRegister, handlers.GetOrdersByClientMovements(services.OrdersByClient))
+	auth.GET("/api/orders-by-client/balance", readRegister, handlers.GetOrdersByClientBalance(services.OrdersByClient))
+	auth.GET("/api/orders-by-client/turnovers", readRegister, handlers.GetOrdersByClientTurnovers(services.OrdersByClient))
+	auth.GET("/api/orders-by-client/:clientId", readRegister, handlers.GetOrdersByClientID(services.OrdersByClient))
+
+	built, err := Spec().Build()
+	if err != nil {
+		return nil, err
+	}
+	*doc = *built
+	return doc, nil
 }
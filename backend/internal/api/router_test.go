@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/numbering"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository/memory"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter регистрирует маршруты над хранилищем в памяти, как сервер
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	services := service.NewServices(memory.NewRepositories(numbering.DefaultOrderFormat),
+		service.AuthConfig{}, service.ExchangeConfig{Dir: t.TempDir()})
+
+	r := gin.New()
+	if _, err := RegisterRoutes(r, services); err != nil {
+		t.Fatalf("register routes: %v", err)
+	}
+	return r
+}
+
+// serve выполняет запрос к маршрутизатору; header — заголовки запроса попарно
+func serve(r http.Handler, method, path, body string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// TestRoutesMatchSpec проверяет контракт API: каждый маршрут описан в
+// operations() ровно один раз и каждое описание имеет маршрут
+func TestRoutesMatchSpec(t *testing.T) {
+	r := newTestRouter(t)
+
+	described := map[string]bool{}
+	for _, op := range operations() {
+		key := op.Method + " " + op.Path
+		if described[key] {
+			t.Errorf("operation %s is described twice", key)
+		}
+		described[key] = true
+	}
+
+	registered := map[string]bool{}
+	for _, route := range r.Routes() {
+		key := route.Method + " " + route.Path
+		registered[key] = true
+		if !described[key] {
+			t.Errorf("route %s has no description in operations()", key)
+		}
+	}
+	for key := range described {
+		if !registered[key] {
+			t.Errorf("operation %s has no route", key)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	r := newTestRouter(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /api/openapi.json: status %d", w.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI == "" {
+		t.Error("document has no openapi version")
+	}
+	if _, ok := doc.Paths["/api/orders/{id}/transitions"]; !ok {
+		t.Errorf("document has no path /api/orders/{id}/transitions; paths: %d", len(doc.Paths))
+	}
+}
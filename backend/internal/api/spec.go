@@ -0,0 +1,338 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/api/handlers"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/api/openapi"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+)
+
+// Параметры и заголовки, общие для нескольких методов
+var (
+	integerSchema = &openapi.Schema{Type: "integer", Format: "int64"}
+	dateSchema    = &openapi.Schema{Type: "string", Description: "RFC 3339 or YYYY-MM-DD"}
+
+	sortParam  = openapi.Param{Name: "sort", Description: "Sort field, prefixed with '-' for descending order"}
+	listParams = []openapi.Param{
+		{Name: "limit", Description: "Page size", Schema: &openapi.Schema{Type: "integer"}},
+		{Name: "offset", Description: "Number of records to skip", Schema: &openapi.Schema{Type: "integer"}},
+		sortParam,
+		{Name: "cursor", Description: "next_cursor of the previous page"},
+	}
+	searchParam       = openapi.Param{Name: "search", Description: "Substring of the name"}
+	orderFilterParams = []openapi.Param{
+		{Name: "client_id", Schema: integerSchema},
+		{Name: "from", Description: "Orders dated from", Schema: dateSchema},
+		{Name: "to", Description: "Orders dated to", Schema: dateSchema},
+		{Name: "status", Description: "Comma-separated statuses"},
+		{Name: "min_amount", Description: "Minimum total amount"},
+		{Name: "max_amount", Description: "Maximum total amount"},
+	}
+
+	ifNoneMatch = []openapi.Param{{Name: "If-None-Match", Description: "ETag of a cached copy; 304 if unchanged"}}
+	ifMatch     = []openapi.Param{{Name: "If-Match", Description: "ETag of the version being updated; 412 if it is stale"}}
+
+	exportParams = []openapi.Param{
+		{Name: "format", Description: "File format; xlsx by default",
+			Schema: &openapi.Schema{Type: "string", Enum: []interface{}{handlers.FormatCSV, handlers.FormatXLSX}}},
+		{Name: "lang", Description: "Language of column headers; by default from Accept-Language, otherwise ru",
+			Schema: &openapi.Schema{Type: "string", Enum: []interface{}{handlers.LangRU, handlers.LangEN}}},
+	}
+	commerceMLFiles = []string{"application/xml"}
+	exchangeParams  = []openapi.Param{
+		{Name: "type", Required: true, Schema: &openapi.Schema{Type: "string", Enum: []interface{}{"catalog", "sale"}},
+			Description: "catalog: catalog upload from 1C; sale: order exchange"},
+		{Name: "mode", Required: true,
+			Schema:      &openapi.Schema{Type: "string", Enum: []interface{}{"checkauth", "init", "file", "import", "query", "success"}},
+			Description: "Step of the exchange session"},
+		{Name: "filename", Description: "Name of the file for mode=file and mode=import"},
+	}
+	systemParam      = []openapi.Param{{Name: "system", Description: "External system, e.g. 1c"}}
+	externalIDParams = []openapi.Param{
+		systemParam[0],
+		{Name: "externalId", Description: "Identifier in the external system, e.g. a 1C reference GUID"},
+	}
+
+	externalRefDescription = "A record has at most one identifier in each system, e.g. the 1C reference GUID in system 1c; " +
+		"the previous identifier of the record in the system is replaced. code is the 1C code or document number, for reference only."
+	upsertDescription = "Updates the record with the external identifier or creates it and assigns the identifier (201). " +
+		"Loading the same data again changes nothing, so repeated loads are idempotent. The record version is not checked."
+	exportFiles = []string{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "text/csv"}
+
+	importForm = []openapi.Param{
+		{Name: "file", Description: "CSV (UTF-8 or Windows-1251; ';', ',' or tab separated) or XLSX, first row is the header",
+			Schema: &openapi.Schema{Type: "string", Format: "binary"}, Required: true},
+		{Name: "columns", Description: `JSON mapping of fields to column headers or 1-based numbers, e.g. {"name": "Наименование", "inn": "3"}; ` +
+			"unmapped fields are found by their Russian or English header"},
+		{Name: "format", Schema: &openapi.Schema{Type: "string", Enum: []interface{}{handlers.FormatCSV, handlers.FormatXLSX}},
+			Description: "By default detected from the file name and content"},
+		{Name: "dry_run", Description: "Validate and report without saving", Schema: &openapi.Schema{Type: "boolean"}},
+		{Name: "mode", Schema: &openapi.Schema{Type: "string", Enum: []interface{}{"atomic", "partial"}},
+			Description: "atomic (default) saves nothing if any row fails; partial saves the valid rows"},
+	}
+)
+
+// Spec описывает все методы API. TestRoutesMatchSpec сверяет описание с
+// маршрутами, поэтому новый маршрут без описания не пройдет тесты.
+func Spec() openapi.Spec {
+	return openapi.Spec{
+		Info: openapi.Info{
+			Title:   "OrderFlow API",
+			Version: "1.0",
+			Description: "Clients, products, customer orders and the orders-by-client register. " +
+				"Amounts and quantities are decimal strings.",
+		},
+		Tags: []openapi.Tag{
+			{Name: "auth", Description: "Tokens and the current user"},
+			{Name: "organizations", Description: "Organizations keeping separate records"},
+			{Name: "users"},
+			{Name: "api-keys", Description: "Keys for integrations"},
+			{Name: "clients"},
+			{Name: "products"},
+			{Name: "import", Description: "Bulk upload of clients and products"},
+			{Name: "export", Description: "Downloads of clients, products, orders and balances"},
+			{Name: "exchange", Description: "Exchange with 1C in CommerceML 2"},
+			{Name: "orders"},
+			{Name: "orders-by-client", Description: "Accumulation register of orders by client"},
+			{Name: "docs", Description: "This specification"},
+		},
+		Error: ErrorResponse{},
+		Types: map[reflect.Type]openapi.Schema{
+			reflect.TypeOf(money.Money(0)): {
+				Type: "string", Format: "decimal", Pattern: `^-?\d+(\.\d{1,2})?$`, Example: "1250.00",
+				Description: "Amount with 2 decimal places; a JSON number is also accepted",
+			},
+			reflect.TypeOf(money.Quantity(0)): {
+				Type: "string", Format: "decimal", Pattern: `^-?\d+(\.\d{1,3})?$`, Example: "2.500",
+				Description: "Quantity with 3 decimal places; a JSON number is also accepted",
+			},
+			reflect.TypeOf(models.OrderStatus("")): {Type: "string", Enum: enum(models.OrderStatuses)},
+			reflect.TypeOf(models.MovementKind("")): {
+				Type: "string", Enum: []interface{}{models.MovementReceipt, models.MovementExpense},
+			},
+		},
+		SecuritySchemes: map[string]openapi.SecurityScheme{
+			"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "Access token from /api/auth/login"},
+			"apiKey":     {Type: "apiKey", In: "header", Name: APIKeyHeader},
+		},
+		Headers: []openapi.Param{
+			{Name: OrganizationHeader, Description: "Organization id or code; by default the user's or the default organization"},
+			{Name: RequestIDHeader, Description: "Request id echoed in the response and written to the audit log"},
+			{Name: ChangeReasonHeader, Description: "Reason of the change for the audit log, URL-encoded"},
+		},
+		Operations: operations(),
+	}
+}
+
+func operations() []openapi.Operation {
+	return []openapi.Operation{
+		// Auth
+		{Method: http.MethodPost, Path: "/api/auth/login", Tag: "auth", Summary: "Issue tokens by login and password",
+			Body: handlers.LoginRequest{}, Response: service.Tokens{}, Public: true},
+		{Method: http.MethodPost, Path: "/api/auth/refresh", Tag: "auth", Summary: "Exchange a refresh token for a new pair",
+			Body: handlers.RefreshRequest{}, Response: service.Tokens{}, Public: true},
+		{Method: http.MethodGet, Path: "/api/auth/me", Tag: "auth", Summary: "Current user or API key",
+			Response: service.Actor{}},
+
+		// Organizations
+		{Method: http.MethodGet, Path: "/api/organizations", Tag: "organizations", Summary: "List organizations",
+			Response: []models.Organization{}},
+		{Method: http.MethodGet, Path: "/api/organizations/:id", Tag: "organizations", Summary: "Get an organization",
+			Response: models.Organization{}},
+		{Method: http.MethodPost, Path: "/api/organizations", Tag: "organizations", Summary: "Create an organization",
+			Body: handlers.OrganizationRequest{}, Response: models.Organization{}, Status: http.StatusCreated},
+
+		// Users
+		{Method: http.MethodGet, Path: "/api/users", Tag: "users", Summary: "List users",
+			Response: []models.User{}},
+		{Method: http.MethodGet, Path: "/api/users/:id", Tag: "users", Summary: "Get a user",
+			Response: models.User{}},
+		{Method: http.MethodPost, Path: "/api/users", Tag: "users", Summary: "Create a user",
+			Body: handlers.UserRequest{}, Response: models.User{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/api/users/:id", Tag: "users", Summary: "Update a user",
+			Headers: ifMatch, Body: handlers.UserRequest{}, Response: models.User{}},
+
+		// API keys
+		{Method: http.MethodGet, Path: "/api/api-keys", Tag: "api-keys", Summary: "List API keys",
+			Response: []models.APIKey{}},
+		{Method: http.MethodGet, Path: "/api/api-keys/:id", Tag: "api-keys", Summary: "Get an API key",
+			Response: models.APIKey{}},
+		{Method: http.MethodPost, Path: "/api/api-keys", Tag: "api-keys", Summary: "Issue an API key",
+			Description: "The key value is returned only in this response.",
+			Body:        handlers.APIKeyRequest{}, Response: handlers.CreatedAPIKey{}, Status: http.StatusCreated},
+		{Method: http.MethodDelete, Path: "/api/api-keys/:id", Tag: "api-keys", Summary: "Revoke an API key",
+			Response: models.APIKey{}},
+
+		// Clients
+		{Method: http.MethodGet, Path: "/api/clients", Tag: "clients", Summary: "List clients",
+			Query: append(listParams, searchParam), Response: repository.Page[handlers.ClientResponse]{}},
+		{Method: http.MethodGet, Path: "/api/clients/:id", Tag: "clients", Summary: "Get a client",
+			Headers: ifNoneMatch, Response: handlers.ClientResponse{}},
+		{Method: http.MethodPost, Path: "/api/clients", Tag: "clients", Summary: "Create a client",
+			Body: handlers.ClientRequest{}, Response: handlers.ClientResponse{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/api/clients/:id", Tag: "clients", Summary: "Update a client",
+			Headers: ifMatch, Body: handlers.ClientRequest{}, Response: handlers.ClientResponse{}},
+		{Method: http.MethodDelete, Path: "/api/clients/:id", Tag: "clients", Summary: "Delete a client",
+			Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/api/clients/:id/orders", Tag: "clients", Summary: "Orders of a client",
+			Response: []handlers.OrderResponse{}},
+		{Method: http.MethodGet, Path: "/api/clients/:id/history", Tag: "clients", Summary: "Audit history of a client",
+			Response: []models.AuditEntry{}},
+		{Method: http.MethodGet, Path: "/api/clients/:id/external-refs", Tag: "clients", Summary: "External identifiers of a client",
+			Response: []models.ExternalRef{}},
+		{Method: http.MethodPut, Path: "/api/clients/:id/external-refs/:system", Tag: "clients", Summary: "Assign an external identifier to a client",
+			PathParams: systemParam, Description: externalRefDescription, Body: handlers.ExternalRefRequest{}, Response: models.ExternalRef{}},
+		{Method: http.MethodDelete, Path: "/api/clients/:id/external-refs/:system", Tag: "clients", Summary: "Remove the external identifier of a client",
+			PathParams: systemParam, Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/api/clients/by-external/:system/:externalId", Tag: "clients", Summary: "Get a client by its external identifier",
+			PathParams: externalIDParams, Headers: ifNoneMatch, Response: handlers.ClientResponse{}},
+		{Method: http.MethodPut, Path: "/api/clients/by-external/:system/:externalId", Tag: "clients", Summary: "Create or update a client by its external identifier",
+			PathParams: externalIDParams, Description: upsertDescription, Body: handlers.UpsertClientRequest{}, Response: handlers.ClientResponse{}},
+
+		// Products
+		{Method: http.MethodGet, Path: "/api/products", Tag: "products", Summary: "List products",
+			Query: append(listParams, searchParam), Response: repository.Page[handlers.ProductResponse]{}},
+		{Method: http.MethodGet, Path: "/api/products/:id", Tag: "products", Summary: "Get a product",
+			Headers: ifNoneMatch, Response: handlers.ProductResponse{}},
+		{Method: http.MethodPost, Path: "/api/products", Tag: "products", Summary: "Create a product",
+			Body: handlers.ProductRequest{}, Response: handlers.ProductResponse{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/api/products/:id", Tag: "products", Summary: "Update a product",
+			Headers: ifMatch, Body: handlers.ProductRequest{}, Response: handlers.ProductResponse{}},
+		{Method: http.MethodDelete, Path: "/api/products/:id", Tag: "products", Summary: "Delete a product",
+			Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/api/products/:id/order-items", Tag: "products", Summary: "Order items with a product",
+			Response: []handlers.OrderItemResponse{}},
+		{Method: http.MethodGet, Path: "/api/products/:id/history", Tag: "products", Summary: "Audit history of a product",
+			Response: []models.AuditEntry{}},
+		{Method: http.MethodGet, Path: "/api/products/:id/external-refs", Tag: "products", Summary: "External identifiers of a product",
+			Response: []models.ExternalRef{}},
+		{Method: http.MethodPut, Path: "/api/products/:id/external-refs/:system", Tag: "products", Summary: "Assign an external identifier to a product",
+			PathParams: systemParam, Description: externalRefDescription, Body: handlers.ExternalRefRequest{}, Response: models.ExternalRef{}},
+		{Method: http.MethodDelete, Path: "/api/products/:id/external-refs/:system", Tag: "products", Summary: "Remove the external identifier of a product",
+			PathParams: systemParam, Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/api/products/by-external/:system/:externalId", Tag: "products", Summary: "Get a product by its external identifier",
+			PathParams: externalIDParams, Headers: ifNoneMatch, Response: handlers.ProductResponse{}},
+		{Method: http.MethodPut, Path: "/api/products/by-external/:system/:externalId", Tag: "products", Summary: "Create or update a product by its external identifier",
+			PathParams: externalIDParams, Description: upsertDescription, Body: handlers.UpsertProductRequest{}, Response: handlers.ProductResponse{}},
+
+		// Import
+		{Method: http.MethodPost, Path: "/api/import/clients", Tag: "import", Summary: "Import clients from CSV or XLSX",
+			Description: "Clients with the INN of an existing client update its name. Row errors are reported with status 200.",
+			Form:        importForm, Response: service.ImportReport{}},
+		{Method: http.MethodPost, Path: "/api/import/products", Tag: "import", Summary: "Import products from CSV or XLSX",
+			Description: "Products with the name and unit of an existing product are left unchanged. Row errors are reported with status 200.",
+			Form:        importForm, Response: service.ImportReport{}},
+
+		// Export
+		{Method: http.MethodGet, Path: "/api/export/clients", Tag: "export", Summary: "Export clients to CSV or XLSX",
+			Description: "Takes the filter and sort of the client list; all matching clients are exported.",
+			Query:       append(append([]openapi.Param{}, exportParams...), sortParam, searchParam), Files: exportFiles},
+		{Method: http.MethodGet, Path: "/api/export/products", Tag: "export", Summary: "Export products to CSV or XLSX",
+			Description: "Takes the filter and sort of the product list; all matching products are exported.",
+			Query:       append(append([]openapi.Param{}, exportParams...), sortParam, searchParam), Files: exportFiles},
+		{Method: http.MethodGet, Path: "/api/export/orders", Tag: "export", Summary: "Export orders with their lines to CSV or XLSX",
+			Description: "One row per order line with the order header repeated. Takes the filter and sort of the order list.",
+			Query:       append(append(append([]openapi.Param{}, exportParams...), sortParam), orderFilterParams...),
+			Files:       exportFiles},
+		{Method: http.MethodGet, Path: "/api/export/orders-by-client", Tag: "export", Summary: "Export balances by client to CSV or XLSX",
+			Query: exportParams, Files: exportFiles},
+
+		// Exchange
+		{Method: http.MethodPost, Path: "/api/exchange/commerceml", Tag: "exchange", Summary: "Import a CommerceML document",
+			Description: "Takes a catalog (import.xml) with counterparties and products or orders (orders.xml), UTF-8 or Windows-1251. " +
+				"Elements are matched by their 1C Ид, then clients by INN and products by name and unit. " +
+				"Orders that are no longer drafts are skipped and listed in the report.",
+			BodyFiles: commerceMLFiles, Response: service.ExchangeReport{}},
+		{Method: http.MethodGet, Path: "/api/exchange/commerceml/import.xml", Tag: "exchange", Summary: "Export clients and products as a CommerceML catalog",
+			Files: commerceMLFiles},
+		{Method: http.MethodGet, Path: "/api/exchange/commerceml/orders.xml", Tag: "exchange", Summary: "Export orders as CommerceML documents",
+			Description: "Takes the filter of the order list.",
+			Query:       append([]openapi.Param{sortParam}, orderFilterParams...), Files: commerceMLFiles},
+		{Method: http.MethodGet, Path: "/api/1c_exchange", Tag: "exchange", Summary: "1C site exchange protocol",
+			Description: "mode=checkauth takes Basic credentials and answers success, the cookie name and the session token; " +
+				"other modes take the token in that cookie. Answers are text: success, or failure and the reason. " +
+				"mode=query (type=sale) returns as CommerceML the orders except drafts that 1C has not received yet " +
+				"or that changed since; mode=success marks the orders of the last query of the session as received.",
+			Query: exchangeParams, Files: []string{"text/plain", "application/xml"}},
+		{Method: http.MethodPost, Path: "/api/1c_exchange", Tag: "exchange", Summary: "1C site exchange protocol: upload a file",
+			Description: "mode=file appends the body to the file filename of the session; orders (type=sale) are imported at once, " +
+				"a catalog (type=catalog) by the following mode=import.",
+			Query: exchangeParams, BodyFiles: commerceMLFiles, Files: []string{"text/plain"}},
+
+		// Orders
+		{Method: http.MethodGet, Path: "/api/orders", Tag: "orders", Summary: "List orders",
+			Query:    append(append([]openapi.Param{}, listParams...), orderFilterParams...),
+			Response: repository.Page[handlers.OrderResponse]{}},
+		{Method: http.MethodGet, Path: "/api/orders/:id", Tag: "orders", Summary: "Get an order",
+			Headers: ifNoneMatch, Response: handlers.OrderResponse{}},
+		{Method: http.MethodPost, Path: "/api/orders", Tag: "orders", Summary: "Create an order",
+			Description: "Without a number the order is numbered automatically.",
+			Body:        handlers.CreateOrderRequest{}, Response: handlers.OrderResponse{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/api/orders/:id", Tag: "orders", Summary: "Update an order",
+			Headers: ifMatch, Body: handlers.UpdateOrderRequest{}, Response: handlers.OrderResponse{}},
+		{Method: http.MethodDelete, Path: "/api/orders/:id", Tag: "orders", Summary: "Delete an order",
+			Status: http.StatusNoContent},
+		{Method: http.MethodPost, Path: "/api/orders/:id/confirm", Tag: "orders", Summary: "Confirm (post) an order",
+			Response: handlers.OrderResponse{}},
+		{Method: http.MethodPost, Path: "/api/orders/:id/unconfirm", Tag: "orders", Summary: "Return an order to draft",
+			Response: handlers.OrderResponse{}},
+		{Method: http.MethodGet, Path: "/api/orders/:id/history", Tag: "orders", Summary: "Audit history of an order",
+			Response: []models.AuditEntry{}},
+		{Method: http.MethodGet, Path: "/api/orders/:id/status-history", Tag: "orders", Summary: "Status changes of an order",
+			Response: []models.OrderHistory{}},
+		{Method: http.MethodGet, Path: "/api/orders/:id/transitions", Tag: "orders", Summary: "Transitions available to the current user",
+			Response: []service.Transition{}},
+		{Method: http.MethodPost, Path: "/api/orders/:id/transitions", Tag: "orders", Summary: "Perform a status transition",
+			Body: handlers.TransitionRequest{}, Response: handlers.OrderResponse{}},
+		{Method: http.MethodGet, Path: "/api/orders/:id/external-refs", Tag: "orders", Summary: "External identifiers of an order",
+			Response: []models.ExternalRef{}},
+		{Method: http.MethodPut, Path: "/api/orders/:id/external-refs/:system", Tag: "orders", Summary: "Assign an external identifier to an order",
+			PathParams: systemParam, Description: externalRefDescription, Body: handlers.ExternalRefRequest{}, Response: models.ExternalRef{}},
+		{Method: http.MethodDelete, Path: "/api/orders/:id/external-refs/:system", Tag: "orders", Summary: "Remove the external identifier of an order",
+			PathParams: systemParam, Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/api/orders/by-external/:system/:externalId", Tag: "orders", Summary: "Get an order by its external identifier",
+			PathParams: externalIDParams, Headers: ifNoneMatch, Response: handlers.OrderResponse{}},
+		{Method: http.MethodPut, Path: "/api/orders/by-external/:system/:externalId", Tag: "orders", Summary: "Create or update an order by its external identifier",
+			PathParams: externalIDParams, Description: upsertDescription +
+				" An order that is no longer a draft can only be loaded again unchanged.", Body: handlers.UpsertOrderRequest{}, Response: handlers.OrderResponse{}},
+
+		// OrdersByClient
+		{Method: http.MethodGet, Path: "/api/orders-by-client", Tag: "orders-by-client", Summary: "Current balances by client",
+			Response: []models.OrdersByClient{}},
+		{Method: http.MethodGet, Path: "/api/orders-by-client/movements", Tag: "orders-by-client", Summary: "Register movements",
+			Query: []openapi.Param{
+				{Name: "recorder_id", Description: "Order that made the movements", Schema: integerSchema},
+				{Name: "client_id", Schema: integerSchema},
+				{Name: "from", Schema: dateSchema},
+				{Name: "to", Schema: dateSchema},
+			},
+			Response: []models.OrdersByClientMovement{}},
+		{Method: http.MethodGet, Path: "/api/orders-by-client/balance", Tag: "orders-by-client", Summary: "Balances at a moment",
+			Query:    []openapi.Param{{Name: "at", Description: "Moment of the balance; now by default", Schema: dateSchema}},
+			Response: []models.OrdersByClient{}},
+		{Method: http.MethodGet, Path: "/api/orders-by-client/turnovers", Tag: "orders-by-client", Summary: "Turnovers for a period",
+			Query:    []openapi.Param{{Name: "from", Schema: dateSchema}, {Name: "to", Schema: dateSchema}},
+			Response: []models.OrdersByClientTurnover{}},
+		{Method: http.MethodGet, Path: "/api/orders-by-client/:clientId", Tag: "orders-by-client", Summary: "Balance of a client",
+			Response: models.OrdersByClient{}},
+
+		// Docs
+		{Method: http.MethodGet, Path: "/api/openapi.json", Tag: "docs", Summary: "This specification",
+			Response: map[string]interface{}{}, Public: true},
+		{Method: http.MethodGet, Path: "/api/docs", Tag: "docs", Summary: "Interactive documentation (HTML)",
+			Public: true},
+	}
+}
+
+func enum[T any](values []T) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"github.com/1C-Migration-Lab/OrderFlow/internal/service"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+	"github.com/gin-gonic/gin"
+)
+
+// OrganizationHeader — заголовок с id или кодом организации, в которой
+// выполняется запрос
+const OrganizationHeader = "X-Organization-ID"
+
+// Tenant определяет организацию запроса (service.OrganizationService.Resolve)
+// и передает ее вместе с именем исполнителя репозиториям. Регистрируется
+// после аутентификации, так как организация исполнителя берется из его
+// токена или ключа API.
+func Tenant(orgs service.OrganizationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		actor := service.ActorFromContext(ctx)
+		org, err := orgs.Resolve(ctx, actor, c.GetHeader(OrganizationHeader))
+		if err != nil {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+
+		ctx = tenant.WithUser(tenant.WithID(ctx, org), actor.Name)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
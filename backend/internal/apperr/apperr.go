@@ -0,0 +1,141 @@
+// Package apperr описывает ошибки приложения: вид ошибки определяет HTTP-статус,
+// код стабилен для клиентов API, а детали указывают на конкретные поля запроса.
+package apperr
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Kind — класс ошибки, по которому выбирается HTTP-статус
+type Kind int
+
+const (
+	KindInternal   Kind = iota // 500
+	KindBadRequest             // 400: запрос не удалось разобрать
+	KindNotFound               // 404
+	KindConflict               // 409: конфликт с текущим состоянием данных
+	KindValidation             // 422: запрос разобран, но данные недопустимы
+	// KindPreconditionFailed (412): запись изменена после того, как клиент ее прочитал
+	KindPreconditionFailed
+	KindForbidden       // 403: у пользователя нет права на действие
+	KindUnauthorized    // 401: пользователь не аутентифицирован
+	KindTooManyRequests // 429: превышен лимит запросов
+	KindTooLarge        // 413: тело запроса больше допустимого
+)
+
+// Status возвращает HTTP-статус для вида ошибки
+func (k Kind) Status() int {
+	switch k {
+	case KindBadRequest:
+		return http.StatusBadRequest
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindValidation:
+		return http.StatusUnprocessableEntity
+	case KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindTooManyRequests:
+		return http.StatusTooManyRequests
+	case KindTooLarge:
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
+
+// FieldError описывает ошибку в отдельном поле запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error — ошибка приложения. Ошибки с одинаковым кодом считаются равными
+// для errors.Is, поэтому копии с деталями совпадают с исходным значением.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Details []FieldError
+	// Current — текущее состояние записи, возвращаемое клиенту при конфликте версий
+	Current interface{}
+	// Err — исходная причина; в ответ клиенту не попадает
+	Err error
+}
+
+// New создает ошибку-образец, обычно объявляемую переменной пакета
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	for _, d := range e.Details {
+		msg += "; " + d.Field + ": " + d.Message
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithMessage возвращает копию ошибки с другим текстом
+func (e *Error) WithMessage(message string) *Error {
+	c := *e
+	c.Message = message
+	return &c
+}
+
+// WithDetails возвращает копию ошибки с добавленными ошибками полей
+func (e *Error) WithDetails(details ...FieldError) *Error {
+	c := *e
+	c.Details = append(append([]FieldError(nil), e.Details...), details...)
+	return &c
+}
+
+// WithCurrent возвращает копию ошибки с текущим состоянием записи
+func (e *Error) WithCurrent(current interface{}) *Error {
+	c := *e
+	c.Current = current
+	return &c
+}
+
+// Wrap возвращает копию ошибки с исходной причиной err
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+// Field создает описание ошибки поля
+func Field(field, message string) FieldError {
+	return FieldError{Field: field, Message: message}
+}
+
+// Общие ошибки, не привязанные к предметной области
+var (
+	ErrBadRequest = New(KindBadRequest, "bad_request", "malformed request")
+	ErrInternal   = New(KindInternal, "internal", "internal server error")
+)
+
+// From возвращает ошибку приложения из цепочки err. Неизвестные ошибки
+// становятся ErrInternal с исходной причиной, чтобы их текст не уходил клиенту.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return ErrInternal.Wrap(err)
+}
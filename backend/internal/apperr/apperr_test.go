@@ -0,0 +1,89 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestKindStatus(t *testing.T) {
+	tests := []struct {
+		kind Kind
+		want int
+	}{
+		{KindInternal, http.StatusInternalServerError},
+		{KindBadRequest, http.StatusBadRequest},
+		{KindNotFound, http.StatusNotFound},
+		{KindConflict, http.StatusConflict},
+		{KindValidation, http.StatusUnprocessableEntity},
+		{KindPreconditionFailed, http.StatusPreconditionFailed},
+		{KindForbidden, http.StatusForbidden},
+		{KindUnauthorized, http.StatusUnauthorized},
+		{KindTooManyRequests, http.StatusTooManyRequests},
+		{KindTooLarge, http.StatusRequestEntityTooLarge},
+		{Kind(100), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := tt.kind.Status(); got != tt.want {
+			t.Errorf("Kind(%d).Status() = %d, want %d", tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	base := New(KindValidation, "validation_failed", "validation failed")
+	cause := errors.New("connection refused")
+	detailed := base.WithDetails(Field("name", "is required")).WithDetails(Field("inn", "is invalid"))
+
+	// Копии совпадают с образцом по коду и не меняют его
+	for name, err := range map[string]error{
+		"details": detailed,
+		"message": base.WithMessage("cannot map file columns"),
+		"current": base.WithCurrent(struct{}{}),
+		"wrapped": fmt.Errorf("row 2: %w", base.Wrap(cause)),
+	} {
+		if !errors.Is(err, base) {
+			t.Errorf("%s: copy does not match the original", name)
+		}
+	}
+	if len(base.Details) != 0 || base.Message != "validation failed" || base.Current != nil || base.Err != nil {
+		t.Errorf("original changed: %+v", base)
+	}
+	if errors.Is(detailed, New(KindValidation, "other", "validation failed")) {
+		t.Error("errors with different codes match")
+	}
+
+	if want := "validation failed; name: is required; inn: is invalid"; detailed.Error() != want {
+		t.Errorf("Error() = %q, want %q", detailed.Error(), want)
+	}
+	if wrapped := base.Wrap(cause); !errors.Is(wrapped, cause) || wrapped.Error() != "validation failed: connection refused" {
+		t.Errorf("Wrap: %q does not keep the cause", wrapped)
+	}
+}
+
+func TestFrom(t *testing.T) {
+	notFound := New(KindNotFound, "not_found", "record not found")
+	cause := errors.New("pq: relation does not exist")
+
+	tests := []struct {
+		name string
+		err  error
+		code string
+		kind Kind
+	}{
+		{"application error", notFound, "not_found", KindNotFound},
+		{"wrapped application error", fmt.Errorf("get client: %w", notFound), "not_found", KindNotFound},
+		{"unknown error", cause, "internal", KindInternal},
+	}
+	for _, tt := range tests {
+		got := From(tt.err)
+		if got.Code != tt.code || got.Kind != tt.kind {
+			t.Errorf("%s: From = %s/%d, want %s/%d", tt.name, got.Code, got.Kind, tt.code, tt.kind)
+		}
+	}
+	// Текст неизвестной ошибки остается причиной, а не сообщением
+	if got := From(cause); got.Message != ErrInternal.Message || !errors.Is(got, cause) {
+		t.Errorf("From(unknown) = %+v", got)
+	}
+}
@@ -0,0 +1,174 @@
+// Package commerceml читает и записывает документы обмена в формате
+// CommerceML 2 — формате, в котором 1С обменивается данными с сайтами и
+// торговыми системами: каталог import.xml с контрагентами и номенклатурой
+// и документы orders.xml с заказами товаров.
+//
+// Пакет описывает только формат. Сопоставление элементов с клиентами,
+// товарами и заказами OrderFlow выполняет service.ExchangeService.
+package commerceml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// SchemaVersion — версия схемы CommerceML в записываемых документах
+const SchemaVersion = "2.08"
+
+// Значения элементов документа заказа
+const (
+	// OperationOrder — хозяйственная операция документа заказа (ХозОперация)
+	OperationOrder = "Заказ товара"
+	RoleSeller     = "Продавец"
+	RoleBuyer      = "Покупатель"
+)
+
+// Имена реквизитов документа (ЗначенияРеквизитов), которые записывает OrderFlow
+const (
+	PropertyStatus    = "Статус заказа"
+	PropertyCancelled = "Отменен"
+)
+
+// Форматы даты и времени в элементах Дата и Время
+const (
+	DateLayout = "2006-01-02"
+	TimeLayout = "15:04:05"
+)
+
+// Document — корневой элемент КоммерческаяИнформация. Пакет import.xml
+// содержит Classifier и Catalog, пакет orders.xml — Orders.
+type Document struct {
+	XMLName    xml.Name    `xml:"КоммерческаяИнформация"`
+	Version    string      `xml:"ВерсияСхемы,attr"`
+	Created    string      `xml:"ДатаФормирования,attr"`
+	Classifier *Classifier `xml:"Классификатор"`
+	Catalog    *Catalog    `xml:"Каталог"`
+	// Counterparties — контрагенты вне классификатора: так их передают
+	// некоторые конфигурации 1С
+	Counterparties []Counterparty `xml:"Контрагенты>Контрагент"`
+	Orders         []Order        `xml:"Документ"`
+}
+
+// Classifier — классификатор каталога со списком контрагентов
+type Classifier struct {
+	ID             string         `xml:"Ид"`
+	Name           string         `xml:"Наименование"`
+	Counterparties []Counterparty `xml:"Контрагенты>Контрагент"`
+}
+
+// Catalog — каталог товаров
+type Catalog struct {
+	// OnlyChanges: "true", если каталог содержит только изменения с прошлого обмена
+	OnlyChanges  string    `xml:"СодержитТолькоИзменения,attr,omitempty"`
+	ID           string    `xml:"Ид"`
+	ClassifierID string    `xml:"ИдКлассификатора"`
+	Name         string    `xml:"Наименование"`
+	Products     []Product `xml:"Товары>Товар"`
+}
+
+// Counterparty — контрагент. Роль указывается у контрагентов документа.
+type Counterparty struct {
+	ID       string `xml:"Ид"`
+	Name     string `xml:"Наименование"`
+	FullName string `xml:"ПолноеНаименование,omitempty"`
+	INN      string `xml:"ИНН,omitempty"`
+	Role     string `xml:"Роль,omitempty"`
+}
+
+// Product — товар каталога
+type Product struct {
+	ID      string `xml:"Ид"`
+	Article string `xml:"Артикул,omitempty"`
+	Name    string `xml:"Наименование"`
+	Unit    Unit   `xml:"БазоваяЕдиница"`
+}
+
+// Unit — единица измерения. Текст элемента — краткое наименование.
+type Unit struct {
+	Code     string `xml:"Код,attr,omitempty"`
+	FullName string `xml:"НаименованиеПолное,attr,omitempty"`
+	Name     string `xml:",chardata"`
+}
+
+// Title возвращает краткое наименование единицы, а без него — полное
+func (u Unit) Title() string {
+	if name := strings.TrimSpace(u.Name); name != "" {
+		return name
+	}
+	return strings.TrimSpace(u.FullName)
+}
+
+// Order — документ заказа товаров. Суммы, цены и количества записываются
+// десятичными числами с точкой.
+type Order struct {
+	ID             string         `xml:"Ид"`
+	Number         string         `xml:"Номер"`
+	Date           string         `xml:"Дата"`
+	Time           string         `xml:"Время,omitempty"`
+	Operation      string         `xml:"ХозОперация"`
+	Role           string         `xml:"Роль"`
+	Currency       string         `xml:"Валюта"`
+	Rate           string         `xml:"Курс"`
+	Amount         string         `xml:"Сумма"`
+	Counterparties []Counterparty `xml:"Контрагенты>Контрагент"`
+	Items          []Item         `xml:"Товары>Товар"`
+	Properties     []Property     `xml:"ЗначенияРеквизитов>ЗначениеРеквизита,omitempty"`
+}
+
+// Buyer возвращает контрагента-покупателя документа, а если роли не
+// указаны — первого контрагента
+func (o Order) Buyer() (Counterparty, bool) {
+	for _, c := range o.Counterparties {
+		if c.Role == RoleBuyer {
+			return c, true
+		}
+	}
+	for _, c := range o.Counterparties {
+		if c.Role == "" {
+			return c, true
+		}
+	}
+	return Counterparty{}, false
+}
+
+// Item — строка товаров документа
+type Item struct {
+	ID       string `xml:"Ид"`
+	Name     string `xml:"Наименование"`
+	Unit     Unit   `xml:"БазоваяЕдиница"`
+	Price    string `xml:"ЦенаЗаЕдиницу"`
+	Quantity string `xml:"Количество"`
+	Amount   string `xml:"Сумма"`
+}
+
+// Property — значение реквизита документа
+type Property struct {
+	Name  string `xml:"Наименование"`
+	Value string `xml:"Значение"`
+}
+
+// Read разбирает документ CommerceML. Кодировка берется из объявления XML:
+// 1С записывает документы в UTF-8 или windows-1251.
+func Read(r io.Reader) (*Document, error) {
+	dec := xml.NewDecoder(r)
+	dec.CharsetReader = charsetReader
+	doc := &Document{}
+	if err := dec.Decode(doc); err != nil {
+		return nil, fmt.Errorf("commerceml: %w", err)
+	}
+	return doc, nil
+}
+
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(label) {
+	case "utf-8", "utf8":
+		return input, nil
+	case "windows-1251", "cp1251":
+		return charmap.Windows1251.NewDecoder().Reader(input), nil
+	}
+	return nil, fmt.Errorf("unsupported encoding %q", label)
+}
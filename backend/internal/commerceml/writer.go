@@ -0,0 +1,149 @@
+package commerceml
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"time"
+)
+
+// Элементы, которые Writer открывает и закрывает сам
+const (
+	elemRoot           = "КоммерческаяИнформация"
+	elemClassifier     = "Классификатор"
+	elemCounterparties = "Контрагенты"
+	elemCatalog        = "Каталог"
+	elemProducts       = "Товары"
+)
+
+var (
+	errNoSection = errors.New("commerceml: element is written outside of its section")
+	errClosed    = errors.New("commerceml: writer is closed")
+)
+
+// Writer записывает документ CommerceML по частям: контрагентов, товары и
+// заказы по одному, не собирая документ в памяти. Разделы идут в порядке
+// вызовов: StartClassifier и WriteCounterparty, StartCatalog и
+// WriteProduct, затем WriteOrder. Начало следующего раздела закрывает
+// предыдущий. Документ записывается в UTF-8.
+type Writer struct {
+	enc *xml.Encoder
+	// open — открытые элементы внутри корневого, от внешнего к внутреннему
+	open   []string
+	closed bool
+}
+
+// NewWriter записывает объявление XML и начало корневого элемента. Закрыть
+// документ нужно методом Close; w он не закрывает.
+func NewWriter(w io.Writer, created time.Time) (*Writer, error) {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return nil, err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	root := start(elemRoot)
+	root.Attr = []xml.Attr{
+		{Name: xml.Name{Local: "ВерсияСхемы"}, Value: SchemaVersion},
+		{Name: xml.Name{Local: "ДатаФормирования"}, Value: created.Format(DateLayout + "T" + TimeLayout)},
+	}
+	if err := enc.EncodeToken(root); err != nil {
+		return nil, err
+	}
+	return &Writer{enc: enc}, nil
+}
+
+// StartClassifier начинает классификатор со списком контрагентов
+func (w *Writer) StartClassifier(id, name string) error {
+	return w.startSection(elemClassifier, elemCounterparties, []field{{"Ид", id}, {"Наименование", name}})
+}
+
+// WriteCounterparty записывает контрагента классификатора
+func (w *Writer) WriteCounterparty(c Counterparty) error {
+	return w.writeIn(elemCounterparties, "Контрагент", c)
+}
+
+// StartCatalog начинает полный каталог товаров классификатора classifierID
+func (w *Writer) StartCatalog(id, classifierID, name string) error {
+	return w.startSection(elemCatalog, elemProducts, []field{{"Ид", id}, {"ИдКлассификатора", classifierID}, {"Наименование", name}},
+		xml.Attr{Name: xml.Name{Local: "СодержитТолькоИзменения"}, Value: "false"})
+}
+
+// WriteProduct записывает товар каталога
+func (w *Writer) WriteProduct(p Product) error {
+	return w.writeIn(elemProducts, "Товар", p)
+}
+
+// WriteOrder записывает документ заказа
+func (w *Writer) WriteOrder(o Order) error {
+	if err := w.closeSections(); err != nil {
+		return err
+	}
+	return w.enc.EncodeElement(o, start("Документ"))
+}
+
+// Close закрывает открытые разделы и корневой элемент
+func (w *Writer) Close() error {
+	if err := w.closeSections(); err != nil {
+		return err
+	}
+	w.closed = true
+	if err := w.enc.EncodeToken(start(elemRoot).End()); err != nil {
+		return err
+	}
+	return w.enc.Flush()
+}
+
+// field — простой элемент заголовка раздела
+type field struct {
+	name, value string
+}
+
+// startSection открывает раздел section с элементами заголовка и списком list
+func (w *Writer) startSection(section, list string, head []field, attrs ...xml.Attr) error {
+	if err := w.closeSections(); err != nil {
+		return err
+	}
+	s := start(section)
+	s.Attr = attrs
+	if err := w.enc.EncodeToken(s); err != nil {
+		return err
+	}
+	for _, f := range head {
+		if err := w.enc.EncodeElement(f.value, start(f.name)); err != nil {
+			return err
+		}
+	}
+	if err := w.enc.EncodeToken(start(list)); err != nil {
+		return err
+	}
+	w.open = append(w.open, section, list)
+	return nil
+}
+
+func (w *Writer) writeIn(list, name string, v interface{}) error {
+	if w.closed {
+		return errClosed
+	}
+	if len(w.open) == 0 || w.open[len(w.open)-1] != list {
+		return errNoSection
+	}
+	return w.enc.EncodeElement(v, start(name))
+}
+
+func (w *Writer) closeSections() error {
+	if w.closed {
+		return errClosed
+	}
+	for len(w.open) > 0 {
+		name := w.open[len(w.open)-1]
+		if err := w.enc.EncodeToken(start(name).End()); err != nil {
+			return err
+		}
+		w.open = w.open[:len(w.open)-1]
+	}
+	return nil
+}
+
+func start(name string) xml.StartElement {
+	return xml.StartElement{Name: xml.Name{Local: name}}
+}
@@ -0,0 +1,508 @@
+// Package config загружает типизированную конфигурацию сервера.
+// Источники применяются по возрастанию приоритета: значения по умолчанию,
+// файл YAML/TOML, переменные окружения, флаги командной строки.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/numbering"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "xxxxx"
+
+// Duration — длительность, которая читается из файла и окружения в виде "5s", "1m30s"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+type Config struct {
+	// Storage выбирает реализацию репозиториев: postgres, sqlite или memory
+	Storage   string          `yaml:"storage" toml:"storage"`
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	CORS      CORSConfig      `yaml:"cors" toml:"cors"`
+	Log       LogConfig       `yaml:"log" toml:"log"`
+	Money     MoneyConfig     `yaml:"money" toml:"money"`
+	Numbering NumberingConfig `yaml:"numbering" toml:"numbering"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	Exchange  ExchangeConfig  `yaml:"exchange" toml:"exchange"`
+
+	// PrintConfig задается флагом -print-config: вывести конфигурацию и выйти
+	PrintConfig bool `yaml:"-" toml:"-"`
+}
+
+type DatabaseConfig struct {
+	// DSN имеет приоритет над отдельными параметрами подключения
+	DSN      string `yaml:"dsn" toml:"dsn"`
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+	Name     string `yaml:"name" toml:"name"`
+	SSLMode  string `yaml:"sslmode" toml:"sslmode"`
+
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+
+	SQLitePath string `yaml:"sqlite_path" toml:"sqlite_path"`
+	// RequireMigrations запрещает запуск сервера, если схема Postgres отстает
+	RequireMigrations bool `yaml:"require_migrations" toml:"require_migrations"`
+}
+
+type ServerConfig struct {
+	ListenAddr      string   `yaml:"listen_addr" toml:"listen_addr"`
+	ReadTimeout     Duration `yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout    Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout     Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins" toml:"allowed_origins"`
+}
+
+type LogConfig struct {
+	// Level: debug, info, warn или error
+	Level string `yaml:"level" toml:"level"`
+}
+
+type MoneyConfig struct {
+	// Rounding: half_up (как NUMERIC в Postgres) или half_even (банковское)
+	Rounding string `yaml:"rounding" toml:"rounding"`
+}
+
+type NumberingConfig struct {
+	// Orders — нумерация заказов, создаваемых без номера
+	Orders numbering.Format `yaml:"orders" toml:"orders"`
+}
+
+type AuthConfig struct {
+	// Enabled включает вход по JWT. Если выключено, все запросы выполняются
+	// без проверки от имени администратора.
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// Secret — ключ подписи токенов, не короче 32 байт
+	Secret     string   `yaml:"secret" toml:"secret"`
+	AccessTTL  Duration `yaml:"access_ttl" toml:"access_ttl"`
+	RefreshTTL Duration `yaml:"refresh_ttl" toml:"refresh_ttl"`
+	// AdminLogin и AdminPassword задают администратора, который создается
+	// при запуске, если пользователя с таким логином нет. Без пароля
+	// администратор не создается.
+	AdminLogin    string `yaml:"admin_login" toml:"admin_login"`
+	AdminPassword string `yaml:"admin_password" toml:"admin_password"`
+}
+
+type ExchangeConfig struct {
+	// Dir — каталог файлов обмена с 1С до их загрузки; по умолчанию —
+	// во временном каталоге системы. Если серверов несколько, каталог
+	// должен быть общим для них.
+	Dir string `yaml:"dir" toml:"dir"`
+}
+
+// minSecretLength — минимальная длина ключа HS256
+const minSecretLength = 32
+
+// Default возвращает конфигурацию по умолчанию
+func Default() *Config {
+	return &Config{
+		Storage: "postgres",
+		Database: DatabaseConfig{
+			Port:            5432,
+			SSLMode:         "require",
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+			SQLitePath:      "orderflow.db",
+		},
+		Server: ServerConfig{
+			ListenAddr:      ":8080",
+			ReadTimeout:     Duration(15 * time.Second),
+			WriteTimeout:    Duration(30 * time.Second),
+			IdleTimeout:     Duration(60 * time.Second),
+			ShutdownTimeout: Duration(10 * time.Second),
+		},
+		CORS:      CORSConfig{AllowedOrigins: []string{"*"}},
+		Log:       LogConfig{Level: "info"},
+		Money:     MoneyConfig{Rounding: "half_up"},
+		Numbering: NumberingConfig{Orders: numbering.DefaultOrderFormat},
+		Auth: AuthConfig{
+			AccessTTL:  Duration(15 * time.Minute),
+			RefreshTTL: Duration(30 * 24 * time.Hour),
+			AdminLogin: "admin",
+		},
+	}
+}
+
+// Load собирает конфигурацию из всех источников и проверяет ее.
+// Возвращает аргументы, оставшиеся после флагов (например, подкоманду migrate).
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	storage := fs.String("storage", "", "storage backend: postgres, sqlite or memory")
+	dsn := fs.String("db-dsn", "", "Postgres connection string")
+	listen := fs.String("listen", "", "HTTP listen address")
+	logLevel := fs.String("log-level", "", "log level: debug, info, warn or error")
+	origins := fs.String("cors-origins", "", "comma-separated list of allowed CORS origins")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print the effective configuration and exit")
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, nil, err
+	}
+
+	// Флаги применяются последними и только если заданы явно
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "storage":
+			cfg.Storage = *storage
+		case "db-dsn":
+			cfg.Database.DSN = *dsn
+		case "listen":
+			cfg.Server.ListenAddr = *listen
+		case "log-level":
+			cfg.Log.Level = *logLevel
+		case "cors-origins":
+			cfg.CORS.AllowedOrigins = splitList(*origins)
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".toml":
+		err = toml.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("config file %s: unsupported format %q, expected .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	var errs []error
+	str := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+	num := func(name string, dst *int) {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				return
+			}
+			*dst = n
+		}
+	}
+	boolean := func(name string, dst *bool) {
+		if v, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				return
+			}
+			*dst = b
+		}
+	}
+	duration := func(name string, dst *Duration) {
+		if v, ok := os.LookupEnv(name); ok {
+			if err := dst.UnmarshalText([]byte(v)); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
+
+	str("STORAGE", &c.Storage)
+	str("DATABASE_URL", &c.Database.DSN)
+	str("DB_DSN", &c.Database.DSN)
+	str("DB_HOST", &c.Database.Host)
+	num("DB_PORT", &c.Database.Port)
+	str("DB_USER", &c.Database.User)
+	str("DB_PASSWORD", &c.Database.Password)
+	str("DB_NAME", &c.Database.Name)
+	str("DB_SSLMODE", &c.Database.SSLMode)
+	num("DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns)
+	num("DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns)
+	duration("DB_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime)
+	str("SQLITE_PATH", &c.Database.SQLitePath)
+	boolean("MIGRATIONS_REQUIRED", &c.Database.RequireMigrations)
+	str("LISTEN_ADDR", &c.Server.ListenAddr)
+	duration("HTTP_READ_TIMEOUT", &c.Server.ReadTimeout)
+	duration("HTTP_WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	duration("HTTP_IDLE_TIMEOUT", &c.Server.IdleTimeout)
+	duration("SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+	if v, ok := os.LookupEnv("CORS_ORIGINS"); ok {
+		c.CORS.AllowedOrigins = splitList(v)
+	}
+	str("LOG_LEVEL", &c.Log.Level)
+	str("MONEY_ROUNDING", &c.Money.Rounding)
+	str("ORDER_NUMBER_PREFIX", &c.Numbering.Orders.Prefix)
+	num("ORDER_NUMBER_WIDTH", &c.Numbering.Orders.Width)
+	if v, ok := os.LookupEnv("ORDER_NUMBER_RESET"); ok {
+		c.Numbering.Orders.Reset = numbering.Reset(v)
+	}
+	boolean("AUTH_ENABLED", &c.Auth.Enabled)
+	str("JWT_SECRET", &c.Auth.Secret)
+	duration("JWT_ACCESS_TTL", &c.Auth.AccessTTL)
+	duration("JWT_REFRESH_TTL", &c.Auth.RefreshTTL)
+	str("AUTH_ADMIN_LOGIN", &c.Auth.AdminLogin)
+	str("AUTH_ADMIN_PASSWORD", &c.Auth.AdminPassword)
+	str("EXCHANGE_DIR", &c.Exchange.Dir)
+
+	return errors.Join(errs...)
+}
+
+// Validate проверяет согласованность конфигурации
+func (c *Config) Validate() error {
+	var errs []error
+
+	switch c.Storage {
+	case "postgres":
+		if c.Database.DSN == "" && c.Database.Host == "" {
+			errs = append(errs, errors.New("database: dsn or host is required for postgres storage"))
+		}
+		if c.Database.DSN == "" && c.Database.Host != "" && (c.Database.User == "" || c.Database.Name == "") {
+			errs = append(errs, errors.New("database: user and name are required with host"))
+		}
+	case "sqlite":
+		if c.Database.SQLitePath == "" {
+			errs = append(errs, errors.New("database: sqlite_path is required for sqlite storage"))
+		}
+	case "memory":
+	default:
+		errs = append(errs, fmt.Errorf("storage: unknown value %q, expected postgres, sqlite or memory", c.Storage))
+	}
+
+	if c.Database.Port <= 0 || c.Database.Port > 65535 {
+		errs = append(errs, fmt.Errorf("database: invalid port %d", c.Database.Port))
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("database: pool sizes must not be negative"))
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("database: max_idle_conns must not exceed max_open_conns"))
+	}
+	if c.Database.ConnMaxLifetime < 0 {
+		errs = append(errs, errors.New("database: conn_max_lifetime must not be negative"))
+	}
+
+	if c.Server.ListenAddr == "" {
+		errs = append(errs, errors.New("server: listen_addr is required"))
+	}
+	for name, d := range map[string]Duration{
+		"read_timeout":     c.Server.ReadTimeout,
+		"write_timeout":    c.Server.WriteTimeout,
+		"idle_timeout":     c.Server.IdleTimeout,
+		"shutdown_timeout": c.Server.ShutdownTimeout,
+	} {
+		if d < 0 {
+			errs = append(errs, fmt.Errorf("server: %s must not be negative", name))
+		}
+	}
+
+	if len(c.CORS.AllowedOrigins) == 0 {
+		errs = append(errs, errors.New("cors: at least one allowed origin is required"))
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log: unknown level %q, expected debug, info, warn or error", c.Log.Level))
+	}
+
+	if _, err := money.ParseRoundingMode(c.Money.Rounding); err != nil {
+		errs = append(errs, fmt.Errorf("money: %w", err))
+	}
+	if err := c.Numbering.Orders.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("numbering.orders: %w", err))
+	}
+
+	if c.Auth.Enabled {
+		if len(c.Auth.Secret) < minSecretLength {
+			errs = append(errs, fmt.Errorf("auth: secret must be at least %d bytes long", minSecretLength))
+		}
+		if c.Auth.AccessTTL <= 0 || c.Auth.RefreshTTL <= 0 {
+			errs = append(errs, errors.New("auth: access_ttl and refresh_ttl must be positive"))
+		} else if c.Auth.AccessTTL > c.Auth.RefreshTTL {
+			errs = append(errs, errors.New("auth: access_ttl must not exceed refresh_ttl"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// PostgresDSN возвращает строку подключения: DSN как есть или собранную из параметров
+func (c DatabaseConfig) PostgresDSN() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     fmt.Sprintf("%s:%d", c.Host, c.Port),
+		Path:     "/" + c.Name,
+		RawQuery: url.Values{"sslmode": {c.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+// dsnPassword находит пароль в DSN вида «host=... password=...»; значение
+// может быть в кавычках и содержать пробелы
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// dsnSecretParams — параметры URL подключения, которые содержат секреты
+var dsnSecretParams = []string{"password", "sslpassword"}
+
+// Redacted возвращает копию конфигурации со скрытыми секретами
+func (c *Config) Redacted() *Config {
+	r := *c
+	r.CORS.AllowedOrigins = append([]string(nil), c.CORS.AllowedOrigins...)
+	if r.Database.Password != "" {
+		r.Database.Password = redacted
+	}
+	if r.Auth.Secret != "" {
+		r.Auth.Secret = redacted
+	}
+	if r.Auth.AdminPassword != "" {
+		r.Auth.AdminPassword = redacted
+	}
+	if r.Database.DSN != "" {
+		r.Database.DSN = redactDSN(r.Database.DSN)
+	}
+	return &r
+}
+
+// redactDSN скрывает пароль в DSN. В URL пароль может быть как в userinfo,
+// так и в параметрах запроса (postgres://host/db?password=...).
+func redactDSN(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" {
+		return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+	}
+	if u.RawQuery != "" {
+		q, err := url.ParseQuery(u.RawQuery)
+		if err != nil {
+			// Параметры, которые не разбираются, могут содержать пароль
+			return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+		}
+		for _, name := range dsnSecretParams {
+			if q.Has(name) {
+				q.Set(name, redacted)
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// LogValue представляет конфигурацию со скрытыми секретами группой атрибутов
+// slog с ключами, как в файле конфигурации: database.host, server.listen_addr
+func (c *Config) LogValue() slog.Value {
+	data, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return slog.StringValue(err.Error())
+	}
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return slog.StringValue(err.Error())
+	}
+	return slog.GroupValue(logAttrs(tree)...)
+}
+
+// logAttrs переводит разобранный YAML в атрибуты slog в порядке ключей
+func logAttrs(tree map[string]interface{}) []slog.Attr {
+	keys := make([]string, 0, len(tree))
+	for k := range tree {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		if sub, ok := tree[k].(map[string]interface{}); ok {
+			attrs = append(attrs, slog.Attr{Key: k, Value: slog.GroupValue(logAttrs(sub)...)})
+			continue
+		}
+		attrs = append(attrs, slog.Any(k, tree[k]))
+	}
+	return attrs
+}
+
+// String выводит конфигурацию в YAML со скрытыми секретами
+func (c *Config) String() string {
+	data, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
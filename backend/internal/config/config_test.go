@@ -0,0 +1,75 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedactedDSN(t *testing.T) {
+	tests := []struct {
+		dsn, want string
+	}{
+		{"postgres://app:secret@db:5432/orderflow?sslmode=require",
+			"postgres://app:xxxxx@db:5432/orderflow?sslmode=require"},
+		{"postgres://app@db/orderflow?password=secret&sslmode=disable",
+			"postgres://app@db/orderflow?password=xxxxx&sslmode=disable"},
+		{"postgres://app:secret@db/orderflow?sslpassword=key&password=secret",
+			"postgres://app:xxxxx@db/orderflow?password=xxxxx&sslpassword=xxxxx"},
+		{"postgres:///orderflow?host=/var/run/postgresql&password=secret",
+			"postgres:///orderflow?host=%2Fvar%2Frun%2Fpostgresql&password=xxxxx"},
+		{"host=db user=app password=secret dbname=orderflow",
+			"host=db user=app password=xxxxx dbname=orderflow"},
+		{"host=db password='a secret' dbname=orderflow",
+			"host=db password=xxxxx dbname=orderflow"},
+		{"postgres://db/orderflow?sslmode=disable",
+			"postgres://db/orderflow?sslmode=disable"},
+	}
+	for _, tt := range tests {
+		c := Default()
+		c.Database.DSN = tt.dsn
+		if got := c.Redacted().Database.DSN; got != tt.want {
+			t.Errorf("Redacted(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+		if c.Database.DSN != tt.dsn {
+			t.Errorf("Redacted changed the original DSN to %q", c.Database.DSN)
+		}
+	}
+}
+
+func TestLogValue(t *testing.T) {
+	c := Default()
+	c.Database.Password = "pg-pass-42"
+	c.Auth.Secret = "jwt-key-42"
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("effective configuration", "config", c)
+
+	if strings.Contains(buf.String(), "-42") {
+		t.Errorf("secrets are logged: %s", buf.String())
+	}
+	if n := strings.Count(strings.TrimSpace(buf.String()), "\n"); n != 0 {
+		t.Errorf("configuration is logged on %d lines, want one", n+1)
+	}
+
+	var record struct {
+		Config struct {
+			Database map[string]interface{} `json:"database"`
+			Server   map[string]interface{} `json:"server"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("log record is not JSON: %v", err)
+	}
+	if got := record.Config.Database["port"]; got != float64(5432) {
+		t.Errorf("config.database.port = %v, want 5432", got)
+	}
+	if got := record.Config.Database["password"]; got != redacted {
+		t.Errorf("config.database.password = %v, want %s", got, redacted)
+	}
+	if _, ok := record.Config.Server["listen_addr"]; !ok {
+		t.Errorf("config.server.listen_addr is missing: %s", buf.String())
+	}
+}
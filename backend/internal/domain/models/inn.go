@@ -0,0 +1,38 @@
+package models
+
+// Весовые коэффициенты контрольных цифр ИНН
+var (
+	innWeights10 = []int{2, 4, 10, 3, 5, 9, 4, 6, 8}
+	innWeights11 = []int{7, 2, 4, 10, 3, 5, 9, 4, 6, 8}
+	innWeights12 = []int{3, 7, 2, 4, 10, 3, 5, 9, 4, 6, 8}
+)
+
+// ValidINN проверяет ИНН: 10 цифр у организации или 12 у физического лица
+// и предпринимателя, с верными контрольными цифрами
+func ValidINN(inn string) bool {
+	digits := make([]int, len(inn))
+	for i := 0; i < len(inn); i++ {
+		if inn[i] < '0' || inn[i] > '9' {
+			return false
+		}
+		digits[i] = int(inn[i] - '0')
+	}
+
+	switch len(digits) {
+	case 10:
+		return innCheckDigit(digits, innWeights10) == digits[9]
+	case 12:
+		return innCheckDigit(digits, innWeights11) == digits[10] &&
+			innCheckDigit(digits, innWeights12) == digits[11]
+	}
+	return false
+}
+
+// innCheckDigit вычисляет контрольную цифру по первым len(weights) цифрам
+func innCheckDigit(digits, weights []int) int {
+	sum := 0
+	for i, w := range weights {
+		sum += digits[i] * w
+	}
+	return sum % 11 % 10
+}
@@ -0,0 +1,35 @@
+package models
+
+import "testing"
+
+func TestValidINN(t *testing.T) {
+	tests := []struct {
+		inn  string
+		want bool
+	}{
+		// Организации: 10 цифр
+		{"7707083893", true},
+		{"7830002293", true},
+		{"7707083894", false},
+		{"0000000000", true},
+		// Физические лица и предприниматели: 12 цифр
+		{"500100732259", true},
+		{"500100732250", false},
+		{"500100732269", false},
+		// Длина
+		{"", false},
+		{"770708389", false},
+		{"77070838931", false},
+		{"5001007322590", false},
+		// Не цифры
+		{"77070838a3", false},
+		{"7707 83893", false},
+		{"-707083893", false},
+		{"５００１００７３２２５９", false},
+	}
+	for _, tt := range tests {
+		if got := ValidINN(tt.inn); got != tt.want {
+			t.Errorf("ValidINN(%q) = %t, want %t", tt.inn, got, tt.want)
+		}
+	}
+}
@@ -1,7 +1,28 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
+)
+
+// Наибольшие длины строковых полей в символах — по размерам столбцов
+// VARCHAR в схеме базы данных
+const (
+	MaxNameLength        = 255
+	MaxUnitLength        = 50
+	MaxOrderNumberLength = 50
+	MaxLoginLength       = 100
+	// MaxActorLength — исполнитель в order_history.actor и audit_log.actor
+	MaxActorLength = 100
+	// MaxAPIKeyNameLength оставляет в MaxActorLength место для префикса
+	// «api-key:», с которым имя ключа становится именем исполнителя
+	MaxAPIKeyNameLength     = 92
+	MaxExternalSystemLength = 50
+	MaxExternalIDLength     = 100
+	MaxExternalCodeLength   = 50
 )
 
 // Client представляет клиента в системе
@@ -9,6 +30,8 @@ type Client struct {
 	ID   int64  `json:"id" gorm:"primaryKey"`
 	Name string `json:"name" gorm:"not null"`
 	INN  string `json:"inn"`
+	// Version увеличивается при каждом изменении и служит ETag записи
+	Version int64 `json:"version"`
 }
 
 // Product представляет товар в системе
@@ -16,6 +39,8 @@ type Product struct {
 	ID   int64  `json:"id" gorm:"primaryKey"`
 	Name string `json:"name" gorm:"not null"`
 	Unit string `json:"unit" gorm:"not null"`
+	// Version увеличивается при каждом изменении и служит ETag записи
+	Version int64 `json:"version"`
 }
 
 // Order представляет заказ в системе
@@ -25,47 +50,241 @@ type Order struct {
 	Client      Client      `json:"client" gorm:"foreignKey:ClientID"`
 	Date        time.Time   `json:"date" gorm:"not null;default:CURRENT_TIMESTAMP"`
 	Number      string      `json:"number" gorm:"not null;unique"`
-	TotalAmount float64     `json:"total_amount" gorm:"type:decimal(15,2);not null;default:0"`
-	IsConfirmed bool        `json:"is_confirmed" gorm:"not null;default:false"`
+	TotalAmount money.Money `json:"total_amount" gorm:"type:decimal(15,2);not null;default:0"`
+	Status      OrderStatus `json:"status" gorm:"not null;default:draft"`
 	CreatedAt   time.Time   `json:"created_at" gorm:"not null;default:CURRENT_TIMESTAMP"`
-	Items       []OrderItem `json:"items" gorm:"foreignKey:OrderID"`
+	// Version увеличивается при каждом изменении заказа, включая смену статуса
+	Version int64       `json:"version" gorm:"not null;default:1"`
+	Items   []OrderItem `json:"items" gorm:"foreignKey:OrderID"`
+}
+
+// OrderStatus — статус заказа. Переходы между статусами описывает
+// service.Workflow, см. NewOrderWorkflow.
+type OrderStatus string
+
+const (
+	OrderStatusDraft        OrderStatus = "draft"
+	OrderStatusConfirmed    OrderStatus = "confirmed"
+	OrderStatusInFulfilment OrderStatus = "in_fulfilment"
+	OrderStatusShipped      OrderStatus = "shipped"
+	OrderStatusClosed       OrderStatus = "closed"
+	OrderStatusCancelled    OrderStatus = "cancelled"
+)
+
+// OrderStatuses перечисляет статусы заказа в порядке жизненного цикла
+var OrderStatuses = []OrderStatus{
+	OrderStatusDraft, OrderStatusConfirmed, OrderStatusInFulfilment,
+	OrderStatusShipped, OrderStatusClosed, OrderStatusCancelled,
+}
+
+// Valid сообщает, является ли строка известным статусом заказа
+func (s OrderStatus) Valid() bool {
+	for _, st := range OrderStatuses {
+		if s == st {
+			return true
+		}
+	}
+	return false
+}
+
+// Posted сообщает, проведен ли заказ в этом статусе, то есть есть ли у него
+// приход по регистру «ЗаказыПоКонтрагентам»
+func (s OrderStatus) Posted() bool {
+	switch s {
+	case OrderStatusConfirmed, OrderStatusInFulfilment, OrderStatusShipped, OrderStatusClosed:
+		return true
+	}
+	return false
 }
 
 // OrderItem представляет позицию заказа
 type OrderItem struct {
-	ID         int64   `json:"id" gorm:"primaryKey"`
-	OrderID    int64   `json:"order_id" gorm:"not null"`
-	ProductID  int64   `json:"product_id" gorm:"not null"`
-	Product    Product `json:"product" gorm:"foreignKey:ProductID"`
-	Quantity   float64 `json:"quantity" gorm:"type:decimal(15,3);not null;check:quantity >= 0"`
-	Price      float64 `json:"price" gorm:"type:decimal(15,2);not null;check:price >= 0"`
-	LineAmount float64 `json:"line_amount" gorm:"type:decimal(15,2);not null;default:0"`
+	ID         int64          `json:"id" gorm:"primaryKey"`
+	OrderID    int64          `json:"order_id" gorm:"not null"`
+	ProductID  int64          `json:"product_id" gorm:"not null"`
+	Product    Product        `json:"product" gorm:"foreignKey:ProductID"`
+	Quantity   money.Quantity `json:"quantity" gorm:"type:decimal(15,3);not null;check:quantity >= 0"`
+	Price      money.Money    `json:"price" gorm:"type:decimal(15,2);not null;check:price >= 0"`
+	LineAmount money.Money    `json:"line_amount" gorm:"type:decimal(15,2);not null;default:0"`
 }
 
+// CalculateAmounts рассчитывает суммы строк по текущему режиму округления
+// и возвращает общую сумму заказа. Если сумма строки или заказа не
+// помещается в DECIMAL(15,2), возвращает ошибку с money.ErrOutOfRange.
+func CalculateAmounts(items []OrderItem) (money.Money, error) {
+	var total money.Money
+	for i := range items {
+		amount, err := items[i].Quantity.Mul(items[i].Price)
+		if err != nil {
+			return 0, &AmountError{Line: i, Err: err}
+		}
+		items[i].LineAmount = amount
+		if total, err = total.Add(amount); err != nil {
+			return 0, &AmountError{Line: -1, Err: err}
+		}
+	}
+	return total, nil
+}
+
+// AmountError — сумма строки Line или, при Line = -1, общая сумма заказа
+// не помещается в DECIMAL(15,2)
+type AmountError struct {
+	Line int
+	Err  error
+}
+
+func (e *AmountError) Error() string {
+	if e.Line < 0 {
+		return "order total: " + e.Err.Error()
+	}
+	return fmt.Sprintf("line %d amount: %s", e.Line+1, e.Err)
+}
+
+func (e *AmountError) Unwrap() error { return e.Err }
+
 // OrdersByClient представляет агрегированные суммы заказов по клиентам
 type OrdersByClient struct {
-	ClientID  int64   `json:"client_id" gorm:"primaryKey"`
-	Client    Client  `json:"client" gorm:"foreignKey:ClientID"`
-	OrdersSum float64 `json:"orders_sum" gorm:"type:decimal(15,2);not null;default:0"`
+	ClientID  int64       `json:"client_id" gorm:"primaryKey"`
+	Client    Client      `json:"client" gorm:"foreignKey:ClientID"`
+	OrdersSum money.Money `json:"orders_sum" gorm:"type:decimal(15,2);not null;default:0"`
 }
 
-// CreateOrderRequest представляет запрос на создание заказа
-type CreateOrderRequest struct {
-	ClientID int64             `json:"client_id" binding:"required"`
-	Number   string            `json:"number" binding:"required"`
-	Items    []CreateOrderItem `json:"items" binding:"required,dive"`
+// Действия с заказом (переходы статусов), фиксируемые в истории
+const (
+	OrderActionConfirm         = "confirm"
+	OrderActionUnconfirm       = "unconfirm"
+	OrderActionStartFulfilment = "start_fulfilment"
+	OrderActionShip            = "ship"
+	OrderActionClose           = "close"
+	OrderActionCancel          = "cancel"
+)
+
+// OrderHistory представляет запись истории статусов заказа: кто, когда
+// и каким действием перевел заказ из одного статуса в другой
+type OrderHistory struct {
+	ID         int64       `json:"id"`
+	OrderID    int64       `json:"order_id"`
+	Action     string      `json:"action"`
+	FromStatus OrderStatus `json:"from_status"`
+	ToStatus   OrderStatus `json:"to_status"`
+	Actor      string      `json:"actor"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// Сущности и действия журнала аудита. Переходы статусов заказа пишутся
+// в журнал под именами переходов (OrderAction*).
+const (
+	AuditEntityClient       = "client"
+	AuditEntityProduct      = "product"
+	AuditEntityOrder        = "order"
+	AuditEntityUser         = "user"
+	AuditEntityAPIKey       = "api_key"
+	AuditEntityOrganization = "organization"
+
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+	AuditActionRevoke = "revoke"
+)
+
+// AuditEntry — запись журнала аудита: снимки сущности до и после изменения,
+// кто, когда, в каком запросе и по какой причине ее изменил. Журнал только
+// дополняется; при создании Before пуст, при удалении пуст After.
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	Entity    string          `json:"entity"`
+	EntityID  int64           `json:"entity_id"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	RequestID string          `json:"request_id"`
+	Reason    string          `json:"reason"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// ExternalRef сопоставляет запись OrderFlow (клиента, товар или заказ —
+// сущности из AuditEntity*) с ее идентификатором во внешней системе,
+// например с GUID ссылки 1С
+type ExternalRef struct {
+	Entity     string `json:"entity"`
+	EntityID   int64  `json:"entity_id"`
+	System     string `json:"system"`
+	ExternalID string `json:"external_id"`
+	// Code — код элемента справочника или номер документа во внешней
+	// системе; только для сведения, записи сопоставляются по ExternalID
+	Code string `json:"code"`
+	// ExportedVersion — версия записи, получение которой подтвердила
+	// внешняя система; 0 — запись ей еще не передана
+	ExportedVersion int64     `json:"exported_version"`
+	CreatedAt       time.Time `json:"created_at"`
 }
 
-// CreateOrderItem представляет запрос на создание позиции заказа
-type CreateOrderItem struct {
-	ProductID int64   `json:"product_id" binding:"required"`
-	Quantity  float64 `json:"quantity" binding:"required,min=0"`
-	Price     float64 `json:"price" binding:"required,min=0"`
+// Organization — организация (юридическое лицо), от имени которой ведется
+// учет. Клиенты, товары, заказы и регистр ведутся раздельно по организациям.
+type Organization struct {
+	ID        int64     `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// User — пользователь API. Пароль хранится только в виде хеша, роли
+// определяют доступные пользователю действия (см. service.Role).
+// Пользователь без организации может работать в любой из них.
+type User struct {
+	ID             int64     `json:"id"`
+	Login          string    `json:"login"`
+	PasswordHash   string    `json:"-"`
+	Roles          []string  `json:"roles"`
+	OrganizationID *int64    `json:"organization_id"`
+	Active         bool      `json:"active"`
+	Version        int64     `json:"version"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// APIKey — ключ доступа к API для интеграций. Сам ключ не хранится, только
+// его хеш; Prefix — начало ключа, по которому его узнают в списке.
+type APIKey struct {
+	ID      int64    `json:"id"`
+	Name    string   `json:"name"`
+	Prefix  string   `json:"prefix"`
+	KeyHash string   `json:"-"`
+	Scopes  []string `json:"scopes"`
+	// OrganizationID — организация, с данными которой работает ключ
+	OrganizationID int64 `json:"organization_id"`
+	// RateLimit — допустимое число запросов в минуту
+	RateLimit  int        `json:"rate_limit"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// MovementKind представляет вид движения регистра накопления (Приход/Расход)
+type MovementKind string
+
+const (
+	MovementReceipt MovementKind = "receipt"
+	MovementExpense MovementKind = "expense"
+)
+
+// OrdersByClientMovement представляет движение регистра накопления «ЗаказыПоКонтрагентам»
+type OrdersByClientMovement struct {
+	RecorderID int64        `json:"recorder_id"`
+	Period     time.Time    `json:"period"`
+	LineNumber int          `json:"line_number"`
+	RecordKind MovementKind `json:"record_kind"`
+	ClientID   int64        `json:"client_id"`
+	Amount     money.Money  `json:"amount"`
 }
 
-// UpdateOrderRequest представляет запрос на обновление заказа
-type UpdateOrderRequest struct {
-	ClientID int64             `json:"client_id"`
-	Number   string            `json:"number"`
-	Items    []CreateOrderItem `json:"items" binding:"dive"`
+// OrdersByClientTurnover представляет обороты регистра по клиенту за период
+type OrdersByClientTurnover struct {
+	ClientID int64       `json:"client_id"`
+	Client   Client      `json:"client"`
+	Receipt  money.Money `json:"receipt"`
+	Expense  money.Money `json:"expense"`
+	Turnover money.Money `json:"turnover"`
 }
@@ -0,0 +1,346 @@
+// Package money содержит типы с фиксированной точкой для денежных сумм
+// (DECIMAL(15,2)) и количеств (DECIMAL(15,3)). Значения хранятся как целое
+// число младших единиц, поэтому суммы совпадают до копейки.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// RoundingMode задает правило округления до младшей единицы
+type RoundingMode int32
+
+const (
+	// HalfUp округляет половину от нуля, как NUMERIC в Postgres
+	HalfUp RoundingMode = iota
+	// HalfEven — банковское округление: половина округляется к четному
+	HalfEven
+)
+
+// maxDigits ограничивает точность, как DECIMAL(15,n) в схеме
+const maxDigits = 15
+
+var (
+	ErrInvalid    = errors.New("invalid decimal value")
+	ErrOutOfRange = errors.New("decimal value out of range")
+)
+
+var rounding atomic.Int32
+
+// SetRounding устанавливает режим округления для всех операций пакета
+func SetRounding(mode RoundingMode) {
+	rounding.Store(int32(mode))
+}
+
+// Rounding возвращает текущий режим округления
+func Rounding() RoundingMode {
+	return RoundingMode(rounding.Load())
+}
+
+// ParseRoundingMode разбирает название режима из конфигурации
+func ParseRoundingMode(s string) (RoundingMode, error) {
+	switch strings.ToLower(s) {
+	case "half_up", "":
+		return HalfUp, nil
+	case "half_even", "bankers":
+		return HalfEven, nil
+	}
+	return HalfUp, fmt.Errorf("unknown rounding mode %q, expected half_up or half_even", s)
+}
+
+func (m RoundingMode) String() string {
+	if m == HalfEven {
+		return "half_even"
+	}
+	return "half_up"
+}
+
+// Money — денежная сумма в копейках (2 знака после запятой)
+type Money int64
+
+// Quantity — количество в тысячных долях единицы (3 знака после запятой)
+type Quantity int64
+
+const (
+	moneyScale    = 2
+	quantityScale = 3
+)
+
+// MoneyFromMinor создает сумму из количества копеек
+func MoneyFromMinor(kopecks int64) Money {
+	return Money(kopecks)
+}
+
+// ParseMoney разбирает десятичную строку, округляя до копеек
+func ParseMoney(s string) (Money, error) {
+	v, err := parseFixed(s, moneyScale)
+	return Money(v), err
+}
+
+// MustParseMoney как ParseMoney, но паникует при ошибке; для констант
+func MustParseMoney(s string) Money {
+	m, err := ParseMoney(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// ParseQuantity разбирает десятичную строку, округляя до тысячных
+func ParseQuantity(s string) (Quantity, error) {
+	v, err := parseFixed(s, quantityScale)
+	return Quantity(v), err
+}
+
+// MustParseQuantity как ParseQuantity, но паникует при ошибке; для констант
+func MustParseQuantity(s string) Quantity {
+	q, err := ParseQuantity(s)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// Minor возвращает сумму в копейках
+func (m Money) Minor() int64 { return int64(m) }
+
+func (m Money) String() string { return formatFixed(int64(m), moneyScale) }
+
+// Float64 возвращает приближенное значение; только для вывода, не для расчетов
+func (m Money) Float64() float64 {
+	f, _ := strconv.ParseFloat(m.String(), 64)
+	return f
+}
+
+func (q Quantity) String() string { return formatFixed(int64(q), quantityScale) }
+
+// Float64 возвращает приближенное значение; только для вывода, не для расчетов
+func (q Quantity) Float64() float64 {
+	f, _ := strconv.ParseFloat(q.String(), 64)
+	return f
+}
+
+// Mul возвращает стоимость количества q по цене price, округленную до
+// копеек. Если стоимость не помещается в DECIMAL(15,2), возвращает ErrOutOfRange.
+func (q Quantity) Mul(price Money) (Money, error) {
+	n := new(big.Int).Mul(big.NewInt(int64(q)), big.NewInt(int64(price)))
+	d := big.NewInt(pow10(quantityScale))
+	v := roundQuo(n, d, Rounding())
+	if !inRange(v) {
+		return 0, fmt.Errorf("%w: %s * %s", ErrOutOfRange, q, price)
+	}
+	return Money(v.Int64()), nil
+}
+
+// Add возвращает m + n или ErrOutOfRange, если сумма не помещается в DECIMAL(15,2)
+func (m Money) Add(n Money) (Money, error) {
+	v := new(big.Int).Add(big.NewInt(int64(m)), big.NewInt(int64(n)))
+	if !inRange(v) {
+		return 0, fmt.Errorf("%w: %s + %s", ErrOutOfRange, m, n)
+	}
+	return Money(v.Int64()), nil
+}
+
+// Sum складывает суммы без потери точности; ErrOutOfRange — если итог или
+// промежуточная сумма не помещается в DECIMAL(15,2)
+func Sum(values ...Money) (Money, error) {
+	var total Money
+	for _, v := range values {
+		var err error
+		if total, err = total.Add(v); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+func (m Money) MarshalJSON() ([]byte, error) { return marshalJSON(m.String()) }
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON(data, moneyScale, (*int64)(m))
+}
+
+func (m Money) MarshalText() ([]byte, error) { return []byte(m.String()), nil }
+
+func (m *Money) UnmarshalText(text []byte) error {
+	v, err := parseFixed(string(text), moneyScale)
+	*m = Money(v)
+	return err
+}
+
+func (m *Money) Scan(src interface{}) error { return scan(src, moneyScale, (*int64)(m)) }
+
+func (m Money) Value() (driver.Value, error) { return m.String(), nil }
+
+func (q Quantity) MarshalJSON() ([]byte, error) { return marshalJSON(q.String()) }
+
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	return unmarshalJSON(data, quantityScale, (*int64)(q))
+}
+
+func (q Quantity) MarshalText() ([]byte, error) { return []byte(q.String()), nil }
+
+func (q *Quantity) UnmarshalText(text []byte) error {
+	v, err := parseFixed(string(text), quantityScale)
+	*q = Quantity(v)
+	return err
+}
+
+func (q *Quantity) Scan(src interface{}) error { return scan(src, quantityScale, (*int64)(q)) }
+
+func (q Quantity) Value() (driver.Value, error) { return q.String(), nil }
+
+// marshalJSON кодирует значение строкой, чтобы клиенты не теряли точность на float
+func marshalJSON(s string) ([]byte, error) {
+	return []byte(`"` + s + `"`), nil
+}
+
+// unmarshalJSON принимает как строку "10.50", так и число 10.5
+func unmarshalJSON(data []byte, scale int, dst *int64) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	v, err := parseFixed(s, scale)
+	if err != nil {
+		return err
+	}
+	*dst = v
+	return nil
+}
+
+func scan(src interface{}, scale int, dst *int64) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*dst = 0
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalid, src)
+	}
+
+	v, err := parseFixed(s, scale)
+	if err != nil {
+		return err
+	}
+	*dst = v
+	return nil
+}
+
+// maxInputLength ограничивает длину разбираемой строки: значения приходят
+// из JSON, CSV и CommerceML, и длинный ввод не должен стоить заметного
+// времени. Нули в начале и лишние знаки после запятой укладываются с запасом.
+const maxInputLength = 64
+
+// parseFixed переводит десятичную строку в целое число единиц 10^-scale.
+// Принимается только запись [+-]цифры[.цифры]: без экспоненты, дробей,
+// шестнадцатеричных чисел и разделителей разрядов.
+func parseFixed(s string, scale int) (int64, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > maxInputLength || !isDecimal(s) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalid, truncate(s))
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+
+	r.Mul(r, new(big.Rat).SetInt64(pow10(scale)))
+	v := roundQuo(r.Num(), r.Denom(), Rounding())
+
+	if !inRange(v) {
+		return 0, fmt.Errorf("%w: %q", ErrOutOfRange, s)
+	}
+	return v.Int64(), nil
+}
+
+// isDecimal проверяет запись [+-]цифры[.цифры]
+func isDecimal(s string) bool {
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+	intPart, frac, hasPoint := strings.Cut(s, ".")
+	return allDigits(intPart) && (!hasPoint || allDigits(frac))
+}
+
+// allDigits сообщает, что s — непустая строка цифр ASCII
+func allDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// truncate сокращает значение в тексте ошибки
+func truncate(s string) string {
+	if len(s) > maxInputLength {
+		return s[:maxInputLength] + "..."
+	}
+	return s
+}
+
+// inRange сообщает, помещается ли число младших единиц в maxDigits цифр
+func inRange(v *big.Int) bool {
+	return new(big.Int).Abs(v).Cmp(big.NewInt(pow10(maxDigits))) < 0
+}
+
+// roundQuo делит n на положительный d с округлением по режиму mode
+func roundQuo(n, d *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+	cmp := twice.Cmp(d)
+	if cmp > 0 || (cmp == 0 && (mode == HalfUp || q.Bit(0) == 1)) {
+		if n.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+func formatFixed(v int64, scale int) string {
+	sign := ""
+	u := uint64(v)
+	if v < 0 {
+		sign = "-"
+		u = uint64(-v)
+	}
+	p := uint64(pow10(scale))
+	return fmt.Sprintf("%s%d.%0*d", sign, u/p, scale, u%p)
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
@@ -0,0 +1,126 @@
+package money
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{"10", "10.00", nil},
+		{"10.505", "10.51", nil},
+		{"-0.005", "-0.01", nil},
+		{"9999999999999.99", "9999999999999.99", nil},
+		{"10000000000000", "", ErrOutOfRange},
+		{"+1.5", "1.50", nil},
+		{" 007.10 ", "7.10", nil},
+		{"1/3", "", ErrInvalid},
+		{"", "", ErrInvalid},
+		{"-", "", ErrInvalid},
+		{".5", "", ErrInvalid},
+		{"5.", "", ErrInvalid},
+		{"1.2.3", "", ErrInvalid},
+		{"1,5", "", ErrInvalid},
+		{"0x10", "", ErrInvalid},
+		{"0b11", "", ErrInvalid},
+		{"0o17", "", ErrInvalid},
+		{"1_000", "", ErrInvalid},
+		{"0x1p4", "", ErrInvalid},
+		{"1e2", "", ErrInvalid},
+		{"1E-2", "", ErrInvalid},
+		{"1e1000000", "", ErrInvalid},
+		{"Inf", "", ErrInvalid},
+		{"NaN", "", ErrInvalid},
+		{"١٢", "", ErrInvalid},
+		{strings.Repeat("0", 64), "0.00", nil},
+		{strings.Repeat("0", 65), "", ErrInvalid},
+	}
+	for _, tt := range tests {
+		m, err := ParseMoney(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseMoney(%q) error = %v, want %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && m.String() != tt.want {
+			t.Errorf("ParseMoney(%q) = %s, want %s", tt.in, m, tt.want)
+		}
+	}
+}
+
+func TestMul(t *testing.T) {
+	tests := []struct {
+		quantity, price string
+		want            string
+		err             error
+	}{
+		{"3", "10.50", "31.50", nil},
+		{"0.333", "0.10", "0.03", nil},
+		{"1.005", "100.00", "100.50", nil},
+		{"999999999999.999", "0.01", "10000000000.00", nil},
+		{"999999999999.999", "9999999999999.99", "", ErrOutOfRange},
+		{"100000", "99999999999", "", ErrOutOfRange},
+		{"100000", "99999999.99", "9999999999000.00", nil},
+		{"100000", "100000000", "", ErrOutOfRange},
+	}
+	for _, tt := range tests {
+		got, err := MustParseQuantity(tt.quantity).Mul(MustParseMoney(tt.price))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s * %s error = %v, want %v", tt.quantity, tt.price, err, tt.err)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("%s * %s = %s, want %s", tt.quantity, tt.price, got, tt.want)
+		}
+	}
+}
+
+func TestMulRounding(t *testing.T) {
+	defer SetRounding(Rounding())
+
+	q, p := MustParseQuantity("0.5"), MustParseMoney("0.05")
+	for mode, want := range map[RoundingMode]string{HalfUp: "0.03", HalfEven: "0.02"} {
+		SetRounding(mode)
+		got, err := q.Mul(p)
+		if err != nil || got.String() != want {
+			t.Errorf("%s: 0.5 * 0.05 = %s, %v; want %s", mode, got, err, want)
+		}
+	}
+}
+
+func TestSum(t *testing.T) {
+	max := MustParseMoney("9999999999999.99")
+
+	if got, err := Sum(max, MustParseMoney("-0.99"), MustParseMoney("0.99")); err != nil || got != max {
+		t.Errorf("Sum at the limit = %s, %v; want %s", got, err, max)
+	}
+	if _, err := Sum(max, MustParseMoney("0.01")); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Sum over the limit error = %v, want ErrOutOfRange", err)
+	}
+	if _, err := (-max).Add(MustParseMoney("-0.01")); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("Add under the negative limit error = %v, want ErrOutOfRange", err)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var m Money
+	if err := m.UnmarshalJSON([]byte(`10.5`)); err != nil || m.String() != "10.50" {
+		t.Errorf("unmarshal number = %s, %v", m, err)
+	}
+	if err := m.UnmarshalJSON([]byte(`"0.07"`)); err != nil || m.String() != "0.07" {
+		t.Errorf("unmarshal string = %s, %v", m, err)
+	}
+	if err := m.UnmarshalJSON([]byte(`"100000000000000000000"`)); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("unmarshal 1e20 error = %v, want ErrOutOfRange", err)
+	}
+	if err := m.UnmarshalJSON([]byte(`1e20`)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("unmarshal number with exponent error = %v, want ErrInvalid", err)
+	}
+	if data, _ := MustParseMoney("-1.2").MarshalJSON(); string(data) != `"-1.20"` {
+		t.Errorf("marshal = %s, want \"-1.20\"", data)
+	}
+}
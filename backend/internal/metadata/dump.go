@@ -0,0 +1,580 @@
+package metadata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Значения по умолчанию для реквизитов без квалификаторов в упрощенном
+// описании: строка как наименование справочника, число как сумма
+const (
+	DefaultStringLength   = 150
+	DefaultDigits         = 15
+	DefaultFractionDigits = 2
+)
+
+// Значения свойств объектов, не указанных в выгрузке конфигуратора, —
+// такие же, как у новых объектов в конфигураторе
+const (
+	defaultDescriptionLength = 25
+	defaultNumberLength      = 11
+)
+
+// dumpDirs — каталоги выгрузки с описаниями объектов каждого вида
+var dumpDirs = []struct {
+	dir  string
+	kind Kind
+}{
+	{"Catalogs", KindCatalog},
+	{"Documents", KindDocument},
+	{"AccumulationRegisters", KindAccumulationRegister},
+}
+
+// descriptionFile — описание объекта в упрощенной выгрузке: Catalogs/Склады/Description.xml
+const descriptionFile = "Description.xml"
+
+// Read читает объекты выгрузки конфигурации из корня fsys. Описание объекта
+// ищется в файле Catalogs/Склады.xml, а если его нет — в
+// Catalogs/Склады/Description.xml. Отсутствующие каталоги пропускаются.
+func Read(fsys fs.FS) (*Configuration, error) {
+	config := &Configuration{}
+	for _, d := range dumpDirs {
+		entries, err := fs.ReadDir(fsys, d.dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			name := path.Join(d.dir, e.Name())
+			switch {
+			case !e.IsDir() && strings.EqualFold(path.Ext(e.Name()), ".xml"):
+			case e.IsDir():
+				// Каталог объекта в выгрузке конфигуратора лежит рядом с
+				// файлом описания и содержит модули
+				if _, err := fs.Stat(fsys, name+".xml"); err == nil {
+					continue
+				}
+				name = path.Join(name, descriptionFile)
+				if _, err := fs.Stat(fsys, name); err != nil {
+					continue
+				}
+			default:
+				continue
+			}
+
+			obj, err := readFile(fsys, name)
+			if err != nil {
+				return nil, err
+			}
+			if obj.Kind != d.kind {
+				return nil, fmt.Errorf("metadata: %s: %s in %s", name, obj.FullName(), d.dir)
+			}
+			if config.Lookup(obj.Kind, obj.Name) != nil {
+				return nil, fmt.Errorf("metadata: %s: duplicate %s", name, obj.FullName())
+			}
+			config.Objects = append(config.Objects, obj)
+		}
+	}
+
+	if err := config.checkRefs(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func readFile(fsys fs.FS, name string) (*Object, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	obj, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return obj, nil
+}
+
+// checkRefs проверяет, что реквизиты ссылаются на объекты конфигурации.
+// Перечисления не проверяются: их описания не читаются.
+func (c *Configuration) checkRefs() error {
+	for _, o := range c.Objects {
+		var fields []Field
+		fields = append(fields, o.Attributes...)
+		fields = append(fields, o.Dimensions...)
+		fields = append(fields, o.Resources...)
+		for _, ts := range o.TabularSections {
+			fields = append(fields, ts.Fields...)
+		}
+		for _, f := range fields {
+			if f.Type.Kind == TypeRef && f.Type.RefKind != KindEnum && c.Lookup(f.Type.RefKind, f.Type.RefName) == nil {
+				return fmt.Errorf("metadata: %s.%s refers to missing %s", o.FullName(), f.Name, f.Type)
+			}
+		}
+		for _, reg := range o.Registers {
+			if c.Lookup(KindAccumulationRegister, reg) == nil {
+				return fmt.Errorf("metadata: %s records missing register %s", o.FullName(), reg)
+			}
+		}
+	}
+	return nil
+}
+
+// Warnings возвращает предупреждения о числах без квалификаторов: их
+// точность взята по умолчанию и может не совпадать с точностью в 1С.
+// Для ресурсов регистров это суммы остатков и оборотов, поэтому точность
+// стоит указать в выгрузке или проверить в созданной миграции.
+func (c *Configuration) Warnings() []string {
+	var warnings []string
+	check := func(o *Object, title string, fields []Field) {
+		for _, f := range fields {
+			if f.Type.Kind == TypeNumber && f.Type.Unqualified {
+				warnings = append(warnings, fmt.Sprintf("%s: %s %s has no number qualifiers; assumed %s", o.FullName(), title, f.Name, f.Type))
+			}
+		}
+	}
+	for _, o := range c.Objects {
+		check(o, "attribute", o.Attributes)
+		for _, ts := range o.TabularSections {
+			check(o, "attribute of "+ts.Name, ts.Fields)
+		}
+		check(o, "dimension", o.Dimensions)
+		check(o, "resource", o.Resources)
+	}
+	return warnings
+}
+
+// Parse читает описание одного объекта в любом из поддерживаемых видов
+func Parse(r io.Reader) (*Object, error) {
+	root, err := parseTree(r)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: %w", err)
+	}
+	if root.name != "MetaDataObject" {
+		return nil, fmt.Errorf("metadata: unexpected root element %s", root.name)
+	}
+
+	var obj *Object
+	if root.attr("type") != "" {
+		obj, err = parseDescription(root)
+	} else {
+		obj, err = parseDesigner(root)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if obj.Name == "" {
+		return nil, errors.New("metadata: object has no name")
+	}
+	return obj, nil
+}
+
+// parseDescription разбирает упрощенное описание:
+// <MetaDataObject type="Document" name="..."> с разделами Properties,
+// TabularSections, Dimensions и Resources
+func parseDescription(root *node) (*Object, error) {
+	kind, ok := ParseKind(root.attr("type"))
+	if !ok || kind == KindEnum {
+		return nil, fmt.Errorf("metadata: unsupported object type %q", root.attr("type"))
+	}
+	obj := &Object{Kind: kind, Name: root.attr("name"), UUID: root.attr("uuid"), Balance: true}
+	if name := root.text("Name"); name != "" {
+		obj.Name = name
+	}
+	obj.Synonym = root.text("Synonym")
+
+	var err error
+	if obj.Attributes, err = parseFields(root.child("Properties").all("Property"), false); err != nil {
+		return nil, obj.errorf(err)
+	}
+	for _, ts := range root.child("TabularSections").all("TabularSection") {
+		section := TabularSection{Name: ts.text("Name"), Synonym: ts.text("Synonym")}
+		if section.Fields, err = parseFields(ts.child("Fields").all("Field"), false); err != nil {
+			return nil, obj.errorf(fmt.Errorf("%s: %w", section.Name, err))
+		}
+		obj.TabularSections = append(obj.TabularSections, section)
+	}
+	if obj.Dimensions, err = parseFields(root.child("Dimensions").all("Dimension"), false); err != nil {
+		return nil, obj.errorf(err)
+	}
+	if obj.Resources, err = parseFields(root.child("Resources").all("Resource"), false); err != nil {
+		return nil, obj.errorf(err)
+	}
+	if t := root.text("RegisterType"); t != "" {
+		obj.Balance = t == "Balance"
+	}
+	for _, item := range root.child("RegisterRecords").elements() {
+		obj.Registers = append(obj.Registers, registerName(item.content()))
+	}
+
+	obj.addStandardAttributes(nil)
+	return obj, nil
+}
+
+// parseDesigner разбирает описание из выгрузки конфигуратора:
+// <MetaDataObject><Catalog><Properties/><ChildObjects/></Catalog></MetaDataObject>
+func parseDesigner(root *node) (*Object, error) {
+	if len(root.children) != 1 {
+		return nil, errors.New("metadata: MetaDataObject must contain one object")
+	}
+	elem := root.children[0]
+	kind, ok := ParseKind(elem.name)
+	if !ok || kind == KindEnum {
+		return nil, fmt.Errorf("metadata: unsupported object type %s", elem.name)
+	}
+
+	props := elem.child("Properties")
+	obj := &Object{
+		Kind:    kind,
+		Name:    props.text("Name"),
+		Synonym: synonym(props.child("Synonym")),
+		UUID:    elem.attr("uuid"),
+		Balance: props.text("RegisterType") != "Turnovers",
+	}
+	for _, item := range props.child("RegisterRecords").elements() {
+		obj.Registers = append(obj.Registers, registerName(item.content()))
+	}
+
+	children := elem.child("ChildObjects")
+	var err error
+	if obj.Attributes, err = parseFields(children.all("Attribute"), true); err != nil {
+		return nil, obj.errorf(err)
+	}
+	for _, ts := range children.all("TabularSection") {
+		tsProps := ts.child("Properties")
+		section := TabularSection{Name: tsProps.text("Name"), Synonym: synonym(tsProps.child("Synonym"))}
+		if section.Fields, err = parseFields(ts.child("ChildObjects").all("Attribute"), true); err != nil {
+			return nil, obj.errorf(fmt.Errorf("%s: %w", section.Name, err))
+		}
+		obj.TabularSections = append(obj.TabularSections, section)
+	}
+	if obj.Dimensions, err = parseFields(children.all("Dimension"), true); err != nil {
+		return nil, obj.errorf(err)
+	}
+	if obj.Resources, err = parseFields(children.all("Resource"), true); err != nil {
+		return nil, obj.errorf(err)
+	}
+
+	obj.addStandardAttributes(props)
+	return obj, nil
+}
+
+func (o *Object) errorf(err error) error {
+	return fmt.Errorf("metadata: %s: %w", o.FullName(), err)
+}
+
+// addStandardAttributes добавляет в начало реквизитов стандартные реквизиты
+// справочника или документа. props — свойства объекта из выгрузки
+// конфигуратора; в упрощенном описании их нет, и стандартными считаются
+// объявленные реквизиты с теми же именами и Наименование справочника.
+func (o *Object) addStandardAttributes(props *node) {
+	var standard []Field
+	switch o.Kind {
+	case KindCatalog:
+		if codeLength := props.number("CodeLength", 0); codeLength > 0 {
+			code := Type{Kind: TypeString, Length: codeLength}
+			if props.text("CodeType") == "Number" {
+				code = Type{Kind: TypeNumber, Digits: codeLength}
+			}
+			standard = append(standard, Field{Name: AttributeCode, Type: code})
+		}
+		length := DefaultStringLength
+		if props != nil {
+			length = props.number("DescriptionLength", defaultDescriptionLength)
+		}
+		if length > 0 {
+			standard = append(standard, Field{Name: AttributeDescription, Type: Type{Kind: TypeString, Length: length}})
+		}
+	case KindDocument:
+		length := props.number("NumberLength", defaultNumberLength)
+		number := Type{Kind: TypeString, Length: length}
+		if props.text("NumberType") == "Number" {
+			number = Type{Kind: TypeNumber, Digits: length}
+		}
+		standard = append(standard,
+			Field{Name: AttributeNumber, Type: number},
+			Field{Name: AttributeDate, Type: Type{Kind: TypeDate}})
+	default:
+		return
+	}
+
+	// Объявленный реквизит с именем стандартного заменяет его
+	attrs := make([]Field, 0, len(standard)+len(o.Attributes))
+	for _, s := range standard {
+		s.Standard = true
+		for i, a := range o.Attributes {
+			if strings.EqualFold(a.Name, s.Name) {
+				s.Type, s.Synonym = a.Type, a.Synonym
+				o.Attributes = append(o.Attributes[:i:i], o.Attributes[i+1:]...)
+				break
+			}
+		}
+		attrs = append(attrs, s)
+	}
+	o.Attributes = append(attrs, o.Attributes...)
+}
+
+// parseFields разбирает реквизиты. В выгрузке конфигуратора имя, синоним и
+// тип реквизита вложены в элемент Properties.
+func parseFields(nodes []*node, designer bool) ([]Field, error) {
+	var fields []Field
+	for _, n := range nodes {
+		props := n
+		if designer {
+			props = n.child("Properties")
+		}
+		f := Field{Name: props.text("Name")}
+		if designer {
+			f.Synonym = synonym(props.child("Synonym"))
+		} else {
+			f.Synonym = props.text("Synonym")
+		}
+		if f.Name == "" {
+			return nil, errors.New("attribute has no name")
+		}
+		t, err := parseType(props)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		f.Type = t
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// parseType разбирает тип реквизита. В выгрузке конфигуратора тип записан
+// как <Type><v8:Type>xs:decimal</v8:Type><v8:NumberQualifiers>…</Type>,
+// в упрощенном описании — <Type>Number</Type> и квалификаторы рядом с ним.
+func parseType(props *node) (Type, error) {
+	typeNode := props.child("Type")
+	if typeNode == nil {
+		return Type{}, errors.New("attribute has no type")
+	}
+	name := typeNode.content()
+	if types := typeNode.all("Type"); len(types) > 0 || typeNode.child("TypeSet") != nil {
+		if len(types) != 1 {
+			return Type{}, errors.New("composite types are not supported")
+		}
+		name = types[0].content()
+	}
+	// Квалификаторы ищутся внутри Type, а затем рядом с ним
+	qualifiers := func(elem string) *node {
+		if q := typeNode.child(elem); q != nil {
+			return q
+		}
+		return props.child(elem)
+	}
+
+	// Префиксы пространств имен выгрузки: xs:string, cfg:CatalogRef.Склады
+	if _, local, ok := strings.Cut(name, ":"); ok {
+		name = local
+	}
+	prefix, refName, isRef := strings.Cut(name, ".")
+	if isRef {
+		kind, ok := refKind(prefix)
+		if !ok || refName == "" {
+			return Type{}, fmt.Errorf("unsupported type %s", name)
+		}
+		return Type{Kind: TypeRef, RefKind: kind, RefName: refName}, nil
+	}
+
+	switch strings.ToLower(name) {
+	case "string", "строка":
+		q := qualifiers("StringQualifiers")
+		return Type{Kind: TypeString, Length: q.number("Length", DefaultStringLength)}, nil
+	case "decimal", "number", "число":
+		q := qualifiers("NumberQualifiers")
+		t := Type{Kind: TypeNumber, Digits: DefaultDigits, FractionDigits: DefaultFractionDigits, Unqualified: q == nil}
+		if q != nil {
+			t.Digits = q.number("Digits", q.number("Precision", DefaultDigits))
+			t.FractionDigits = q.number("FractionDigits", q.number("Scale", 0))
+		}
+		return t, nil
+	case "datetime", "date", "дата":
+		return Type{Kind: TypeDate, DateOnly: qualifiers("DateQualifiers").text("DateFractions") == "Date"}, nil
+	case "boolean", "булево":
+		return Type{Kind: TypeBoolean}, nil
+	}
+	return Type{}, fmt.Errorf("unsupported type %s", name)
+}
+
+// refKind распознает вид объекта в типе ссылки: CatalogRef, СправочникСсылка
+func refKind(prefix string) (Kind, bool) {
+	switch {
+	case strings.HasSuffix(prefix, "Ref"):
+		return ParseKind(strings.TrimSuffix(prefix, "Ref"))
+	case strings.HasSuffix(prefix, "Ссылка"):
+		return ParseKind(strings.TrimSuffix(prefix, "Ссылка"))
+	}
+	return "", false
+}
+
+// registerName извлекает имя регистра из «AccumulationRegister.Имя»
+func registerName(s string) string {
+	if _, name, ok := strings.Cut(s, "."); ok {
+		return name
+	}
+	return s
+}
+
+// synonym возвращает русский синоним из <Synonym><v8:item>…</v8:item></Synonym>
+// или первый, если русского нет
+func synonym(n *node) string {
+	var first string
+	for _, item := range n.all("item") {
+		content := item.text("content")
+		if item.text("lang") == "ru" {
+			return content
+		}
+		if first == "" {
+			first = content
+		}
+	}
+	return first
+}
+
+// node — элемент XML без пространств имен. Описания объектов невелики,
+// поэтому документ разбирается в дерево целиком.
+type node struct {
+	name     string
+	attrs    []xml.Attr
+	children []*node
+	chars    strings.Builder
+}
+
+// parseTree читает документ в дерево элементов. Выгрузка конфигуратора
+// записывается в UTF-8 с BOM, упрощенное описание может быть в windows-1251.
+func parseTree(r io.Reader) (*node, error) {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte{0xEF, 0xBB, 0xBF}) {
+		br.Discard(3)
+	}
+	dec := xml.NewDecoder(br)
+	dec.CharsetReader = charsetReader
+
+	var stack []*node
+	var root *node
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name.Local, attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].chars.Write(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("empty document")
+	}
+	return root, nil
+}
+
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(label) {
+	case "utf-8", "utf8":
+		return input, nil
+	case "windows-1251", "cp1251":
+		return charmap.Windows1251.NewDecoder().Reader(input), nil
+	}
+	return nil, fmt.Errorf("unsupported encoding %s", label)
+}
+
+// Методы node допускают nil: отсутствующий элемент пуст
+
+func (n *node) child(name string) *node {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *node) all(name string) []*node {
+	if n == nil {
+		return nil
+	}
+	var nodes []*node
+	for _, c := range n.children {
+		if c.name == name {
+			nodes = append(nodes, c)
+		}
+	}
+	return nodes
+}
+
+// elements возвращает все дочерние элементы
+func (n *node) elements() []*node {
+	if n == nil {
+		return nil
+	}
+	return n.children
+}
+
+func (n *node) attr(name string) string {
+	if n == nil {
+		return ""
+	}
+	for _, a := range n.attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// content возвращает текст элемента без пробелов по краям
+func (n *node) content() string {
+	if n == nil {
+		return ""
+	}
+	return strings.TrimSpace(n.chars.String())
+}
+
+// text возвращает текст дочернего элемента name
+func (n *node) text(name string) string {
+	return n.child(name).content()
+}
+
+// number возвращает целое из дочернего элемента name или def, если его нет
+func (n *node) number(name string, def int) int {
+	v, err := strconv.Atoi(n.text(name))
+	if err != nil {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,171 @@
+package metadata
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// register — упрощенное описание регистра остатков ЗаказыПоКонтрагентам из
+// docs/sample3.txt с ресурсом без квалификаторов
+const register = `<?xml version="1.0" encoding="UTF-8"?>
+<MetaDataObject type="AccumulationRegister" name="ЗаказыПоКонтрагентам">
+    <Dimensions>
+        <Dimension>
+            <Name>Контрагент</Name>
+            <Type>CatalogRef.Контрагенты</Type>
+        </Dimension>
+    </Dimensions>
+    <Resources>
+        <Resource>
+            <Name>СуммаЗаказов</Name>
+            <Type>Number</Type>
+        </Resource>
+        <Resource>
+            <Name>Количество</Name>
+            <Type>Number</Type>
+            <NumberQualifiers>
+                <Precision>15</Precision>
+                <Scale>3</Scale>
+            </NumberQualifiers>
+        </Resource>
+    </Resources>
+</MetaDataObject>
+`
+
+// catalog — справочник из выгрузки конфигуратора
+const catalog = `<?xml version="1.0" encoding="UTF-8"?>
+<MetaDataObject xmlns="http://v8.1c.ru/8.3/MDClasses" xmlns:v8="http://v8.1c.ru/8.1/data/core">
+    <Catalog uuid="0a6d2f1c-0000-4000-8000-000000000001">
+        <Properties>
+            <Name>Контрагенты</Name>
+            <Synonym>
+                <v8:item>
+                    <v8:lang>ru</v8:lang>
+                    <v8:content>Контрагенты</v8:content>
+                </v8:item>
+            </Synonym>
+            <CodeLength>0</CodeLength>
+            <DescriptionLength>100</DescriptionLength>
+        </Properties>
+        <ChildObjects>
+            <Attribute>
+                <Properties>
+                    <Name>ИНН</Name>
+                    <Type>
+                        <v8:Type>xs:string</v8:Type>
+                        <v8:StringQualifiers>
+                            <v8:Length>12</v8:Length>
+                        </v8:StringQualifiers>
+                    </Type>
+                </Properties>
+            </Attribute>
+            <Attribute>
+                <Properties>
+                    <Name>КредитныйЛимит</Name>
+                    <Type>
+                        <v8:Type>xs:decimal</v8:Type>
+                        <v8:NumberQualifiers>
+                            <v8:Digits>15</v8:Digits>
+                            <v8:FractionDigits>2</v8:FractionDigits>
+                        </v8:NumberQualifiers>
+                    </Type>
+                </Properties>
+            </Attribute>
+        </ChildObjects>
+    </Catalog>
+</MetaDataObject>
+`
+
+func TestRead(t *testing.T) {
+	config, err := Read(fstest.MapFS{
+		"Catalogs/Контрагенты.xml":                                   {Data: []byte(catalog)},
+		"AccumulationRegisters/ЗаказыПоКонтрагентам/Description.xml": {Data: []byte(register)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clients := config.Lookup(KindCatalog, "контрагенты")
+	if clients == nil {
+		t.Fatal("catalog Контрагенты is not read")
+	}
+	want := []Field{
+		{Name: AttributeDescription, Type: Type{Kind: TypeString, Length: 100}, Standard: true},
+		{Name: "ИНН", Type: Type{Kind: TypeString, Length: 12}},
+		{Name: "КредитныйЛимит", Type: Type{Kind: TypeNumber, Digits: 15, FractionDigits: 2}},
+	}
+	if !reflect.DeepEqual(clients.Attributes, want) {
+		t.Errorf("Контрагенты attributes = %+v, want %+v", clients.Attributes, want)
+	}
+
+	reg := config.Lookup(KindAccumulationRegister, "ЗаказыПоКонтрагентам")
+	if reg == nil {
+		t.Fatal("register ЗаказыПоКонтрагентам is not read")
+	}
+	if !reg.Balance {
+		t.Error("register without RegisterType is not a balance register")
+	}
+	wantDims := []Field{{Name: "Контрагент", Type: Type{Kind: TypeRef, RefKind: KindCatalog, RefName: "Контрагенты"}}}
+	if !reflect.DeepEqual(reg.Dimensions, wantDims) {
+		t.Errorf("dimensions = %+v, want %+v", reg.Dimensions, wantDims)
+	}
+	wantResources := []Field{
+		{Name: "СуммаЗаказов", Type: Type{Kind: TypeNumber, Digits: DefaultDigits, FractionDigits: DefaultFractionDigits, Unqualified: true}},
+		{Name: "Количество", Type: Type{Kind: TypeNumber, Digits: 15, FractionDigits: 3}},
+	}
+	if !reflect.DeepEqual(reg.Resources, wantResources) {
+		t.Errorf("resources = %+v, want %+v", reg.Resources, wantResources)
+	}
+}
+
+// TestWarnings проверяет, что точность по умолчанию не подставляется молча
+func TestWarnings(t *testing.T) {
+	config, err := Read(fstest.MapFS{
+		"Catalogs/Контрагенты.xml":                                   {Data: []byte(catalog)},
+		"AccumulationRegisters/ЗаказыПоКонтрагентам/Description.xml": {Data: []byte(register)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"РегистрНакопления.ЗаказыПоКонтрагентам: resource СуммаЗаказов has no number qualifiers; assumed Number(15,2)",
+	}
+	if got := config.Warnings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Warnings() = %q, want %q", got, want)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		err  string
+	}{
+		{
+			name: "missing reference",
+			fsys: fstest.MapFS{"AccumulationRegisters/ЗаказыПоКонтрагентам/Description.xml": {Data: []byte(register)}},
+			err:  "refers to missing CatalogRef.Контрагенты",
+		},
+		{
+			name: "kind in another directory",
+			fsys: fstest.MapFS{"Documents/Контрагенты.xml": {Data: []byte(catalog)}},
+			err:  "Справочник.Контрагенты in Documents",
+		},
+		{
+			name: "composite type",
+			fsys: fstest.MapFS{"Catalogs/Склады.xml": {Data: []byte(strings.Replace(catalog,
+				"<v8:Type>xs:string</v8:Type>", "<v8:Type>xs:string</v8:Type><v8:Type>xs:decimal</v8:Type>", 1))}},
+			err: "composite types are not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := Read(tt.fsys)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package metadata читает выгрузку конфигурации 1С в XML и строит по ней
+// промежуточное представление (IR) прикладных объектов: справочников,
+// документов с табличными частями и регистров накопления, их реквизитов и
+// типов. По IR пакет scaffold генерирует модели, миграции и репозитории
+// OrderFlow.
+//
+// Поддерживаются два вида выгрузки: выгрузка конфигуратора в файлы
+// («Конфигурация → Выгрузить конфигурацию в файлы», Catalogs/Склады.xml) и
+// упрощенное описание Catalogs/Склады/Description.xml из docs/sample3.txt.
+// Модули, формы и макеты не читаются.
+package metadata
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind — вид объекта конфигурации
+type Kind string
+
+const (
+	KindCatalog              Kind = "Catalog"
+	KindDocument             Kind = "Document"
+	KindAccumulationRegister Kind = "AccumulationRegister"
+	// KindEnum — перечисление; встречается только в типах реквизитов
+	KindEnum Kind = "Enum"
+)
+
+// kindNames — русские имена видов объектов в полных именах и типах ссылок
+var kindNames = map[Kind]string{
+	KindCatalog:              "Справочник",
+	KindDocument:             "Документ",
+	KindAccumulationRegister: "РегистрНакопления",
+	KindEnum:                 "Перечисление",
+}
+
+// Russian возвращает русское имя вида, например «Справочник»
+func (k Kind) Russian() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return string(k)
+}
+
+// ParseKind распознает вид объекта по английскому или русскому имени
+func ParseKind(s string) (Kind, bool) {
+	for kind, name := range kindNames {
+		if strings.EqualFold(s, string(kind)) || strings.EqualFold(s, name) {
+			return kind, true
+		}
+	}
+	return "", false
+}
+
+// Configuration — объекты конфигурации в порядке чтения выгрузки
+type Configuration struct {
+	Objects []*Object
+}
+
+// Lookup ищет объект по виду и имени; имена в 1С сравниваются без учета регистра
+func (c *Configuration) Lookup(kind Kind, name string) *Object {
+	for _, o := range c.Objects {
+		if o.Kind == kind && strings.EqualFold(o.Name, name) {
+			return o
+		}
+	}
+	return nil
+}
+
+// Object — справочник, документ или регистр накопления
+type Object struct {
+	Kind    Kind
+	Name    string
+	Synonym string
+	UUID    string
+	// Attributes — реквизиты, включая стандартные (Наименование, Код, Номер,
+	// Дата), если они есть у объекта
+	Attributes      []Field
+	TabularSections []TabularSection
+	// Dimensions и Resources — измерения и ресурсы регистра
+	Dimensions []Field
+	Resources  []Field
+	// Balance — регистр остатков (у движений есть вид Приход/Расход);
+	// иначе регистр оборотов
+	Balance bool
+	// Registers — регистры, по которым документ формирует движения
+	Registers []string
+}
+
+// FullName возвращает полное имя объекта, например «Справочник.Склады»
+func (o *Object) FullName() string {
+	return o.Kind.Russian() + "." + o.Name
+}
+
+// Title возвращает синоним объекта или, если его нет, имя
+func (o *Object) Title() string {
+	if o.Synonym != "" {
+		return o.Synonym
+	}
+	return o.Name
+}
+
+// ParseFullName разбирает полное имя объекта: «Справочник.Склады»,
+// «Catalog.Склады» или «РегистрНакопления.ЗаказыПоКонтрагентам»
+func ParseFullName(s string) (Kind, string, error) {
+	prefix, name, ok := strings.Cut(strings.TrimSpace(s), ".")
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("metadata: %q is not a full object name like Справочник.Склады", s)
+	}
+	kind, ok := ParseKind(prefix)
+	if !ok || kind == KindEnum {
+		return "", "", fmt.Errorf("metadata: unsupported object kind %q", prefix)
+	}
+	return kind, name, nil
+}
+
+// Стандартные реквизиты, которые 1С создает сама
+const (
+	AttributeDescription = "Наименование"
+	AttributeCode        = "Код"
+	AttributeNumber      = "Номер"
+	AttributeDate        = "Дата"
+)
+
+// Field — реквизит, реквизит табличной части, измерение или ресурс
+type Field struct {
+	Name    string
+	Synonym string
+	Type    Type
+	// Standard — стандартный реквизит объекта (см. Attribute*)
+	Standard bool
+}
+
+// TabularSection — табличная часть документа или справочника
+type TabularSection struct {
+	Name    string
+	Synonym string
+	Fields  []Field
+}
+
+// TypeKind — примитивный тип 1С или ссылка
+type TypeKind string
+
+const (
+	TypeString  TypeKind = "String"
+	TypeNumber  TypeKind = "Number"
+	TypeDate    TypeKind = "Date"
+	TypeBoolean TypeKind = "Boolean"
+	TypeRef     TypeKind = "Ref"
+)
+
+// Type — тип значения реквизита. Составные типы не поддерживаются.
+type Type struct {
+	Kind TypeKind
+	// Length — длина строки; 0 — строка неограниченной длины
+	Length int
+	// Digits и FractionDigits — длина и точность числа
+	Digits         int
+	FractionDigits int
+	// DateOnly — дата без времени (квалификатор «Дата»)
+	DateOnly bool
+	// Unqualified — у числа нет квалификаторов, длина и точность взяты по
+	// умолчанию (DefaultDigits, DefaultFractionDigits)
+	Unqualified bool
+	// RefKind и RefName — объект, на который ссылается реквизит типа TypeRef
+	RefKind Kind
+	RefName string
+}
+
+// String возвращает тип в записи 1С, например «CatalogRef.Склады» или «Number(15,2)»
+func (t Type) String() string {
+	switch t.Kind {
+	case TypeString:
+		if t.Length == 0 {
+			return "String"
+		}
+		return fmt.Sprintf("String(%d)", t.Length)
+	case TypeNumber:
+		return fmt.Sprintf("Number(%d,%d)", t.Digits, t.FractionDigits)
+	case TypeDate:
+		if t.DateOnly {
+			return "Date"
+		}
+		return "DateTime"
+	case TypeRef:
+		return string(t.RefKind) + "Ref." + t.RefName
+	}
+	return string(t.Kind)
+}
@@ -0,0 +1,175 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/1C-Migration-Lab/OrderFlow/migrations"
+	_ "github.com/lib/pq"
+)
+
+// migrateDSNEnv — строка подключения к пустой базе Postgres для проверки
+// применения и отката всех миграций. Тест удаляет схему, поэтому база
+// нужна отдельная; база с примененными миграциями не используется.
+const migrateDSNEnv = "ORDERFLOW_TEST_MIGRATE_DSN"
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000010_b.up.sql":   {Data: []byte("UP 10")},
+		"000010_b.down.sql": {Data: []byte("DOWN 10")},
+		"000002_a.up.sql":   {Data: []byte("UP 2")},
+		"000002_a.down.sql": {Data: []byte("DOWN 2")},
+		"000003_c.up.sql":   {Data: []byte("UP 3")},
+		"README.md":         {Data: []byte("not a migration")},
+		"000004_d.sql":      {Data: []byte("no direction")},
+	}
+	got, err := load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Migration{
+		{Version: 2, Name: "a", Up: "UP 2", Down: "DOWN 2"},
+		{Version: 3, Name: "c", Up: "UP 3"},
+		{Version: 10, Name: "b", Up: "UP 10", Down: "DOWN 10"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("load = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("migration %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if latest, err := Latest(fsys); err != nil || latest != 10 {
+		t.Errorf("Latest = %d, %v; want 10", latest, err)
+	}
+	if latest, err := Latest(fstest.MapFS{}); err != nil || latest != 0 {
+		t.Errorf("Latest without migrations = %d, %v; want 0", latest, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		err  string
+	}{
+		{"down without up", fstest.MapFS{"000001_a.down.sql": {Data: []byte("DOWN")}}, "has no up file"},
+		{"conflicting names", fstest.MapFS{
+			"000001_a.up.sql":   {Data: []byte("UP")},
+			"000001_b.down.sql": {Data: []byte("DOWN")},
+		}, "conflicting names"},
+	}
+	for _, tt := range tests {
+		_, err := load(tt.fsys)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+// schemaObjects находит в SQL создаваемые (create) или удаляемые таблицы,
+// типы и столбцы
+var schemaObjects = map[string]*regexp.Regexp{
+	"create": regexp.MustCompile(`(?i)\b(?:CREATE (TABLE|TYPE)|ADD (COLUMN))(?: IF NOT EXISTS)? (\w+)`),
+	"drop":   regexp.MustCompile(`(?i)\bDROP (TABLE|TYPE|COLUMN)(?: IF EXISTS)? ([\w, ]+)`),
+}
+
+// TestMigrations проверяет встроенные миграции: версии идут подряд с 1, у
+// каждой есть откат, и откат удаляет созданные таблицы, типы и столбцы
+func TestMigrations(t *testing.T) {
+	all, err := load(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+
+	for i, m := range all {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %d_%s: want version %d, versions must have no gaps", m.Version, m.Name, i+1)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+			continue
+		}
+
+		dropped := map[string]bool{}
+		for _, match := range schemaObjects["drop"].FindAllStringSubmatch(m.Down, -1) {
+			// DROP TABLE a, b CASCADE; — имена до CASCADE через запятую
+			for _, name := range strings.Split(match[2], ",") {
+				if fields := strings.Fields(name); len(fields) > 0 {
+					dropped[strings.ToUpper(match[1])+" "+strings.ToLower(fields[0])] = true
+				}
+			}
+		}
+		for _, match := range schemaObjects["create"].FindAllStringSubmatch(m.Up, -1) {
+			kind := strings.ToUpper(match[1] + match[2])
+			if key := kind + " " + strings.ToLower(match[3]); !dropped[key] {
+				t.Errorf("migration %d_%s: down does not drop %s", m.Version, m.Name, strings.ToLower(key))
+			}
+		}
+	}
+}
+
+// TestRoundTrip применяет все миграции, откатывает их и применяет снова.
+// Миграции и Migrator используют возможности Postgres (advisory-блокировки,
+// RLS, PL/pgSQL), поэтому тест выполняется только на Postgres.
+func TestRoundTrip(t *testing.T) {
+	dsn := os.Getenv(migrateDSNEnv)
+	if dsn == "" {
+		t.Skip(migrateDSNEnv + " is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	m, err := New(db, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != len(m.migrations) {
+		t.Fatalf("database has applied migrations; %s must point to an empty database", migrateDSNEnv)
+	}
+
+	for _, step := range []string{"up", "down", "up again"} {
+		var done []Migration
+		if step == "down" {
+			done, err = m.Down(ctx, len(m.migrations))
+		} else {
+			done, err = m.Up(ctx)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+		if len(done) != len(m.migrations) {
+			t.Errorf("%s: %d of %d migrations", step, len(done), len(m.migrations))
+		}
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			t.Errorf("migration %d_%s is not applied after the round trip", s.Version, s.Name)
+		}
+	}
+	if _, err := m.Down(ctx, len(m.migrations)); err != nil {
+		t.Errorf("clean up: %v", err)
+	}
+}
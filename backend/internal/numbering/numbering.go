@@ -0,0 +1,97 @@
+// Package numbering описывает автоматическую нумерацию документов, как в 1С:
+// номер состоит из префикса и дополненного нулями порядкового номера, а
+// последовательность начинается заново в каждом периоде (год, квартал, месяц).
+// Сами счетчики хранятся в репозиториях, см. таблицу number_sequences.
+package numbering
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reset задает периодичность сброса нумерации
+type Reset string
+
+const (
+	ResetNever   Reset = "never"
+	ResetYear    Reset = "year"
+	ResetQuarter Reset = "quarter"
+	ResetMonth   Reset = "month"
+)
+
+// DocumentOrder — ключ последовательности номеров заказов
+const DocumentOrder = "order"
+
+// Format — правило нумерации документа.
+//
+// Префикс может содержать подстановки периода: {YYYY}, {YY}, {Q}, {MM}.
+// Поскольку номер заказа уникален, при сбросе нумерации префикс обязан
+// включать период сброса, иначе номера разных периодов совпадут.
+type Format struct {
+	Prefix string `yaml:"prefix" toml:"prefix"`
+	Width  int    `yaml:"width" toml:"width"`
+	Reset  Reset  `yaml:"reset" toml:"reset"`
+}
+
+// DefaultOrderFormat — нумерация заказов по умолчанию: ORD-2024-000001
+var DefaultOrderFormat = Format{Prefix: "ORD-{YYYY}-", Width: 6, Reset: ResetYear}
+
+// Validate проверяет правило нумерации
+func (f Format) Validate() error {
+	var errs []error
+
+	if f.Width < 1 || f.Width > 18 {
+		errs = append(errs, fmt.Errorf("width must be between 1 and 18, got %d", f.Width))
+	}
+
+	hasYear := strings.Contains(f.Prefix, "{YYYY}") || strings.Contains(f.Prefix, "{YY}")
+	switch f.Reset {
+	case ResetNever:
+	case ResetYear:
+		if !hasYear {
+			errs = append(errs, errors.New("prefix must contain {YYYY} or {YY} with yearly reset"))
+		}
+	case ResetQuarter:
+		if !hasYear || !strings.Contains(f.Prefix, "{Q}") {
+			errs = append(errs, errors.New("prefix must contain the year and {Q} with quarterly reset"))
+		}
+	case ResetMonth:
+		if !hasYear || !strings.Contains(f.Prefix, "{MM}") {
+			errs = append(errs, errors.New("prefix must contain the year and {MM} with monthly reset"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown reset %q, expected never, year, quarter or month", f.Reset))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Period возвращает начало периода нумерации, которому принадлежит дата t.
+// Без сброса все документы относятся к одному периоду с нулевой датой.
+func (f Format) Period(t time.Time) time.Time {
+	y, m, _ := t.Date()
+	switch f.Reset {
+	case ResetYear:
+		return time.Date(y, time.January, 1, 0, 0, 0, 0, time.UTC)
+	case ResetQuarter:
+		return time.Date(y, m-(m-1)%3, 1, 0, 0, 0, 0, time.UTC)
+	case ResetMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Time{}
+}
+
+// Number формирует номер документа с датой t и порядковым номером seq
+func (f Format) Number(t time.Time, seq int64) string {
+	y, m, _ := t.Date()
+	prefix := strings.NewReplacer(
+		"{YYYY}", fmt.Sprintf("%04d", y),
+		"{YY}", fmt.Sprintf("%02d", y%100),
+		"{Q}", strconv.Itoa((int(m)-1)/3+1),
+		"{MM}", fmt.Sprintf("%02d", int(m)),
+	).Replace(f.Prefix)
+	return fmt.Sprintf("%s%0*d", prefix, f.Width, seq)
+}
@@ -0,0 +1,131 @@
+package numbering
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 12, 0, 0, 0, time.UTC)
+}
+
+// month возвращает начало месяца, как Period
+func month(y int, m time.Month) time.Time {
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		prefix string
+		width  int
+		at     time.Time
+		seq    int64
+		want   string
+	}{
+		{"ORD-{YYYY}-", 6, date(2024, time.March, 5), 1, "ORD-2024-000001"},
+		{"{YY}/", 3, date(2009, time.December, 31), 42, "09/042"},
+		{"ЗК-{YYYY}-{Q}-", 4, date(2024, time.August, 1), 7, "ЗК-2024-3-0007"},
+		{"{YYYY}{MM}-", 2, date(2024, time.February, 29), 5, "202402-05"},
+		{"{YYYY}.{MM}.{Q}.{YY}-", 1, date(2025, time.October, 10), 9, "2025.10.4.25-9"},
+		{"T-", 3, date(2024, time.January, 1), 1234, "T-1234"},
+		{"", 1, date(2024, time.January, 1), 3, "3"},
+		{"{DD}-{yyyy}-", 2, date(2024, time.January, 1), 1, "{DD}-{yyyy}-01"},
+	}
+	for _, tt := range tests {
+		f := Format{Prefix: tt.prefix, Width: tt.width, Reset: ResetNever}
+		if got := f.Number(tt.at, tt.seq); got != tt.want {
+			t.Errorf("Number(%q, width %d, %s, %d) = %q, want %q", tt.prefix, tt.width, tt.at.Format("2006-01-02"), tt.seq, got, tt.want)
+		}
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	tests := []struct {
+		reset Reset
+		at    time.Time
+		want  time.Time
+	}{
+		{ResetNever, date(2024, time.May, 5), time.Time{}},
+		{ResetYear, date(2024, time.December, 31), month(2024, time.January)},
+		{ResetQuarter, date(2024, time.March, 31), month(2024, time.January)},
+		{ResetQuarter, date(2024, time.April, 1), month(2024, time.April)},
+		{ResetQuarter, date(2024, time.December, 15), month(2024, time.October)},
+		{ResetMonth, date(2024, time.February, 29), month(2024, time.February)},
+		// Период считается по дате документа в его часовом поясе
+		{ResetYear, time.Date(2025, time.January, 1, 0, 30, 0, 0, msk), month(2025, time.January)},
+	}
+	for _, tt := range tests {
+		if got := (Format{Reset: tt.reset}).Period(tt.at); !got.Equal(tt.want) {
+			t.Errorf("%s: Period(%s) = %s, want %s", tt.reset, tt.at, got, tt.want)
+		}
+	}
+}
+
+// TestSequence нумерует документы по порядку дат так же, как репозитории:
+// счетчик ведется для каждого периода Period и начинается с 1
+func TestSequence(t *testing.T) {
+	dates := []time.Time{
+		date(2023, time.December, 31), date(2024, time.January, 1), date(2024, time.January, 31),
+		date(2024, time.February, 1), date(2024, time.March, 31), date(2024, time.April, 1),
+		date(2024, time.April, 2), date(2025, time.January, 1),
+	}
+	tests := []struct {
+		format Format
+		want   []string
+	}{
+		{Format{Prefix: "N-", Width: 2, Reset: ResetNever},
+			[]string{"N-01", "N-02", "N-03", "N-04", "N-05", "N-06", "N-07", "N-08"}},
+		{Format{Prefix: "{YYYY}-", Width: 2, Reset: ResetYear},
+			[]string{"2023-01", "2024-01", "2024-02", "2024-03", "2024-04", "2024-05", "2024-06", "2025-01"}},
+		{Format{Prefix: "{YY}{Q}-", Width: 2, Reset: ResetQuarter},
+			[]string{"234-01", "241-01", "241-02", "241-03", "241-04", "242-01", "242-02", "251-01"}},
+		{Format{Prefix: "{YYYY}{MM}-", Width: 2, Reset: ResetMonth},
+			[]string{"202312-01", "202401-01", "202401-02", "202402-01", "202403-01", "202404-01", "202404-02", "202501-01"}},
+	}
+	for _, tt := range tests {
+		if err := tt.format.Validate(); err != nil {
+			t.Fatalf("%s: %v", tt.format.Reset, err)
+		}
+		counters := map[time.Time]int64{}
+		var got []string
+		for _, d := range dates {
+			period := tt.format.Period(d)
+			counters[period]++
+			got = append(got, tt.format.Number(d, counters[period]))
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: numbers %v, want %v", tt.format.Reset, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		format Format
+		err    string
+	}{
+		{DefaultOrderFormat, ""},
+		{Format{Prefix: "T-", Width: 3, Reset: ResetNever}, ""},
+		{Format{Prefix: "{YY}-{Q}-", Width: 18, Reset: ResetQuarter}, ""},
+		{Format{Prefix: "{YYYY}{MM}", Width: 1, Reset: ResetMonth}, ""},
+		{Format{Prefix: "T-", Width: 0, Reset: ResetNever}, "width must be between 1 and 18"},
+		{Format{Prefix: "T-", Width: 19, Reset: ResetNever}, "width must be between 1 and 18"},
+		// Без периода в префиксе номера разных периодов совпали бы
+		{Format{Prefix: "T-", Width: 6, Reset: ResetYear}, "{YYYY} or {YY}"},
+		{Format{Prefix: "{YYYY}-", Width: 6, Reset: ResetQuarter}, "{Q}"},
+		{Format{Prefix: "{Q}-", Width: 6, Reset: ResetQuarter}, "the year and {Q}"},
+		{Format{Prefix: "{YYYY}-", Width: 6, Reset: ResetMonth}, "{MM}"},
+		{Format{Prefix: "T-", Width: 6, Reset: "week"}, `unknown reset "week"`},
+	}
+	for _, tt := range tests {
+		err := tt.format.Validate()
+		if tt.err == "" && err != nil {
+			t.Errorf("%+v: %v", tt.format, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%+v: err = %v, want %q", tt.format, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/lib/pq"
+)
+
+const apiKeyColumns = `id, name, prefix, key_hash, scopes, organization_id, rate_limit, expires_at, revoked_at, last_used_at, created_by, created_at`
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	query := `
+		INSERT INTO api_keys (name, prefix, key_hash, scopes, organization_id, rate_limit, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), key.OrganizationID, key.RateLimit, key.ExpiresAt, key.CreatedBy,
+	).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *apiKeyRepository) GetByID(ctx context.Context, id int64) (*models.APIKey, error) {
+	return r.get(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id)
+}
+
+func (r *apiKeyRepository) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	return r.get(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, hash)
+}
+
+func (r *apiKeyRepository) get(ctx context.Context, query string, arg interface{}) (*models.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, arg))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (r *apiKeyRepository) List(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, id int64, at time.Time) error {
+	query := `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $1) WHERE id = $2`
+	return r.exec(ctx, query, at, id)
+}
+
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, id int64, at time.Time) error {
+	query := `UPDATE api_keys SET last_used_at = $1 WHERE id = $2`
+	return r.exec(ctx, query, at, id)
+}
+
+func (r *apiKeyRepository) exec(ctx context.Context, query string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return translateError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// scanAPIKey читает строку с колонками apiKeyColumns
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	var scopes pq.StringArray
+	var expires, revoked, used sql.NullTime
+	err := row.Scan(
+		&key.ID, &key.Name, &key.Prefix, &key.KeyHash, &scopes, &key.OrganizationID, &key.RateLimit,
+		&expires, &revoked, &used, &key.CreatedBy, &key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	key.Scopes = append([]string{}, scopes...)
+	key.ExpiresAt, key.RevokedAt, key.LastUsedAt = nullTime(expires), nullTime(revoked), nullTime(used)
+	return key, nil
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -5,30 +5,41 @@ import (
 	"database/sql"
 
 	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
 )
 
 func (r *clientRepository) Create(ctx context.Context, client *models.Client) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO clients (name, inn)
-		VALUES ($1, $2)
-		RETURNING id`
+		INSERT INTO clients (organization_id, name, inn)
+		VALUES ($1, $2, $3)
+		RETURNING id, version`
 
-	err := r.db.QueryRowContext(ctx, query, client.Name, client.INN).Scan(&client.ID)
+	err = r.db.QueryRowContext(ctx, query, org, client.Name, client.INN).Scan(&client.ID, &client.Version)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
 	return nil
 }
 
 func (r *clientRepository) GetByID(ctx context.Context, id int64) (*models.Client, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT id, name, inn
+		SELECT id, name, inn, version
 		FROM clients
-		WHERE id = $1`
+		WHERE id = $1 AND organization_id = $2`
 
 	client := &models.Client{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&client.ID, &client.Name, &client.INN)
+	err = r.db.QueryRowContext(ctx, query, id, org).Scan(&client.ID, &client.Name, &client.INN, &client.Version)
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
 	}
@@ -39,39 +50,70 @@ func (r *clientRepository) GetByID(ctx context.Context, id int64) (*models.Clien
 	return client, nil
 }
 
-func (r *clientRepository) Update(ctx context.Context, client *models.Client) error {
+func (r *clientRepository) GetByINN(ctx context.Context, inn string) (*models.Client, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		UPDATE clients
-		SET name = $1, inn = $2
-		WHERE id = $3`
+		SELECT id, name, inn, version
+		FROM clients
+		WHERE organization_id = $1 AND inn = $2
+		ORDER BY id
+		LIMIT 1`
 
-	result, err := r.db.ExecContext(ctx, query, client.Name, client.INN, client.ID)
+	client := &models.Client{}
+	err = r.db.QueryRowContext(ctx, query, org, inn).Scan(&client.ID, &client.Name, &client.INN, &client.Version)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return client, nil
+}
+
+func (r *clientRepository) Update(ctx context.Context, client *models.Client) error {
+	org, err := tenant.ID(ctx)
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return ErrNotFound
+
+	query := `
+		UPDATE clients
+		SET name = $1, inn = $2, version = version + 1
+		WHERE id = $3 AND organization_id = $5 AND ($4::bigint = 0 OR version = $4)
+		RETURNING version`
+
+	err = r.db.QueryRowContext(ctx, query, client.Name, client.INN, client.ID, client.Version, org).Scan(&client.Version)
+	if err == sql.ErrNoRows {
+		return staleOrMissingIn(ctx, r.db, "clients", org, client.ID)
+	}
+	if err != nil {
+		return translateError(err)
 	}
 
 	return nil
 }
 
 func (r *clientRepository) Delete(ctx context.Context, id int64) error {
-	query := `DELETE FROM clients WHERE id = $1`
-
-	result, err := r.db.ExecContext(ctx, query, id)
+	org, err := tenant.ID(ctx)
 	if err != nil {
 		return err
 	}
 
+	query := `DELETE FROM clients WHERE id = $1 AND organization_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, id, org)
+	if err != nil {
+		return translateError(err)
+	}
+
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 	if rowsAffected == 0 {
 		return ErrNotFound
@@ -80,42 +122,106 @@ func (r *clientRepository) Delete(ctx context.Context, id int64) error {
 	return nil
 }
 
-func (r *clientRepository) GetAll(ctx context.Context) ([]models.Client, error) {
+func (r *clientRepository) List(ctx context.Context, q ClientQuery) (Page[models.Client], error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return Page[models.Client]{}, err
+	}
+
+	w := clientWhere(org, q)
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM clients"+w.sql(), w.args...).Scan(&total); err != nil {
+		return Page[models.Client]{}, err
+	}
+
+	paging, err := w.page(q.ListParams, clientSortColumns, "id")
+	if err != nil {
+		return Page[models.Client]{}, err
+	}
 	query := `
-		SELECT id, name, inn
-		FROM clients
-		ORDER BY name`
+		SELECT id, name, inn, version
+		FROM clients` + w.sql() + paging
 
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := r.db.QueryContext(ctx, query, w.args...)
 	if err != nil {
-		return nil, err
+		return Page[models.Client]{}, err
 	}
 	defer rows.Close()
 
 	var clients []models.Client
 	for rows.Next() {
 		var client models.Client
-		if err := rows.Scan(&client.ID, &client.Name, &client.INN); err != nil {
-			return nil, err
+		if err := rows.Scan(&client.ID, &client.Name, &client.INN, &client.Version); err != nil {
+			return Page[models.Client]{}, err
 		}
 		clients = append(clients, client)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return Page[models.Client]{}, err
 	}
 
-	return clients, nil
+	return NewPage(clients, total, q.ListParams, ClientSortValue, func(c models.Client) int64 { return c.ID }), nil
+}
+
+// clientWhere отбирает клиентов организации org по q
+func clientWhere(org int64, q ClientQuery) where {
+	var w where
+	w.add("organization_id = ?", org)
+	if q.Search != "" {
+		w.add("(name ILIKE ? OR inn ILIKE ?)", likePattern(q.Search), likePattern(q.Search))
+	}
+	return w
+}
+
+// Each читает отбор построчно и передает записи fn
+func (r *clientRepository) Each(ctx context.Context, q ClientQuery, fn func(models.Client) error) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := clientWhere(org, q)
+	order, err := orderBy(q.ListParams, clientSortColumns, "id")
+	if err != nil {
+		return err
+	}
+	query := `
+		SELECT id, name, inn, version
+		FROM clients` + w.sql() + order
+
+	rows, err := r.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var client models.Client
+		if err := rows.Scan(&client.ID, &client.Name, &client.INN, &client.Version); err != nil {
+			return err
+		}
+		if err := fn(client); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
 
 // GetClientOrders возвращает все заказы клиента
 func (r *clientRepository) GetClientOrders(ctx context.Context, clientID int64) ([]models.Order, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT o.id, o.client_id, o.date, o.number, o.total_amount, o.is_confirmed, o.created_at
+		SELECT o.id, o.client_id, o.date, o.number, o.total_amount, o.status, o.created_at, o.version
 		FROM orders o
-		WHERE o.client_id = $1`
+		WHERE o.client_id = $1 AND o.organization_id = $2`
 
-	rows, err := r.db.QueryContext(ctx, query, clientID)
+	rows, err := r.db.QueryContext(ctx, query, clientID, org)
 	if err != nil {
 		return nil, err
 	}
@@ -130,8 +236,9 @@ func (r *clientRepository) GetClientOrders(ctx context.Context, clientID int64)
 			&order.Date,
 			&order.Number,
 			&order.TotalAmount,
-			&order.IsConfirmed,
+			&order.Status,
 			&order.CreatedAt,
+			&order.Version,
 		)
 		if err != nil {
 			return nil, err
@@ -3,15 +3,21 @@ package repository
 
 import (
 	"database/sql"
+
+	"github.com/lib/pq"
 )
 
+// NewDB открывает пул соединений Postgres. Соединения передают базе
+// организацию и исполнителя из контекста каждого запроса (см. scopedConnector).
 func NewDB(connStr string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", connStr)
+	connector, err := pq.NewConnector(connStr)
 	if err != nil {
 		return nil, err
 	}
 
+	db := sql.OpenDB(scopedConnector{Connector: connector})
 	if err = db.Ping(); err != nil {
+		db.Close()
 		return nil, err
 	}
 
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Коды ошибок Postgres, см. https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+	pqCheckViolation      = "23514"
+	pqNotNullViolation    = "23502"
+	pqNumericOutOfRange   = "22003"
+	pqStringTooLong       = "22001"
+)
+
+// translateError переводит нарушения ограничений Postgres в ошибки репозитория.
+// Имя ограничения сохраняется в причине ошибки для журнала.
+func translateError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch pqErr.Code {
+	case pqUniqueViolation:
+		return ErrConflict.Wrap(err)
+	case pqForeignKeyViolation:
+		return ErrForeignKey.Wrap(err)
+	case pqCheckViolation, pqNotNullViolation, pqNumericOutOfRange, pqStringTooLong:
+		return ErrCheck.Wrap(err)
+	}
+	return err
+}
+
+// staleOrMissing объясняет, почему условное UPDATE не изменило запись:
+// запись удалена (ErrNotFound) или ее версия устарела (ErrVersionMismatch)
+func staleOrMissing(ctx context.Context, db DBTX, table string, id int64) error {
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM "+table+" WHERE id = $1)", id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrVersionMismatch
+}
+
+// staleOrMissingIn — staleOrMissing для таблиц учета: запись другой
+// организации считается отсутствующей
+func staleOrMissingIn(ctx context.Context, db DBTX, table string, org, id int64) error {
+	var exists bool
+	query := "SELECT EXISTS (SELECT 1 FROM " + table + " WHERE id = $1 AND organization_id = $2)"
+	if err := db.QueryRowContext(ctx, query, id, org).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrVersionMismatch
+}
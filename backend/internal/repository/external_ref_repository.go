@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+)
+
+func (r *externalRefRepository) Find(ctx context.Context, entity, system, externalID string) (int64, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		SELECT entity_id
+		FROM external_refs
+		WHERE organization_id = $1 AND entity = $2 AND system = $3 AND external_id = $4`
+
+	var id int64
+	err = r.db.QueryRowContext(ctx, query, org, entity, system, externalID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (r *externalRefRepository) List(ctx context.Context, entity, system string) (map[int64]string, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT entity_id, external_id
+		FROM external_refs
+		WHERE organization_id = $1 AND entity = $2 AND system = $3`
+
+	rows, err := r.db.QueryContext(ctx, query, org, entity, system)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refs := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var externalID string
+		if err := rows.Scan(&id, &externalID); err != nil {
+			return nil, err
+		}
+		refs[id] = externalID
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return refs, nil
+}
+
+func (r *externalRefRepository) ListByEntity(ctx context.Context, entity string, entityID int64) ([]models.ExternalRef, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT entity, entity_id, system, external_id, code, exported_version, created_at
+		FROM external_refs
+		WHERE organization_id = $1 AND entity = $2 AND entity_id = $3
+		ORDER BY system`
+
+	rows, err := r.db.QueryContext(ctx, query, org, entity, entityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refs := []models.ExternalRef{}
+	for rows.Next() {
+		var ref models.ExternalRef
+		if err := rows.Scan(&ref.Entity, &ref.EntityID, &ref.System, &ref.ExternalID, &ref.Code, &ref.ExportedVersion, &ref.CreatedAt); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return refs, nil
+}
+
+func (r *externalRefRepository) Put(ctx context.Context, ref *models.ExternalRef) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return inTx(ctx, r.db, func(tx DBTX) error {
+		// Удаляются другие идентификаторы записи и сопоставление
+		// идентификатора с другой записью; то же сопоставление остается
+		query := `
+			DELETE FROM external_refs
+			WHERE organization_id = $1 AND entity = $2 AND system = $3
+			  AND (entity_id = $4) <> (external_id = $5)`
+
+		if _, err := tx.ExecContext(ctx, query, org, ref.Entity, ref.System, ref.EntityID, ref.ExternalID); err != nil {
+			return err
+		}
+
+		query = `
+			INSERT INTO external_refs (organization_id, entity, system, external_id, entity_id, code)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (organization_id, entity, system, external_id) DO UPDATE SET code = EXCLUDED.code
+			RETURNING exported_version, created_at`
+
+		return tx.QueryRowContext(ctx, query, org, ref.Entity, ref.System, ref.ExternalID, ref.EntityID, ref.Code).Scan(&ref.ExportedVersion, &ref.CreatedAt)
+	})
+}
+
+func (r *externalRefRepository) Delete(ctx context.Context, entity string, entityID int64, system string) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		DELETE FROM external_refs
+		WHERE organization_id = $1 AND entity = $2 AND entity_id = $3 AND system = $4`
+
+	result, err := r.db.ExecContext(ctx, query, org, entity, entityID, system)
+	if err != nil {
+		return translateError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return translateError(err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *externalRefRepository) ListExported(ctx context.Context, entity, system string) (map[int64]int64, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT entity_id, exported_version
+		FROM external_refs
+		WHERE organization_id = $1 AND entity = $2 AND system = $3 AND exported_version > 0`
+
+	rows, err := r.db.QueryContext(ctx, query, org, entity, system)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[int64]int64{}
+	for rows.Next() {
+		var id, version int64
+		if err := rows.Scan(&id, &version); err != nil {
+			return nil, err
+		}
+		versions[id] = version
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+func (r *externalRefRepository) MarkExported(ctx context.Context, entity string, entityID int64, system string, version int64) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE external_refs
+		SET exported_version = $5
+		WHERE organization_id = $1 AND entity = $2 AND entity_id = $3 AND system = $4
+		  AND exported_version < $5`
+
+	_, err = r.db.ExecContext(ctx, query, org, entity, entityID, system, version)
+	return err
+}
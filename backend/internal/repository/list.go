@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+)
+
+// where собирает условия отбора списка с нумерованными параметрами Postgres.
+// В тексте условия параметры обозначаются "?" и нумеруются по порядку.
+type where struct {
+	conds []string
+	args  []interface{}
+}
+
+func (w *where) add(cond string, args ...interface{}) {
+	for _, arg := range args {
+		w.args = append(w.args, arg)
+		cond = strings.Replace(cond, "?", "$"+strconv.Itoa(len(w.args)), 1)
+	}
+	w.conds = append(w.conds, cond)
+}
+
+func (w *where) sql() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return "\n\t\tWHERE " + strings.Join(w.conds, " AND ")
+}
+
+// placeholders возвращает n параметров "?" через запятую для условия IN
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// statusArgs переводит статусы заказов в аргументы запроса
+func statusArgs(statuses []models.OrderStatus) []interface{} {
+	args := make([]interface{}, len(statuses))
+	for i, s := range statuses {
+		args[i] = string(s)
+	}
+	return args
+}
+
+// page добавляет условие курсора и возвращает ORDER BY, LIMIT и OFFSET страницы.
+// Запрашивается на одну запись больше, чтобы определить наличие следующей страницы.
+// Поле сортировки ищется в columns, поэтому в текст запроса попадают только известные столбцы.
+func (w *where) page(p ListParams, columns map[string]string, idColumn string) (string, error) {
+	column, ok := columns[p.Sort]
+	if !ok {
+		return "", fmt.Errorf("unknown sort key %q", p.Sort)
+	}
+
+	dir, cmp := "ASC", ">"
+	if p.Desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	offset := p.Offset
+	if p.Cursor != nil {
+		arg, err := p.Cursor.Arg()
+		if err != nil {
+			return "", err
+		}
+		w.add("("+column+", "+idColumn+") "+cmp+" (?, ?)", arg, p.Cursor.ID)
+		offset = 0
+	}
+
+	w.args = append(w.args, p.Limit+1, offset)
+	n := len(w.args)
+	return "\n\t\tORDER BY " + column + " " + dir + ", " + idColumn + " " + dir +
+		"\n\t\tLIMIT $" + strconv.Itoa(n-1) + " OFFSET $" + strconv.Itoa(n), nil
+}
+
+// orderBy возвращает ORDER BY по полю сортировки p без страницы — для выборок
+// всего отбора
+func orderBy(p ListParams, columns map[string]string, idColumn string) (string, error) {
+	column, ok := columns[p.Sort]
+	if !ok {
+		return "", fmt.Errorf("unknown sort key %q", p.Sort)
+	}
+	dir := "ASC"
+	if p.Desc {
+		dir = "DESC"
+	}
+	return "\n\t\tORDER BY " + column + " " + dir + ", " + idColumn + " " + dir, nil
+}
+
+// Столбцы сортировки списков по ключам ClientSortKeys, ProductSortKeys и OrderSortKeys
+var (
+	clientSortColumns  = map[string]string{"name": "name", "inn": "inn", "id": "id"}
+	productSortColumns = map[string]string{"name": "name", "unit": "unit", "id": "id"}
+	orderSortColumns   = map[string]string{
+		"created_at":   "o.created_at",
+		"date":         "o.date",
+		"number":       "o.number",
+		"total_amount": "o.total_amount",
+		"id":           "o.id",
+	}
+)
+
+// likePattern экранирует спецсимволы LIKE и ищет подстроку
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+	return "%" + s + "%"
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+type apiKeyRepository struct {
+	access
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	return r.write(func(d *dataset) error {
+		// Аналог ограничения UNIQUE (key_hash)
+		for _, k := range d.apiKeys {
+			if k.KeyHash == key.KeyHash {
+				return repository.ErrConflict
+			}
+		}
+		if _, ok := d.organizations[key.OrganizationID]; !ok {
+			return repository.ErrForeignKey
+		}
+		key.ID = d.nextID("api_keys")
+		key.CreatedAt = time.Now()
+		d.apiKeys[key.ID] = copyAPIKey(*key)
+		return nil
+	})
+}
+
+func (r *apiKeyRepository) GetByID(ctx context.Context, id int64) (*models.APIKey, error) {
+	return r.find(func(k models.APIKey) bool { return k.ID == id })
+}
+
+func (r *apiKeyRepository) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	return r.find(func(k models.APIKey) bool { return k.KeyHash == hash })
+}
+
+func (r *apiKeyRepository) find(match func(k models.APIKey) bool) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.read(func(d *dataset) error {
+		for _, k := range d.apiKeys {
+			if match(k) {
+				key = copyAPIKey(k)
+				return nil
+			}
+		}
+		return repository.ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) List(ctx context.Context) ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	err := r.read(func(d *dataset) error {
+		for _, k := range d.apiKeys {
+			keys = append(keys, copyAPIKey(k))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, id int64, at time.Time) error {
+	return r.update(id, func(k *models.APIKey) {
+		if k.RevokedAt == nil {
+			k.RevokedAt = &at
+		}
+	})
+}
+
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, id int64, at time.Time) error {
+	return r.update(id, func(k *models.APIKey) { k.LastUsedAt = &at })
+}
+
+func (r *apiKeyRepository) update(id int64, fn func(k *models.APIKey)) error {
+	return r.write(func(d *dataset) error {
+		k, ok := d.apiKeys[id]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		fn(&k)
+		d.apiKeys[id] = k
+		return nil
+	})
+}
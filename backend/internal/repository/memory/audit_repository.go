@@ -0,0 +1,34 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+)
+
+type auditRepository struct {
+	access
+}
+
+func (r *auditRepository) Append(ctx context.Context, entry *models.AuditEntry) error {
+	return r.writeLedger(ctx, func(d *ledger) error {
+		entry.ID = d.nextID("audit_log")
+		entry.CreatedAt = time.Now()
+		d.audit = append(d.audit, *entry)
+		return nil
+	})
+}
+
+func (r *auditRepository) List(ctx context.Context, entity string, entityID int64) ([]models.AuditEntry, error) {
+	entries := []models.AuditEntry{}
+	err := r.readLedger(ctx, func(d *ledger) error {
+		for _, e := range d.audit {
+			if e.Entity == entity && e.EntityID == entityID {
+				entries = append(entries, e)
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+type clientRepository struct {
+	access
+}
+
+func (r *clientRepository) Create(ctx context.Context, client *models.Client) error {
+	return r.writeLedger(ctx, func(d *ledger) error {
+		client.ID = d.nextID("clients")
+		client.Version = 1
+		d.clients[client.ID] = *client
+		return nil
+	})
+}
+
+func (r *clientRepository) GetByID(ctx context.Context, id int64) (*models.Client, error) {
+	var client models.Client
+	err := r.readLedger(ctx, func(d *ledger) error {
+		c, ok := d.clients[id]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		client = c
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *clientRepository) GetByINN(ctx context.Context, inn string) (*models.Client, error) {
+	var client *models.Client
+	err := r.readLedger(ctx, func(d *ledger) error {
+		for _, c := range d.clients {
+			if c.INN == inn && (client == nil || c.ID < client.ID) {
+				c := c
+				client = &c
+			}
+		}
+		if client == nil {
+			return repository.ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (r *clientRepository) Update(ctx context.Context, client *models.Client) error {
+	return r.writeLedger(ctx, func(d *ledger) error {
+		current, ok := d.clients[client.ID]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		if client.Version != 0 && client.Version != current.Version {
+			return repository.ErrVersionMismatch
+		}
+		client.Version = current.Version + 1
+		d.clients[client.ID] = *client
+		return nil
+	})
+}
+
+func (r *clientRepository) Delete(ctx context.Context, id int64) error {
+	return r.writeLedger(ctx, func(d *ledger) error {
+		if _, ok := d.clients[id]; !ok {
+			return repository.ErrNotFound
+		}
+		// Аналог ограничений fk_client и внешних ключей регистра
+		for _, o := range d.orders {
+			if o.ClientID == id {
+				return repository.ErrForeignKey
+			}
+		}
+		for k := range d.totals {
+			if k.clientID == id {
+				return repository.ErrForeignKey
+			}
+		}
+		delete(d.clients, id)
+		return nil
+	})
+}
+
+func (r *clientRepository) List(ctx context.Context, q repository.ClientQuery) (repository.Page[models.Client], error) {
+	var clients []models.Client
+	err := r.readLedger(ctx, func(d *ledger) error {
+		for _, c := range d.clients {
+			if !matchClient(c, q) {
+				continue
+			}
+			clients = append(clients, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return repository.Page[models.Client]{}, err
+	}
+
+	return paginate(clients, q.ListParams, clientSorter)
+}
+
+// Each копирует отбор под блокировкой и передает записи fn уже без нее,
+// чтобы медленный получатель не задерживал запись в хранилище
+func (r *clientRepository) Each(ctx context.Context, q repository.ClientQuery, fn func(models.Client) error) error {
+	var clients []models.Client
+	err := r.readLedger(ctx, func(d *ledger) error {
+		for _, c := range d.clients {
+			if !matchClient(c, q) {
+				continue
+			}
+			clients = append(clients, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return each(clients, q.ListParams, clientSorter, fn)
+}
+
+// matchClient проверяет клиента на соответствие отбору запроса
+func matchClient(c models.Client, q repository.ClientQuery) bool {
+	return q.Search == "" || contains(c.Name, q.Search) || contains(c.INN, q.Search)
+}
+
+// GetClientOrders возвращает все заказы клиента
+func (r *clientRepository) GetClientOrders(ctx context.Context, clientID int64) ([]models.Order, error) {
+	var orders []models.Order
+	err := r.readLedger(ctx, func(d *ledger) error {
+		for _, o := range d.orders {
+			if o.ClientID == clientID {
+				orders = append(orders, orderHeader(o))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+	return orders, nil
+}
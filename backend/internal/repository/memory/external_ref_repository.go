@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+type externalRefRepository struct {
+	access
+}
+
+func (r *externalRefRepository) Find(ctx context.Context, entity, system, externalID string) (int64, error) {
+	var id int64
+	err := r.readLedger(ctx, func(d *ledger) error {
+		ref, ok := d.refs[refKey{entity, system, externalID}]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		id = ref.EntityID
+		return nil
+	})
+	return id, err
+}
+
+func (r *externalRefRepository) List(ctx context.Context, entity, system string) (map[int64]string, error) {
+	refs := map[int64]string{}
+	err := r.readLedger(ctx, func(d *ledger) error {
+		for k, ref := range d.refs {
+			if k.entity == entity && k.system == system {
+				refs[ref.EntityID] = ref.ExternalID
+			}
+		}
+		return nil
+	})
+	return refs, err
+}
+
+func (r *externalRefRepository) ListByEntity(ctx context.Context, entity string, entityID int64) ([]models.ExternalRef, error) {
+	refs := []models.ExternalRef{}
+	err := r.readLedger(ctx, func(d *ledger) error {
+		for _, ref := range d.refs {
+			if ref.Entity == entity && ref.EntityID == entityID {
+				refs = append(refs, ref)
+			}
+		}
+		return nil
+	})
+	sort.Slice(refs, func(i, j int) bool { return refs[i].System < refs[j].System })
+	return refs, err
+}
+
+func (r *externalRefRepository) Put(ctx context.Context, ref *models.ExternalRef) error {
+	return r.writeLedger(ctx, func(d *ledger) error {
+		key := refKey{ref.Entity, ref.System, ref.ExternalID}
+		for k, v := range d.refs {
+			if k.entity == ref.Entity && k.system == ref.System && v.EntityID == ref.EntityID && k != key {
+				delete(d.refs, k)
+			}
+		}
+		ref.CreatedAt = time.Now()
+		ref.ExportedVersion = 0
+		if current, ok := d.refs[key]; ok && current.EntityID == ref.EntityID {
+			ref.CreatedAt = current.CreatedAt
+			ref.ExportedVersion = current.ExportedVersion
+		}
+		d.refs[key] = *ref
+		return nil
+	})
+}
+
+func (r *externalRefRepository) Delete(ctx context.Context, entity string, entityID int64, system string) error {
+	return r.writeLedger(ctx, func(d *ledger) error {
+		for k, v := range d.refs {
+			if k.entity == entity && k.system == system && v.EntityID == entityID {
+				delete(d.refs, k)
+				return nil
+			}
+		}
+		return repository.ErrNotFound
+	})
+}
+
+func (r *externalRefRepository) ListExported(ctx context.Context, entity, system string) (map[int64]int64, error) {
+	versions := map[int64]int64{}
+	err := r.readLedger(ctx, func(d *ledger) error {
+		for k, ref := range d.refs {
+			if k.entity == entity && k.system == system && ref.ExportedVersion > 0 {
+				versions[ref.EntityID] = ref.ExportedVersion
+			}
+		}
+		return nil
+	})
+	return versions, err
+}
+
+func (r *externalRefRepository) MarkExported(ctx context.Context, entity string, entityID int64, system string, version int64) error {
+	return r.writeLedger(ctx, func(d *ledger) error {
+		for k, ref := range d.refs {
+			if k.entity == entity && k.system == system && ref.EntityID == entityID && ref.ExportedVersion < version {
+				ref.ExportedVersion = version
+				d.refs[k] = ref
+			}
+		}
+		return nil
+	})
+}
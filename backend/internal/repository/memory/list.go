@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+// sorter сравнивает записи по ключу сортировки и строит запись-образец из курсора
+type sorter[T any] struct {
+	compare    func(a, b T, key string) int
+	fromCursor func(c *repository.Cursor) (T, error)
+	sortValue  func(T, string) string
+	id         func(T) int64
+	keys       []string
+}
+
+// paginate упорядочивает отобранные записи и вырезает страницу, как ORDER BY,
+// условие курсора, LIMIT и OFFSET в реализациях на SQL
+func paginate[T any](items []T, p repository.ListParams, s sorter[T]) (repository.Page[T], error) {
+	if !slices.Contains(s.keys, p.Sort) {
+		return repository.Page[T]{}, fmt.Errorf("unknown sort key %q", p.Sort)
+	}
+
+	less := func(a, b T) int {
+		c := s.compare(a, b, p.Sort)
+		if c == 0 {
+			c = cmp.Compare(s.id(a), s.id(b))
+		}
+		if p.Desc {
+			c = -c
+		}
+		return c
+	}
+	slices.SortFunc(items, less)
+	total := int64(len(items))
+
+	start := p.Offset
+	if p.Cursor != nil {
+		probe, err := s.fromCursor(p.Cursor)
+		if err != nil {
+			return repository.Page[T]{}, err
+		}
+		start, _ = slices.BinarySearchFunc(items, probe, func(item, probe T) int {
+			if less(item, probe) <= 0 {
+				return -1
+			}
+			return 1
+		})
+	}
+	start = min(start, len(items))
+	end := min(start+p.Limit+1, len(items))
+
+	return repository.NewPage(items[start:end], total, p, s.sortValue, s.id), nil
+}
+
+// each упорядочивает отобранные записи, как paginate, и передает их fn
+func each[T any](items []T, p repository.ListParams, s sorter[T], fn func(T) error) error {
+	p.Limit, p.Offset, p.Cursor = len(items), 0, nil
+	page, err := paginate(items, p, s)
+	if err != nil {
+		return err
+	}
+	for _, item := range page.Items {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// contains ищет подстроку без учета регистра, как ILIKE в Postgres
+func contains(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+var clientSorter = sorter[models.Client]{
+	compare: func(a, b models.Client, key string) int {
+		switch key {
+		case "name":
+			return strings.Compare(a.Name, b.Name)
+		case "inn":
+			return strings.Compare(a.INN, b.INN)
+		}
+		return 0
+	},
+	fromCursor: func(c *repository.Cursor) (models.Client, error) {
+		return models.Client{ID: c.ID, Name: c.Value, INN: c.Value}, nil
+	},
+	sortValue: repository.ClientSortValue,
+	id:        func(c models.Client) int64 { return c.ID },
+	keys:      repository.ClientSortKeys,
+}
+
+var productSorter = sorter[models.Product]{
+	compare: func(a, b models.Product, key string) int {
+		switch key {
+		case "name":
+			return strings.Compare(a.Name, b.Name)
+		case "unit":
+			return strings.Compare(a.Unit, b.Unit)
+		}
+		return 0
+	},
+	fromCursor: func(c *repository.Cursor) (models.Product, error) {
+		return models.Product{ID: c.ID, Name: c.Value, Unit: c.Value}, nil
+	},
+	sortValue: repository.ProductSortValue,
+	id:        func(p models.Product) int64 { return p.ID },
+	keys:      repository.ProductSortKeys,
+}
+
+var orderSorter = sorter[models.Order]{
+	compare: func(a, b models.Order, key string) int {
+		switch key {
+		case "created_at":
+			return a.CreatedAt.Compare(b.CreatedAt)
+		case "date":
+			return a.Date.Compare(b.Date)
+		case "number":
+			return strings.Compare(a.Number, b.Number)
+		case "total_amount":
+			return cmp.Compare(a.TotalAmount, b.TotalAmount)
+		}
+		return 0
+	},
+	fromCursor: func(c *repository.Cursor) (models.Order, error) {
+		o := models.Order{ID: c.ID, Number: c.Value}
+		arg, err := c.Arg()
+		if err != nil {
+			return o, err
+		}
+		switch v := arg.(type) {
+		case time.Time:
+			o.CreatedAt, o.Date = v, v
+		case money.Money:
+			o.TotalAmount = v
+		}
+		return o, nil
+	},
+	sortValue: repository.OrderSortValue,
+	id:        func(o models.Order) int64 { return o.ID },
+	keys:      repository.OrderSortKeys,
+}
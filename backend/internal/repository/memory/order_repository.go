@@ -0,0 +1,280 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/numbering"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+type orderRepository struct {
+	access
+}
+
+// nextNumber выделяет очередной номер заказа, пропуская номера,
+// уже занятые заказами с введенным вручную номером
+func (r *orderRepository) nextNumber(d *ledger, date time.Time) string {
+	format := r.st.orderNumbers
+	key := numberKey{document: numbering.DocumentOrder, period: format.Period(date)}
+	for {
+		d.numbers[key]++
+		number := format.Number(date, d.numbers[key])
+		if !numberTaken(d, number) {
+			return number
+		}
+	}
+}
+
+func numberTaken(d *ledger, number string) bool {
+	for _, o := range d.orders {
+		if o.Number == number {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *orderRepository) Create(ctx context.Context, order *models.Order, items []models.OrderItem) error {
+	return r.writeLedger(ctx, func(d *ledger) error {
+		if err := checkOrder(d, 0, order, items); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if order.Number == "" {
+			order.Number = r.nextNumber(d, now)
+		}
+		order.ID = d.nextID("orders")
+		order.Date = now
+		order.CreatedAt = now
+		order.Status = models.OrderStatusDraft
+		order.Version = 1
+
+		storeOrder(d, order, items)
+		return nil
+	})
+}
+
+func (r *orderRepository) GetByID(ctx context.Context, id int64) (*models.Order, error) {
+	var order models.Order
+	err := r.readLedger(ctx, func(d *ledger) error {
+		o, ok := d.orders[id]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		order = copyOrder(o)
+		order.Client = d.clients[o.ClientID]
+		for i := range order.Items {
+			order.Items[i].Product = d.products[order.Items[i].ProductID]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// GetForUpdate в памяти совпадает с GetByID: транзакция UnitOfWork
+// и так держит эксклюзивную блокировку хранилища
+func (r *orderRepository) GetForUpdate(ctx context.Context, id int64) (*models.Order, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *orderRepository) Update(ctx context.Context, order *models.Order, items []models.OrderItem) error {
+	return r.writeLedger(ctx, func(d *ledger) error {
+		current, ok := d.orders[order.ID]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		if order.Version != 0 && order.Version != current.Version {
+			return repository.ErrVersionMismatch
+		}
+		if current.Status != models.OrderStatusDraft {
+			return repository.ErrStateConflict
+		}
+		if err := checkOrder(d, order.ID, order, items); err != nil {
+			return err
+		}
+
+		order.Date = current.Date
+		order.CreatedAt = current.CreatedAt
+		order.Status = current.Status
+		order.Version = current.Version + 1
+
+		storeOrder(d, order, items)
+		return nil
+	})
+}
+
+func (r *orderRepository) Delete(ctx context.Context, id int64) error {
+	return r.writeLedger(ctx, func(d *ledger) error {
+		if _, ok := d.orders[id]; !ok {
+			return repository.ErrNotFound
+		}
+		delete(d.orders, id)
+
+		// ON DELETE CASCADE для движений регистра и истории
+		movements := d.movements[:0]
+		for _, m := range d.movements {
+			if m.RecorderID != id {
+				movements = append(movements, m)
+			}
+		}
+		d.movements = movements
+
+		history := d.history[:0]
+		for _, h := range d.history {
+			if h.OrderID != id {
+				history = append(history, h)
+			}
+		}
+		d.history = history
+		return nil
+	})
+}
+
+func (r *orderRepository) List(ctx context.Context, q repository.OrderQuery) (repository.Page[models.Order], error) {
+	var orders []models.Order
+	err := r.readLedger(ctx, func(d *ledger) error {
+		for _, o := range d.orders {
+			if !matchOrder(o, q) {
+				continue
+			}
+			order := orderHeader(o)
+			order.Client = d.clients[o.ClientID]
+			orders = append(orders, order)
+		}
+		return nil
+	})
+	if err != nil {
+		return repository.Page[models.Order]{}, err
+	}
+
+	return paginate(orders, q.ListParams, orderSorter)
+}
+
+// Each копирует заказы отбора с позициями под блокировкой и передает их fn уже без нее
+func (r *orderRepository) Each(ctx context.Context, q repository.OrderQuery, fn func(models.Order) error) error {
+	var orders []models.Order
+	err := r.readLedger(ctx, func(d *ledger) error {
+		for _, o := range d.orders {
+			if !matchOrder(o, q) {
+				continue
+			}
+			order := copyOrder(o)
+			order.Client = d.clients[o.ClientID]
+			for i := range order.Items {
+				order.Items[i].Product = d.products[order.Items[i].ProductID]
+			}
+			orders = append(orders, order)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return each(orders, q.ListParams, orderSorter, fn)
+}
+
+// matchOrder проверяет заказ на соответствие отбору запроса
+func matchOrder(o models.Order, q repository.OrderQuery) bool {
+	switch {
+	case q.ClientID != 0 && o.ClientID != q.ClientID,
+		!q.From.IsZero() && o.Date.Before(q.From),
+		!q.To.IsZero() && o.Date.After(q.To),
+		len(q.Statuses) > 0 && !slices.Contains(q.Statuses, o.Status),
+		q.MinAmount != nil && o.TotalAmount < *q.MinAmount,
+		q.MaxAmount != nil && o.TotalAmount > *q.MaxAmount:
+		return false
+	}
+	return true
+}
+
+func (r *orderRepository) SetStatus(ctx context.Context, change *models.OrderHistory) error {
+	return r.writeLedger(ctx, func(d *ledger) error {
+		o, ok := d.orders[change.OrderID]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		if o.Status != change.FromStatus {
+			return repository.ErrStateConflict
+		}
+
+		o.Status = change.ToStatus
+		o.Version++
+		d.orders[o.ID] = o
+
+		change.ID = d.nextID("order_history")
+		change.CreatedAt = time.Now()
+		d.history = append(d.history, *change)
+		return nil
+	})
+}
+
+func (r *orderRepository) GetHistory(ctx context.Context, id int64) ([]models.OrderHistory, error) {
+	var history []models.OrderHistory
+	err := r.readLedger(ctx, func(d *ledger) error {
+		for _, h := range d.history {
+			if h.OrderID == id {
+				history = append(history, h)
+			}
+		}
+		return nil
+	})
+	return history, err
+}
+
+// checkOrder проверяет внешние ключи и уникальность номера, как ограничения
+// схемы Postgres, и рассчитывает суммы заказа: сумма, которая не помещается
+// в DECIMAL(15,2), дает ошибку до изменения данных
+func checkOrder(d *ledger, id int64, order *models.Order, items []models.OrderItem) error {
+	if _, ok := d.clients[order.ClientID]; !ok {
+		return repository.ErrForeignKey
+	}
+	for _, o := range d.orders {
+		if o.ID != id && o.Number == order.Number {
+			return repository.ErrConflict
+		}
+	}
+	for _, item := range items {
+		if _, ok := d.products[item.ProductID]; !ok {
+			return repository.ErrForeignKey
+		}
+		if item.Quantity < 0 || item.Price < 0 {
+			return repository.ErrCheck
+		}
+	}
+	total, err := models.CalculateAmounts(items)
+	if err != nil {
+		return err
+	}
+	order.TotalAmount = total
+	return nil
+}
+
+// storeOrder сохраняет заказ с позициями и суммами, рассчитанными checkOrder
+func storeOrder(d *ledger, order *models.Order, items []models.OrderItem) {
+	for i := range items {
+		items[i].ID = d.nextID("order_items")
+		items[i].OrderID = order.ID
+	}
+
+	stored := *order
+	stored.Client = models.Client{}
+	stored.Items = make([]models.OrderItem, len(items))
+	for i, item := range items {
+		item.Product = models.Product{}
+		stored.Items[i] = item
+	}
+	d.orders[order.ID] = stored
+}
+
+// orderHeader возвращает шапку заказа без позиций
+func orderHeader(o models.Order) models.Order {
+	o.Items = nil
+	return o
+}
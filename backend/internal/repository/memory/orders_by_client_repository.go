@@ -0,0 +1,216 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+type ordersByClientRepository struct {
+	access
+}
+
+func (r *ordersByClientRepository) GetByID(ctx context.Context, clientID int64) (*models.OrdersByClient, error) {
+	var result *models.OrdersByClient
+	err := r.readLedger(ctx, func(d *ledger) error {
+		balances := balancesFrom(d, func(k totalKey) bool { return k.clientID == clientID }, nil)
+		if len(balances) == 0 {
+			return repository.ErrNotFound
+		}
+		result = &balances[0]
+		return nil
+	})
+	return result, err
+}
+
+func (r *ordersByClientRepository) GetAll(ctx context.Context) ([]models.OrdersByClient, error) {
+	var results []models.OrdersByClient
+	err := r.readLedger(ctx, func(d *ledger) error {
+		results = balancesFrom(d, func(totalKey) bool { return true }, nil)
+		return nil
+	})
+	return results, err
+}
+
+// GetMovements возвращает движения регистра, отобранные по регистратору, клиенту и периоду
+func (r *ordersByClientRepository) GetMovements(ctx context.Context, filter repository.MovementFilter) ([]models.OrdersByClientMovement, error) {
+	var movements []models.OrdersByClientMovement
+	err := r.readLedger(ctx, func(d *ledger) error {
+		for _, m := range d.movements {
+			if filter.RecorderID != 0 && m.RecorderID != filter.RecorderID {
+				continue
+			}
+			if filter.ClientID != 0 && m.ClientID != filter.ClientID {
+				continue
+			}
+			if !filter.From.IsZero() && m.Period.Before(filter.From) {
+				continue
+			}
+			if !filter.To.IsZero() && m.Period.After(filter.To) {
+				continue
+			}
+			movements = append(movements, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(movements, func(i, j int) bool {
+		a, b := movements[i], movements[j]
+		if !a.Period.Equal(b.Period) {
+			return a.Period.Before(b.Period)
+		}
+		if a.RecorderID != b.RecorderID {
+			return a.RecorderID < b.RecorderID
+		}
+		return a.LineNumber < b.LineNumber
+	})
+	return movements, nil
+}
+
+// GetBalance возвращает остатки регистра на момент времени at: итоги за месяцы
+// до at плюс движения текущего месяца до at включительно
+func (r *ordersByClientRepository) GetBalance(ctx context.Context, at time.Time) ([]models.OrdersByClient, error) {
+	month := monthStart(at)
+	var results []models.OrdersByClient
+	err := r.readLedger(ctx, func(d *ledger) error {
+		var current []models.OrdersByClientMovement
+		for _, m := range d.movements {
+			if !m.Period.Before(month) && !m.Period.After(at) {
+				current = append(current, m)
+			}
+		}
+		results = balancesFrom(d, func(k totalKey) bool { return k.period.Before(month) }, current)
+		return nil
+	})
+	return results, err
+}
+
+// GetTurnovers возвращает обороты регистра по клиентам за период [from, to]
+func (r *ordersByClientRepository) GetTurnovers(ctx context.Context, from, to time.Time) ([]models.OrdersByClientTurnover, error) {
+	var turnovers []models.OrdersByClientTurnover
+	err := r.readLedger(ctx, func(d *ledger) error {
+		byClient := make(map[int64]*models.OrdersByClientTurnover)
+		for _, m := range d.movements {
+			if m.Period.Before(from) || m.Period.After(to) {
+				continue
+			}
+			t, ok := byClient[m.ClientID]
+			if !ok {
+				t = &models.OrdersByClientTurnover{ClientID: m.ClientID, Client: d.clients[m.ClientID]}
+				byClient[m.ClientID] = t
+			}
+			if m.RecordKind == models.MovementReceipt {
+				t.Receipt += m.Amount
+			} else {
+				t.Expense += m.Amount
+			}
+		}
+		for _, t := range byClient {
+			t.Turnover = t.Receipt - t.Expense
+			turnovers = append(turnovers, *t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(turnovers, func(i, j int) bool { return turnovers[i].Client.Name < turnovers[j].Client.Name })
+	return turnovers, nil
+}
+
+func (r *ordersByClientRepository) WriteMovements(ctx context.Context, recorderID int64, movements []models.OrdersByClientMovement) error {
+	return r.writeLedger(ctx, func(d *ledger) error {
+		if _, ok := d.orders[recorderID]; !ok {
+			return repository.ErrForeignKey
+		}
+		for _, m := range movements {
+			if _, ok := d.clients[m.ClientID]; !ok {
+				return repository.ErrForeignKey
+			}
+		}
+
+		deleteMovements(d, recorderID)
+		for i := range movements {
+			m := &movements[i]
+			m.RecorderID = recorderID
+			if m.LineNumber == 0 {
+				m.LineNumber = i + 1
+			}
+			d.movements = append(d.movements, *m)
+			addTotal(d, *m, 1)
+		}
+		return nil
+	})
+}
+
+func (r *ordersByClientRepository) DeleteMovements(ctx context.Context, recorderID int64) error {
+	return r.writeLedger(ctx, func(d *ledger) error {
+		deleteMovements(d, recorderID)
+		return nil
+	})
+}
+
+// deleteMovements удаляет движения регистратора и вычитает их из итогов
+func deleteMovements(d *ledger, recorderID int64) {
+	kept := d.movements[:0]
+	for _, m := range d.movements {
+		if m.RecorderID == recorderID {
+			addTotal(d, m, -1)
+			continue
+		}
+		kept = append(kept, m)
+	}
+	d.movements = kept
+}
+
+// addTotal изменяет месячный итог регистра на знаковую сумму движения
+func addTotal(d *ledger, m models.OrdersByClientMovement, sign money.Money) {
+	amount := m.Amount
+	if m.RecordKind == models.MovementExpense {
+		amount = -amount
+	}
+	key := totalKey{period: monthStart(m.Period), clientID: m.ClientID}
+	d.totals[key] += sign * amount
+}
+
+// balancesFrom суммирует отобранные итоги и дополнительные движения по клиентам.
+// Результат упорядочен по убыванию суммы, как в реализации на Postgres.
+func balancesFrom(d *ledger, include func(totalKey) bool, movements []models.OrdersByClientMovement) []models.OrdersByClient {
+	sums := make(map[int64]money.Money)
+	for k, v := range d.totals {
+		if include(k) {
+			sums[k.clientID] += v
+		}
+	}
+	for _, m := range movements {
+		if m.RecordKind == models.MovementExpense {
+			sums[m.ClientID] -= m.Amount
+		} else {
+			sums[m.ClientID] += m.Amount
+		}
+	}
+
+	results := make([]models.OrdersByClient, 0, len(sums))
+	for clientID, sum := range sums {
+		results = append(results, models.OrdersByClient{
+			ClientID:  clientID,
+			Client:    d.clients[clientID],
+			OrdersSum: sum,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].OrdersSum != results[j].OrdersSum {
+			return results[i].OrdersSum > results[j].OrdersSum
+		}
+		return results[i].ClientID < results[j].ClientID
+	})
+	return results
+}
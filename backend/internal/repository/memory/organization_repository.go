@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+type organizationRepository struct {
+	access
+}
+
+func (r *organizationRepository) Create(ctx context.Context, org *models.Organization) error {
+	return r.write(func(d *dataset) error {
+		// Аналог уникального индекса idx_organizations_code
+		for _, o := range d.organizations {
+			if strings.EqualFold(o.Code, org.Code) {
+				return repository.ErrConflict
+			}
+		}
+		org.ID = d.nextID("organizations")
+		org.CreatedAt = time.Now()
+		d.organizations[org.ID] = *org
+		return nil
+	})
+}
+
+func (r *organizationRepository) GetByID(ctx context.Context, id int64) (*models.Organization, error) {
+	return r.find(func(o models.Organization) bool { return o.ID == id })
+}
+
+func (r *organizationRepository) GetByCode(ctx context.Context, code string) (*models.Organization, error) {
+	return r.find(func(o models.Organization) bool { return strings.EqualFold(o.Code, code) })
+}
+
+func (r *organizationRepository) find(match func(o models.Organization) bool) (*models.Organization, error) {
+	var org models.Organization
+	err := r.read(func(d *dataset) error {
+		for _, o := range d.organizations {
+			if match(o) {
+				org = o
+				return nil
+			}
+		}
+		return repository.ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (r *organizationRepository) List(ctx context.Context) ([]models.Organization, error) {
+	orgs := []models.Organization{}
+	err := r.read(func(d *dataset) error {
+		for _, o := range d.organizations {
+			orgs = append(orgs, o)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].ID < orgs[j].ID })
+	return orgs, nil
+}
@@ -0,0 +1,154 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+type productRepository struct {
+	access
+}
+
+func (r *productRepository) Create(ctx context.Context, product *models.Product) error {
+	return r.writeLedger(ctx, func(d *ledger) error {
+		product.ID = d.nextID("products")
+		product.Version = 1
+		d.products[product.ID] = *product
+		return nil
+	})
+}
+
+func (r *productRepository) GetByID(ctx context.Context, id int64) (*models.Product, error) {
+	var product models.Product
+	err := r.readLedger(ctx, func(d *ledger) error {
+		p, ok := d.products[id]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		product = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+func (r *productRepository) GetByNameUnit(ctx context.Context, name, unit string) (*models.Product, error) {
+	var product *models.Product
+	err := r.readLedger(ctx, func(d *ledger) error {
+		for _, p := range d.products {
+			if p.Name == name && p.Unit == unit && (product == nil || p.ID < product.ID) {
+				p := p
+				product = &p
+			}
+		}
+		if product == nil {
+			return repository.ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+func (r *productRepository) Update(ctx context.Context, product *models.Product) error {
+	return r.writeLedger(ctx, func(d *ledger) error {
+		current, ok := d.products[product.ID]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		if product.Version != 0 && product.Version != current.Version {
+			return repository.ErrVersionMismatch
+		}
+		product.Version = current.Version + 1
+		d.products[product.ID] = *product
+		return nil
+	})
+}
+
+func (r *productRepository) Delete(ctx context.Context, id int64) error {
+	return r.writeLedger(ctx, func(d *ledger) error {
+		if _, ok := d.products[id]; !ok {
+			return repository.ErrNotFound
+		}
+		// Аналог ограничения fk_product
+		for _, o := range d.orders {
+			for _, item := range o.Items {
+				if item.ProductID == id {
+					return repository.ErrForeignKey
+				}
+			}
+		}
+		delete(d.products, id)
+		return nil
+	})
+}
+
+func (r *productRepository) List(ctx context.Context, q repository.ProductQuery) (repository.Page[models.Product], error) {
+	var products []models.Product
+	err := r.readLedger(ctx, func(d *ledger) error {
+		for _, p := range d.products {
+			if !matchProduct(p, q) {
+				continue
+			}
+			products = append(products, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return repository.Page[models.Product]{}, err
+	}
+
+	return paginate(products, q.ListParams, productSorter)
+}
+
+// Each копирует отбор под блокировкой и передает записи fn уже без нее
+func (r *productRepository) Each(ctx context.Context, q repository.ProductQuery, fn func(models.Product) error) error {
+	var products []models.Product
+	err := r.readLedger(ctx, func(d *ledger) error {
+		for _, p := range d.products {
+			if !matchProduct(p, q) {
+				continue
+			}
+			products = append(products, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return each(products, q.ListParams, productSorter, fn)
+}
+
+// matchProduct проверяет товар на соответствие отбору запроса
+func matchProduct(p models.Product, q repository.ProductQuery) bool {
+	return q.Search == "" || contains(p.Name, q.Search)
+}
+
+// GetProductOrderItems возвращает все позиции заказов, где используется товар
+func (r *productRepository) GetProductOrderItems(ctx context.Context, productID int64) ([]models.OrderItem, error) {
+	var items []models.OrderItem
+	err := r.readLedger(ctx, func(d *ledger) error {
+		for _, o := range d.orders {
+			for _, item := range o.Items {
+				if item.ProductID == productID {
+					item.Product = models.Product{}
+					items = append(items, item)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items, nil
+}
@@ -0,0 +1,300 @@
+// Package memory реализует интерфейсы репозиториев поверх структур в памяти.
+// Используется в тестах и в демонстрационном режиме сервера (STORAGE=memory).
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/numbering"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+)
+
+// dataset хранит все таблицы: общие для развертывания (организации,
+// пользователи, ключи API) и учетные данные организаций в ledgers.
+type dataset struct {
+	organizations map[int64]models.Organization
+	users         map[int64]models.User
+	apiKeys       map[int64]models.APIKey
+	ledgers       map[int64]*ledger
+	seq           map[string]int64
+}
+
+// ledger хранит учетные данные одной организации. Заказы хранятся вместе
+// с позициями. Репозитории учета видят только ledger организации из
+// контекста, поэтому записи других организаций им недоступны.
+type ledger struct {
+	clients   map[int64]models.Client
+	products  map[int64]models.Product
+	orders    map[int64]models.Order
+	movements []models.OrdersByClientMovement
+	totals    map[totalKey]money.Money
+	history   []models.OrderHistory
+	audit     []models.AuditEntry
+	refs      map[refKey]models.ExternalRef
+	// numbers — счетчики автоматической нумерации документов, как number_sequences
+	numbers map[numberKey]int64
+	// seq — последовательности dataset: идентификаторы, как и в Postgres,
+	// уникальны во всех организациях
+	seq map[string]int64
+}
+
+type numberKey struct {
+	document string
+	period   time.Time
+}
+
+// refKey — внешний идентификатор, как первичный ключ external_refs
+type refKey struct {
+	entity, system, externalID string
+}
+
+type totalKey struct {
+	period   time.Time
+	clientID int64
+}
+
+func newDataset() *dataset {
+	d := &dataset{
+		organizations: make(map[int64]models.Organization),
+		users:         make(map[int64]models.User),
+		apiKeys:       make(map[int64]models.APIKey),
+		ledgers:       make(map[int64]*ledger),
+		seq:           make(map[string]int64),
+	}
+	d.seq["organizations"] = tenant.Default
+	d.organizations[tenant.Default] = models.Organization{
+		ID: tenant.Default, Code: "default", Name: "Default organization", CreatedAt: time.Now(),
+	}
+	return d
+}
+
+func newLedger(seq map[string]int64) *ledger {
+	return &ledger{
+		clients:  make(map[int64]models.Client),
+		products: make(map[int64]models.Product),
+		orders:   make(map[int64]models.Order),
+		totals:   make(map[totalKey]money.Money),
+		refs:     make(map[refKey]models.ExternalRef),
+		numbers:  make(map[numberKey]int64),
+		seq:      seq,
+	}
+}
+
+func (d *dataset) clone() *dataset {
+	c := &dataset{
+		organizations: make(map[int64]models.Organization),
+		users:         make(map[int64]models.User),
+		apiKeys:       make(map[int64]models.APIKey),
+		ledgers:       make(map[int64]*ledger),
+		seq:           make(map[string]int64),
+	}
+	for k, v := range d.organizations {
+		c.organizations[k] = v
+	}
+	for k, v := range d.users {
+		c.users[k] = copyUser(v)
+	}
+	for k, v := range d.apiKeys {
+		c.apiKeys[k] = copyAPIKey(v)
+	}
+	for k, v := range d.seq {
+		c.seq[k] = v
+	}
+	for k, v := range d.ledgers {
+		c.ledgers[k] = v.clone(c.seq)
+	}
+	return c
+}
+
+func (l *ledger) clone(seq map[string]int64) *ledger {
+	c := newLedger(seq)
+	for k, v := range l.clients {
+		c.clients[k] = v
+	}
+	for k, v := range l.products {
+		c.products[k] = v
+	}
+	for k, v := range l.orders {
+		c.orders[k] = copyOrder(v)
+	}
+	c.movements = append(c.movements, l.movements...)
+	for k, v := range l.totals {
+		c.totals[k] = v
+	}
+	c.history = append(c.history, l.history...)
+	c.audit = append(c.audit, l.audit...)
+	for k, v := range l.refs {
+		c.refs[k] = v
+	}
+	for k, v := range l.numbers {
+		c.numbers[k] = v
+	}
+	return c
+}
+
+// nextID возвращает следующее значение последовательности таблицы, как SERIAL в Postgres
+func (d *dataset) nextID(table string) int64 {
+	d.seq[table]++
+	return d.seq[table]
+}
+
+func (l *ledger) nextID(table string) int64 {
+	l.seq[table]++
+	return l.seq[table]
+}
+
+// store защищает dataset; все репозитории одного хранилища разделяют его
+type store struct {
+	mu   sync.RWMutex
+	data *dataset
+	// orderNumbers задает нумерацию заказов, создаваемых без номера
+	orderNumbers numbering.Format
+}
+
+// access дает репозиторию доступ к данным. Внутри транзакции UnitOfWork
+// блокировка уже захвачена, поэтому access не блокирует повторно.
+type access struct {
+	st   *store
+	inTx bool
+}
+
+func (a access) read(fn func(d *dataset) error) error {
+	if !a.inTx {
+		a.st.mu.RLock()
+		defer a.st.mu.RUnlock()
+	}
+	return fn(a.st.data)
+}
+
+func (a access) write(fn func(d *dataset) error) error {
+	if !a.inTx {
+		a.st.mu.Lock()
+		defer a.st.mu.Unlock()
+	}
+	return fn(a.st.data)
+}
+
+// readLedger дает доступ к данным организации из ctx. У организации без
+// данных читается пустой ledger.
+func (a access) readLedger(ctx context.Context, fn func(l *ledger) error) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+	return a.read(func(d *dataset) error {
+		l, ok := d.ledgers[org]
+		if !ok {
+			l = newLedger(nil)
+		}
+		return fn(l)
+	})
+}
+
+// writeLedger дает доступ на изменение к данным организации из ctx.
+// Данные несуществующей организации не создаются, как и внешний ключ
+// organization_id в Postgres.
+func (a access) writeLedger(ctx context.Context, fn func(l *ledger) error) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+	return a.write(func(d *dataset) error {
+		l, ok := d.ledgers[org]
+		if !ok {
+			if _, ok := d.organizations[org]; !ok {
+				return repository.ErrForeignKey
+			}
+			l = newLedger(d.seq)
+			d.ledgers[org] = l
+		}
+		return fn(l)
+	})
+}
+
+// NewRepositories создает пустое хранилище в памяти и репозитории над ним
+func NewRepositories(orderNumbers numbering.Format) *repository.Repositories {
+	st := &store{data: newDataset(), orderNumbers: orderNumbers}
+	repos := newRepositories(access{st: st})
+	repos.UnitOfWork = &unitOfWork{st: st}
+	return repos
+}
+
+func newRepositories(a access) *repository.Repositories {
+	return &repository.Repositories{
+		Client:         &clientRepository{a},
+		Product:        &productRepository{a},
+		Order:          &orderRepository{a},
+		OrdersByClient: &ordersByClientRepository{a},
+		Audit:          &auditRepository{a},
+		User:           &userRepository{a},
+		APIKey:         &apiKeyRepository{a},
+		Organization:   &organizationRepository{a},
+		ExternalRef:    &externalRefRepository{a},
+	}
+}
+
+// unitOfWork выполняет транзакцию под эксклюзивной блокировкой хранилища.
+// При ошибке данные восстанавливаются из снимка, сделанного перед началом.
+type unitOfWork struct {
+	st *store
+}
+
+func (u *unitOfWork) Do(ctx context.Context, fn func(repos *repository.Repositories) error) error {
+	u.st.mu.Lock()
+	defer u.st.mu.Unlock()
+
+	snapshot := u.st.data.clone()
+	repos := newRepositories(access{st: u.st, inTx: true})
+	repos.UnitOfWork = &nestedUnitOfWork{repos: repos}
+
+	if err := fn(repos); err != nil {
+		u.st.data = snapshot
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		u.st.data = snapshot
+		return err
+	}
+
+	return nil
+}
+
+// nestedUnitOfWork выполняет вложенный Do в уже открытой транзакции
+type nestedUnitOfWork struct {
+	repos *repository.Repositories
+}
+
+func (u *nestedUnitOfWork) Do(ctx context.Context, fn func(repos *repository.Repositories) error) error {
+	return fn(u.repos)
+}
+
+func copyOrder(o models.Order) models.Order {
+	if o.Items != nil {
+		o.Items = append([]models.OrderItem(nil), o.Items...)
+	}
+	return o
+}
+
+func copyUser(u models.User) models.User {
+	u.Roles = append([]string{}, u.Roles...)
+	if u.OrganizationID != nil {
+		org := *u.OrganizationID
+		u.OrganizationID = &org
+	}
+	return u
+}
+
+func copyAPIKey(k models.APIKey) models.APIKey {
+	k.Scopes = append([]string{}, k.Scopes...)
+	return k
+}
+
+// monthStart возвращает начало месяца, к которому относятся итоги регистра
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+type userRepository struct {
+	access
+}
+
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	return r.write(func(d *dataset) error {
+		if loginTaken(d, user.Login, 0) {
+			return repository.ErrConflict
+		}
+		if !userOrganizationExists(d, user) {
+			return repository.ErrForeignKey
+		}
+		user.ID = d.nextID("users")
+		user.Version = 1
+		user.CreatedAt = time.Now()
+		d.users[user.ID] = copyUser(*user)
+		return nil
+	})
+}
+
+func (r *userRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	var user models.User
+	err := r.read(func(d *dataset) error {
+		u, ok := d.users[id]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		user = copyUser(u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) GetByLogin(ctx context.Context, login string) (*models.User, error) {
+	var user models.User
+	err := r.read(func(d *dataset) error {
+		for _, u := range d.users {
+			if strings.EqualFold(u.Login, login) {
+				user = copyUser(u)
+				return nil
+			}
+		}
+		return repository.ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) List(ctx context.Context) ([]models.User, error) {
+	users := []models.User{}
+	err := r.read(func(d *dataset) error {
+		for _, u := range d.users {
+			users = append(users, copyUser(u))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].Login < users[j].Login })
+	return users, nil
+}
+
+func (r *userRepository) Update(ctx context.Context, user *models.User) error {
+	return r.write(func(d *dataset) error {
+		current, ok := d.users[user.ID]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		if user.Version != 0 && user.Version != current.Version {
+			return repository.ErrVersionMismatch
+		}
+		// Аналог уникального индекса idx_users_login
+		if loginTaken(d, user.Login, user.ID) {
+			return repository.ErrConflict
+		}
+		if !userOrganizationExists(d, user) {
+			return repository.ErrForeignKey
+		}
+		user.Version = current.Version + 1
+		user.CreatedAt = current.CreatedAt
+		d.users[user.ID] = copyUser(*user)
+		return nil
+	})
+}
+
+// userOrganizationExists — аналог внешнего ключа users.organization_id
+func userOrganizationExists(d *dataset, user *models.User) bool {
+	if user.OrganizationID == nil {
+		return true
+	}
+	_, ok := d.organizations[*user.OrganizationID]
+	return ok
+}
+
+func loginTaken(d *dataset, login string, exceptID int64) bool {
+	for id, u := range d.users {
+		if id != exceptID && strings.EqualFold(u.Login, login) {
+			return true
+		}
+	}
+	return false
+}
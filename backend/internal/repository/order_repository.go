@@ -3,81 +3,115 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/numbering"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
 )
 
 func (r *orderRepository) Create(ctx context.Context, order *models.Order, items []models.OrderItem) error {
-	tx, err := r.db.BeginTx(ctx, nil)
+	org, err := tenant.ID(ctx)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
 
-	// Создаем заказ
-	query := `
-		INSERT INTO orders (client_id, date, number, total_amount, is_confirmed)
-		VALUES ($1, CURRENT_TIMESTAMP, $2, 0, false)
-		RETURNING id, date, created_at`
-
-	err = tx.QueryRowContext(ctx, query, order.ClientID, order.Number).
-		Scan(&order.ID, &order.Date, &order.CreatedAt)
-	if err != nil {
-		return err
-	}
+	return inTx(ctx, r.db, func(tx DBTX) error {
+		// Пустой номер назначается автоматически по дате документа. CURRENT_TIMESTAMP
+		// постоянен в пределах транзакции, поэтому совпадает с датой в INSERT ниже.
+		if order.Number == "" {
+			var now time.Time
+			if err := tx.QueryRowContext(ctx, "SELECT CURRENT_TIMESTAMP::timestamp").Scan(&now); err != nil {
+				return err
+			}
+			number, err := r.nextNumber(ctx, tx, org, now)
+			if err != nil {
+				return err
+			}
+			order.Number = number
+		}
 
-	// Создаем позиции заказа
-	for i := range items {
-		items[i].OrderID = order.ID
-		query = `
-			INSERT INTO order_items (order_id, product_id, quantity, price)
-			VALUES ($1, $2, $3, $4)
-			RETURNING id, line_amount`
+		// Создаем заказ
+		query := `
+			INSERT INTO orders (organization_id, client_id, date, number, total_amount, status)
+			VALUES ($1, $2, CURRENT_TIMESTAMP, $3, 0, $4)
+			RETURNING id, date, created_at, version`
 
-		err = tx.QueryRowContext(ctx, query,
-			items[i].OrderID,
-			items[i].ProductID,
-			items[i].Quantity,
-			items[i].Price,
-		).Scan(&items[i].ID, &items[i].LineAmount)
+		order.Status = models.OrderStatusDraft
+		err := tx.QueryRowContext(ctx, query, org, order.ClientID, order.Number, order.Status).
+			Scan(&order.ID, &order.Date, &order.CreatedAt, &order.Version)
 		if err != nil {
 			return err
 		}
-	}
 
-	// Обновляем общую сумму заказа
-	query = `
-		UPDATE orders
-		SET total_amount = (
-			SELECT COALESCE(SUM(line_amount), 0)
-			FROM order_items
-			WHERE order_id = $1
-		)
-		WHERE id = $1
-		RETURNING total_amount`
+		return insertOrderItems(ctx, tx, org, order, items)
+	})
+}
 
-	err = tx.QueryRowContext(ctx, query, order.ID).Scan(&order.TotalAmount)
-	if err != nil {
-		return err
-	}
+// nextNumber выделяет очередной номер заказа. Счетчик периода увеличивается
+// одним UPSERT, который блокирует строку последовательности до конца транзакции,
+// поэтому параллельные транзакции разных экземпляров сервера получают разные номера.
+// Номера, уже занятые заказами с введенным вручную номером, пропускаются.
+// Каждая организация нумерует заказы независимо.
+func (r *orderRepository) nextNumber(ctx context.Context, tx DBTX, org int64, date time.Time) (string, error) {
+	query := `
+		INSERT INTO number_sequences (organization_id, document, period, last_value)
+		VALUES ($1, $2, $3, 1)
+		ON CONFLICT (organization_id, document, period) DO UPDATE
+		SET last_value = number_sequences.last_value + 1
+		RETURNING last_value`
+
+	for {
+		var seq int64
+		if err := tx.QueryRowContext(ctx, query, org, numbering.DocumentOrder, r.numbers.Period(date)).Scan(&seq); err != nil {
+			return "", err
+		}
 
-	return tx.Commit()
+		number := r.numbers.Number(date, seq)
+		var taken bool
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM orders WHERE organization_id = $1 AND number = $2)", org, number).
+			Scan(&taken)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return number, nil
+		}
+	}
 }
 
 func (r *orderRepository) GetByID(ctx context.Context, id int64) (*models.Order, error) {
+	return r.getByID(ctx, id, false)
+}
+
+// GetForUpdate возвращает заказ, блокируя его строку до конца транзакции
+func (r *orderRepository) GetForUpdate(ctx context.Context, id int64) (*models.Order, error) {
+	return r.getByID(ctx, id, true)
+}
+
+func (r *orderRepository) getByID(ctx context.Context, id int64, forUpdate bool) (*models.Order, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	// Получаем заказ
 	query := `
-		SELECT o.id, o.client_id, o.date, o.number, o.total_amount, o.is_confirmed, o.created_at,
-			   c.id, c.name, c.inn
+		SELECT o.id, o.client_id, o.date, o.number, o.total_amount, o.status, o.created_at, o.version,
+			   c.id, c.name, c.inn, c.version
 		FROM orders o
 		JOIN clients c ON c.id = o.client_id
-		WHERE o.id = $1`
+		WHERE o.id = $1 AND o.organization_id = $2`
+	if forUpdate {
+		query += `
+		FOR UPDATE OF o`
+	}
 
 	order := &models.Order{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err = r.db.QueryRowContext(ctx, query, id, org).Scan(
 		&order.ID, &order.ClientID, &order.Date, &order.Number,
-		&order.TotalAmount, &order.IsConfirmed, &order.CreatedAt,
-		&order.Client.ID, &order.Client.Name, &order.Client.INN,
+		&order.TotalAmount, &order.Status, &order.CreatedAt, &order.Version,
+		&order.Client.ID, &order.Client.Name, &order.Client.INN, &order.Client.Version,
 	)
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
@@ -89,12 +123,12 @@ func (r *orderRepository) GetByID(ctx context.Context, id int64) (*models.Order,
 	// Получаем позиции заказа
 	query = `
 		SELECT i.id, i.product_id, i.quantity, i.price, i.line_amount,
-			   p.id, p.name, p.unit
+			   p.id, p.name, p.unit, p.version
 		FROM order_items i
 		JOIN products p ON p.id = i.product_id
-		WHERE i.order_id = $1`
+		WHERE i.order_id = $1 AND i.organization_id = $2`
 
-	rows, err := r.db.QueryContext(ctx, query, id)
+	rows, err := r.db.QueryContext(ctx, query, id, org)
 	if err != nil {
 		return nil, err
 	}
@@ -104,7 +138,7 @@ func (r *orderRepository) GetByID(ctx context.Context, id int64) (*models.Order,
 		var item models.OrderItem
 		err := rows.Scan(
 			&item.ID, &item.ProductID, &item.Quantity, &item.Price, &item.LineAmount,
-			&item.Product.ID, &item.Product.Name, &item.Product.Unit,
+			&item.Product.ID, &item.Product.Name, &item.Product.Unit, &item.Product.Version,
 		)
 		if err != nil {
 			return nil, err
@@ -120,93 +154,61 @@ func (r *orderRepository) GetByID(ctx context.Context, id int64) (*models.Order,
 }
 
 func (r *orderRepository) Update(ctx context.Context, order *models.Order, items []models.OrderItem) error {
-	tx, err := r.db.BeginTx(ctx, nil)
+	org, err := tenant.ID(ctx)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
 
-	// Проверяем, что заказ не подтвержден
-	var isConfirmed bool
-	err = tx.QueryRowContext(ctx, "SELECT is_confirmed FROM orders WHERE id = $1", order.ID).
-		Scan(&isConfirmed)
-	if err == sql.ErrNoRows {
-		return ErrNotFound
-	}
-	if err != nil {
-		return err
-	}
-	if isConfirmed {
-		return ErrConflict
-	}
-
-	// Обновляем заказ
-	query := `
-		UPDATE orders
-		SET client_id = $1, number = $2
-		WHERE id = $3`
-
-	result, err := tx.ExecContext(ctx, query, order.ClientID, order.Number, order.ID)
-	if err != nil {
-		return err
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rowsAffected == 0 {
-		return ErrNotFound
-	}
-
-	// Удаляем старые позиции
-	_, err = tx.ExecContext(ctx, "DELETE FROM order_items WHERE order_id = $1", order.ID)
-	if err != nil {
-		return err
-	}
+	return inTx(ctx, r.db, func(tx DBTX) error {
+		// Проверяем версию и то, что заказ еще черновик
+		var status models.OrderStatus
+		var version int64
+		query := "SELECT status, version FROM orders WHERE id = $1 AND organization_id = $2 FOR UPDATE"
+		err := tx.QueryRowContext(ctx, query, order.ID, org).
+			Scan(&status, &version)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if order.Version != 0 && order.Version != version {
+			return ErrVersionMismatch
+		}
+		if status != models.OrderStatusDraft {
+			return ErrStateConflict
+		}
 
-	// Создаем новые позиции
-	for i := range items {
-		items[i].OrderID = order.ID
+		// Обновляем заказ
 		query = `
-			INSERT INTO order_items (order_id, product_id, quantity, price)
-			VALUES ($1, $2, $3, $4)
-			RETURNING id, line_amount`
+			UPDATE orders
+			SET client_id = $1, number = $2, version = version + 1
+			WHERE id = $3
+			RETURNING version`
 
-		err = tx.QueryRowContext(ctx, query,
-			items[i].OrderID,
-			items[i].ProductID,
-			items[i].Quantity,
-			items[i].Price,
-		).Scan(&items[i].ID, &items[i].LineAmount)
+		if err := tx.QueryRowContext(ctx, query, order.ClientID, order.Number, order.ID).Scan(&order.Version); err != nil {
+			return err
+		}
+
+		// Удаляем старые позиции
+		_, err = tx.ExecContext(ctx, "DELETE FROM order_items WHERE order_id = $1", order.ID)
 		if err != nil {
 			return err
 		}
-	}
 
-	// Обновляем общую сумму заказа
-	query = `
-		UPDATE orders
-		SET total_amount = (
-			SELECT COALESCE(SUM(line_amount), 0)
-			FROM order_items
-			WHERE order_id = $1
-		)
-		WHERE id = $1
-		RETURNING total_amount`
+		return insertOrderItems(ctx, tx, org, order, items)
+	})
+}
 
-	err = tx.QueryRowContext(ctx, query, order.ID).Scan(&order.TotalAmount)
+func (r *orderRepository) Delete(ctx context.Context, id int64) error {
+	org, err := tenant.ID(ctx)
 	if err != nil {
 		return err
 	}
 
-	return tx.Commit()
-}
+	query := `DELETE FROM orders WHERE id = $1 AND organization_id = $2`
 
-func (r *orderRepository) Delete(ctx context.Context, id int64) error {
-	query := `DELETE FROM orders WHERE id = $1`
-
-	result, err := r.db.ExecContext(ctx, query, id)
+	result, err := r.db.ExecContext(ctx, query, id, org)
 	if err != nil {
 		return err
 	}
@@ -222,17 +224,32 @@ func (r *orderRepository) Delete(ctx context.Context, id int64) error {
 	return nil
 }
 
-func (r *orderRepository) GetAll(ctx context.Context) ([]models.Order, error) {
+func (r *orderRepository) List(ctx context.Context, q OrderQuery) (Page[models.Order], error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return Page[models.Order]{}, err
+	}
+
+	w := orderWhere(org, q)
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM orders o"+w.sql(), w.args...).Scan(&total); err != nil {
+		return Page[models.Order]{}, err
+	}
+
+	paging, err := w.page(q.ListParams, orderSortColumns, "o.id")
+	if err != nil {
+		return Page[models.Order]{}, err
+	}
 	query := `
-		SELECT o.id, o.client_id, o.date, o.number, o.total_amount, o.is_confirmed, o.created_at,
-			   c.id, c.name, c.inn
+		SELECT o.id, o.client_id, o.date, o.number, o.total_amount, o.status, o.created_at, o.version,
+			   c.id, c.name, c.inn, c.version
 		FROM orders o
-		JOIN clients c ON c.id = o.client_id
-		ORDER BY o.created_at DESC`
+		JOIN clients c ON c.id = o.client_id` + w.sql() + paging
 
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := r.db.QueryContext(ctx, query, w.args...)
 	if err != nil {
-		return nil, err
+		return Page[models.Order]{}, err
 	}
 	defer rows.Close()
 
@@ -241,74 +258,224 @@ func (r *orderRepository) GetAll(ctx context.Context) ([]models.Order, error) {
 		var order models.Order
 		err := rows.Scan(
 			&order.ID, &order.ClientID, &order.Date, &order.Number,
-			&order.TotalAmount, &order.IsConfirmed, &order.CreatedAt,
-			&order.Client.ID, &order.Client.Name, &order.Client.INN,
+			&order.TotalAmount, &order.Status, &order.CreatedAt, &order.Version,
+			&order.Client.ID, &order.Client.Name, &order.Client.INN, &order.Client.Version,
 		)
 		if err != nil {
-			return nil, err
+			return Page[models.Order]{}, err
 		}
 		orders = append(orders, order)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return Page[models.Order]{}, err
 	}
 
-	return orders, nil
+	return NewPage(orders, total, q.ListParams, OrderSortValue, func(o models.Order) int64 { return o.ID }), nil
 }
 
-func (r *orderRepository) Confirm(ctx context.Context, id int64) error {
-	tx, err := r.db.BeginTx(ctx, nil)
+// orderWhere отбирает заказы организации org по q
+func orderWhere(org int64, q OrderQuery) where {
+	var w where
+	w.add("o.organization_id = ?", org)
+	if q.ClientID != 0 {
+		w.add("o.client_id = ?", q.ClientID)
+	}
+	if !q.From.IsZero() {
+		w.add("o.date >= ?", q.From)
+	}
+	if !q.To.IsZero() {
+		w.add("o.date <= ?", q.To)
+	}
+	if len(q.Statuses) > 0 {
+		w.add("o.status IN ("+placeholders(len(q.Statuses))+")", statusArgs(q.Statuses)...)
+	}
+	if q.MinAmount != nil {
+		w.add("o.total_amount >= ?", *q.MinAmount)
+	}
+	if q.MaxAmount != nil {
+		w.add("o.total_amount <= ?", *q.MaxAmount)
+	}
+	return w
+}
+
+// Each читает заказы отбора вместе с позициями одним запросом: строки
+// выборки упорядочены по заказу, и заказ передается fn, как только
+// прочитана его последняя позиция
+func (r *orderRepository) Each(ctx context.Context, q OrderQuery, fn func(models.Order) error) error {
+	org, err := tenant.ID(ctx)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
-
-	// Получаем данные заказа
-	var clientID int64
-	var totalAmount float64
-	var isConfirmed bool
 
+	w := orderWhere(org, q)
+	order, err := orderBy(q.ListParams, orderSortColumns, "o.id")
+	if err != nil {
+		return err
+	}
+	// Заказ без позиций дает одну строку с нулевой позицией
 	query := `
-		SELECT client_id, total_amount, is_confirmed
-		FROM orders
-		WHERE id = $1
-		FOR UPDATE`
+		SELECT o.id, o.client_id, o.date, o.number, o.total_amount, o.status, o.created_at, o.version,
+			   c.id, c.name, c.inn, c.version,
+			   COALESCE(i.id, 0), COALESCE(i.product_id, 0), COALESCE(i.quantity, 0),
+			   COALESCE(i.price, 0), COALESCE(i.line_amount, 0),
+			   COALESCE(p.name, ''), COALESCE(p.unit, ''), COALESCE(p.version, 0)
+		FROM orders o
+		JOIN clients c ON c.id = o.client_id
+		LEFT JOIN order_items i ON i.order_id = o.id
+		LEFT JOIN products p ON p.id = i.product_id` + w.sql() + order + ", i.id"
 
-	err = tx.QueryRowContext(ctx, query, id).Scan(&clientID, &totalAmount, &isConfirmed)
-	if err == sql.ErrNoRows {
-		return ErrNotFound
+	rows, err := r.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current *models.Order
+	for rows.Next() {
+		var o models.Order
+		var item models.OrderItem
+		err := rows.Scan(
+			&o.ID, &o.ClientID, &o.Date, &o.Number,
+			&o.TotalAmount, &o.Status, &o.CreatedAt, &o.Version,
+			&o.Client.ID, &o.Client.Name, &o.Client.INN, &o.Client.Version,
+			&item.ID, &item.ProductID, &item.Quantity, &item.Price, &item.LineAmount,
+			&item.Product.Name, &item.Product.Unit, &item.Product.Version,
+		)
+		if err != nil {
+			return err
+		}
+		if current != nil && current.ID != o.ID {
+			if err := fn(*current); err != nil {
+				return err
+			}
+			current = nil
+		}
+		if current == nil {
+			current = &o
+		}
+		if item.ID != 0 {
+			item.OrderID, item.Product.ID = current.ID, item.ProductID
+			current.Items = append(current.Items, item)
+		}
 	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if current != nil {
+		return fn(*current)
+	}
+	return nil
+}
+
+// SetStatus меняет статус заказа и пишет переход в историю.
+// Побочные действия перехода (движения по регистру) выполняет сервис в той же транзакции.
+func (r *orderRepository) SetStatus(ctx context.Context, change *models.OrderHistory) error {
+	org, err := tenant.ID(ctx)
 	if err != nil {
 		return err
 	}
 
-	if isConfirmed {
-		return ErrConflict
+	return inTx(ctx, r.db, func(tx DBTX) error {
+		query := `
+			UPDATE orders
+			SET status = $3, version = version + 1
+			WHERE id = $1 AND status = $2 AND organization_id = $4`
+
+		result, err := tx.ExecContext(ctx, query, change.OrderID, change.FromStatus, change.ToStatus, org)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			var exists bool
+			query := "SELECT EXISTS (SELECT 1 FROM orders WHERE id = $1 AND organization_id = $2)"
+			if err := tx.QueryRowContext(ctx, query, change.OrderID, org).Scan(&exists); err != nil {
+				return err
+			}
+			if !exists {
+				return ErrNotFound
+			}
+			return ErrStateConflict
+		}
+
+		query = `
+			INSERT INTO order_history (organization_id, order_id, action, from_status, to_status, actor)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id, created_at`
+
+		return tx.QueryRowContext(ctx, query,
+			org, change.OrderID, change.Action, change.FromStatus, change.ToStatus, change.Actor,
+		).Scan(&change.ID, &change.CreatedAt)
+	})
+}
+
+func (r *orderRepository) GetHistory(ctx context.Context, id int64) ([]models.OrderHistory, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	// Обновляем статус заказа
-	query = `
-		UPDATE orders
-		SET is_confirmed = true
-		WHERE id = $1`
+	query := `
+		SELECT id, order_id, action, from_status, to_status, actor, created_at
+		FROM order_history
+		WHERE order_id = $1 AND organization_id = $2
+		ORDER BY created_at, id`
 
-	_, err = tx.ExecContext(ctx, query, id)
+	rows, err := r.db.QueryContext(ctx, query, id, org)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	// Обновляем сумму в orders_by_client
-	query = `
-		INSERT INTO orders_by_client (client_id, orders_sum)
-		VALUES ($1, $2)
-		ON CONFLICT (client_id)
-		DO UPDATE SET orders_sum = orders_by_client.orders_sum + $2`
+	var history []models.OrderHistory
+	for rows.Next() {
+		var h models.OrderHistory
+		if err := rows.Scan(&h.ID, &h.OrderID, &h.Action, &h.FromStatus, &h.ToStatus, &h.Actor, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, h)
+	}
 
-	_, err = tx.ExecContext(ctx, query, clientID, totalAmount)
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// insertOrderItems создает позиции заказа и пересчитывает его общую сумму
+func insertOrderItems(ctx context.Context, tx DBTX, org int64, order *models.Order, items []models.OrderItem) error {
+	total, err := models.CalculateAmounts(items)
 	if err != nil {
 		return err
 	}
+	order.TotalAmount = total
+	for i := range items {
+		items[i].OrderID = order.ID
+		query := `
+			INSERT INTO order_items (organization_id, order_id, product_id, quantity, price, line_amount)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id`
+
+		err := tx.QueryRowContext(ctx, query,
+			org,
+			items[i].OrderID,
+			items[i].ProductID,
+			items[i].Quantity,
+			items[i].Price,
+			items[i].LineAmount,
+		).Scan(&items[i].ID)
+		if err != nil {
+			return err
+		}
+	}
 
-	return tx.Commit()
+	// Обновляем общую сумму заказа
+	_, err = tx.ExecContext(ctx, "UPDATE orders SET total_amount = $2 WHERE id = $1", order.ID, order.TotalAmount)
+	return err
 }
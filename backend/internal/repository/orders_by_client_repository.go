@@ -3,20 +3,33 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
 )
 
+// signedAmount переводит ресурс движения в знаковую сумму: приход увеличивает остаток, расход уменьшает
+const signedAmount = `CASE record_kind WHEN 'receipt' THEN amount ELSE -amount END`
+
 func (r *ordersByClientRepository) GetByID(ctx context.Context, clientID int64) (*models.OrdersByClient, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT obc.client_id, obc.orders_sum,
+		SELECT t.client_id, SUM(t.amount),
 			   c.id, c.name, c.inn
-		FROM orders_by_client obc
-		JOIN clients c ON c.id = obc.client_id
-		WHERE obc.client_id = $1`
+		FROM orders_by_client_totals t
+		JOIN clients c ON c.id = t.client_id
+		WHERE t.client_id = $1 AND t.organization_id = $2
+		GROUP BY t.client_id, c.id, c.name, c.inn`
 
 	result := &models.OrdersByClient{}
-	err := r.db.QueryRowContext(ctx, query, clientID).Scan(
+	err = r.db.QueryRowContext(ctx, query, clientID, org).Scan(
 		&result.ClientID, &result.OrdersSum,
 		&result.Client.ID, &result.Client.Name, &result.Client.INN,
 	)
@@ -31,14 +44,179 @@ func (r *ordersByClientRepository) GetByID(ctx context.Context, clientID int64)
 }
 
 func (r *ordersByClientRepository) GetAll(ctx context.Context) ([]models.OrdersByClient, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT obc.client_id, obc.orders_sum,
+		SELECT t.client_id, SUM(t.amount),
 			   c.id, c.name, c.inn
-		FROM orders_by_client obc
-		JOIN clients c ON c.id = obc.client_id
-		ORDER BY obc.orders_sum DESC`
+		FROM orders_by_client_totals t
+		JOIN clients c ON c.id = t.client_id
+		WHERE t.organization_id = $1
+		GROUP BY t.client_id, c.id, c.name, c.inn
+		ORDER BY SUM(t.amount) DESC`
+
+	return r.queryBalances(ctx, query, org)
+}
 
-	rows, err := r.db.QueryContext(ctx, query)
+// GetMovements возвращает движения регистра, отобранные по регистратору, клиенту и периоду
+func (r *ordersByClientRepository) GetMovements(ctx context.Context, filter MovementFilter) ([]models.OrdersByClientMovement, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(cond, len(args)))
+	}
+
+	addCondition("organization_id = $%d", org)
+	if filter.RecorderID != 0 {
+		addCondition("recorder_id = $%d", filter.RecorderID)
+	}
+	if filter.ClientID != 0 {
+		addCondition("client_id = $%d", filter.ClientID)
+	}
+	if !filter.From.IsZero() {
+		addCondition("period >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("period <= $%d", filter.To)
+	}
+
+	query := `
+		SELECT recorder_id, period, line_number, record_kind, client_id, amount
+		FROM orders_by_client_movements
+		WHERE ` + strings.Join(conditions, " AND ")
+	query += "\n\t\tORDER BY period, recorder_id, line_number"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []models.OrdersByClientMovement
+	for rows.Next() {
+		var m models.OrdersByClientMovement
+		err := rows.Scan(&m.RecorderID, &m.Period, &m.LineNumber, &m.RecordKind, &m.ClientID, &m.Amount)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movements, nil
+}
+
+// GetBalance возвращает остатки регистра на момент времени at.
+// Остаток складывается из итогов за месяцы до at и движений текущего месяца до at включительно.
+func (r *ordersByClientRepository) GetBalance(ctx context.Context, at time.Time) ([]models.OrdersByClient, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT b.client_id, SUM(b.amount),
+			   c.id, c.name, c.inn
+		FROM (
+			SELECT client_id, amount
+			FROM orders_by_client_totals
+			WHERE period < date_trunc('month', $1::timestamp) AND organization_id = $2
+			UNION ALL
+			SELECT client_id, ` + signedAmount + `
+			FROM orders_by_client_movements
+			WHERE period >= date_trunc('month', $1::timestamp) AND period <= $1 AND organization_id = $2
+		) b
+		JOIN clients c ON c.id = b.client_id
+		GROUP BY b.client_id, c.id, c.name, c.inn
+		ORDER BY SUM(b.amount) DESC`
+
+	return r.queryBalances(ctx, query, at, org)
+}
+
+// GetTurnovers возвращает обороты регистра по клиентам за период [from, to]
+func (r *ordersByClientRepository) GetTurnovers(ctx context.Context, from, to time.Time) ([]models.OrdersByClientTurnover, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT m.client_id,
+			   COALESCE(SUM(CASE m.record_kind WHEN 'receipt' THEN m.amount ELSE 0 END), 0),
+			   COALESCE(SUM(CASE m.record_kind WHEN 'expense' THEN m.amount ELSE 0 END), 0),
+			   c.id, c.name, c.inn
+		FROM orders_by_client_movements m
+		JOIN clients c ON c.id = m.client_id
+		WHERE m.period >= $1 AND m.period <= $2 AND m.organization_id = $3
+		GROUP BY m.client_id, c.id, c.name, c.inn
+		ORDER BY c.name`
+
+	rows, err := r.db.QueryContext(ctx, query, from, to, org)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var turnovers []models.OrdersByClientTurnover
+	for rows.Next() {
+		var t models.OrdersByClientTurnover
+		err := rows.Scan(
+			&t.ClientID, &t.Receipt, &t.Expense,
+			&t.Client.ID, &t.Client.Name, &t.Client.INN,
+		)
+		if err != nil {
+			return nil, err
+		}
+		t.Turnover = t.Receipt - t.Expense
+		turnovers = append(turnovers, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return turnovers, nil
+}
+
+func (r *ordersByClientRepository) WriteMovements(ctx context.Context, recorderID int64, movements []models.OrdersByClientMovement) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return inTx(ctx, r.db, func(tx DBTX) error {
+		if err := deleteMovements(ctx, tx, org, recorderID); err != nil {
+			return err
+		}
+		return insertMovements(ctx, tx, org, recorderID, movements)
+	})
+}
+
+func (r *ordersByClientRepository) DeleteMovements(ctx context.Context, recorderID int64) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return inTx(ctx, r.db, func(tx DBTX) error {
+		return deleteMovements(ctx, tx, org, recorderID)
+	})
+}
+
+func (r *ordersByClientRepository) queryBalances(ctx context.Context, query string, args ...interface{}) ([]models.OrdersByClient, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -64,24 +242,60 @@ func (r *ordersByClientRepository) GetAll(ctx context.Context) ([]models.OrdersB
 	return results, nil
 }
 
-func (r *ordersByClientRepository) UpdateSum(ctx context.Context, clientID int64, amount float64) error {
+// deleteMovements удаляет движения регистратора и вычитает их из итогов
+func deleteMovements(ctx context.Context, tx DBTX, org, recorderID int64) error {
 	query := `
-		INSERT INTO orders_by_client (client_id, orders_sum)
-		VALUES ($1, $2)
-		ON CONFLICT (client_id)
-		DO UPDATE SET orders_sum = orders_by_client.orders_sum + $2`
+		UPDATE orders_by_client_totals t
+		SET amount = t.amount - m.amount
+		FROM (
+			SELECT date_trunc('month', period)::date AS period, client_id, SUM(` + signedAmount + `) AS amount
+			FROM orders_by_client_movements
+			WHERE recorder_id = $1 AND organization_id = $2
+			GROUP BY date_trunc('month', period)::date, client_id
+		) m
+		WHERE t.period = m.period AND t.client_id = m.client_id AND t.organization_id = $2`
 
-	result, err := r.db.ExecContext(ctx, query, clientID, amount)
-	if err != nil {
+	if _, err := tx.ExecContext(ctx, query, recorderID, org); err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rowsAffected == 0 {
-		return ErrNotFound
+	_, err := tx.ExecContext(ctx, "DELETE FROM orders_by_client_movements WHERE recorder_id = $1 AND organization_id = $2", recorderID, org)
+	return err
+}
+
+// insertMovements записывает движения регистратора и добавляет их к итогам
+func insertMovements(ctx context.Context, tx DBTX, org, recorderID int64, movements []models.OrdersByClientMovement) error {
+	for i := range movements {
+		m := &movements[i]
+		m.RecorderID = recorderID
+		if m.LineNumber == 0 {
+			m.LineNumber = i + 1
+		}
+
+		query := `
+			INSERT INTO orders_by_client_movements (organization_id, recorder_id, period, line_number, record_kind, client_id, amount)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+		_, err := tx.ExecContext(ctx, query,
+			org, m.RecorderID, m.Period, m.LineNumber, m.RecordKind, m.ClientID, m.Amount)
+		if err != nil {
+			return err
+		}
+
+		amount := m.Amount
+		if m.RecordKind == models.MovementExpense {
+			amount = -amount
+		}
+
+		query = `
+			INSERT INTO orders_by_client_totals (organization_id, period, client_id, amount)
+			VALUES ($4, date_trunc('month', $1::timestamp)::date, $2, $3)
+			ON CONFLICT (period, client_id)
+			DO UPDATE SET amount = orders_by_client_totals.amount + $3`
+
+		if _, err := tx.ExecContext(ctx, query, m.Period, m.ClientID, amount, org); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -5,30 +5,41 @@ import (
 	"database/sql"
 
 	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
 )
 
 func (r *productRepository) Create(ctx context.Context, product *models.Product) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO products (name, unit)
-		VALUES ($1, $2)
-		RETURNING id`
+		INSERT INTO products (organization_id, name, unit)
+		VALUES ($1, $2, $3)
+		RETURNING id, version`
 
-	err := r.db.QueryRowContext(ctx, query, product.Name, product.Unit).Scan(&product.ID)
+	err = r.db.QueryRowContext(ctx, query, org, product.Name, product.Unit).Scan(&product.ID, &product.Version)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
 	return nil
 }
 
 func (r *productRepository) GetByID(ctx context.Context, id int64) (*models.Product, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT id, name, unit
+		SELECT id, name, unit, version
 		FROM products
-		WHERE id = $1`
+		WHERE id = $1 AND organization_id = $2`
 
 	product := &models.Product{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&product.ID, &product.Name, &product.Unit)
+	err = r.db.QueryRowContext(ctx, query, id, org).Scan(&product.ID, &product.Name, &product.Unit, &product.Version)
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
 	}
@@ -39,39 +50,70 @@ func (r *productRepository) GetByID(ctx context.Context, id int64) (*models.Prod
 	return product, nil
 }
 
-func (r *productRepository) Update(ctx context.Context, product *models.Product) error {
+func (r *productRepository) GetByNameUnit(ctx context.Context, name, unit string) (*models.Product, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		UPDATE products
-		SET name = $1, unit = $2
-		WHERE id = $3`
+		SELECT id, name, unit, version
+		FROM products
+		WHERE organization_id = $1 AND name = $2 AND unit = $3
+		ORDER BY id
+		LIMIT 1`
 
-	result, err := r.db.ExecContext(ctx, query, product.Name, product.Unit, product.ID)
+	product := &models.Product{}
+	err = r.db.QueryRowContext(ctx, query, org, name, unit).Scan(&product.ID, &product.Name, &product.Unit, &product.Version)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return product, nil
+}
+
+func (r *productRepository) Update(ctx context.Context, product *models.Product) error {
+	org, err := tenant.ID(ctx)
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return ErrNotFound
+
+	query := `
+		UPDATE products
+		SET name = $1, unit = $2, version = version + 1
+		WHERE id = $3 AND organization_id = $5 AND ($4::bigint = 0 OR version = $4)
+		RETURNING version`
+
+	err = r.db.QueryRowContext(ctx, query, product.Name, product.Unit, product.ID, product.Version, org).Scan(&product.Version)
+	if err == sql.ErrNoRows {
+		return staleOrMissingIn(ctx, r.db, "products", org, product.ID)
+	}
+	if err != nil {
+		return translateError(err)
 	}
 
 	return nil
 }
 
 func (r *productRepository) Delete(ctx context.Context, id int64) error {
-	query := `DELETE FROM products WHERE id = $1`
-
-	result, err := r.db.ExecContext(ctx, query, id)
+	org, err := tenant.ID(ctx)
 	if err != nil {
 		return err
 	}
 
+	query := `DELETE FROM products WHERE id = $1 AND organization_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, id, org)
+	if err != nil {
+		return translateError(err)
+	}
+
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 	if rowsAffected == 0 {
 		return ErrNotFound
@@ -80,42 +122,106 @@ func (r *productRepository) Delete(ctx context.Context, id int64) error {
 	return nil
 }
 
-func (r *productRepository) GetAll(ctx context.Context) ([]models.Product, error) {
+func (r *productRepository) List(ctx context.Context, q ProductQuery) (Page[models.Product], error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return Page[models.Product]{}, err
+	}
+
+	w := productWhere(org, q)
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM products"+w.sql(), w.args...).Scan(&total); err != nil {
+		return Page[models.Product]{}, err
+	}
+
+	paging, err := w.page(q.ListParams, productSortColumns, "id")
+	if err != nil {
+		return Page[models.Product]{}, err
+	}
 	query := `
-		SELECT id, name, unit
-		FROM products
-		ORDER BY name`
+		SELECT id, name, unit, version
+		FROM products` + w.sql() + paging
 
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := r.db.QueryContext(ctx, query, w.args...)
 	if err != nil {
-		return nil, err
+		return Page[models.Product]{}, err
 	}
 	defer rows.Close()
 
 	var products []models.Product
 	for rows.Next() {
 		var product models.Product
-		if err := rows.Scan(&product.ID, &product.Name, &product.Unit); err != nil {
-			return nil, err
+		if err := rows.Scan(&product.ID, &product.Name, &product.Unit, &product.Version); err != nil {
+			return Page[models.Product]{}, err
 		}
 		products = append(products, product)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return Page[models.Product]{}, err
 	}
 
-	return products, nil
+	return NewPage(products, total, q.ListParams, ProductSortValue, func(p models.Product) int64 { return p.ID }), nil
+}
+
+// productWhere отбирает товары организации org по q
+func productWhere(org int64, q ProductQuery) where {
+	var w where
+	w.add("organization_id = ?", org)
+	if q.Search != "" {
+		w.add("name ILIKE ?", likePattern(q.Search))
+	}
+	return w
+}
+
+// Each читает отбор построчно и передает записи fn
+func (r *productRepository) Each(ctx context.Context, q ProductQuery, fn func(models.Product) error) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := productWhere(org, q)
+	order, err := orderBy(q.ListParams, productSortColumns, "id")
+	if err != nil {
+		return err
+	}
+	query := `
+		SELECT id, name, unit, version
+		FROM products` + w.sql() + order
+
+	rows, err := r.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product models.Product
+		if err := rows.Scan(&product.ID, &product.Name, &product.Unit, &product.Version); err != nil {
+			return err
+		}
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
 
 // GetProductOrderItems возвращает все позиции заказов, где используется товар
 func (r *productRepository) GetProductOrderItems(ctx context.Context, productID int64) ([]models.OrderItem, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		SELECT oi.id, oi.order_id, oi.product_id, oi.quantity, oi.price, oi.line_amount
 		FROM order_items oi
-		WHERE oi.product_id = $1`
+		WHERE oi.product_id = $1 AND oi.organization_id = $2`
 
-	rows, err := r.db.QueryContext(ctx, query, productID)
+	rows, err := r.db.QueryContext(ctx, query, productID, org)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+)
+
+// Допустимые поля сортировки списков. Первое поле используется по умолчанию.
+var (
+	ClientSortKeys  = []string{"name", "inn", "id"}
+	ProductSortKeys = []string{"name", "unit", "id"}
+	OrderSortKeys   = []string{"created_at", "date", "number", "total_amount", "id"}
+)
+
+// ListParams задает страницу и порядок списка. При заданном Cursor страница
+// продолжается после записи из курсора, а Offset не используется.
+type ListParams struct {
+	Limit  int
+	Offset int
+	Sort   string
+	Desc   bool
+	Cursor *Cursor
+}
+
+// ClientQuery отбирает клиентов; Search ищет подстроку в наименовании или ИНН
+type ClientQuery struct {
+	ListParams
+	Search string
+}
+
+// ProductQuery отбирает товары; Search ищет подстроку в наименовании
+type ProductQuery struct {
+	ListParams
+	Search string
+}
+
+// OrderQuery отбирает заказы. Нулевые значения полей не ограничивают отбор.
+type OrderQuery struct {
+	ListParams
+	ClientID  int64
+	From, To  time.Time
+	Statuses  []models.OrderStatus
+	MinAmount *money.Money
+	MaxAmount *money.Money
+}
+
+// Page — страница списка с общим количеством записей, удовлетворяющих отбору
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Cursor указывает на последнюю запись страницы: значение поля сортировки и ID
+type Cursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+// Encode возвращает непрозрачное строковое представление курсора
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor разбирает курсор, полученный от клиента
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Arg возвращает значение курсора в типе поля сортировки
+func (c Cursor) Arg() (interface{}, error) {
+	switch c.Sort {
+	case "created_at", "date":
+		return time.Parse(time.RFC3339Nano, c.Value)
+	case "total_amount":
+		return money.ParseMoney(c.Value)
+	case "id":
+		return c.ID, nil
+	}
+	return c.Value, nil
+}
+
+// ClientSortValue возвращает значение поля сортировки клиента для курсора
+func ClientSortValue(c models.Client, key string) string {
+	switch key {
+	case "name":
+		return c.Name
+	case "inn":
+		return c.INN
+	}
+	return strconv.FormatInt(c.ID, 10)
+}
+
+// ProductSortValue возвращает значение поля сортировки товара для курсора
+func ProductSortValue(p models.Product, key string) string {
+	switch key {
+	case "name":
+		return p.Name
+	case "unit":
+		return p.Unit
+	}
+	return strconv.FormatInt(p.ID, 10)
+}
+
+// OrderSortValue возвращает значение поля сортировки заказа для курсора
+func OrderSortValue(o models.Order, key string) string {
+	switch key {
+	case "created_at":
+		return o.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case "date":
+		return o.Date.UTC().Format(time.RFC3339Nano)
+	case "number":
+		return o.Number
+	case "total_amount":
+		return o.TotalAmount.String()
+	}
+	return strconv.FormatInt(o.ID, 10)
+}
+
+// NewPage формирует страницу из выборки, запрошенной с лимитом на одну запись
+// больше страницы: лишняя запись означает, что есть следующая страница
+func NewPage[T any](items []T, total int64, p ListParams, sortValue func(T, string) string, id func(T) int64) Page[T] {
+	page := Page[T]{Items: items, Total: total}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if len(items) > p.Limit {
+		page.Items = items[:p.Limit]
+		last := page.Items[p.Limit-1]
+		page.NextCursor = Cursor{Sort: p.Sort, Desc: p.Desc, Value: sortValue(last, p.Sort), ID: id(last)}.Encode()
+	}
+	return page
+}
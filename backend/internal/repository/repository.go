@@ -4,44 +4,75 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
 	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/numbering"
 )
 
+// Ошибки репозиториев общие для всех реализаций хранилища. Нарушения
+// ограничений базы переводятся в них, чтобы сервисы не зависели от драйвера.
 var (
-	ErrNotFound = errors.New("record not found")
-	ErrConflict = errors.New("record already exists")
+	ErrNotFound   = apperr.New(apperr.KindNotFound, "not_found", "record not found")
+	ErrConflict   = apperr.New(apperr.KindConflict, "conflict", "record already exists")
+	ErrForeignKey = apperr.New(apperr.KindConflict, "foreign_key_violation", "record references missing or is referenced by other records")
+	ErrCheck      = apperr.New(apperr.KindValidation, "check_violation", "value violates a constraint")
+	// ErrStateConflict: запись уже не в том состоянии, в котором ее ожидали изменить
+	ErrStateConflict = apperr.New(apperr.KindConflict, "state_conflict", "record state has changed")
+	// ErrVersionMismatch: запись изменена после чтения, ожидаемая версия устарела
+	ErrVersionMismatch = apperr.New(apperr.KindPreconditionFailed, "version_mismatch", "record has been modified by another request")
 )
 
-// Repository определяет интерфейс для работы с данными
+// Repositories объединяет репозитории и единицу работы для транзакций
 type Repositories struct {
 	Client         ClientRepository
 	Product        ProductRepository
 	Order          OrderRepository
 	OrdersByClient OrdersByClientRepository
+	Audit          AuditRepository
+	User           UserRepository
+	APIKey         APIKeyRepository
+	Organization   OrganizationRepository
+	ExternalRef    ExternalRefRepository
+	UnitOfWork     UnitOfWork
 }
 
-type PostgresRepositories struct {
-	Client         ClientRepository
-	Product        ProductRepository
-	Order          OrderRepository
-	OrdersByClient OrdersByClientRepository
+// NewPostgresRepository создает репозитории Postgres. orderNumbers задает
+// нумерацию заказов, создаваемых без номера.
+func NewPostgresRepository(db *sql.DB, orderNumbers numbering.Format) *Repositories {
+	repos := newRepositories(db, orderNumbers)
+	repos.UnitOfWork = NewUnitOfWork(db, orderNumbers)
+	return repos
 }
 
-func NewPostgresRepository(db *sql.DB) *PostgresRepositories {
-	return &PostgresRepositories{
+func newRepositories(db DBTX, orderNumbers numbering.Format) *Repositories {
+	return &Repositories{
 		Client:         NewClientRepository(db),
 		Product:        NewProductRepository(db),
-		Order:          NewOrderRepository(db),
+		Order:          NewOrderRepository(db, orderNumbers),
 		OrdersByClient: NewOrdersByClientRepository(db),
+		Audit:          NewAuditRepository(db),
+		User:           NewUserRepository(db),
+		APIKey:         NewAPIKeyRepository(db),
+		Organization:   NewOrganizationRepository(db),
+		ExternalRef:    NewExternalRefRepository(db),
 	}
 }
 
+// Репозитории клиентов, товаров, заказов, регистра и журнала аудита работают
+// в организации из контекста (см. пакет tenant): записи других организаций
+// для них не существуют. Без организации в контексте они возвращают
+// tenant.ErrMissing.
+
 // ClientRepository определяет методы для работы с клиентами
 type ClientRepository interface {
 	Create(ctx context.Context, client *models.Client) error
 	GetByID(ctx context.Context, id int64) (*models.Client, error)
-	GetAll(ctx context.Context) ([]models.Client, error)
+	// GetByINN возвращает клиента с ИНН inn; если таких несколько — созданного первым
+	GetByINN(ctx context.Context, inn string) (*models.Client, error)
+	List(ctx context.Context, q ClientQuery) (Page[models.Client], error)
+	Each(ctx context.Context, q ClientQuery, fn func(models.Client) error) error
 	Update(ctx context.Context, client *models.Client) error
 	Delete(ctx context.Context, id int64) error
 	GetClientOrders(ctx context.Context, id int64) ([]models.Order, error)
@@ -51,67 +82,247 @@ type ClientRepository interface {
 type ProductRepository interface {
 	Create(ctx context.Context, product *models.Product) error
 	GetByID(ctx context.Context, id int64) (*models.Product, error)
-	GetAll(ctx context.Context) ([]models.Product, error)
+	// GetByNameUnit возвращает товар с наименованием name и единицей unit;
+	// если таких несколько — созданный первым
+	GetByNameUnit(ctx context.Context, name, unit string) (*models.Product, error)
+	List(ctx context.Context, q ProductQuery) (Page[models.Product], error)
+	Each(ctx context.Context, q ProductQuery, fn func(models.Product) error) error
 	Update(ctx context.Context, product *models.Product) error
 	Delete(ctx context.Context, id int64) error
 	GetProductOrderItems(ctx context.Context, id int64) ([]models.OrderItem, error)
 }
 
+// Методы Update проверяют версию записи: если Version не равна нулю и не совпадает
+// с сохраненной, запись не изменяется и возвращается ErrVersionMismatch. После
+// успешного изменения в Version записывается новая версия.
+//
+// Методы Each читают весь отбор q в порядке его сортировки и передают записи
+// fn по одной, не загружая выборку в память, — для выгрузок. Limit, Offset
+// и Cursor не учитываются. Ошибка fn прекращает чтение и возвращается из Each.
+
 // OrderRepository определяет методы для работы с заказами
 type OrderRepository interface {
 	Create(ctx context.Context, order *models.Order, items []models.OrderItem) error
 	GetByID(ctx context.Context, id int64) (*models.Order, error)
-	GetAll(ctx context.Context) ([]models.Order, error)
+	// GetForUpdate возвращает заказ и блокирует его до конца транзакции UnitOfWork
+	GetForUpdate(ctx context.Context, id int64) (*models.Order, error)
+	List(ctx context.Context, q OrderQuery) (Page[models.Order], error)
+	// Each передает fn заказы вместе с позициями
+	Each(ctx context.Context, q OrderQuery, fn func(models.Order) error) error
 	Update(ctx context.Context, order *models.Order, items []models.OrderItem) error
 	Delete(ctx context.Context, id int64) error
-	Confirm(ctx context.Context, id int64) error
+	// SetStatus переводит заказ из change.FromStatus в change.ToStatus и пишет
+	// change в историю. Если статус заказа уже другой, возвращает ErrStateConflict.
+	SetStatus(ctx context.Context, change *models.OrderHistory) error
+	GetHistory(ctx context.Context, id int64) ([]models.OrderHistory, error)
+}
+
+// MovementFilter задает отбор движений регистра; нулевые поля не участвуют в отборе
+type MovementFilter struct {
+	RecorderID int64
+	ClientID   int64
+	From       time.Time
+	To         time.Time
 }
 
-// OrdersByClientRepository определяет методы для работы с агрегированными суммами
+// OrdersByClientRepository определяет методы для работы с регистром накопления
+// «ЗаказыПоКонтрагентам»: движениями, остатками и оборотами
 type OrdersByClientRepository interface {
 	GetByID(ctx context.Context, clientID int64) (*models.OrdersByClient, error)
 	GetAll(ctx context.Context) ([]models.OrdersByClient, error)
-	UpdateSum(ctx context.Context, clientID int64, amount float64) error
+	GetMovements(ctx context.Context, filter MovementFilter) ([]models.OrdersByClientMovement, error)
+	GetBalance(ctx context.Context, at time.Time) ([]models.OrdersByClient, error)
+	GetTurnovers(ctx context.Context, from, to time.Time) ([]models.OrdersByClientTurnover, error)
+	// WriteMovements замещает набор движений регистратора переданным набором
+	WriteMovements(ctx context.Context, recorderID int64, movements []models.OrdersByClientMovement) error
+	DeleteMovements(ctx context.Context, recorderID int64) error
+}
+
+// AuditRepository хранит журнал аудита. Записи журнала не изменяются
+// и не удаляются, в том числе при удалении самой сущности.
+type AuditRepository interface {
+	Append(ctx context.Context, entry *models.AuditEntry) error
+	// List возвращает записи о сущности в порядке их добавления
+	List(ctx context.Context, entity string, entityID int64) ([]models.AuditEntry, error)
+}
+
+// ExternalRefRepository хранит идентификаторы записей учета во внешних
+// системах (models.ExternalRef). Внешний идентификатор указывает на одну
+// запись, у записи не больше одного идентификатора в каждой системе.
+// Сопоставление удаленной записи не удаляется вместе с ней: сервисы
+// проверяют, что запись существует.
+type ExternalRefRepository interface {
+	// Find возвращает id записи entity с идентификатором externalID в системе system
+	Find(ctx context.Context, entity, system, externalID string) (int64, error)
+	// List возвращает идентификаторы записей entity в системе system по id записей
+	List(ctx context.Context, entity, system string) (map[int64]string, error)
+	// ListByEntity возвращает идентификаторы записи во всех системах по порядку систем
+	ListByEntity(ctx context.Context, entity string, entityID int64) ([]models.ExternalRef, error)
+	// Put сопоставляет запись с внешним идентификатором. Прежние
+	// сопоставления записи и внешнего идентификатора в этой системе
+	// заменяются; у того же сопоставления изменяется только код.
+	Put(ctx context.Context, ref *models.ExternalRef) error
+	// Delete удаляет идентификатор записи в системе system
+	Delete(ctx context.Context, entity string, entityID int64, system string) error
+	// ListExported возвращает по id записей entity версии, получение
+	// которых подтвердила система system
+	ListExported(ctx context.Context, entity, system string) (map[int64]int64, error)
+	// MarkExported отмечает, что система system получила запись entity в
+	// версии version. Более поздняя отмеченная версия не уменьшается, запись
+	// без идентификатора в системе пропускается.
+	MarkExported(ctx context.Context, entity string, entityID int64, system string, version int64) error
+}
+
+// UpsertByExternalID изменяет запись, сопоставленную с внешним
+// идентификатором ref, функцией update. Если сопоставления нет или запись
+// удалена (update возвращает ErrNotFound), запись создается функцией create
+// и сопоставляется с идентификатором; created сообщает об этом. Код ref
+// сохраняется в обоих случаях. Вызывается в транзакции UnitOfWork, чтобы
+// запись и сопоставление изменились вместе.
+func UpsertByExternalID(ctx context.Context, refs ExternalRefRepository, ref *models.ExternalRef,
+	update func(id int64) error, create func() (int64, error)) (created bool, err error) {
+	id, err := refs.Find(ctx, ref.Entity, ref.System, ref.ExternalID)
+	switch {
+	case err == nil:
+		err = update(id)
+		if err == nil {
+			ref.EntityID = id
+			return false, refs.Put(ctx, ref)
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return false, err
+		}
+	case !errors.Is(err, ErrNotFound):
+		return false, err
+	}
+
+	if ref.EntityID, err = create(); err != nil {
+		return false, err
+	}
+	return true, refs.Put(ctx, ref)
+}
+
+// UserRepository хранит пользователей API. Логин уникален без учета регистра,
+// повторный логин при создании или изменении дает ErrConflict.
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id int64) (*models.User, error)
+	GetByLogin(ctx context.Context, login string) (*models.User, error)
+	List(ctx context.Context) ([]models.User, error)
+	Update(ctx context.Context, user *models.User) error
+}
+
+// APIKeyRepository хранит ключи API. Отозванные ключи не удаляются.
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	GetByID(ctx context.Context, id int64) (*models.APIKey, error)
+	GetByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	List(ctx context.Context) ([]models.APIKey, error)
+	// Revoke отзывает ключ; у уже отозванного ключа время отзыва не меняется
+	Revoke(ctx context.Context, id int64, at time.Time) error
+	// TouchLastUsed запоминает время последнего использования ключа
+	TouchLastUsed(ctx context.Context, id int64, at time.Time) error
+}
+
+// OrganizationRepository хранит организации. Код организации уникален
+// без учета регистра, повторный код дает ErrConflict.
+type OrganizationRepository interface {
+	Create(ctx context.Context, org *models.Organization) error
+	GetByID(ctx context.Context, id int64) (*models.Organization, error)
+	GetByCode(ctx context.Context, code string) (*models.Organization, error)
+	List(ctx context.Context) ([]models.Organization, error)
 }
 
 // Структуры конкретных репозиториев
 type clientRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
 type productRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
 type orderRepository struct {
-	db *sql.DB
+	db      DBTX
+	numbers numbering.Format
 }
 
 type ordersByClientRepository struct {
-	db *sql.DB
+	db DBTX
+}
+
+type auditRepository struct {
+	db DBTX
+}
+
+type userRepository struct {
+	db DBTX
+}
+
+type apiKeyRepository struct {
+	db DBTX
+}
+
+type organizationRepository struct {
+	db DBTX
+}
+
+type externalRefRepository struct {
+	db DBTX
 }
 
 // Функции создания репозиториев
-func NewClientRepository(db *sql.DB) ClientRepository {
+func NewClientRepository(db DBTX) ClientRepository {
 	return &clientRepository{
 		db: db,
 	}
 }
 
-func NewProductRepository(db *sql.DB) ProductRepository {
+func NewProductRepository(db DBTX) ProductRepository {
 	return &productRepository{
 		db: db,
 	}
 }
 
-func NewOrderRepository(db *sql.DB) OrderRepository {
+func NewOrderRepository(db DBTX, numbers numbering.Format) OrderRepository {
 	return &orderRepository{
-		db: db,
+		db:      db,
+		numbers: numbers,
 	}
 }
 
-func NewOrdersByClientRepository(db *sql.DB) OrdersByClientRepository {
+func NewOrdersByClientRepository(db DBTX) OrdersByClientRepository {
 	return &ordersByClientRepository{
 		db: db,
 	}
 }
+
+func NewAuditRepository(db DBTX) AuditRepository {
+	return &auditRepository{
+		db: db,
+	}
+}
+
+func NewUserRepository(db DBTX) UserRepository {
+	return &userRepository{
+		db: db,
+	}
+}
+
+func NewAPIKeyRepository(db DBTX) APIKeyRepository {
+	return &apiKeyRepository{
+		db: db,
+	}
+}
+
+func NewOrganizationRepository(db DBTX) OrganizationRepository {
+	return &organizationRepository{
+		db: db,
+	}
+}
+
+func NewExternalRefRepository(db DBTX) ExternalRefRepository {
+	return &externalRefRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,657 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/numbering"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository/memory"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository/sqlite"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+)
+
+// Тесты проверяют одни и те же свойства у реализаций в памяти и SQLite:
+// реализация в памяти повторяет ограничения схемы, и сервисы, проверенные
+// на ней, должны вести себя так же на базе.
+
+var orderNumbers = numbering.Format{Prefix: "T-", Width: 3, Reset: numbering.ResetNever}
+
+type backend struct {
+	name string
+	open func(t *testing.T) *repository.Repositories
+}
+
+var backends = []backend{
+	{"memory", func(t *testing.T) *repository.Repositories {
+		return memory.NewRepositories(orderNumbers)
+	}},
+	{"sqlite", func(t *testing.T) *repository.Repositories {
+		db, err := sqlite.NewDB(filepath.Join(t.TempDir(), "orderflow.db"))
+		if err != nil {
+			t.Fatalf("open sqlite: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return sqlite.NewRepositories(db, orderNumbers)
+	}},
+}
+
+func forEachBackend(t *testing.T, fn func(t *testing.T, ctx context.Context, repos *repository.Repositories)) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			fn(t, tenant.WithID(context.Background(), tenant.Default), b.open(t))
+		})
+	}
+}
+
+// fixture создает клиента и товар для заказов
+func fixture(t *testing.T, ctx context.Context, repos *repository.Repositories) (*models.Client, *models.Product) {
+	t.Helper()
+	client := &models.Client{Name: "ООО Ромашка", INN: "7707083893"}
+	if err := repos.Client.Create(ctx, client); err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	product := &models.Product{Name: "Гвозди", Unit: "кг"}
+	if err := repos.Product.Create(ctx, product); err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	return client, product
+}
+
+func item(productID int64, quantity, price string) models.OrderItem {
+	return models.OrderItem{
+		ProductID: productID,
+		Quantity:  money.MustParseQuantity(quantity),
+		Price:     money.MustParseMoney(price),
+	}
+}
+
+func TestOrderNumbers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ctx context.Context, repos *repository.Repositories) {
+		client, _ := fixture(t, ctx, repos)
+
+		first := &models.Order{ClientID: client.ID}
+		if err := repos.Order.Create(ctx, first, nil); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if first.Number != "T-001" {
+			t.Errorf("first number = %q, want T-001", first.Number)
+		}
+
+		// Номер, введенный вручную, занимает следующий автоматический номер
+		manual := &models.Order{ClientID: client.ID, Number: "T-002"}
+		if err := repos.Order.Create(ctx, manual, nil); err != nil {
+			t.Fatalf("create manual: %v", err)
+		}
+		next := &models.Order{ClientID: client.ID}
+		if err := repos.Order.Create(ctx, next, nil); err != nil {
+			t.Fatalf("create next: %v", err)
+		}
+		if next.Number != "T-003" {
+			t.Errorf("number after manual T-002 = %q, want T-003", next.Number)
+		}
+
+		duplicate := &models.Order{ClientID: client.ID, Number: "T-001"}
+		if err := repos.Order.Create(ctx, duplicate, nil); !errors.Is(err, repository.ErrConflict) {
+			t.Errorf("duplicate number: err = %v, want ErrConflict", err)
+		}
+
+		next.Number = "T-002"
+		if err := repos.Order.Update(ctx, next, nil); !errors.Is(err, repository.ErrConflict) {
+			t.Errorf("update to taken number: err = %v, want ErrConflict", err)
+		}
+	})
+}
+
+func TestOrderNumbersPerOrganization(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ctx context.Context, repos *repository.Repositories) {
+		org := &models.Organization{Code: "acme", Name: "Acme"}
+		if err := repos.Organization.Create(ctx, org); err != nil {
+			t.Fatalf("create organization: %v", err)
+		}
+		for _, ctx := range []context.Context{ctx, tenant.WithID(ctx, org.ID)} {
+			client, _ := fixture(t, ctx, repos)
+			order := &models.Order{ClientID: client.ID}
+			if err := repos.Order.Create(ctx, order, nil); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			if order.Number != "T-001" {
+				t.Errorf("number = %q, want T-001 in every organization", order.Number)
+			}
+		}
+	})
+}
+
+func TestOrderReferences(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ctx context.Context, repos *repository.Repositories) {
+		client, product := fixture(t, ctx, repos)
+
+		tests := []struct {
+			name  string
+			order models.Order
+			items []models.OrderItem
+			want  error
+		}{
+			{"missing client", models.Order{ClientID: client.ID + 1000}, nil, repository.ErrForeignKey},
+			{"missing product", models.Order{ClientID: client.ID}, []models.OrderItem{item(product.ID+1000, "1", "1")}, repository.ErrForeignKey},
+			{"negative quantity", models.Order{ClientID: client.ID}, []models.OrderItem{item(product.ID, "-1", "1")}, repository.ErrCheck},
+			{"negative price", models.Order{ClientID: client.ID}, []models.OrderItem{item(product.ID, "1", "-1")}, repository.ErrCheck},
+		}
+		for _, tt := range tests {
+			if err := repos.Order.Create(ctx, &tt.order, tt.items); !errors.Is(err, tt.want) {
+				t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+			}
+		}
+
+		order := &models.Order{ClientID: client.ID}
+		if err := repos.Order.Create(ctx, order, []models.OrderItem{item(product.ID, "1", "1")}); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if err := repos.Client.Delete(ctx, client.ID); !errors.Is(err, repository.ErrForeignKey) {
+			t.Errorf("delete referenced client: err = %v, want ErrForeignKey", err)
+		}
+		if err := repos.Product.Delete(ctx, product.ID); !errors.Is(err, repository.ErrForeignKey) {
+			t.Errorf("delete referenced product: err = %v, want ErrForeignKey", err)
+		}
+	})
+}
+
+func TestNotFound(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ctx context.Context, repos *repository.Repositories) {
+		client, _ := fixture(t, ctx, repos)
+		order := &models.Order{ClientID: client.ID}
+		if err := repos.Order.Create(ctx, order, nil); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+
+		const missing = 1 << 40
+		checks := map[string]error{
+			"get client":     get(repos.Client.GetByID(ctx, missing)),
+			"update client":  repos.Client.Update(ctx, &models.Client{ID: missing, Name: "x"}),
+			"delete client":  repos.Client.Delete(ctx, missing),
+			"get product":    get(repos.Product.GetByID(ctx, missing)),
+			"delete product": repos.Product.Delete(ctx, missing),
+			"get order":      get(repos.Order.GetByID(ctx, missing)),
+			"update order":   repos.Order.Update(ctx, &models.Order{ID: missing, ClientID: client.ID, Number: "X"}, nil),
+			"delete order":   repos.Order.Delete(ctx, missing),
+		}
+		for name, err := range checks {
+			if !errors.Is(err, repository.ErrNotFound) {
+				t.Errorf("%s: err = %v, want ErrNotFound", name, err)
+			}
+		}
+
+		// Записи другой организации для репозиториев не существуют
+		org := &models.Organization{Code: "other", Name: "Other"}
+		if err := repos.Organization.Create(ctx, org); err != nil {
+			t.Fatalf("create organization: %v", err)
+		}
+		other := tenant.WithID(ctx, org.ID)
+		if _, err := repos.Order.GetByID(other, order.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("order of another organization: err = %v, want ErrNotFound", err)
+		}
+		if err := repos.Client.Delete(other, client.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("delete client of another organization: err = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func get[T any](_ T, err error) error { return err }
+
+func TestOrderAmounts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ctx context.Context, repos *repository.Repositories) {
+		client, product := fixture(t, ctx, repos)
+
+		order := &models.Order{ClientID: client.ID}
+		items := []models.OrderItem{
+			item(product.ID, "3", "10.50"),
+			item(product.ID, "0.333", "0.10"),
+			item(product.ID, "1.005", "100.00"),
+		}
+		if err := repos.Order.Create(ctx, order, items); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+
+		wantLines := []string{"31.50", "0.03", "100.50"}
+		const wantTotal = "132.03"
+		if order.TotalAmount.String() != wantTotal {
+			t.Errorf("created total = %s, want %s", order.TotalAmount, wantTotal)
+		}
+
+		stored, err := repos.Order.GetByID(ctx, order.ID)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if stored.TotalAmount.String() != wantTotal {
+			t.Errorf("stored total = %s, want %s", stored.TotalAmount, wantTotal)
+		}
+		if len(stored.Items) != len(wantLines) {
+			t.Fatalf("stored %d items, want %d", len(stored.Items), len(wantLines))
+		}
+		for i, want := range wantLines {
+			if got := stored.Items[i].LineAmount.String(); got != want {
+				t.Errorf("line %d amount = %s, want %s", i+1, got, want)
+			}
+		}
+
+		// Изменение пересчитывает суммы по новым позициям
+		stored.Version = 0
+		if err := repos.Order.Update(ctx, stored, []models.OrderItem{item(product.ID, "2", "0.01")}); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		updated, err := repos.Order.GetByID(ctx, order.ID)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if updated.TotalAmount.String() != "0.02" || len(updated.Items) != 1 {
+			t.Errorf("after update total = %s with %d items, want 0.02 with 1 item", updated.TotalAmount, len(updated.Items))
+		}
+	})
+}
+
+func TestOrderVersion(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ctx context.Context, repos *repository.Repositories) {
+		client, _ := fixture(t, ctx, repos)
+		order := &models.Order{ClientID: client.ID}
+		if err := repos.Order.Create(ctx, order, nil); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+
+		stale := *order
+		if err := repos.Order.Update(ctx, order, nil); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		if order.Version != stale.Version+1 {
+			t.Errorf("version after update = %d, want %d", order.Version, stale.Version+1)
+		}
+		if err := repos.Order.Update(ctx, &stale, nil); !errors.Is(err, repository.ErrVersionMismatch) {
+			t.Errorf("stale update: err = %v, want ErrVersionMismatch", err)
+		}
+	})
+}
+
+func TestUnitOfWorkRollback(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ctx context.Context, repos *repository.Repositories) {
+		failure := errors.New("rollback")
+		err := repos.UnitOfWork.Do(ctx, func(tx *repository.Repositories) error {
+			if err := tx.Client.Create(ctx, &models.Client{Name: "Временный"}); err != nil {
+				return err
+			}
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Fatalf("Do: err = %v, want %v", err, failure)
+		}
+
+		page, err := repos.Client.List(ctx, repository.ClientQuery{
+			ListParams: repository.ListParams{Limit: repository.DefaultPageLimit, Sort: "id"},
+		})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		for _, c := range page.Items {
+			if strings.Contains(c.Name, "Временный") {
+				t.Errorf("client %d created in a rolled back transaction", c.ID)
+			}
+		}
+	})
+}
+
+func TestOrderAmountOutOfRange(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ctx context.Context, repos *repository.Repositories) {
+		client, product := fixture(t, ctx, repos)
+
+		tests := map[string][]models.OrderItem{
+			"line": {item(product.ID, "999999999999.999", "9999999999999.99")},
+			"total": {
+				item(product.ID, "1", "9999999999999.99"),
+				item(product.ID, "1", "0.01"),
+			},
+		}
+		for name, items := range tests {
+			order := &models.Order{ClientID: client.ID}
+			err := repos.Order.Create(ctx, order, items)
+			if !errors.Is(err, money.ErrOutOfRange) {
+				t.Errorf("%s: err = %v, want ErrOutOfRange", name, err)
+			}
+		}
+
+		page, err := repos.Order.List(ctx, repository.OrderQuery{
+			ListParams: repository.ListParams{Limit: repository.DefaultPageLimit, Sort: "id"},
+		})
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if page.Total != 0 {
+			t.Errorf("%d orders stored after overflow, want none", page.Total)
+		}
+	})
+}
+
+func TestExternalRefExported(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ctx context.Context, repos *repository.Repositories) {
+		ref := &models.ExternalRef{Entity: models.AuditEntityOrder, EntityID: 7, System: "1c", ExternalID: "guid-7"}
+		if err := repos.ExternalRef.Put(ctx, ref); err != nil {
+			t.Fatal(err)
+		}
+
+		mark := func(id, version int64) {
+			t.Helper()
+			if err := repos.ExternalRef.MarkExported(ctx, models.AuditEntityOrder, id, "1c", version); err != nil {
+				t.Fatalf("mark %d: %v", id, err)
+			}
+		}
+		mark(7, 3)
+		mark(7, 2) // более ранняя версия не уменьшает отметку
+		mark(8, 1) // без идентификатора пропускается
+
+		exported, err := repos.ExternalRef.ListExported(ctx, models.AuditEntityOrder, "1c")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(exported) != 1 || exported[7] != 3 {
+			t.Errorf("exported = %v, want map[7:3]", exported)
+		}
+
+		// Повторное сопоставление сохраняет отметку, новый идентификатор ее сбрасывает
+		ref.Code = "ЗК-7"
+		if err := repos.ExternalRef.Put(ctx, ref); err != nil {
+			t.Fatal(err)
+		}
+		if ref.ExportedVersion != 3 {
+			t.Errorf("same mapping: exported version = %d, want 3", ref.ExportedVersion)
+		}
+		ref = &models.ExternalRef{Entity: models.AuditEntityOrder, EntityID: 7, System: "1c", ExternalID: "guid-7b"}
+		if err := repos.ExternalRef.Put(ctx, ref); err != nil {
+			t.Fatal(err)
+		}
+		if ref.ExportedVersion != 0 {
+			t.Errorf("new identifier: exported version = %d, want 0", ref.ExportedVersion)
+		}
+	})
+}
+
+// pageIDs читает список страницами по limit записей, переходя по NextCursor,
+// и возвращает id записей по страницам
+func pageIDs[T any](t *testing.T, limit int, list func(p repository.ListParams) (repository.Page[T], error), id func(T) int64) [][]int64 {
+	t.Helper()
+	var pages [][]int64
+	p := repository.ListParams{Limit: limit}
+	for {
+		page, err := list(p)
+		if err != nil {
+			t.Fatalf("page %d: %v", len(pages)+1, err)
+		}
+		var ids []int64
+		for _, item := range page.Items {
+			ids = append(ids, id(item))
+		}
+		pages = append(pages, ids)
+		if page.NextCursor == "" {
+			return pages
+		}
+		if len(pages) > 10 {
+			t.Fatal("cursor does not advance")
+		}
+		if p.Cursor, err = repository.DecodeCursor(page.NextCursor); err != nil {
+			t.Fatalf("decode next cursor: %v", err)
+		}
+	}
+}
+
+// TestListCursor проверяет, что записи с равными ключами сортировки
+// упорядочиваются по id и не теряются и не повторяются между страницами
+func TestListCursor(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ctx context.Context, repos *repository.Repositories) {
+		var ids []int64
+		for _, name := range []string{"Бета", "Альфа", "Бета", "Бета", "Вега"} {
+			client := &models.Client{Name: name}
+			if err := repos.Client.Create(ctx, client); err != nil {
+				t.Fatalf("create client: %v", err)
+			}
+			ids = append(ids, client.ID)
+		}
+		clientID := func(c models.Client) int64 { return c.ID }
+
+		tests := []struct {
+			name  string
+			sort  string
+			desc  bool
+			limit int
+			want  [][]int64
+		}{
+			{"by name", "name", false, 2, [][]int64{{ids[1], ids[0]}, {ids[2], ids[3]}, {ids[4]}}},
+			{"by name descending", "name", true, 2, [][]int64{{ids[4], ids[3]}, {ids[2], ids[0]}, {ids[1]}}},
+			{"full last page", "name", false, 5, [][]int64{{ids[1], ids[0], ids[2], ids[3], ids[4]}}},
+			{"equal inn", "inn", false, 3, [][]int64{{ids[0], ids[1], ids[2]}, {ids[3], ids[4]}}},
+		}
+		for _, tt := range tests {
+			got := pageIDs(t, tt.limit, func(p repository.ListParams) (repository.Page[models.Client], error) {
+				p.Sort, p.Desc = tt.sort, tt.desc
+				return repos.Client.List(ctx, repository.ClientQuery{ListParams: p})
+			}, clientID)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: pages = %v, want %v", tt.name, got, tt.want)
+			}
+		}
+
+		// Заказы без позиций имеют равные суммы: курсор по сумме переводится
+		// в деньги и продолжается по id
+		client, _ := fixture(t, ctx, repos)
+		var orders []int64
+		for i := 0; i < 5; i++ {
+			order := &models.Order{ClientID: client.ID}
+			if err := repos.Order.Create(ctx, order, nil); err != nil {
+				t.Fatalf("create order: %v", err)
+			}
+			orders = append(orders, order.ID)
+		}
+		got := pageIDs(t, 2, func(p repository.ListParams) (repository.Page[models.Order], error) {
+			p.Sort, p.Desc = "total_amount", true
+			return repos.Order.List(ctx, repository.OrderQuery{ListParams: p})
+		}, func(o models.Order) int64 { return o.ID })
+		want := [][]int64{{orders[4], orders[3]}, {orders[2], orders[1]}, {orders[0]}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("orders by total: pages = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestDecodeCursor(t *testing.T) {
+	next := repository.Cursor{Sort: "name", Value: "Бета", ID: 3}.Encode()
+	if c, err := repository.DecodeCursor(next); err != nil || *c != (repository.Cursor{Sort: "name", Value: "Бета", ID: 3}) {
+		t.Errorf("DecodeCursor(Encode()) = %+v, %v", c, err)
+	}
+	for _, s := range []string{"garbage!", "e30=", "bm90IGpzb24", next + "*"} {
+		if _, err := repository.DecodeCursor(s); err == nil {
+			t.Errorf("DecodeCursor(%q) accepted a tampered cursor", s)
+		}
+	}
+}
+
+// TestUpsertByExternalID проверяет, что повторная загрузка по внешнему
+// идентификатору изменяет ту же запись и не размножает сопоставления
+func TestUpsertByExternalID(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ctx context.Context, repos *repository.Repositories) {
+		var updated []int64
+		update := func(id int64) error {
+			if _, err := repos.Client.GetByID(ctx, id); err != nil {
+				return err
+			}
+			updated = append(updated, id)
+			return nil
+		}
+		create := func() (int64, error) {
+			client := &models.Client{Name: "ООО Ромашка"}
+			err := repos.Client.Create(ctx, client)
+			return client.ID, err
+		}
+		upsert := func(code string) (bool, int64) {
+			t.Helper()
+			ref := &models.ExternalRef{Entity: models.AuditEntityClient, System: "1c", ExternalID: "guid-1", Code: code}
+			created, err := repository.UpsertByExternalID(ctx, repos.ExternalRef, ref, update, create)
+			if err != nil {
+				t.Fatalf("upsert: %v", err)
+			}
+			return created, ref.EntityID
+		}
+
+		created, id := upsert("000001")
+		if !created || len(updated) != 0 {
+			t.Fatalf("first upsert: created = %t, updated %v", created, updated)
+		}
+		for i := 0; i < 2; i++ {
+			if created, again := upsert("000002"); created || again != id {
+				t.Errorf("repeated upsert: created = %t, id %d, want the same client %d", created, again, id)
+			}
+		}
+		if len(updated) != 2 || updated[0] != id || updated[1] != id {
+			t.Errorf("updated %v, want client %d twice", updated, id)
+		}
+		refs, err := repos.ExternalRef.List(ctx, models.AuditEntityClient, "1c")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(refs) != 1 || refs[id] != "guid-1" {
+			t.Errorf("refs = %v, want only guid-1 for client %d", refs, id)
+		}
+		if byEntity, _ := repos.ExternalRef.ListByEntity(ctx, models.AuditEntityClient, id); len(byEntity) != 1 || byEntity[0].Code != "000002" {
+			t.Errorf("refs of client %d = %+v, want one with the new code", id, byEntity)
+		}
+
+		// Тот же идентификатор у товара не относится к клиенту
+		if _, err := repos.ExternalRef.Find(ctx, models.AuditEntityProduct, "1c", "guid-1"); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("product with the client's external id: err = %v, want ErrNotFound", err)
+		}
+
+		// Запись удалена: идентификатор сопоставляется с новой записью
+		if err := repos.Client.Delete(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		created, recreated := upsert("000002")
+		if !created || recreated == id {
+			t.Errorf("upsert after delete: created = %t, id %d, want a new client", created, recreated)
+		}
+		if found, err := repos.ExternalRef.Find(ctx, models.AuditEntityClient, "1c", "guid-1"); err != nil || found != recreated {
+			t.Errorf("Find = %d, %v; want %d", found, err, recreated)
+		}
+
+		// Другие ошибки изменения не создают запись
+		failure := errors.New("update failed")
+		ref := &models.ExternalRef{Entity: models.AuditEntityClient, System: "1c", ExternalID: "guid-1"}
+		_, err = repository.UpsertByExternalID(ctx, repos.ExternalRef, ref,
+			func(int64) error { return failure },
+			func() (int64, error) { t.Error("record created after a failed update"); return 0, nil })
+		if !errors.Is(err, failure) {
+			t.Errorf("failed update: err = %v, want %v", err, failure)
+		}
+	})
+}
+
+// movement возвращает движение регистра по клиенту на сумму amount
+func movement(period time.Time, kind models.MovementKind, clientID int64, amount string) models.OrdersByClientMovement {
+	return models.OrdersByClientMovement{Period: period, RecordKind: kind, ClientID: clientID, Amount: money.MustParseMoney(amount)}
+}
+
+// TestOrdersByClientRegister проверяет, что остатки и обороты складываются из
+// месячных итогов и движений текущего месяца одинаково на обеих реализациях
+func TestOrdersByClientRegister(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ctx context.Context, repos *repository.Repositories) {
+		first, product := fixture(t, ctx, repos)
+		second := &models.Client{Name: "ООО Лютик", INN: "5001007329"}
+		if err := repos.Client.Create(ctx, second); err != nil {
+			t.Fatal(err)
+		}
+		var recorders []int64
+		for i := 0; i < 2; i++ {
+			order := &models.Order{ClientID: first.ID}
+			if err := repos.Order.Create(ctx, order, []models.OrderItem{item(product.ID, "1", "1")}); err != nil {
+				t.Fatal(err)
+			}
+			recorders = append(recorders, order.ID)
+		}
+
+		day := func(month time.Month, d, hour int) time.Time {
+			return time.Date(2024, month, d, hour, 0, 0, 0, time.UTC)
+		}
+		sets := [][]models.OrdersByClientMovement{{
+			movement(day(1, 20, 12), models.MovementReceipt, first.ID, "100"),
+			movement(day(1, 31, 23), models.MovementReceipt, first.ID, "10"),
+			movement(day(2, 10, 12), models.MovementReceipt, first.ID, "50"),
+			movement(day(2, 20, 12), models.MovementExpense, first.ID, "30"),
+		}, {
+			movement(day(3, 1, 0), models.MovementReceipt, second.ID, "200"),
+		}}
+		for i, set := range sets {
+			if err := repos.OrdersByClient.WriteMovements(ctx, recorders[i], set); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		balance := func(at time.Time) []string {
+			t.Helper()
+			balances, err := repos.OrdersByClient.GetBalance(ctx, at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, b := range balances {
+				got = append(got, fmt.Sprintf("%s %s", b.Client.Name, b.OrdersSum))
+			}
+			return got
+		}
+		for _, tt := range []struct {
+			at   time.Time
+			want []string
+		}{
+			{day(1, 20, 11), nil},
+			{day(2, 15, 0), []string{"ООО Ромашка 160.00"}},
+			// Движение в момент at входит в остаток
+			{day(2, 20, 12), []string{"ООО Ромашка 130.00"}},
+			{day(2, 29, 23), []string{"ООО Ромашка 130.00"}},
+			{day(3, 1, 0), []string{"ООО Лютик 200.00", "ООО Ромашка 130.00"}},
+		} {
+			if got := balance(tt.at); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("balance at %s = %q, want %q", tt.at.Format(time.DateTime), got, tt.want)
+			}
+		}
+
+		for _, tt := range []struct {
+			from, to time.Time
+			want     []string
+		}{
+			{day(1, 25, 0), day(2, 15, 0), []string{"ООО Ромашка 60.00 0.00 60.00"}},
+			{day(1, 31, 23), day(3, 1, 0), []string{"ООО Лютик 200.00 0.00 200.00", "ООО Ромашка 60.00 30.00 30.00"}},
+			{day(3, 2, 0), day(3, 31, 0), nil},
+		} {
+			turnovers, err := repos.OrdersByClient.GetTurnovers(ctx, tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, o := range turnovers {
+				got = append(got, fmt.Sprintf("%s %s %s %s", o.Client.Name, o.Receipt, o.Expense, o.Turnover))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("turnovers %s - %s = %q, want %q", tt.from.Format(time.DateTime), tt.to.Format(time.DateTime), got, tt.want)
+			}
+		}
+
+		// Удаление движений вычитает их из месячных итогов; обнуленные итоги
+		// остаются в остатках нулевой строкой
+		if err := repos.OrdersByClient.DeleteMovements(ctx, recorders[0]); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := balance(day(3, 1, 0)), []string{"ООО Лютик 200.00", "ООО Ромашка 0.00"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("balance after delete = %q, want %q", got, want)
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/numbering"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+	"github.com/lib/pq"
+)
+
+// rlsDSNEnv — переменная окружения со строкой подключения к базе Postgres
+// с примененными миграциями. Подключаться нужно обычной ролью: политики
+// не действуют на суперпользователя и роли с BYPASSRLS.
+const rlsDSNEnv = "ORDERFLOW_TEST_DSN"
+
+// rlsTables — таблицы учета под политиками миграций 000012_row_level_security
+// и 000014_external_refs
+var rlsTables = []string{
+	"clients", "products", "orders", "order_items", "order_history",
+	"orders_by_client_movements", "orders_by_client_totals", "number_sequences", "audit_log",
+	"external_refs",
+}
+
+// rlsTenant — организация теста и созданные в ней записи
+type rlsTenant struct {
+	ctx                        context.Context
+	id, client, product, order int64
+}
+
+// TestRLSIsolation проверяет, что политики RLS изолируют организации
+// независимо от отборов в запросах: соединения NewDB передают базе
+// организацию из контекста через orderflow.organization_id, и запросы без
+// отбора не читают, не изменяют и не создают строки другой организации.
+// Все записи создаются в транзакции, которая откатывается.
+func TestRLSIsolation(t *testing.T) {
+	dsn := os.Getenv(rlsDSNEnv)
+	if dsn == "" {
+		t.Skip(rlsDSNEnv + " is not set")
+	}
+	db, err := repository.NewDB(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	checkRLSSetup(t, ctx, db)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	tenants := make([]*rlsTenant, 2)
+	for i := range tenants {
+		org := &rlsTenant{}
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO organizations (code, name) VALUES ($1, $2) RETURNING id`,
+			fmt.Sprintf("rls-test-%s-%d", suffix, i), "RLS test "+strconv.Itoa(i),
+		).Scan(&org.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		org.ctx = tenant.WithUser(tenant.WithID(ctx, org.id), "rls-test")
+		seedRLSTenant(t, tx, org, suffix)
+		tenants[i] = org
+	}
+
+	for i, own := range tenants {
+		other := tenants[1-i]
+
+		var setting string
+		if err := tx.QueryRowContext(own.ctx, `SELECT current_setting($1)`, repository.SettingOrganization).Scan(&setting); err != nil {
+			t.Fatal(err)
+		}
+		if want := strconv.FormatInt(own.id, 10); setting != want {
+			t.Errorf("organization %d: %s = %q, want %q", own.id, repository.SettingOrganization, setting, want)
+		}
+
+		// Запросы без отбора по организации, как в репозитории с ошибкой
+		for _, table := range []string{"clients", "products", "orders", "order_items"} {
+			var ownRows, foreignRows int
+			err := tx.QueryRowContext(own.ctx,
+				`SELECT COUNT(*) FILTER (WHERE organization_id = $1), COUNT(*) FILTER (WHERE organization_id <> $1) FROM `+table,
+				own.id,
+			).Scan(&ownRows, &foreignRows)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ownRows == 0 || foreignRows != 0 {
+				t.Errorf("organization %d: SELECT from %s: own %d, foreign %d rows", own.id, table, ownRows, foreignRows)
+			}
+		}
+
+		var found int
+		if err := tx.QueryRowContext(own.ctx, `SELECT COUNT(*) FROM orders WHERE id = $1`, other.order).Scan(&found); err != nil {
+			t.Fatal(err)
+		}
+		if found != 0 {
+			t.Errorf("organization %d: SELECT finds order %d of organization %d", own.id, other.order, other.id)
+		}
+		_, err := repository.NewOrderRepository(tx, numbering.DefaultOrderFormat).GetByID(own.ctx, other.order)
+		if !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("organization %d: GetByID of the other organization's order: err = %v, want ErrNotFound", own.id, err)
+		}
+
+		for _, stmt := range []struct {
+			name, query string
+			id          int64
+		}{
+			{"UPDATE", `UPDATE clients SET name = name WHERE id = $1`, other.client},
+			{"DELETE", `DELETE FROM orders WHERE id = $1`, other.order},
+		} {
+			res, err := tx.ExecContext(own.ctx, stmt.query, stmt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n, err := res.RowsAffected(); err != nil || n != 0 {
+				t.Errorf("organization %d: %s of the other organization's row affected %d row(s), %v", own.id, stmt.name, n, err)
+			}
+		}
+
+		err = insertForeignClient(t, own.ctx, tx, other.id)
+		var pqErr *pq.Error
+		if !errors.As(err, &pqErr) || pqErr.Code != "42501" {
+			t.Errorf("organization %d: INSERT into organization %d: err = %v, want insufficient_privilege", own.id, other.id, err)
+		}
+	}
+
+	// Без организации в контексте строки учета не видны вовсе
+	var visible int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM orders`).Scan(&visible); err != nil {
+		t.Fatal(err)
+	}
+	if visible != 0 {
+		t.Errorf("without organization %d orders are visible, want none", visible)
+	}
+}
+
+// checkRLSSetup проверяет роль подключения и включение политик на таблицах
+func checkRLSSetup(t *testing.T, ctx context.Context, db *sql.DB) {
+	t.Helper()
+	var role string
+	var super, bypass bool
+	err := db.QueryRowContext(ctx,
+		`SELECT rolname, rolsuper, rolbypassrls FROM pg_roles WHERE rolname = current_user`,
+	).Scan(&role, &super, &bypass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if super || bypass {
+		t.Fatalf("role %s is a superuser or has BYPASSRLS and ignores policies; connect as an ordinary role", role)
+	}
+
+	for _, table := range rlsTables {
+		var enabled, forced bool
+		err := db.QueryRowContext(ctx,
+			`SELECT relrowsecurity, relforcerowsecurity FROM pg_class WHERE oid = to_regclass($1)`, table,
+		).Scan(&enabled, &forced)
+		if errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("table %s does not exist", table)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !enabled || !forced {
+			t.Errorf("table %s: row security enabled %t, forced %t", table, enabled, forced)
+		}
+	}
+}
+
+// seedRLSTenant создает клиента, товар и заказ с позицией в организации org
+func seedRLSTenant(t *testing.T, tx *sql.Tx, org *rlsTenant, suffix string) {
+	t.Helper()
+	if err := tx.QueryRowContext(org.ctx,
+		`INSERT INTO clients (organization_id, name) VALUES ($1, 'RLS test client') RETURNING id`, org.id,
+	).Scan(&org.client); err != nil {
+		t.Fatalf("seed organization %d: %v", org.id, err)
+	}
+	if err := tx.QueryRowContext(org.ctx,
+		`INSERT INTO products (organization_id, name, unit) VALUES ($1, 'RLS test product', 'pcs') RETURNING id`, org.id,
+	).Scan(&org.product); err != nil {
+		t.Fatalf("seed organization %d: %v", org.id, err)
+	}
+	if err := tx.QueryRowContext(org.ctx,
+		`INSERT INTO orders (organization_id, client_id, number) VALUES ($1, $2, $3) RETURNING id`,
+		org.id, org.client, "RLS-"+suffix,
+	).Scan(&org.order); err != nil {
+		t.Fatalf("seed organization %d: %v", org.id, err)
+	}
+	if _, err := tx.ExecContext(org.ctx,
+		`INSERT INTO order_items (organization_id, order_id, product_id, quantity, price) VALUES ($1, $2, $3, 1, 1)`,
+		org.id, org.order, org.product,
+	); err != nil {
+		t.Fatalf("seed organization %d: %v", org.id, err)
+	}
+}
+
+// insertForeignClient пытается создать клиента другой организации. Ошибка
+// прерывает транзакцию, поэтому попытка выполняется в точке сохранения.
+func insertForeignClient(t *testing.T, ctx context.Context, tx *sql.Tx, organization int64) error {
+	t.Helper()
+	if _, err := tx.ExecContext(ctx, `SAVEPOINT rls_test`); err != nil {
+		t.Fatal(err)
+	}
+	_, insertErr := tx.ExecContext(ctx,
+		`INSERT INTO clients (organization_id, name) VALUES ($1, 'RLS test intruder')`, organization)
+	if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT rls_test`); err != nil {
+		t.Fatal(err)
+	}
+	return insertErr
+}
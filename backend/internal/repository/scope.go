@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+)
+
+// Переменные сеанса Postgres, по которым политики RLS (миграция
+// 000012_row_level_security) отбирают строки организации. Без организации
+// политики не пропускают ни одной строки учета.
+const (
+	SettingOrganization = "orderflow.organization_id"
+	SettingUser         = "orderflow.user"
+)
+
+const setScopeQuery = `SELECT set_config('` + SettingOrganization + `', $1, false), set_config('` + SettingUser + `', $2, false)`
+
+// scope — организация и исполнитель, от имени которых выполняется запрос
+type scope struct {
+	organization string
+	user         string
+}
+
+func scopeOf(ctx context.Context) scope {
+	var s scope
+	if id, ok := tenant.FromContext(ctx); ok {
+		s.organization = strconv.FormatInt(id, 10)
+	}
+	s.user = tenant.User(ctx)
+	return s
+}
+
+// pqConn — методы соединения lib/pq, которые использует database/sql
+type pqConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+// scopedConnector выдает соединения, которые перед каждым запросом и
+// транзакцией передают базе организацию и исполнителя из контекста запроса.
+// Так политики RLS защищают данные, даже если запрос репозитория забыл
+// отбор по организации.
+type scopedConnector struct {
+	driver.Connector
+}
+
+func (c scopedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pc, ok := conn.(pqConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("postgres driver connection %T does not support context methods", conn)
+	}
+	return &scopedConn{pqConn: pc}, nil
+}
+
+// scopedConn запоминает переданную базе область, чтобы не повторять
+// set_config, пока соединение обслуживает ту же организацию и исполнителя.
+// database/sql не использует соединение из нескольких горутин одновременно.
+type scopedConn struct {
+	pqConn
+	// current — область, установленная в сеансе; nil — неизвестна
+	current *scope
+	inTx    bool
+	// txChanged: область менялась внутри текущей транзакции, и откат ее вернет
+	txChanged bool
+}
+
+// apply устанавливает переменные сеанса для области ctx
+func (c *scopedConn) apply(ctx context.Context) error {
+	s := scopeOf(ctx)
+	if c.current != nil && *c.current == s {
+		return nil
+	}
+
+	_, err := c.pqConn.ExecContext(ctx, setScopeQuery, []driver.NamedValue{
+		{Ordinal: 1, Value: s.organization},
+		{Ordinal: 2, Value: s.user},
+	})
+	if err != nil {
+		c.current = nil
+		return err
+	}
+	c.current = &s
+	c.txChanged = c.txChanged || c.inTx
+	return nil
+}
+
+func (c *scopedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.apply(ctx); err != nil {
+		return nil, err
+	}
+	return c.pqConn.ExecContext(ctx, query, args)
+}
+
+func (c *scopedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.apply(ctx); err != nil {
+		return nil, err
+	}
+	return c.pqConn.QueryContext(ctx, query, args)
+}
+
+func (c *scopedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := c.apply(ctx); err != nil {
+		return nil, err
+	}
+	st, err := c.pqConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	ps, ok := st.(pqStmt)
+	if !ok {
+		st.Close()
+		return nil, fmt.Errorf("postgres driver statement %T does not support context methods", st)
+	}
+	return &scopedStmt{pqStmt: ps, conn: c}, nil
+}
+
+func (c *scopedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	tx, err := c.pqConn.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.inTx, c.txChanged = true, false
+
+	if err := c.apply(ctx); err != nil {
+		c.end(tx.Rollback())
+		return nil, err
+	}
+	return &scopedTx{tx: tx, conn: c}, nil
+}
+
+// end завершает транзакцию соединения. Откат возвращает переменные сеанса,
+// установленные в транзакции, поэтому область после него неизвестна.
+func (c *scopedConn) end(err error) error {
+	if err != nil || c.txChanged {
+		c.current = nil
+	}
+	c.inTx, c.txChanged = false, false
+	return err
+}
+
+// pqStmt — методы подготовленного запроса lib/pq, которые использует database/sql
+type pqStmt interface {
+	driver.Stmt
+	driver.StmtExecContext
+	driver.StmtQueryContext
+}
+
+// scopedStmt передает базе область контекста перед каждым выполнением
+// подготовленного запроса: sql.Stmt выполняется в контексте вызова, и
+// область, установленная при подготовке, к этому времени могла смениться.
+// database/sql вызывает только методы с контекстом, поэтому Exec и Query
+// без контекста не переопределяются.
+type scopedStmt struct {
+	pqStmt
+	conn *scopedConn
+}
+
+func (s *scopedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := s.conn.apply(ctx); err != nil {
+		return nil, err
+	}
+	return s.pqStmt.ExecContext(ctx, args)
+}
+
+func (s *scopedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if err := s.conn.apply(ctx); err != nil {
+		return nil, err
+	}
+	return s.pqStmt.QueryContext(ctx, args)
+}
+
+type scopedTx struct {
+	tx   driver.Tx
+	conn *scopedConn
+}
+
+func (t *scopedTx) Commit() error {
+	err := t.tx.Commit()
+	// После фиксации установленная область остается в сеансе
+	t.conn.txChanged = false
+	return t.conn.end(err)
+}
+
+func (t *scopedTx) Rollback() error {
+	return t.conn.end(t.tx.Rollback())
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+)
+
+// fakeConn — соединение, которое записывает установленную область и
+// выполненные запросы вместо обращения к Postgres
+type fakeConn struct {
+	log *[]string
+}
+
+func (c fakeConn) record(query string, args []driver.NamedValue) {
+	if query == setScopeQuery {
+		*c.log = append(*c.log, "scope "+args[0].Value.(string))
+		return
+	}
+	*c.log = append(*c.log, query)
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c fakeConn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	return fakeStmt{conn: c, query: query}, nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.record(query, args)
+	return driver.RowsAffected(0), nil
+}
+
+func (c fakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+func (c fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+func (c fakeConn) Close() error                       { return nil }
+func (c fakeConn) Ping(context.Context) error         { return nil }
+func (c fakeConn) ResetSession(context.Context) error { return nil }
+func (c fakeConn) IsValid() bool                      { return true }
+
+type fakeStmt struct {
+	conn  fakeConn
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("exec without context")
+}
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("query without context")
+}
+
+func (s fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s fakeStmt) QueryContext(context.Context, []driver.NamedValue) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+type fakeConnector struct {
+	log *[]string
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn(c), nil }
+func (c fakeConnector) Driver() driver.Driver                        { return nil }
+
+// TestScopedStmt проверяет, что подготовленный запрос выполняется в области
+// контекста выполнения, а не той, в которой был подготовлен
+func TestScopedStmt(t *testing.T) {
+	var log []string
+	db := sql.OpenDB(scopedConnector{fakeConnector{log: &log}})
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	first := tenant.WithID(context.Background(), 1)
+	second := tenant.WithID(context.Background(), 2)
+
+	stmt, err := db.PrepareContext(first, "DELETE FROM clients")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	for _, ctx := range []context.Context{second, second, first} {
+		if _, err := stmt.ExecContext(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"scope 1", "scope 2", "DELETE FROM clients", "DELETE FROM clients", "scope 1", "DELETE FROM clients"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("queries = %q, want %q", log, want)
+	}
+}
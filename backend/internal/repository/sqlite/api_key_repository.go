@@ -0,0 +1,135 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+const apiKeyColumns = `id, name, prefix, key_hash, scopes, organization_id, rate_limit, expires_at, revoked_at, last_used_at, created_by, created_at`
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	key.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	query := `
+		INSERT INTO api_keys (name, prefix, key_hash, scopes, organization_id, rate_limit, expires_at, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`
+
+	err := r.db.QueryRowContext(ctx, query,
+		key.Name, key.Prefix, key.KeyHash, strings.Join(key.Scopes, ","), key.OrganizationID, key.RateLimit,
+		nullTS(key.ExpiresAt), key.CreatedBy, ts(key.CreatedAt),
+	).Scan(&key.ID)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *apiKeyRepository) GetByID(ctx context.Context, id int64) (*models.APIKey, error) {
+	return r.get(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = ?`, id)
+}
+
+func (r *apiKeyRepository) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	return r.get(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = ?`, hash)
+}
+
+func (r *apiKeyRepository) get(ctx context.Context, query string, arg interface{}) (*models.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, arg))
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (r *apiKeyRepository) List(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *apiKeyRepository) Revoke(ctx context.Context, id int64, at time.Time) error {
+	query := `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?`
+	return r.exec(ctx, query, ts(at), id)
+}
+
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, id int64, at time.Time) error {
+	query := `UPDATE api_keys SET last_used_at = ? WHERE id = ?`
+	return r.exec(ctx, query, ts(at), id)
+}
+
+func (r *apiKeyRepository) exec(ctx context.Context, query string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return translateError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+// scanAPIKey читает строку с колонками apiKeyColumns
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	var scopes string
+	var expires, revoked, used sql.NullTime
+	err := row.Scan(
+		&key.ID, &key.Name, &key.Prefix, &key.KeyHash, &scopes, &key.OrganizationID, &key.RateLimit,
+		&expires, &revoked, &used, &key.CreatedBy, &key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	key.Scopes = []string{}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	key.ExpiresAt, key.RevokedAt, key.LastUsedAt = nullTime(expires), nullTime(revoked), nullTime(used)
+	return key, nil
+}
+
+// nullTS передает отсутствующее время как NULL
+func nullTS(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return ts(*t)
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
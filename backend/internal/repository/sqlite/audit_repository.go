@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+)
+
+func (r *auditRepository) Append(ctx context.Context, entry *models.AuditEntry) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	query := `
+		INSERT INTO audit_log (organization_id, entity, entity_id, action, actor, request_id, reason, before_data, after_data, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`
+
+	return r.db.QueryRowContext(ctx, query,
+		org, entry.Entity, entry.EntityID, entry.Action, entry.Actor, entry.RequestID, entry.Reason,
+		jsonArg(entry.Before), jsonArg(entry.After), ts(entry.CreatedAt),
+	).Scan(&entry.ID)
+}
+
+func (r *auditRepository) List(ctx context.Context, entity string, entityID int64) ([]models.AuditEntry, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, entity, entity_id, action, actor, request_id, reason, before_data, after_data, created_at
+		FROM audit_log
+		WHERE organization_id = ? AND entity = ? AND entity_id = ?
+		ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, org, entity, entityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		var before, after []byte
+		err := rows.Scan(
+			&e.ID, &e.Entity, &e.EntityID, &e.Action, &e.Actor, &e.RequestID, &e.Reason,
+			&before, &after, &e.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		e.Before, e.After = before, after
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// jsonArg передает пустой снимок как NULL
+func jsonArg(data []byte) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
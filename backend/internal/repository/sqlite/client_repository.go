@@ -0,0 +1,255 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+)
+
+func (r *clientRepository) Create(ctx context.Context, client *models.Client) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO clients (organization_id, name, inn)
+		VALUES (?, ?, ?)
+		RETURNING id, version`
+
+	err = r.db.QueryRowContext(ctx, query, org, client.Name, client.INN).Scan(&client.ID, &client.Version)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *clientRepository) GetByID(ctx context.Context, id int64) (*models.Client, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, name, inn, version
+		FROM clients
+		WHERE id = ? AND organization_id = ?`
+
+	client := &models.Client{}
+	err = r.db.QueryRowContext(ctx, query, id, org).Scan(&client.ID, &client.Name, &client.INN, &client.Version)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (r *clientRepository) GetByINN(ctx context.Context, inn string) (*models.Client, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, name, inn, version
+		FROM clients
+		WHERE organization_id = ? AND inn = ?
+		ORDER BY id
+		LIMIT 1`
+
+	client := &models.Client{}
+	err = r.db.QueryRowContext(ctx, query, org, inn).Scan(&client.ID, &client.Name, &client.INN, &client.Version)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (r *clientRepository) Update(ctx context.Context, client *models.Client) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE clients
+		SET name = ?, inn = ?, version = version + 1
+		WHERE id = ? AND organization_id = ? AND (? = 0 OR version = ?)
+		RETURNING version`
+
+	err = r.db.QueryRowContext(ctx, query, client.Name, client.INN, client.ID, org, client.Version, client.Version).Scan(&client.Version)
+	if err == sql.ErrNoRows {
+		return staleOrMissingIn(ctx, r.db, "clients", org, client.ID)
+	}
+	if err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *clientRepository) Delete(ctx context.Context, id int64) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM clients WHERE id = ? AND organization_id = ?`
+
+	result, err := r.db.ExecContext(ctx, query, id, org)
+	if err != nil {
+		return translateError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return translateError(err)
+	}
+	if rowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *clientRepository) List(ctx context.Context, q repository.ClientQuery) (repository.Page[models.Client], error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return repository.Page[models.Client]{}, err
+	}
+
+	w := clientWhere(org, q)
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM clients"+w.sql(), w.args...).Scan(&total); err != nil {
+		return repository.Page[models.Client]{}, err
+	}
+
+	paging, err := w.page(q.ListParams, clientSortColumns, "id")
+	if err != nil {
+		return repository.Page[models.Client]{}, err
+	}
+	query := `
+		SELECT id, name, inn, version
+		FROM clients` + w.sql() + paging
+
+	rows, err := r.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return repository.Page[models.Client]{}, err
+	}
+	defer rows.Close()
+
+	var clients []models.Client
+	for rows.Next() {
+		var client models.Client
+		if err := rows.Scan(&client.ID, &client.Name, &client.INN, &client.Version); err != nil {
+			return repository.Page[models.Client]{}, err
+		}
+		clients = append(clients, client)
+	}
+
+	if err = rows.Err(); err != nil {
+		return repository.Page[models.Client]{}, err
+	}
+
+	return repository.NewPage(clients, total, q.ListParams, repository.ClientSortValue, func(c models.Client) int64 { return c.ID }), nil
+}
+
+// clientWhere отбирает клиентов организации org по q
+func clientWhere(org int64, q repository.ClientQuery) where {
+	var w where
+	w.add("organization_id = ?", org)
+	if q.Search != "" {
+		w.add("(name LIKE ? ESCAPE '\\' OR inn LIKE ? ESCAPE '\\')", likePattern(q.Search), likePattern(q.Search))
+	}
+	return w
+}
+
+// Each читает отбор построчно и передает записи fn
+func (r *clientRepository) Each(ctx context.Context, q repository.ClientQuery, fn func(models.Client) error) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := clientWhere(org, q)
+	order, err := orderBy(q.ListParams, clientSortColumns, "id")
+	if err != nil {
+		return err
+	}
+	query := `
+		SELECT id, name, inn, version
+		FROM clients` + w.sql() + order
+
+	rows, err := r.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var client models.Client
+		if err := rows.Scan(&client.ID, &client.Name, &client.INN, &client.Version); err != nil {
+			return err
+		}
+		if err := fn(client); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetClientOrders возвращает все заказы клиента
+func (r *clientRepository) GetClientOrders(ctx context.Context, clientID int64) ([]models.Order, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT o.id, o.client_id, o.date, o.number, o.total_amount, o.status, o.created_at, o.version
+		FROM orders o
+		WHERE o.client_id = ? AND o.organization_id = ?`
+
+	rows, err := r.db.QueryContext(ctx, query, clientID, org)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	for rows.Next() {
+		var order models.Order
+		err := rows.Scan(
+			&order.ID,
+			&order.ClientID,
+			&order.Date,
+			&order.Number,
+			&order.TotalAmount,
+			&order.Status,
+			&order.CreatedAt,
+			&order.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
@@ -0,0 +1,386 @@
+// Package sqlite реализует интерфейсы репозиториев поверх локального файла SQLite.
+// Позволяет запускать сервис одним бинарным файлом без Postgres (STORAGE=sqlite).
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/numbering"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+//go:embed schema.sql
+var schema string
+
+// timeLayout хранит время в UTC с фиксированной шириной, чтобы строки
+// сравнивались в SQL так же, как моменты времени
+const timeLayout = "2006-01-02 15:04:05.000000"
+
+// NewDB открывает файл базы, включает внешние ключи и создает схему
+func NewDB(path string) (*sql.DB, error) {
+	// _txlock=immediate захватывает блокировку на запись в начале транзакции:
+	// это заменяет SELECT ... FOR UPDATE из реализации на Postgres
+	dsn := "file:" + path + "?_foreign_keys=on&_busy_timeout=5000&_txlock=immediate&_journal_mode=WAL"
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if _, err = db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err = addColumns(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err = upgradeOrganizations(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err = addIndexes(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if _, err = db.Exec(bindUsers); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// addedColumns — колонки, появившиеся в схеме после создания таблиц.
+// CREATE TABLE IF NOT EXISTS не меняет существующие файлы, поэтому
+// такие колонки добавляются отдельно, а backfill заполняет их по старым данным.
+var addedColumns = []struct{ table, column, definition, backfill string }{
+	{"clients", "version", "INTEGER NOT NULL DEFAULT 1", ""},
+	{"products", "version", "INTEGER NOT NULL DEFAULT 1", ""},
+	{"orders", "version", "INTEGER NOT NULL DEFAULT 1", ""},
+	// Статус заменил признак is_confirmed, который остается в старых файлах неиспользуемым
+	{"orders", "status", "VARCHAR(20) NOT NULL DEFAULT 'draft'",
+		"UPDATE orders SET status = 'confirmed' WHERE is_confirmed"},
+	{"order_history", "from_status", "VARCHAR(20) NOT NULL DEFAULT ''",
+		"UPDATE order_history SET from_status = CASE action WHEN 'confirm' THEN 'draft' ELSE 'confirmed' END"},
+	{"order_history", "to_status", "VARCHAR(20) NOT NULL DEFAULT ''",
+		"UPDATE order_history SET to_status = CASE action WHEN 'confirm' THEN 'confirmed' ELSE 'draft' END"},
+	{"order_history", "actor", "VARCHAR(100) NOT NULL DEFAULT ''", ""},
+	// Данные, созданные до разделения на организации, принадлежат организации 1.
+	// Ограничения с organization_id добавляет upgradeOrganizations.
+	{"clients", "organization_id", "INTEGER NOT NULL DEFAULT 1", ""},
+	{"products", "organization_id", "INTEGER NOT NULL DEFAULT 1", ""},
+	{"orders", "organization_id", "INTEGER NOT NULL DEFAULT 1", ""},
+	{"order_items", "organization_id", "INTEGER NOT NULL DEFAULT 1", ""},
+	{"order_history", "organization_id", "INTEGER NOT NULL DEFAULT 1", ""},
+	{"orders_by_client_movements", "organization_id", "INTEGER NOT NULL DEFAULT 1", ""},
+	{"orders_by_client_totals", "organization_id", "INTEGER NOT NULL DEFAULT 1", ""},
+	{"number_sequences", "organization_id", "INTEGER NOT NULL DEFAULT 1", ""},
+	{"audit_log", "organization_id", "INTEGER NOT NULL DEFAULT 1", ""},
+	{"api_keys", "organization_id", "INTEGER NOT NULL DEFAULT 1", ""},
+	{"users", "organization_id", "INTEGER", ""},
+	{"external_refs", "code", "VARCHAR(50) NOT NULL DEFAULT ''", ""},
+	{"external_refs", "exported_version", "INTEGER NOT NULL DEFAULT 0", ""},
+}
+
+func addColumns(db *sql.DB) error {
+	for _, c := range addedColumns {
+		var n int
+		err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", c.table, c.column).Scan(&n)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE " + c.table + " ADD COLUMN " + c.column + " " + c.definition); err != nil {
+			return err
+		}
+		if c.backfill != "" {
+			if _, err := db.Exec(c.backfill); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addedIndexes — индексы по колонкам, которых может не быть в старом файле
+// при выполнении schema.sql, поэтому они создаются после addColumns
+var addedIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_clients_inn ON clients (organization_id, inn)",
+	"CREATE INDEX IF NOT EXISTS idx_products_name_unit ON products (organization_id, name, unit)",
+}
+
+func addIndexes(db *sql.DB) error {
+	for _, stmt := range addedIndexes {
+		if _, err := db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bindUsers переносит в организацию 1 пользователей без организации, кроме
+// администраторов, как миграция 000016_user_organizations в Postgres. Без
+// организации пользователь работал бы с данными всех организаций.
+const bindUsers = `
+	UPDATE users SET organization_id = 1
+	WHERE organization_id IS NULL AND ',' || roles || ',' NOT LIKE '%,admin,%'`
+
+// organizationTables — таблицы учета, которые пересоздаются по schema.sql,
+// чтобы получить ограничения с organization_id: уникальность номера заказа
+// в организации и внешние ключи на записи той же организации
+var organizationTables = []string{
+	"clients", "products", "orders", "order_items", "order_history",
+	"orders_by_client_movements", "orders_by_client_totals", "number_sequences",
+}
+
+// schemaVersionOrganizations — значение PRAGMA user_version после upgradeOrganizations
+const schemaVersionOrganizations = 1
+
+// upgradeOrganizations пересоздает таблицы учета файлов, созданных до
+// разделения на организации. SQLite не изменяет ограничения существующих
+// таблиц, поэтому данные переносятся в новую таблицу, как описано в
+// https://www.sqlite.org/lang_altertable.html#otheralter. Для нового файла
+// таблицы уже созданы по schema.sql и пересоздание ничего не меняет.
+func upgradeOrganizations(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version >= schemaVersionOrganizations {
+		return nil
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Внешние ключи отключаются на время переноса, иначе удаление старых
+	// таблиц каскадно удалит ссылающиеся на них строки
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range organizationTables {
+		if err := rebuildTable(ctx, tx, table); err != nil {
+			return err
+		}
+	}
+
+	// Индексы удаленных таблиц создаются заново
+	if _, err := tx.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_audit_log_organization ON audit_log (organization_id, entity, entity_id, id)"); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return err
+	}
+	broken := rows.Next()
+	rows.Close()
+	if broken {
+		return fmt.Errorf("sqlite: foreign key violations after moving data to organizations")
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", schemaVersionOrganizations)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rebuildTable переносит строки table в таблицу с определением из schema.sql.
+// Копируются колонки новой таблицы; устаревшие колонки старой отбрасываются.
+func rebuildTable(ctx context.Context, tx *sql.Tx, table string) error {
+	create, err := tableDefinition(table)
+	if err != nil {
+		return err
+	}
+	tmp := table + "_new"
+	if _, err := tx.ExecContext(ctx, strings.Replace(create, "IF NOT EXISTS "+table, tmp, 1)); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", tmp)
+	if err != nil {
+		return err
+	}
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		columns = append(columns, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	list := strings.Join(columns, ", ")
+	statements := []string{
+		"INSERT INTO " + tmp + " (" + list + ") SELECT " + list + " FROM " + table,
+		"DROP TABLE " + table,
+		"ALTER TABLE " + tmp + " RENAME TO " + table,
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("sqlite: rebuild %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// tableDefinition возвращает CREATE TABLE таблицы из schema.sql
+func tableDefinition(table string) (string, error) {
+	start := strings.Index(schema, "CREATE TABLE IF NOT EXISTS "+table+" (")
+	if start < 0 {
+		return "", fmt.Errorf("sqlite: table %s is not in schema", table)
+	}
+	end := strings.Index(schema[start:], "\n);")
+	if end < 0 {
+		return "", fmt.Errorf("sqlite: unterminated definition of %s", table)
+	}
+	return schema[start : start+end+len("\n);")], nil
+}
+
+// NewRepositories создает репозитории SQLite. orderNumbers задает
+// нумерацию заказов, создаваемых без номера.
+func NewRepositories(db *sql.DB, orderNumbers numbering.Format) *repository.Repositories {
+	repos := newRepositories(db, orderNumbers)
+	repos.UnitOfWork = &unitOfWork{db: db, orderNumbers: orderNumbers}
+	return repos
+}
+
+func newRepositories(db repository.DBTX, orderNumbers numbering.Format) *repository.Repositories {
+	return &repository.Repositories{
+		Client:         &clientRepository{db: db},
+		Product:        &productRepository{db: db},
+		Order:          &orderRepository{db: db, numbers: orderNumbers},
+		OrdersByClient: &ordersByClientRepository{db: db},
+		Audit:          &auditRepository{db: db},
+		User:           &userRepository{db: db},
+		APIKey:         &apiKeyRepository{db: db},
+		Organization:   &organizationRepository{db: db},
+		ExternalRef:    &externalRefRepository{db: db},
+	}
+}
+
+// Структуры конкретных репозиториев
+type clientRepository struct {
+	db repository.DBTX
+}
+
+type productRepository struct {
+	db repository.DBTX
+}
+
+type orderRepository struct {
+	db      repository.DBTX
+	numbers numbering.Format
+}
+
+type ordersByClientRepository struct {
+	db repository.DBTX
+}
+
+type auditRepository struct {
+	db repository.DBTX
+}
+
+type userRepository struct {
+	db repository.DBTX
+}
+
+type apiKeyRepository struct {
+	db repository.DBTX
+}
+
+type organizationRepository struct {
+	db repository.DBTX
+}
+
+type externalRefRepository struct {
+	db repository.DBTX
+}
+
+type unitOfWork struct {
+	db           *sql.DB
+	orderNumbers numbering.Format
+}
+
+func (u *unitOfWork) Do(ctx context.Context, fn func(repos *repository.Repositories) error) error {
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(newRepositories(tx, u.orderNumbers)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// inTx выполняет fn в транзакции, если репозиторий не работает внутри UnitOfWork.
+// Нарушения ограничений SQLite переводятся в ошибки репозитория.
+func inTx(ctx context.Context, db repository.DBTX, fn func(tx repository.DBTX) error) error {
+	sqlDB, ok := db.(*sql.DB)
+	if !ok {
+		return translateError(fn(db))
+	}
+
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return translateError(err)
+	}
+
+	return tx.Commit()
+}
+
+// ts переводит время в формат хранения timeLayout
+func ts(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// monthStart возвращает начало месяца, к которому относятся итоги регистра
+func monthStart(t time.Time) string {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).Format(timeLayout)
+}
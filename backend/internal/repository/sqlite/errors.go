@@ -0,0 +1,56 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/mattn/go-sqlite3"
+)
+
+// translateError переводит нарушения ограничений SQLite в ошибки репозитория,
+// как это делает реализация на Postgres
+func translateError(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return err
+	}
+
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return repository.ErrConflict.Wrap(err)
+	case sqlite3.ErrConstraintForeignKey:
+		return repository.ErrForeignKey.Wrap(err)
+	case sqlite3.ErrConstraintCheck, sqlite3.ErrConstraintNotNull:
+		return repository.ErrCheck.Wrap(err)
+	}
+	return err
+}
+
+// staleOrMissing объясняет, почему условное UPDATE не изменило запись:
+// запись удалена (ErrNotFound) или ее версия устарела (ErrVersionMismatch)
+func staleOrMissing(ctx context.Context, db repository.DBTX, table string, id int64) error {
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM "+table+" WHERE id = ?)", id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return repository.ErrNotFound
+	}
+	return repository.ErrVersionMismatch
+}
+
+// staleOrMissingIn — staleOrMissing для таблиц учета: запись другой
+// организации считается отсутствующей
+func staleOrMissingIn(ctx context.Context, db repository.DBTX, table string, org, id int64) error {
+	var exists bool
+	query := "SELECT EXISTS (SELECT 1 FROM " + table + " WHERE id = ? AND organization_id = ?)"
+	if err := db.QueryRowContext(ctx, query, id, org).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return repository.ErrNotFound
+	}
+	return repository.ErrVersionMismatch
+}
@@ -0,0 +1,475 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/numbering"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+)
+
+func (r *orderRepository) Create(ctx context.Context, order *models.Order, items []models.OrderItem) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return inTx(ctx, r.db, func(tx repository.DBTX) error {
+		// Создаем заказ; пустой номер назначается автоматически по дате документа
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		if order.Number == "" {
+			number, err := r.nextNumber(ctx, tx, org, now)
+			if err != nil {
+				return err
+			}
+			order.Number = number
+		}
+
+		query := `
+			INSERT INTO orders (organization_id, client_id, date, number, total_amount, status, created_at)
+			VALUES (?, ?, ?, ?, 0, ?, ?)
+			RETURNING id, version`
+
+		order.Status = models.OrderStatusDraft
+		err := tx.QueryRowContext(ctx, query, org, order.ClientID, ts(now), order.Number, order.Status, ts(now)).
+			Scan(&order.ID, &order.Version)
+		if err != nil {
+			return err
+		}
+		order.Date = now
+		order.CreatedAt = now
+
+		return insertOrderItems(ctx, tx, org, order, items)
+	})
+}
+
+// nextNumber выделяет очередной номер заказа. Транзакция SQLite открыта с
+// _txlock=immediate и держит блокировку базы на запись, поэтому UPSERT счетчика
+// не пересекается с другими процессами. Номера, уже занятые заказами с
+// введенным вручную номером, пропускаются. Каждая организация нумерует
+// заказы независимо.
+func (r *orderRepository) nextNumber(ctx context.Context, tx repository.DBTX, org int64, date time.Time) (string, error) {
+	query := `
+		INSERT INTO number_sequences (organization_id, document, period, last_value)
+		VALUES (?, ?, ?, 1)
+		ON CONFLICT (organization_id, document, period) DO UPDATE
+		SET last_value = number_sequences.last_value + 1
+		RETURNING last_value`
+
+	for {
+		var seq int64
+		if err := tx.QueryRowContext(ctx, query, org, numbering.DocumentOrder, ts(r.numbers.Period(date))).Scan(&seq); err != nil {
+			return "", err
+		}
+
+		number := r.numbers.Number(date, seq)
+		var taken bool
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM orders WHERE organization_id = ? AND number = ?)", org, number).
+			Scan(&taken)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return number, nil
+		}
+	}
+}
+
+// GetForUpdate в SQLite совпадает с GetByID: транзакции открываются
+// с _txlock=immediate и уже держат блокировку базы на запись
+func (r *orderRepository) GetForUpdate(ctx context.Context, id int64) (*models.Order, error) {
+	return r.GetByID(ctx, id)
+}
+
+func (r *orderRepository) GetByID(ctx context.Context, id int64) (*models.Order, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Получаем заказ
+	query := `
+		SELECT o.id, o.client_id, o.date, o.number, o.total_amount, o.status, o.created_at, o.version,
+			   c.id, c.name, c.inn, c.version
+		FROM orders o
+		JOIN clients c ON c.id = o.client_id
+		WHERE o.id = ? AND o.organization_id = ?`
+
+	order := &models.Order{}
+	err = r.db.QueryRowContext(ctx, query, id, org).Scan(
+		&order.ID, &order.ClientID, &order.Date, &order.Number,
+		&order.TotalAmount, &order.Status, &order.CreatedAt, &order.Version,
+		&order.Client.ID, &order.Client.Name, &order.Client.INN, &order.Client.Version,
+	)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Получаем позиции заказа
+	query = `
+		SELECT i.id, i.product_id, i.quantity, i.price, i.line_amount,
+			   p.id, p.name, p.unit, p.version
+		FROM order_items i
+		JOIN products p ON p.id = i.product_id
+		WHERE i.order_id = ? AND i.organization_id = ?`
+
+	rows, err := r.db.QueryContext(ctx, query, id, org)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.OrderItem
+		err := rows.Scan(
+			&item.ID, &item.ProductID, &item.Quantity, &item.Price, &item.LineAmount,
+			&item.Product.ID, &item.Product.Name, &item.Product.Unit, &item.Product.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		order.Items = append(order.Items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+func (r *orderRepository) Update(ctx context.Context, order *models.Order, items []models.OrderItem) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return inTx(ctx, r.db, func(tx repository.DBTX) error {
+		// Проверяем версию и то, что заказ еще черновик
+		var status models.OrderStatus
+		var version int64
+		err := tx.QueryRowContext(ctx, "SELECT status, version FROM orders WHERE id = ? AND organization_id = ?", order.ID, org).
+			Scan(&status, &version)
+		if err == sql.ErrNoRows {
+			return repository.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if order.Version != 0 && order.Version != version {
+			return repository.ErrVersionMismatch
+		}
+		if status != models.OrderStatusDraft {
+			return repository.ErrStateConflict
+		}
+
+		// Обновляем заказ
+		query := `
+			UPDATE orders
+			SET client_id = ?, number = ?, version = version + 1
+			WHERE id = ?
+			RETURNING version`
+
+		if err := tx.QueryRowContext(ctx, query, order.ClientID, order.Number, order.ID).Scan(&order.Version); err != nil {
+			return err
+		}
+
+		// Удаляем старые позиции
+		_, err = tx.ExecContext(ctx, "DELETE FROM order_items WHERE order_id = ?", order.ID)
+		if err != nil {
+			return err
+		}
+
+		return insertOrderItems(ctx, tx, org, order, items)
+	})
+}
+
+func (r *orderRepository) Delete(ctx context.Context, id int64) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM orders WHERE id = ? AND organization_id = ?`
+
+	result, err := r.db.ExecContext(ctx, query, id, org)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *orderRepository) List(ctx context.Context, q repository.OrderQuery) (repository.Page[models.Order], error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return repository.Page[models.Order]{}, err
+	}
+
+	w := orderWhere(org, q)
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM orders o"+w.sql(), w.args...).Scan(&total); err != nil {
+		return repository.Page[models.Order]{}, err
+	}
+
+	paging, err := w.page(q.ListParams, orderSortColumns, "o.id")
+	if err != nil {
+		return repository.Page[models.Order]{}, err
+	}
+	query := `
+		SELECT o.id, o.client_id, o.date, o.number, o.total_amount, o.status, o.created_at, o.version,
+			   c.id, c.name, c.inn, c.version
+		FROM orders o
+		JOIN clients c ON c.id = o.client_id` + w.sql() + paging
+
+	rows, err := r.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return repository.Page[models.Order]{}, err
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	for rows.Next() {
+		var order models.Order
+		err := rows.Scan(
+			&order.ID, &order.ClientID, &order.Date, &order.Number,
+			&order.TotalAmount, &order.Status, &order.CreatedAt, &order.Version,
+			&order.Client.ID, &order.Client.Name, &order.Client.INN, &order.Client.Version,
+		)
+		if err != nil {
+			return repository.Page[models.Order]{}, err
+		}
+		orders = append(orders, order)
+	}
+
+	if err = rows.Err(); err != nil {
+		return repository.Page[models.Order]{}, err
+	}
+
+	return repository.NewPage(orders, total, q.ListParams, repository.OrderSortValue, func(o models.Order) int64 { return o.ID }), nil
+}
+
+// orderWhere отбирает заказы организации org по q
+func orderWhere(org int64, q repository.OrderQuery) where {
+	var w where
+	w.add("o.organization_id = ?", org)
+	if q.ClientID != 0 {
+		w.add("o.client_id = ?", q.ClientID)
+	}
+	if !q.From.IsZero() {
+		w.add("o.date >= ?", ts(q.From))
+	}
+	if !q.To.IsZero() {
+		w.add("o.date <= ?", ts(q.To))
+	}
+	if len(q.Statuses) > 0 {
+		w.add("o.status IN ("+placeholders(len(q.Statuses))+")", statusArgs(q.Statuses)...)
+	}
+	if q.MinAmount != nil {
+		w.add("o.total_amount >= ?", q.MinAmount.Float64())
+	}
+	if q.MaxAmount != nil {
+		w.add("o.total_amount <= ?", q.MaxAmount.Float64())
+	}
+	return w
+}
+
+// Each читает заказы отбора вместе с позициями одним запросом: строки
+// выборки упорядочены по заказу, и заказ передается fn, как только
+// прочитана его последняя позиция
+func (r *orderRepository) Each(ctx context.Context, q repository.OrderQuery, fn func(models.Order) error) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := orderWhere(org, q)
+	order, err := orderBy(q.ListParams, orderSortColumns, "o.id")
+	if err != nil {
+		return err
+	}
+	// Заказ без позиций дает одну строку с нулевой позицией
+	query := `
+		SELECT o.id, o.client_id, o.date, o.number, o.total_amount, o.status, o.created_at, o.version,
+			   c.id, c.name, c.inn, c.version,
+			   COALESCE(i.id, 0), COALESCE(i.product_id, 0), COALESCE(i.quantity, 0),
+			   COALESCE(i.price, 0), COALESCE(i.line_amount, 0),
+			   COALESCE(p.name, ''), COALESCE(p.unit, ''), COALESCE(p.version, 0)
+		FROM orders o
+		JOIN clients c ON c.id = o.client_id
+		LEFT JOIN order_items i ON i.order_id = o.id
+		LEFT JOIN products p ON p.id = i.product_id` + w.sql() + order + ", i.id"
+
+	rows, err := r.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current *models.Order
+	for rows.Next() {
+		var o models.Order
+		var item models.OrderItem
+		err := rows.Scan(
+			&o.ID, &o.ClientID, &o.Date, &o.Number,
+			&o.TotalAmount, &o.Status, &o.CreatedAt, &o.Version,
+			&o.Client.ID, &o.Client.Name, &o.Client.INN, &o.Client.Version,
+			&item.ID, &item.ProductID, &item.Quantity, &item.Price, &item.LineAmount,
+			&item.Product.Name, &item.Product.Unit, &item.Product.Version,
+		)
+		if err != nil {
+			return err
+		}
+		if current != nil && current.ID != o.ID {
+			if err := fn(*current); err != nil {
+				return err
+			}
+			current = nil
+		}
+		if current == nil {
+			current = &o
+		}
+		if item.ID != 0 {
+			item.OrderID, item.Product.ID = current.ID, item.ProductID
+			current.Items = append(current.Items, item)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if current != nil {
+		return fn(*current)
+	}
+	return nil
+}
+
+// SetStatus меняет статус заказа и пишет переход в историю.
+// Побочные действия перехода (движения по регистру) выполняет сервис в той же транзакции.
+func (r *orderRepository) SetStatus(ctx context.Context, change *models.OrderHistory) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return inTx(ctx, r.db, func(tx repository.DBTX) error {
+		query := `
+			UPDATE orders
+			SET status = ?, version = version + 1
+			WHERE id = ? AND status = ? AND organization_id = ?`
+
+		result, err := tx.ExecContext(ctx, query, change.ToStatus, change.OrderID, change.FromStatus, org)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			var exists bool
+			err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM orders WHERE id = ? AND organization_id = ?)", change.OrderID, org).
+				Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return repository.ErrNotFound
+			}
+			return repository.ErrStateConflict
+		}
+
+		change.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+		query = `
+			INSERT INTO order_history (organization_id, order_id, action, from_status, to_status, actor, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			RETURNING id`
+
+		return tx.QueryRowContext(ctx, query,
+			org, change.OrderID, change.Action, change.FromStatus, change.ToStatus, change.Actor, ts(change.CreatedAt),
+		).Scan(&change.ID)
+	})
+}
+
+func (r *orderRepository) GetHistory(ctx context.Context, id int64) ([]models.OrderHistory, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, order_id, action, from_status, to_status, actor, created_at
+		FROM order_history
+		WHERE order_id = ? AND organization_id = ?
+		ORDER BY created_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, id, org)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []models.OrderHistory
+	for rows.Next() {
+		var h models.OrderHistory
+		if err := rows.Scan(&h.ID, &h.OrderID, &h.Action, &h.FromStatus, &h.ToStatus, &h.Actor, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, h)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// insertOrderItems создает позиции заказа и сохраняет его общую сумму.
+// Суммы рассчитываются в Go, как и в реализации на Postgres.
+func insertOrderItems(ctx context.Context, tx repository.DBTX, org int64, order *models.Order, items []models.OrderItem) error {
+	total, err := models.CalculateAmounts(items)
+	if err != nil {
+		return err
+	}
+	order.TotalAmount = total
+	for i := range items {
+		items[i].OrderID = order.ID
+
+		query := `
+			INSERT INTO order_items (organization_id, order_id, product_id, quantity, price, line_amount)
+			VALUES (?, ?, ?, ?, ?, ?)
+			RETURNING id`
+
+		err := tx.QueryRowContext(ctx, query,
+			org,
+			items[i].OrderID,
+			items[i].ProductID,
+			items[i].Quantity,
+			items[i].Price,
+			items[i].LineAmount,
+		).Scan(&items[i].ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Обновляем общую сумму заказа
+	_, err = tx.ExecContext(ctx, "UPDATE orders SET total_amount = ? WHERE id = ?", order.TotalAmount, order.ID)
+	return err
+}
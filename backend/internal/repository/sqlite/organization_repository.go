@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+func (r *organizationRepository) Create(ctx context.Context, org *models.Organization) error {
+	org.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	query := `
+		INSERT INTO organizations (code, name, created_at)
+		VALUES (?, ?, ?)
+		RETURNING id`
+
+	err := r.db.QueryRowContext(ctx, query, org.Code, org.Name, ts(org.CreatedAt)).Scan(&org.ID)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *organizationRepository) GetByID(ctx context.Context, id int64) (*models.Organization, error) {
+	return r.get(ctx, `SELECT id, code, name, created_at FROM organizations WHERE id = ?`, id)
+}
+
+func (r *organizationRepository) GetByCode(ctx context.Context, code string) (*models.Organization, error) {
+	return r.get(ctx, `SELECT id, code, name, created_at FROM organizations WHERE code = ? COLLATE NOCASE`, code)
+}
+
+func (r *organizationRepository) get(ctx context.Context, query string, arg interface{}) (*models.Organization, error) {
+	org := &models.Organization{}
+	err := r.db.QueryRowContext(ctx, query, arg).Scan(&org.ID, &org.Code, &org.Name, &org.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+func (r *organizationRepository) List(ctx context.Context) ([]models.Organization, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, code, name, created_at FROM organizations ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []models.Organization{}
+	for rows.Next() {
+		var org models.Organization
+		if err := rows.Scan(&org.ID, &org.Code, &org.Name, &org.CreatedAt); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orgs, nil
+}
@@ -0,0 +1,253 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+)
+
+func (r *productRepository) Create(ctx context.Context, product *models.Product) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO products (organization_id, name, unit)
+		VALUES (?, ?, ?)
+		RETURNING id, version`
+
+	err = r.db.QueryRowContext(ctx, query, org, product.Name, product.Unit).Scan(&product.ID, &product.Version)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *productRepository) GetByID(ctx context.Context, id int64) (*models.Product, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, name, unit, version
+		FROM products
+		WHERE id = ? AND organization_id = ?`
+
+	product := &models.Product{}
+	err = r.db.QueryRowContext(ctx, query, id, org).Scan(&product.ID, &product.Name, &product.Unit, &product.Version)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+func (r *productRepository) GetByNameUnit(ctx context.Context, name, unit string) (*models.Product, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, name, unit, version
+		FROM products
+		WHERE organization_id = ? AND name = ? AND unit = ?
+		ORDER BY id
+		LIMIT 1`
+
+	product := &models.Product{}
+	err = r.db.QueryRowContext(ctx, query, org, name, unit).Scan(&product.ID, &product.Name, &product.Unit, &product.Version)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+func (r *productRepository) Update(ctx context.Context, product *models.Product) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE products
+		SET name = ?, unit = ?, version = version + 1
+		WHERE id = ? AND organization_id = ? AND (? = 0 OR version = ?)
+		RETURNING version`
+
+	err = r.db.QueryRowContext(ctx, query, product.Name, product.Unit, product.ID, org, product.Version, product.Version).Scan(&product.Version)
+	if err == sql.ErrNoRows {
+		return staleOrMissingIn(ctx, r.db, "products", org, product.ID)
+	}
+	if err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *productRepository) Delete(ctx context.Context, id int64) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM products WHERE id = ? AND organization_id = ?`
+
+	result, err := r.db.ExecContext(ctx, query, id, org)
+	if err != nil {
+		return translateError(err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return translateError(err)
+	}
+	if rowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *productRepository) List(ctx context.Context, q repository.ProductQuery) (repository.Page[models.Product], error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return repository.Page[models.Product]{}, err
+	}
+
+	w := productWhere(org, q)
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM products"+w.sql(), w.args...).Scan(&total); err != nil {
+		return repository.Page[models.Product]{}, err
+	}
+
+	paging, err := w.page(q.ListParams, productSortColumns, "id")
+	if err != nil {
+		return repository.Page[models.Product]{}, err
+	}
+	query := `
+		SELECT id, name, unit, version
+		FROM products` + w.sql() + paging
+
+	rows, err := r.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return repository.Page[models.Product]{}, err
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		var product models.Product
+		if err := rows.Scan(&product.ID, &product.Name, &product.Unit, &product.Version); err != nil {
+			return repository.Page[models.Product]{}, err
+		}
+		products = append(products, product)
+	}
+
+	if err = rows.Err(); err != nil {
+		return repository.Page[models.Product]{}, err
+	}
+
+	return repository.NewPage(products, total, q.ListParams, repository.ProductSortValue, func(p models.Product) int64 { return p.ID }), nil
+}
+
+// productWhere отбирает товары организации org по q
+func productWhere(org int64, q repository.ProductQuery) where {
+	var w where
+	w.add("organization_id = ?", org)
+	if q.Search != "" {
+		w.add("name LIKE ? ESCAPE '\\'", likePattern(q.Search))
+	}
+	return w
+}
+
+// Each читает отбор построчно и передает записи fn
+func (r *productRepository) Each(ctx context.Context, q repository.ProductQuery, fn func(models.Product) error) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := productWhere(org, q)
+	order, err := orderBy(q.ListParams, productSortColumns, "id")
+	if err != nil {
+		return err
+	}
+	query := `
+		SELECT id, name, unit, version
+		FROM products` + w.sql() + order
+
+	rows, err := r.db.QueryContext(ctx, query, w.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product models.Product
+		if err := rows.Scan(&product.ID, &product.Name, &product.Unit, &product.Version); err != nil {
+			return err
+		}
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetProductOrderItems возвращает все позиции заказов, где используется товар
+func (r *productRepository) GetProductOrderItems(ctx context.Context, productID int64) ([]models.OrderItem, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT oi.id, oi.order_id, oi.product_id, oi.quantity, oi.price, oi.line_amount
+		FROM order_items oi
+		WHERE oi.product_id = ? AND oi.organization_id = ?`
+
+	rows, err := r.db.QueryContext(ctx, query, productID, org)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.OrderItem
+	for rows.Next() {
+		var item models.OrderItem
+		err := rows.Scan(
+			&item.ID,
+			&item.OrderID,
+			&item.ProductID,
+			&item.Quantity,
+			&item.Price,
+			&item.LineAmount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
@@ -0,0 +1,182 @@
+-- SQLite schema of OrderFlow. Line and order amounts are calculated in Go,
+-- see models.CalculateAmounts.
+PRAGMA foreign_keys = ON;
+
+-- Organizations (legal entities). Accounting tables carry organization_id and
+-- reference each other together with it, so records of different
+-- organizations cannot point to each other.
+CREATE TABLE IF NOT EXISTS organizations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code VARCHAR(50) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organizations_code ON organizations (code COLLATE NOCASE);
+
+INSERT OR IGNORE INTO organizations (id, code, name, created_at)
+VALUES (1, 'default', 'Default organization', strftime('%Y-%m-%d %H:%M:%f000', 'now'));
+
+CREATE TABLE IF NOT EXISTS clients (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id),
+    name VARCHAR(255) NOT NULL,
+    inn VARCHAR(50) NOT NULL DEFAULT '',
+    version INTEGER NOT NULL DEFAULT 1,
+    UNIQUE (organization_id, id)
+);
+
+CREATE TABLE IF NOT EXISTS products (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id),
+    name VARCHAR(255) NOT NULL,
+    unit VARCHAR(50) NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    UNIQUE (organization_id, id)
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id),
+    client_id INTEGER NOT NULL,
+    date TIMESTAMP NOT NULL,
+    number VARCHAR(50) NOT NULL,
+    total_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'confirmed', 'in_fulfilment', 'shipped', 'closed', 'cancelled')),
+    created_at TIMESTAMP NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    UNIQUE (organization_id, id),
+    UNIQUE (organization_id, number),
+    FOREIGN KEY (organization_id, client_id) REFERENCES clients (organization_id, id)
+);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    organization_id INTEGER NOT NULL,
+    order_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    quantity DECIMAL(15,3) NOT NULL CHECK (quantity >= 0),
+    price DECIMAL(15,2) NOT NULL CHECK (price >= 0),
+    line_amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    FOREIGN KEY (organization_id, order_id) REFERENCES orders (organization_id, id) ON DELETE CASCADE,
+    FOREIGN KEY (organization_id, product_id) REFERENCES products (organization_id, id)
+);
+
+CREATE TABLE IF NOT EXISTS orders_by_client_movements (
+    organization_id INTEGER NOT NULL,
+    recorder_id INTEGER NOT NULL,
+    period TIMESTAMP NOT NULL,
+    line_number INTEGER NOT NULL,
+    record_kind VARCHAR(10) NOT NULL CHECK (record_kind IN ('receipt', 'expense')),
+    client_id INTEGER NOT NULL,
+    amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (recorder_id, line_number),
+    FOREIGN KEY (organization_id, recorder_id) REFERENCES orders (organization_id, id) ON DELETE CASCADE,
+    FOREIGN KEY (organization_id, client_id) REFERENCES clients (organization_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_by_client_movements_period ON orders_by_client_movements (period, client_id);
+
+CREATE TABLE IF NOT EXISTS orders_by_client_totals (
+    organization_id INTEGER NOT NULL,
+    period DATE NOT NULL,
+    client_id INTEGER NOT NULL,
+    amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (period, client_id),
+    FOREIGN KEY (organization_id, client_id) REFERENCES clients (organization_id, id)
+);
+
+CREATE TABLE IF NOT EXISTS order_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    organization_id INTEGER NOT NULL,
+    order_id INTEGER NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    from_status VARCHAR(20) NOT NULL DEFAULT '',
+    to_status VARCHAR(20) NOT NULL DEFAULT '',
+    actor VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (organization_id, order_id) REFERENCES orders (organization_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_history_order_id ON order_history (order_id);
+
+CREATE TABLE IF NOT EXISTS number_sequences (
+    organization_id INTEGER NOT NULL REFERENCES organizations(id),
+    document VARCHAR(50) NOT NULL,
+    period DATE NOT NULL,
+    last_value BIGINT NOT NULL,
+    PRIMARY KEY (organization_id, document, period)
+);
+
+-- Append-only audit trail, see repository.AuditRepository
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id),
+    entity VARCHAR(50) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    actor VARCHAR(100) NOT NULL DEFAULT '',
+    request_id VARCHAR(100) NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    before_data TEXT,
+    after_data TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log (entity, entity_id, id);
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+-- API users; roles are stored as a comma-separated list
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    login VARCHAR(100) NOT NULL,
+    password_hash VARCHAR(100) NOT NULL,
+    roles TEXT NOT NULL DEFAULT '',
+    organization_id INTEGER REFERENCES organizations(id),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_login ON users (login COLLATE NOCASE);
+
+-- API keys; scopes are stored as a comma-separated list
+CREATE TABLE IF NOT EXISTS api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    scopes TEXT NOT NULL DEFAULT '',
+    organization_id INTEGER NOT NULL REFERENCES organizations(id),
+    rate_limit INTEGER NOT NULL CHECK (rate_limit > 0),
+    expires_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    created_by VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Identifiers of records in external systems, see repository.ExternalRefRepository
+CREATE TABLE IF NOT EXISTS external_refs (
+    organization_id INTEGER NOT NULL REFERENCES organizations(id),
+    entity VARCHAR(50) NOT NULL,
+    system VARCHAR(50) NOT NULL,
+    external_id VARCHAR(100) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    code VARCHAR(50) NOT NULL DEFAULT '',
+    exported_version INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (organization_id, entity, system, external_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_external_refs_entity ON external_refs (organization_id, entity, system, entity_id);
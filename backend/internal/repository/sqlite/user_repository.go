@@ -0,0 +1,108 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+const userColumns = `id, login, password_hash, roles, organization_id, active, version, created_at`
+
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	user.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	query := `
+		INSERT INTO users (login, password_hash, roles, organization_id, active, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id, version`
+
+	err := r.db.QueryRowContext(ctx, query,
+		user.Login, user.PasswordHash, strings.Join(user.Roles, ","), user.OrganizationID, user.Active, ts(user.CreatedAt),
+	).Scan(&user.ID, &user.Version)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
+
+func (r *userRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	return r.get(ctx, `SELECT `+userColumns+` FROM users WHERE id = ?`, id)
+}
+
+func (r *userRepository) GetByLogin(ctx context.Context, login string) (*models.User, error) {
+	return r.get(ctx, `SELECT `+userColumns+` FROM users WHERE login = ? COLLATE NOCASE`, login)
+}
+
+func (r *userRepository) get(ctx context.Context, query string, arg interface{}) (*models.User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, arg))
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *userRepository) List(ctx context.Context) ([]models.User, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+userColumns+` FROM users ORDER BY login`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *userRepository) Update(ctx context.Context, user *models.User) error {
+	query := `
+		UPDATE users
+		SET login = ?, password_hash = ?, roles = ?, organization_id = ?, active = ?, version = version + 1
+		WHERE id = ? AND (? = 0 OR version = ?)
+		RETURNING version`
+
+	err := r.db.QueryRowContext(ctx, query,
+		user.Login, user.PasswordHash, strings.Join(user.Roles, ","), user.OrganizationID, user.Active,
+		user.ID, user.Version, user.Version,
+	).Scan(&user.Version)
+	if err == sql.ErrNoRows {
+		return staleOrMissing(ctx, r.db, "users", user.ID)
+	}
+	if err != nil {
+		return translateError(err)
+	}
+
+	return nil
+}
+
+// scanUser читает строку с колонками userColumns
+func scanUser(row interface{ Scan(dest ...any) error }) (*models.User, error) {
+	user := &models.User{}
+	var roles string
+	err := row.Scan(&user.ID, &user.Login, &user.PasswordHash, &roles, &user.OrganizationID, &user.Active, &user.Version, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = []string{}
+	if roles != "" {
+		user.Roles = strings.Split(roles, ",")
+	}
+	return user, nil
+}
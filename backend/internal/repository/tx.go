@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/numbering"
+)
+
+// DBTX описывает общие методы *sql.DB и *sql.Tx, через которые работают репозитории
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// UnitOfWork выполняет несколько операций репозиториев в одной транзакции
+type UnitOfWork interface {
+	// Do вызывает fn с репозиториями, привязанными к одной транзакции.
+	// Транзакция фиксируется, если fn вернула nil, иначе откатывается.
+	Do(ctx context.Context, fn func(repos *Repositories) error) error
+}
+
+type postgresUnitOfWork struct {
+	db           *sql.DB
+	orderNumbers numbering.Format
+}
+
+func NewUnitOfWork(db *sql.DB, orderNumbers numbering.Format) UnitOfWork {
+	return &postgresUnitOfWork{db: db, orderNumbers: orderNumbers}
+}
+
+func (u *postgresUnitOfWork) Do(ctx context.Context, fn func(repos *Repositories) error) error {
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(newRepositories(tx, u.orderNumbers)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// inTx выполняет fn в транзакции. Если репозиторий уже работает внутри
+// транзакции UnitOfWork, fn выполняется в ней без открытия новой.
+// Нарушения ограничений Postgres переводятся в ошибки репозитория.
+func inTx(ctx context.Context, db DBTX, fn func(tx DBTX) error) error {
+	beginner, ok := db.(txBeginner)
+	if !ok {
+		return translateError(fn(db))
+	}
+
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return translateError(err)
+	}
+
+	return tx.Commit()
+}
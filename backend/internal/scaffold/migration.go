@@ -0,0 +1,217 @@
+package scaffold
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/metadata"
+)
+
+// table — таблица в миграции
+type table struct {
+	Name    string
+	Comment string
+	Defs    []string
+	Indexes []string
+}
+
+// migrationData — данные шаблонов миграции. Ссылки добавляются после
+// создания всех таблиц, поэтому объекты могут ссылаться друг на друга.
+type migrationData struct {
+	Objects    []string
+	Tables     []table
+	References []string
+}
+
+// migration возвращает тексты up- и down-миграции для объектов. Таблицы
+// устроены как таблицы заказов: организация в каждой строке, ссылки вместе
+// с организацией (записи ссылаются только на записи своей организации) и
+// политика tenant_isolation, как в 000012_row_level_security.
+func migration(entities []*entity) (up, down []byte, err error) {
+	var data migrationData
+	for _, e := range entities {
+		data.Objects = append(data.Objects, e.Object.FullName())
+
+		switch e.Object.Kind {
+		case metadata.KindAccumulationRegister:
+			data.Tables = append(data.Tables, registerTable(e), totalsTable(e))
+			if e.RecorderTable != "" {
+				data.References = append(data.References, foreignKey(e.Table, "fk_recorder", "recorder_id", e.RecorderTable, " ON DELETE CASCADE"))
+			}
+		default:
+			data.Tables = append(data.Tables, objectTable(e))
+		}
+		data.References = append(data.References, references(e.Table, e.Columns)...)
+		if e.Totals != "" {
+			data.References = append(data.References, references(e.Totals, e.Dimensions)...)
+		}
+
+		for _, s := range e.Sections {
+			data.Tables = append(data.Tables, sectionTable(e, s))
+			data.References = append(data.References, references(s.Table, s.Columns)...)
+		}
+	}
+
+	for _, t := range data.Tables {
+		for _, name := range append([]string{t.Name}, indexNames(t)...) {
+			if len(name) > maxIdentifier {
+				return nil, nil, fmt.Errorf("scaffold: identifier %s is longer than %d characters", name, maxIdentifier)
+			}
+		}
+	}
+
+	if up, err = render("migration.up.sql.tmpl", data, false); err != nil {
+		return nil, nil, err
+	}
+	down, err = render("migration.down.sql.tmpl", data, false)
+	return up, down, err
+}
+
+// objectTable — таблица справочника или документа
+func objectTable(e *entity) table {
+	t := table{
+		Name:    e.Table,
+		Comment: fmt.Sprintf("%s %q (%s)", e.Object.Kind, e.Object.Title(), e.Object.FullName()),
+	}
+	t.Defs = append(t.Defs,
+		"id BIGSERIAL PRIMARY KEY",
+		"organization_id BIGINT NOT NULL REFERENCES organizations(id)")
+	t.Defs = append(t.Defs, columnDefs(e.Columns)...)
+	t.Defs = append(t.Defs,
+		"version BIGINT NOT NULL DEFAULT 1",
+		fmt.Sprintf("CONSTRAINT uq_%s_organization UNIQUE (organization_id, id)", e.Table))
+
+	switch e.Object.Kind {
+	case metadata.KindCatalog:
+		if hasColumn(e.Columns, "name") {
+			t.Indexes = append(t.Indexes, fmt.Sprintf("CREATE INDEX idx_%s_name ON %s (organization_id, name);", e.Table, e.Table))
+		}
+	case metadata.KindDocument:
+		t.Indexes = append(t.Indexes,
+			fmt.Sprintf("CREATE INDEX idx_%s_number ON %s (organization_id, number);", e.Table, e.Table),
+			fmt.Sprintf("CREATE INDEX idx_%s_date ON %s (organization_id, date);", e.Table, e.Table))
+	}
+	return t
+}
+
+// sectionTable — таблица строк табличной части; строки удаляются вместе с владельцем
+func sectionTable(e *entity, s section) table {
+	t := table{
+		Name:    s.Table,
+		Comment: fmt.Sprintf("Rows of the %q tabular section of %s", s.Section.Name, e.Object.FullName()),
+	}
+	t.Defs = append(t.Defs,
+		"id BIGSERIAL PRIMARY KEY",
+		"organization_id BIGINT NOT NULL",
+		s.ParentColumn+" BIGINT NOT NULL",
+		"line_number INTEGER NOT NULL")
+	t.Defs = append(t.Defs, columnDefs(s.Columns)...)
+	t.Defs = append(t.Defs, fmt.Sprintf(
+		"CONSTRAINT fk_owner FOREIGN KEY (organization_id, %s)\n        REFERENCES %s (organization_id, id) ON DELETE CASCADE",
+		s.ParentColumn, e.Table))
+	t.Indexes = append(t.Indexes, fmt.Sprintf("CREATE INDEX idx_%s_owner ON %s (%s, line_number);", s.Table, s.Table, s.ParentColumn))
+	return t
+}
+
+// registerTable — таблица движений регистра, как orders_by_client_movements
+func registerTable(e *entity) table {
+	t := table{
+		Name:    e.Table,
+		Comment: fmt.Sprintf("Movements of the %q accumulation register (%s)", e.Object.Title(), e.Object.FullName()),
+	}
+	if e.RecorderTable == "" {
+		t.Comment += "\n-- The dump names no recorder document, so recorder_id is not a foreign key"
+	}
+	t.Defs = append(t.Defs,
+		"organization_id BIGINT NOT NULL REFERENCES organizations(id)",
+		"recorder_id BIGINT NOT NULL",
+		"period TIMESTAMP NOT NULL",
+		"line_number INTEGER NOT NULL")
+	if e.Balance() {
+		t.Defs = append(t.Defs, "record_kind VARCHAR(10) NOT NULL CHECK (record_kind IN ('receipt', 'expense'))")
+	}
+	t.Defs = append(t.Defs, columnDefs(e.Columns)...)
+	t.Defs = append(t.Defs, "PRIMARY KEY (organization_id, recorder_id, line_number)")
+	t.Indexes = append(t.Indexes, fmt.Sprintf("CREATE INDEX idx_%s_period ON %s (organization_id, period);", e.Table, e.Table))
+	return t
+}
+
+// totalsTable — итоги регистра по месяцам, как orders_by_client_totals: у
+// регистра остатков — изменение остатка за месяц, у регистра оборотов —
+// обороты за месяц. Строки ищутся по организации, месяцу и измерениям.
+func totalsTable(e *entity) table {
+	t := table{
+		Name:    e.Totals,
+		Comment: fmt.Sprintf("Monthly totals of the %q accumulation register (%s)", e.Object.Title(), e.Object.FullName()),
+	}
+	if e.Balance() {
+		t.Comment += ": net balance change per month"
+	} else {
+		t.Comment += ": turnover per month"
+	}
+	t.Defs = append(t.Defs,
+		"organization_id BIGINT NOT NULL REFERENCES organizations(id)",
+		"period DATE NOT NULL")
+	t.Defs = append(t.Defs, columnDefs(e.Dimensions)...)
+	t.Defs = append(t.Defs, columnDefs(e.Resources)...)
+	t.Indexes = append(t.Indexes, fmt.Sprintf("CREATE UNIQUE INDEX uq_%s ON %s (%s);", e.Totals, e.Totals, e.TotalsKey()))
+	return t
+}
+
+func columnDefs(columns []column) []string {
+	var defs []string
+	for _, c := range columns {
+		def := c.Name + " " + c.SQLType
+		if !strings.HasPrefix(c.GoType, "*") {
+			def += " NOT NULL"
+		}
+		if c.Default != "" {
+			def += " DEFAULT " + c.Default
+		}
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+// references возвращает внешние ключи ссылочных колонок table
+func references(table string, columns []column) []string {
+	var refs []string
+	for _, c := range columns {
+		if c.RefTable != "" {
+			refs = append(refs, foreignKey(table, "fk_"+strings.TrimSuffix(c.Name, "_id"), c.Name, c.RefTable, ""))
+		}
+	}
+	return refs
+}
+
+func foreignKey(table, name, column, target, action string) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (organization_id, %s)\n    REFERENCES %s (organization_id, id)%s;",
+		table, name, column, target, action)
+}
+
+func hasColumn(columns []column, name string) bool {
+	for _, c := range columns {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// indexNames извлекает имена индексов и ограничений уникальности: они,
+// в отличие от имен внешних ключей, должны быть уникальны во всей схеме
+func indexNames(t table) []string {
+	var names []string
+	for _, s := range append(append([]string(nil), t.Defs...), t.Indexes...) {
+		f := strings.Fields(s)
+		switch {
+		case len(f) > 2 && f[0] == "CREATE" && f[1] == "INDEX":
+			names = append(names, f[2])
+		case len(f) > 3 && f[0] == "CREATE" && f[1] == "UNIQUE" && f[2] == "INDEX":
+			names = append(names, f[3])
+		case len(f) > 1 && f[0] == "CONSTRAINT":
+			names = append(names, f[1])
+		}
+	}
+	return names
+}
@@ -0,0 +1,153 @@
+package scaffold
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+	"unicode"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/metadata"
+)
+
+// translit — транслитерация кириллицы в идентификаторах, как в загранпаспортах
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// standardNames — имена стандартных реквизитов в OrderFlow, как у клиентов и заказов
+var standardNames = map[string]string{
+	metadata.AttributeDescription: "name",
+	metadata.AttributeCode:        "code",
+	metadata.AttributeNumber:      "number",
+	metadata.AttributeDate:        "date",
+}
+
+// maxIdentifier — наибольшая длина идентификатора в Postgres; более длинные
+// имена Postgres молча обрезает
+const maxIdentifier = 63
+
+// words делит имя 1С на слова по заглавным буквам и подчеркиваниям:
+// ЗаказПокупателя — «Заказ», «Покупателя»; СтавкаНДСПродажи — «Ставка»,
+// «НДС», «Продажи». Цифры остаются в слове перед ними.
+func words(name string) []string {
+	var result []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			result = append(result, string(word))
+			word = nil
+		}
+	}
+
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && len(word) > 0:
+			prev := word[len(word)-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			// Новое слово начинается после строчной буквы или цифры и в конце
+			// аббревиатуры перед строчной: НДС|Продажи
+			if !unicode.IsUpper(prev) || nextLower {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+	return result
+}
+
+// latin транслитерирует слово в нижнем регистре
+func latin(word string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(word) {
+		if s, ok := translit[r]; ok {
+			b.WriteString(s)
+		} else if r < unicode.MaxASCII {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isAcronym сообщает, что слово из нескольких букв записано заглавными: ИНН, НДС
+func isAcronym(word string) bool {
+	letters := 0
+	for _, r := range word {
+		if unicode.IsLower(r) {
+			return false
+		}
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	return letters > 1
+}
+
+// goName возвращает экспортируемый идентификатор Go: ЗаказПокупателя —
+// ZakazPokupatelya, ИНН — INN
+func goName(name string) (string, error) {
+	var b strings.Builder
+	for _, w := range words(name) {
+		l := latin(w)
+		if l == "" {
+			continue
+		}
+		if isAcronym(w) {
+			b.WriteString(strings.ToUpper(l))
+		} else {
+			b.WriteString(strings.ToUpper(l[:1]) + l[1:])
+		}
+	}
+	return identifier(name, b.String())
+}
+
+// snakeName возвращает имя таблицы или колонки: ЗаказПокупателя — zakaz_pokupatelya
+func snakeName(name string) (string, error) {
+	var parts []string
+	for _, w := range words(name) {
+		if l := latin(w); l != "" {
+			parts = append(parts, l)
+		}
+	}
+	s, err := identifier(name, strings.Join(parts, "_"))
+	if err == nil && len(s) > maxIdentifier {
+		err = fmt.Errorf("scaffold: %s: identifier %s is longer than %d characters", name, s, maxIdentifier)
+	}
+	return s, err
+}
+
+func identifier(name, s string) (string, error) {
+	if s == "" || !unicode.IsLetter(rune(s[0])) {
+		return "", fmt.Errorf("scaffold: cannot derive an identifier from %q", name)
+	}
+	return s, nil
+}
+
+// lowerName возвращает имя переменной для записи: Sklady — sklady.
+// Имена, совпадающие с ключевыми словами и переменными шаблонов, заменяются.
+func lowerName(name string) string {
+	i := 0
+	for i < len(name) && unicode.IsUpper(rune(name[i])) {
+		i++
+	}
+	if i > 1 && i < len(name) {
+		i-- // INNKod — innKod
+	}
+	v := strings.ToLower(name[:i]) + name[i:]
+	switch v {
+	case "ctx", "org", "err", "query", "rows", "result", "id", "tx", "r", "rowsAffected", "recorderID", "movements", "models", "row", "i", "m":
+		return "record"
+	}
+	if token.IsKeyword(v) {
+		return "record"
+	}
+	return v
+}
@@ -0,0 +1,303 @@
+package scaffold
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/metadata"
+)
+
+// builtin — объект конфигурации «Мини Заказы», который OrderFlow уже
+// реализует; для него код не генерируется, а ссылки на него указывают на
+// существующую таблицу
+type builtin struct {
+	table string
+	// recorder — регистратор движений регистра, если таблица регистра
+	recorder string
+}
+
+var builtins = map[string]builtin{
+	"Справочник.Контрагенты":                 {table: "clients"},
+	"Справочник.Номенклатура":                {table: "products"},
+	"Документ.ЗаказПокупателя":               {table: "orders"},
+	"РегистрНакопления.ЗаказыПоКонтрагентам": {table: "orders_by_client_movements", recorder: "orders"},
+}
+
+// reservedTables — таблицы схемы OrderFlow, которые нельзя создать заново
+var reservedTables = map[string]bool{
+	"clients": true, "products": true, "orders": true, "order_items": true,
+	"order_history": true, "orders_by_client_movements": true, "orders_by_client_totals": true,
+	"number_sequences": true, "audit_log": true, "users": true, "api_keys": true,
+	"organizations": true, "external_refs": true, "schema_migrations": true,
+}
+
+// lookupBuiltin ищет объект среди реализованных в OrderFlow
+func lookupBuiltin(kind metadata.Kind, name string) (builtin, bool) {
+	for full, b := range builtins {
+		k, n, _ := metadata.ParseFullName(full)
+		if k == kind && strings.EqualFold(n, name) {
+			return b, true
+		}
+	}
+	return builtin{}, false
+}
+
+// entity — план генерации одного объекта: имена типов и таблиц и колонки
+type entity struct {
+	Object *metadata.Object
+	// Model — тип модели, Var — имя переменной записи в репозитории
+	Model string
+	Var   string
+	Table string
+	// Columns — реквизиты объекта, для регистра — измерения, ресурсы и реквизиты
+	Columns  []column
+	Sections []section
+	// RecorderTable — таблица документа-регистратора движений регистра,
+	// пусто, если регистратор не указан в выгрузке
+	RecorderTable string
+	// Totals — таблица итогов регистра по месяцам, Dimensions и Resources —
+	// колонки измерений и ресурсов, по которым считаются итоги
+	Totals     string
+	Dimensions []column
+	Resources  []column
+}
+
+// section — план табличной части: модель строки и таблица строк
+type section struct {
+	Section metadata.TabularSection
+	Model   string
+	Table   string
+	// Field и JSON — поле модели документа со строками
+	Field string
+	JSON  string
+	// Parent и ParentColumn — поле и колонка ссылки строки на владельца
+	Parent       string
+	ParentColumn string
+	Columns      []column
+}
+
+// column — реквизит в модели и таблице
+type column struct {
+	Field   metadata.Field
+	GoName  string
+	Name    string
+	GoType  string
+	SQLType string
+	// Default — значение по умолчанию в SQL, пусто — без значения
+	Default string
+	// RefTable — таблица, на которую ссылается колонка
+	RefTable string
+}
+
+// planner строит планы объектов и разрешает ссылки между ними
+type planner struct {
+	config *metadata.Configuration
+	// tables — таблицы объектов: реализованных в OrderFlow и генерируемых
+	tables map[*metadata.Object]string
+}
+
+// tableOf возвращает таблицу объекта: существующую или генерируемую
+func (p *planner) tableOf(o *metadata.Object) (string, error) {
+	if t, ok := p.tables[o]; ok {
+		return t, nil
+	}
+	if b, ok := lookupBuiltin(o.Kind, o.Name); ok {
+		p.tables[o] = b.table
+		return b.table, nil
+	}
+	t, err := snakeName(o.Name)
+	if err != nil {
+		return "", err
+	}
+	if o.Kind == metadata.KindAccumulationRegister {
+		t += "_movements"
+	}
+	if reservedTables[t] {
+		return "", fmt.Errorf("scaffold: %s: table %s already exists in OrderFlow", o.FullName(), t)
+	}
+	p.tables[o] = t
+	return t, nil
+}
+
+func (p *planner) plan(o *metadata.Object) (*entity, error) {
+	model, err := goName(o.Name)
+	if err != nil {
+		return nil, err
+	}
+	if o.Kind == metadata.KindAccumulationRegister {
+		model += "Movement"
+	}
+	table, err := p.tableOf(o)
+	if err != nil {
+		return nil, err
+	}
+	e := &entity{Object: o, Model: model, Var: lowerName(model), Table: table}
+
+	if e.Columns, err = p.columns(o, o.Attributes); err != nil {
+		return nil, err
+	}
+	if o.Kind == metadata.KindAccumulationRegister {
+		if err := p.planRegister(e); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, ts := range o.TabularSections {
+		s := section{Section: ts, Parent: e.Model + "ID", ParentColumn: e.Table + "_id"}
+		if s.Field, err = goName(ts.Name); err != nil {
+			return nil, err
+		}
+		if s.JSON, err = snakeName(ts.Name); err != nil {
+			return nil, err
+		}
+		s.Model = e.Model + s.Field
+		if s.Table, err = snakeName(o.Name + "_" + ts.Name); err != nil {
+			return nil, err
+		}
+		if reservedTables[s.Table] {
+			return nil, fmt.Errorf("scaffold: %s.%s: table %s already exists in OrderFlow", o.FullName(), ts.Name, s.Table)
+		}
+		if s.Columns, err = p.columns(o, ts.Fields); err != nil {
+			return nil, err
+		}
+		e.Sections = append(e.Sections, s)
+	}
+
+	return e, p.checkNames(e)
+}
+
+// recorder возвращает таблицу единственного документа, формирующего движения регистра
+func (p *planner) recorder(reg *metadata.Object) (string, error) {
+	if b, ok := lookupBuiltin(reg.Kind, reg.Name); ok {
+		return b.recorder, nil
+	}
+	var recorders []*metadata.Object
+	for _, o := range p.config.Objects {
+		for _, name := range o.Registers {
+			if strings.EqualFold(name, reg.Name) {
+				recorders = append(recorders, o)
+			}
+		}
+	}
+	switch len(recorders) {
+	case 0:
+		return "", nil
+	case 1:
+		return p.tableOf(recorders[0])
+	}
+	return "", fmt.Errorf("scaffold: %s has %d recorders; registers with several recorder documents are not supported", reg.FullName(), len(recorders))
+}
+
+func (p *planner) columns(o *metadata.Object, fields []metadata.Field) ([]column, error) {
+	var columns []column
+	for _, f := range fields {
+		c, err := p.column(f)
+		if err != nil {
+			return nil, fmt.Errorf("scaffold: %s.%s: %w", o.FullName(), f.Name, err)
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// column сопоставляет тип реквизита с типами Go и Postgres: суммы и
+// количества хранятся в money.Money и money.Quantity, как в заказах, а
+// ссылки — в идентификаторе записи, который пуст (nil) для пустой ссылки
+func (p *planner) column(f metadata.Field) (column, error) {
+	c := column{Field: f}
+	var err error
+	if name, ok := standardNames[f.Name]; ok && f.Standard {
+		c.Name = name
+		c.GoName = strings.ToUpper(name[:1]) + name[1:]
+	} else {
+		if c.GoName, err = goName(f.Name); err != nil {
+			return c, err
+		}
+		if c.Name, err = snakeName(f.Name); err != nil {
+			return c, err
+		}
+	}
+
+	t := f.Type
+	switch t.Kind {
+	case metadata.TypeString:
+		c.GoType, c.SQLType, c.Default = "string", fmt.Sprintf("VARCHAR(%d)", t.Length), "''"
+		if t.Length == 0 {
+			c.SQLType = "TEXT"
+		}
+	case metadata.TypeNumber:
+		c.Default = "0"
+		switch t.FractionDigits {
+		case 0:
+			c.GoType, c.SQLType = "int64", "BIGINT"
+		case 2:
+			c.GoType, c.SQLType = "money.Money", fmt.Sprintf("DECIMAL(%d,2)", t.Digits)
+		case 3:
+			c.GoType, c.SQLType = "money.Quantity", fmt.Sprintf("DECIMAL(%d,3)", t.Digits)
+		default:
+			c.GoType, c.SQLType = "float64", fmt.Sprintf("DECIMAL(%d,%d)", t.Digits, t.FractionDigits)
+		}
+	case metadata.TypeDate:
+		c.GoType, c.SQLType = "time.Time", "TIMESTAMP"
+		if t.DateOnly {
+			c.SQLType = "DATE"
+		}
+		if f.Standard {
+			c.Default = "CURRENT_TIMESTAMP"
+		}
+	case metadata.TypeBoolean:
+		c.GoType, c.SQLType, c.Default = "bool", "BOOLEAN", "FALSE"
+	case metadata.TypeRef:
+		if t.RefKind == metadata.KindEnum {
+			c.GoType, c.SQLType, c.Default = "string", "VARCHAR(150)", "''"
+			break
+		}
+		target := p.config.Lookup(t.RefKind, t.RefName)
+		if target == nil {
+			return c, fmt.Errorf("missing %s", t)
+		}
+		if c.RefTable, err = p.tableOf(target); err != nil {
+			return c, err
+		}
+		c.GoName += "ID"
+		c.Name += "_id"
+		c.GoType, c.SQLType = "*int64", "BIGINT"
+	default:
+		return c, fmt.Errorf("unsupported type %s", t)
+	}
+	return c, nil
+}
+
+// fixedColumns — поля, которые шаблоны добавляют в каждую модель
+var fixedColumns = []string{"ID", "Version", "LineNumber", "RecorderID", "Period", "RecordKind"}
+
+// checkNames проверяет, что реквизиты не совпадают по именам после транслитерации
+func (p *planner) checkNames(e *entity) error {
+	check := func(owner string, columns []column, extra ...string) error {
+		seen := make(map[string]string)
+		for _, name := range append(fixedColumns, extra...) {
+			seen[name] = "field " + name
+		}
+		for _, c := range columns {
+			if prev, ok := seen[c.GoName]; ok {
+				return fmt.Errorf("scaffold: %s: %s and %s have the same name %s", owner, prev, c.Field.Name, c.GoName)
+			}
+			seen[c.GoName] = c.Field.Name
+		}
+		return nil
+	}
+
+	var sections []string
+	for _, s := range e.Sections {
+		sections = append(sections, s.Field)
+	}
+	if err := check(e.Object.FullName(), e.Columns, sections...); err != nil {
+		return err
+	}
+	for _, s := range e.Sections {
+		if err := check(e.Object.FullName()+"."+s.Section.Name, s.Columns, s.Parent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
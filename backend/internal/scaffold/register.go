@@ -0,0 +1,135 @@
+package scaffold
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/metadata"
+)
+
+// planRegister дополняет план регистра накопления: колонки измерений и
+// ресурсов, таблица итогов и регистратор. Регистр хранится как
+// ЗаказыПоКонтрагентам: движения по регистратору и итоги по месяцам,
+// которые изменяются вместе с движениями, так что остатки читаются без
+// суммирования всех движений.
+func (p *planner) planRegister(e *entity) error {
+	o := e.Object
+	attrs := e.Columns
+
+	var err error
+	if e.Dimensions, err = p.columns(o, o.Dimensions); err != nil {
+		return err
+	}
+	if e.Resources, err = p.columns(o, o.Resources); err != nil {
+		return err
+	}
+	if len(e.Resources) == 0 {
+		return fmt.Errorf("scaffold: %s has no resources", o.FullName())
+	}
+	for _, c := range e.Resources {
+		if c.Field.Type.Kind != metadata.TypeNumber {
+			return fmt.Errorf("scaffold: %s.%s: resource of type %s; resources must be numbers", o.FullName(), c.Field.Name, c.Field.Type)
+		}
+	}
+	e.Columns = append(append(append([]column(nil), e.Dimensions...), e.Resources...), attrs...)
+
+	e.Totals = strings.TrimSuffix(e.Table, "_movements") + "_totals"
+	if reservedTables[e.Totals] {
+		return fmt.Errorf("scaffold: %s: table %s already exists in OrderFlow", o.FullName(), e.Totals)
+	}
+	e.RecorderTable, err = p.recorder(o)
+	return err
+}
+
+// Total возвращает тип итога регистра по измерениям
+func (e *entity) Total() string {
+	return e.Base() + "Total"
+}
+
+// Функции ниже возвращают части запросов к итогам регистра. У регистра
+// без измерений один итог на месяц.
+
+// DimensionNames перечисляет колонки измерений без начальной запятой для GROUP BY и ORDER BY
+func (e *entity) DimensionNames() string {
+	return strings.TrimPrefix(columnNames(e.Dimensions), ", ")
+}
+
+// TotalsKey — ключ строки итогов: организация, месяц и измерения.
+// Пустая ссылка (NULL) в измерении сравнивается как 0, иначе каждое
+// движение с пустой ссылкой создавало бы новую строку итогов.
+func (e *entity) TotalsKey() string {
+	key := []string{"organization_id", "period"}
+	for _, c := range e.Dimensions {
+		if strings.HasPrefix(c.GoType, "*") {
+			key = append(key, fmt.Sprintf("(COALESCE(%s, 0))", c.Name))
+		} else {
+			key = append(key, c.Name)
+		}
+	}
+	return strings.Join(key, ", ")
+}
+
+// TotalsMatch сравнивает измерения строки итогов t и сгруппированных движений m
+func (e *entity) TotalsMatch() string {
+	var b strings.Builder
+	for _, c := range e.Dimensions {
+		op := "="
+		if strings.HasPrefix(c.GoType, "*") {
+			op = "IS NOT DISTINCT FROM"
+		}
+		fmt.Fprintf(&b, " AND t.%s %s m.%s", c.Name, op, c.Name)
+	}
+	return b.String()
+}
+
+// Signed возвращает ресурсы движения со знаком: у регистра остатков
+// приход увеличивает остаток, расход уменьшает
+func (e *entity) Signed() string {
+	var b strings.Builder
+	for _, c := range e.Resources {
+		b.WriteString(", " + e.signed(c))
+	}
+	return b.String()
+}
+
+// SignedSums возвращает суммы ресурсов движений со знаком под именами колонок
+func (e *entity) SignedSums() string {
+	var b strings.Builder
+	for _, c := range e.Resources {
+		fmt.Fprintf(&b, ", SUM(%s) AS %s", e.signed(c), c.Name)
+	}
+	return b.String()
+}
+
+func (e *entity) signed(c column) string {
+	if !e.Balance() {
+		return c.Name
+	}
+	return fmt.Sprintf("CASE record_kind WHEN 'receipt' THEN %s ELSE -%s END", c.Name, c.Name)
+}
+
+// Sums возвращает суммы ресурсов; без строк сумма равна 0
+func (e *entity) Sums() string {
+	var b strings.Builder
+	for _, c := range e.Resources {
+		fmt.Fprintf(&b, ", COALESCE(SUM(%s), 0)", c.Name)
+	}
+	return b.String()
+}
+
+// TotalsAdd и TotalsSubtract изменяют ресурсы итогов на сгруппированные движения
+func (e *entity) TotalsAdd() string {
+	var set []string
+	for _, c := range e.Resources {
+		set = append(set, fmt.Sprintf("%s = %s.%s + EXCLUDED.%s", c.Name, e.Totals, c.Name, c.Name))
+	}
+	return strings.Join(set, ", ")
+}
+
+func (e *entity) TotalsSubtract() string {
+	var set []string
+	for _, c := range e.Resources {
+		set = append(set, fmt.Sprintf("%s = t.%s - m.%s", c.Name, c.Name, c.Name))
+	}
+	return strings.Join(set, ", ")
+}
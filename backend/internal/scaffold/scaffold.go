@@ -0,0 +1,300 @@
+// Package scaffold генерирует по промежуточному представлению конфигурации
+// 1С (пакет metadata) заготовки кода OrderFlow: модели в internal/domain/models,
+// миграцию Postgres и репозитории Postgres в internal/repository в том же
+// виде, что и написанные вручную. Объекты, которые OrderFlow уже реализует
+// (Контрагенты, Номенклатура, ЗаказПокупателя, ЗаказыПоКонтрагентам), не
+// генерируются, а ссылки на них указывают на существующие таблицы.
+//
+// Генерируется только хранение: сервисы, обработчики API и реализации
+// репозиториев для SQLite и памяти пишутся вручную.
+package scaffold
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"go/format"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/metadata"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"columns":   columnNames,
+	"values":    placeholders,
+	"args":      fieldArgs,
+	"scan":      scanArgs,
+	"set":       setList,
+	"add":       func(a, b int) int { return a + b },
+	"kindTitle": kindTitle,
+	"join":      strings.Join,
+}).ParseFS(templateFS, "templates/*.tmpl"))
+
+// Каталоги сгенерированных файлов относительно каталога backend
+const (
+	ModelsDir      = "internal/domain/models"
+	RepositoryDir  = "internal/repository"
+	MigrationsDir  = "migrations"
+	migrationLabel = "1c"
+)
+
+// Options — что и куда генерировать
+type Options struct {
+	// Objects — полные имена объектов, например «Справочник.Склады»; пусто —
+	// все объекты выгрузки, кроме реализованных в OrderFlow
+	Objects []string
+	// Migration — номер создаваемой миграции
+	Migration int64
+	// MigrationName — описание в имени файла миграции; по умолчанию имя
+	// таблицы единственного объекта или 1c_import
+	MigrationName string
+}
+
+// File — сгенерированный файл; Path задан относительно каталога backend
+type File struct {
+	Path    string
+	Content []byte
+}
+
+// Generate строит планы выбранных объектов и возвращает файлы моделей,
+// репозиториев и миграции
+func Generate(config *metadata.Configuration, opts Options) ([]File, error) {
+	objects, err := selectObjects(config, opts.Objects)
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("scaffold: nothing to generate: all objects of the dump are implemented by OrderFlow")
+	}
+
+	p := &planner{config: config, tables: make(map[*metadata.Object]string)}
+	var entities []*entity
+	for _, o := range objects {
+		e, err := p.plan(o)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, e)
+	}
+
+	var files []File
+	for _, e := range entities {
+		model, err := render("model.go.tmpl", e, true)
+		if err != nil {
+			return nil, err
+		}
+		repo, err := render(repositoryTemplate(e), e, true)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files,
+			File{Path: path.Join(ModelsDir, e.Table+".go"), Content: model},
+			File{Path: path.Join(RepositoryDir, e.Table+"_repository.go"), Content: repo})
+	}
+
+	name := opts.MigrationName
+	if name == "" {
+		name = migrationLabel + "_import"
+		if len(entities) == 1 {
+			name = migrationLabel + "_" + entities[0].Table
+		}
+	}
+	up, down, err := migration(entities)
+	if err != nil {
+		return nil, err
+	}
+	base := fmt.Sprintf("%06d_%s", opts.Migration, name)
+	files = append(files,
+		File{Path: path.Join(MigrationsDir, base+".up.sql"), Content: up},
+		File{Path: path.Join(MigrationsDir, base+".down.sql"), Content: down})
+	return files, nil
+}
+
+// selectObjects возвращает объекты по полным именам или все, кроме реализованных в OrderFlow
+func selectObjects(config *metadata.Configuration, names []string) ([]*metadata.Object, error) {
+	if len(names) == 0 {
+		var objects []*metadata.Object
+		for _, o := range config.Objects {
+			if _, ok := lookupBuiltin(o.Kind, o.Name); !ok {
+				objects = append(objects, o)
+			}
+		}
+		return objects, nil
+	}
+
+	var objects []*metadata.Object
+	for _, name := range names {
+		kind, objName, err := metadata.ParseFullName(name)
+		if err != nil {
+			return nil, err
+		}
+		o := config.Lookup(kind, objName)
+		if o == nil {
+			return nil, fmt.Errorf("scaffold: %s is not in the dump", name)
+		}
+		if _, ok := lookupBuiltin(kind, objName); ok {
+			return nil, fmt.Errorf("scaffold: %s is already implemented by OrderFlow", o.FullName())
+		}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+func repositoryTemplate(e *entity) string {
+	if e.Object.Kind == metadata.KindAccumulationRegister {
+		return "register_repository.go.tmpl"
+	}
+	return "repository.go.tmpl"
+}
+
+// render выполняет шаблон; код Go форматируется, как gofmt
+func render(name string, data interface{}, gofmt bool) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, fmt.Errorf("scaffold: %s: %w", name, err)
+	}
+	if !gofmt {
+		return buf.Bytes(), nil
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("scaffold: %s: generated code does not parse: %w", name, err)
+	}
+	return src, nil
+}
+
+// Imports возвращает пакеты, нужные модели: time и money по типам полей
+func (e *entity) Imports() []string {
+	var imports []string
+	uses := func(prefix string) bool {
+		if prefix == "time." && e.Object.Kind == metadata.KindAccumulationRegister {
+			return true
+		}
+		for _, c := range e.allColumns() {
+			if strings.HasPrefix(c.GoType, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	if uses("time.") {
+		imports = append(imports, `"time"`)
+	}
+	if uses("money.") {
+		if len(imports) > 0 {
+			imports = append(imports, "")
+		}
+		imports = append(imports, `"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"`)
+	}
+	return imports
+}
+
+func (e *entity) allColumns() []column {
+	columns := append([]column(nil), e.Columns...)
+	for _, s := range e.Sections {
+		columns = append(columns, s.Columns...)
+	}
+	return columns
+}
+
+// Base возвращает имя объекта в именах типов и функций: модель без
+// суффикса Movement у регистра
+func (e *entity) Base() string {
+	return strings.TrimSuffix(e.Model, "Movement")
+}
+
+// Repository возвращает имя интерфейса репозитория
+func (e *entity) Repository() string {
+	return e.Base() + "Repository"
+}
+
+// Impl возвращает имя типа реализации репозитория
+func (e *entity) Impl() string {
+	return lowerName(e.Repository())
+}
+
+// Noun и Records называют запись объекта в комментариях: «документ»,
+// «с документами»; «элемент справочника», «с элементами справочника»
+func (e *entity) Noun() string {
+	if e.Object.Kind == metadata.KindDocument {
+		return "документ"
+	}
+	return "элемент " + kindTitle(e.Object.Kind)
+}
+
+func (e *entity) Records() string {
+	if e.Object.Kind == metadata.KindDocument {
+		return "документами"
+	}
+	return "элементами " + kindTitle(e.Object.Kind)
+}
+
+// Balance сообщает, что регистр хранит вид движения (Приход/Расход)
+func (e *entity) Balance() bool {
+	return e.Object.Kind == metadata.KindAccumulationRegister && e.Object.Balance
+}
+
+// kindTitle возвращает вид объекта в родительном падеже для комментариев:
+// «справочника», «документа», «регистра накопления»
+func kindTitle(kind metadata.Kind) string {
+	switch kind {
+	case metadata.KindCatalog:
+		return "справочника"
+	case metadata.KindDocument:
+		return "документа"
+	case metadata.KindAccumulationRegister:
+		return "регистра накопления"
+	}
+	return string(kind)
+}
+
+// Функции шаблонов перечисляют колонки в запросах и поля записи v в
+// аргументах. Списки начинаются с запятой: они продолжают колонки, которые
+// есть в каждом запросе (organization_id, id).
+
+func columnNames(columns []column) string {
+	var b strings.Builder
+	for _, c := range columns {
+		b.WriteString(", " + c.Name)
+	}
+	return b.String()
+}
+
+// placeholders возвращает n параметров начиная с $from
+func placeholders(from, n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, ", $%d", from+i)
+	}
+	return b.String()
+}
+
+func fieldArgs(v string, columns []column) string {
+	var b strings.Builder
+	for _, c := range columns {
+		b.WriteString(", " + v + "." + c.GoName)
+	}
+	return b.String()
+}
+
+func scanArgs(v string, columns []column) string {
+	var b strings.Builder
+	for _, c := range columns {
+		b.WriteString(", &" + v + "." + c.GoName)
+	}
+	return b.String()
+}
+
+// setList возвращает «a = $1, b = $2, » для UPDATE
+func setList(columns []column) string {
+	var b strings.Builder
+	for i, c := range columns {
+		fmt.Fprintf(&b, "%s = $%d, ", c.Name, i+1)
+	}
+	return b.String()
+}
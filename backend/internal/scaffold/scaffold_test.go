@@ -0,0 +1,160 @@
+package scaffold
+
+import (
+	"bytes"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/metadata"
+)
+
+// update перезаписывает эталоны: go test ./internal/scaffold -update
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// goldenDir — эталоны сгенерированных файлов по путям File.Path с
+// расширением .golden, чтобы go и gofmt не считали их исходниками
+const goldenDir = "testdata/golden"
+
+// readTestDump читает выгрузку testdata/dump: справочник в упрощенном
+// описании, справочник и документ в выгрузке конфигуратора, регистр
+// остатков с регистратором и регистр оборотов без него
+func readTestDump(t *testing.T) *metadata.Configuration {
+	t.Helper()
+	config, err := metadata.Read(os.DirFS("testdata/dump"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+// TestGenerateGolden сравнивает модели, репозитории и миграцию по выгрузке
+// testdata/dump с эталонами
+func TestGenerateGolden(t *testing.T) {
+	files, err := Generate(readTestDump(t), Options{Migration: 18})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *update {
+		if err := os.RemoveAll(goldenDir); err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range files {
+			path := filepath.Join(goldenDir, filepath.FromSlash(f.Path)+".golden")
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, f.Content, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+
+	golden := map[string]bool{}
+	err = fs.WalkDir(os.DirFS(goldenDir), ".", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			golden[strings.TrimSuffix(path, ".golden")] = true
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range files {
+		if !golden[f.Path] {
+			t.Errorf("%s: no golden file; run go test -update", f.Path)
+			continue
+		}
+		delete(golden, f.Path)
+		want, err := os.ReadFile(filepath.Join(goldenDir, filepath.FromSlash(f.Path)+".golden"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(f.Content, want) {
+			t.Errorf("%s differs from the golden file; run go test -update and review the diff\n%s", f.Path, firstDiff(f.Content, want))
+		}
+	}
+	for path := range golden {
+		t.Errorf("%s: golden file is not generated", path)
+	}
+}
+
+// firstDiff возвращает первую различающуюся строку сгенерированного файла и эталона
+func firstDiff(got, want []byte) string {
+	gotLines, wantLines := strings.Split(string(got), "\n"), strings.Split(string(want), "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			return "line " + strconv.Itoa(i+1) + ":\n  got:  " + g + "\n  want: " + w
+		}
+	}
+	return ""
+}
+
+func TestGenerateObjects(t *testing.T) {
+	config := readTestDump(t)
+	tests := []struct {
+		name    string
+		objects []string
+		files   []string
+		err     string
+	}{
+		{
+			name:    "catalog",
+			objects: []string{"Справочник.Склады"},
+			files: []string{
+				"internal/domain/models/sklady.go",
+				"internal/repository/sklady_repository.go",
+				"migrations/000018_1c_sklady.up.sql",
+				"migrations/000018_1c_sklady.down.sql",
+			},
+		},
+		{
+			name:    "register",
+			objects: []string{"AccumulationRegister.Продажи"},
+			files: []string{
+				"internal/domain/models/prodazhi_movements.go",
+				"internal/repository/prodazhi_movements_repository.go",
+				"migrations/000018_1c_prodazhi_movements.up.sql",
+				"migrations/000018_1c_prodazhi_movements.down.sql",
+			},
+		},
+		{name: "builtin", objects: []string{"Справочник.Номенклатура"}, err: "already implemented"},
+		{name: "missing", objects: []string{"Справочник.Кассы"}, err: "not in the dump"},
+		{name: "bad name", objects: []string{"Склады"}, err: "not a full object name"},
+	}
+
+	for _, tt := range tests {
+		files, err := Generate(config, Options{Objects: tt.objects, Migration: 18})
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var paths []string
+		for _, f := range files {
+			paths = append(paths, f.Path)
+		}
+		if strings.Join(paths, "\n") != strings.Join(tt.files, "\n") {
+			t.Errorf("%s: files\n%s\nwant\n%s", tt.name, strings.Join(paths, "\n"), strings.Join(tt.files, "\n"))
+		}
+	}
+}
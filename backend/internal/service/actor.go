@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"slices"
+)
+
+// Role — роль пользователя, от которой зависят доступные ему действия
+type Role string
+
+const (
+	RoleAdmin Role = "admin"
+	// RoleManager — менеджер по продажам: ведет справочники и заказы
+	RoleManager Role = "manager"
+	// RoleHeadOfSales — руководитель продаж: вдобавок может отменять
+	// подтверждение и удалять подтвержденные заказы
+	RoleHeadOfSales Role = "head_of_sales"
+	// RoleAccountant — бухгалтер: только чтение
+	RoleAccountant Role = "accountant"
+	RoleWarehouse  Role = "warehouse"
+)
+
+// Roles перечисляет все роли
+var Roles = []Role{RoleAdmin, RoleManager, RoleHeadOfSales, RoleAccountant, RoleWarehouse}
+
+// Valid сообщает, известна ли роль
+func (r Role) Valid() bool {
+	return slices.Contains(Roles, r)
+}
+
+// Scope — область доступа ключа API
+type Scope string
+
+const (
+	ScopeReadClients   Scope = "read:clients"
+	ScopeWriteClients  Scope = "write:clients"
+	ScopeReadProducts  Scope = "read:products"
+	ScopeWriteProducts Scope = "write:products"
+	ScopeReadOrders    Scope = "read:orders"
+	ScopeWriteOrders   Scope = "write:orders"
+	// ScopeConfirmOrders разрешает проводить заказы и отменять проведение
+	ScopeConfirmOrders Scope = "confirm:orders"
+	// ScopeFulfilOrders разрешает переходы склада: сборку и отгрузку
+	ScopeFulfilOrders Scope = "fulfil:orders"
+	// ScopeReadRegister разрешает читать регистр «ЗаказыПоКонтрагентам»
+	ScopeReadRegister Scope = "read:orders-by-client"
+)
+
+// Scopes перечисляет все области доступа
+var Scopes = []Scope{
+	ScopeReadClients, ScopeWriteClients, ScopeReadProducts, ScopeWriteProducts,
+	ScopeReadOrders, ScopeWriteOrders, ScopeConfirmOrders, ScopeFulfilOrders, ScopeReadRegister,
+}
+
+// Valid сообщает, известна ли область доступа
+func (s Scope) Valid() bool {
+	return slices.Contains(Scopes, s)
+}
+
+// Actor — пользователь или система, от имени которой выполняется запрос.
+// Имя исполнителя попадает в историю заказа и журнал аудита. Для запросов
+// с ключом API задан KeyID, а права определяются областями Scopes, а не ролями.
+// Исполнитель с OrganizationID работает только с данными этой организации;
+// без нее — только администратор развертывания, которому доступна любая
+// организация (см. OrganizationService.Resolve).
+type Actor struct {
+	Name           string  `json:"name"`
+	Roles          []Role  `json:"roles"`
+	KeyID          int64   `json:"key_id,omitempty"`
+	Scopes         []Scope `json:"scopes,omitempty"`
+	OrganizationID int64   `json:"organization_id,omitempty"`
+}
+
+// Anonymous — исполнитель запросов, когда аутентификация выключена
+// (AuthConfig.Enabled). Ему доступны все действия.
+var Anonymous = Actor{Name: "anonymous", Roles: []Role{RoleAdmin}}
+
+// HasAnyRole сообщает, есть ли у исполнителя одна из ролей. Администратору
+// доступно все.
+func (a Actor) HasAnyRole(roles ...Role) bool {
+	if slices.Contains(a.Roles, RoleAdmin) {
+		return true
+	}
+	for _, r := range roles {
+		if slices.Contains(a.Roles, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin сообщает, может ли исполнитель администрировать развертывание:
+// организации, пользователей и ключи API. Это администратор, не привязанный
+// к организации; администратор организации управляет только ее данными.
+func (a Actor) IsAdmin() bool {
+	return a.KeyID == 0 && a.OrganizationID == 0 && slices.Contains(a.Roles, RoleAdmin)
+}
+
+// Can сообщает, разрешено ли исполнителю действие. Ключу API его разрешает
+// область доступа scope, пользователю — одна из ролей roles; действие без
+// ролей доступно любому пользователю.
+func (a Actor) Can(scope Scope, roles ...Role) bool {
+	if a.KeyID != 0 {
+		return slices.Contains(a.Scopes, scope)
+	}
+	if len(roles) == 0 {
+		return true
+	}
+	return a.HasAnyRole(roles...)
+}
+
+type actorKey struct{}
+
+// WithActor возвращает контекст запроса с исполнителем
+func WithActor(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, a)
+}
+
+// ActorFromContext возвращает исполнителя запроса или Anonymous
+func ActorFromContext(ctx context.Context) Actor {
+	if a, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return a
+	}
+	return Anonymous
+}
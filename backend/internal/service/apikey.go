@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+	"golang.org/x/time/rate"
+)
+
+var (
+	ErrInvalidAPIKey = apperr.New(apperr.KindUnauthorized, "invalid_api_key", "API key is invalid, expired or revoked")
+	ErrRateLimited   = apperr.New(apperr.KindTooManyRequests, "rate_limited", "API key rate limit exceeded")
+)
+
+// RateLimitError — отказ по лимиту запросов ключа. Сводится к ErrRateLimited
+// и сообщает, через сколько можно повторить запрос.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrRateLimited.Message, e.RetryAfter)
+}
+
+func (e *RateLimitError) Unwrap() error { return ErrRateLimited }
+
+const (
+	// apiKeyPrefix отличает ключи OrderFlow от других секретов, например в логах
+	apiKeyPrefix = "ofk_"
+	// apiKeyActorPrefix — префикс имени исполнителя запросов с ключом
+	apiKeyActorPrefix = "api-key:"
+	// apiKeyVisible — сколько первых символов ключа хранится открыто для списка ключей
+	apiKeyVisible = len(apiKeyPrefix) + 8
+
+	// DefaultAPIKeyRateLimit — лимит запросов в минуту, если он не указан при выпуске
+	DefaultAPIKeyRateLimit = 60
+	maxAPIKeyRateLimit     = 100000
+
+	// lastUsedPrecision — как часто обновляется время последнего использования,
+	// чтобы не писать в базу на каждый запрос
+	lastUsedPrecision = time.Minute
+)
+
+type APIKeyService interface {
+	List(ctx context.Context) ([]models.APIKey, error)
+	GetByID(ctx context.Context, id int64) (*models.APIKey, error)
+	// Create выпускает ключ и возвращает его значение. Значение ключа не
+	// хранится и больше не может быть получено. Ключ без OrganizationID
+	// выпускается для организации запроса.
+	Create(ctx context.Context, key *models.APIKey) (string, error)
+	Revoke(ctx context.Context, id int64) (*models.APIKey, error)
+	// Authenticate проверяет ключ, его срок действия и лимит запросов
+	// и возвращает исполнителя с областями доступа ключа
+	Authenticate(ctx context.Context, secret string) (Actor, error)
+}
+
+// APIKeyService implementation
+type apiKeyService struct {
+	repo repository.APIKeyRepository
+	// uow записывает выпуск и отзыв ключа вместе с журналом аудита
+	uow repository.UnitOfWork
+
+	mu       sync.Mutex
+	limiters map[int64]*rate.Limiter
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository, uow repository.UnitOfWork) APIKeyService {
+	return &apiKeyService{repo: repo, uow: uow, limiters: make(map[int64]*rate.Limiter)}
+}
+
+func (s *apiKeyService) List(ctx context.Context) ([]models.APIKey, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *apiKeyService) GetByID(ctx context.Context, id int64) (*models.APIKey, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *apiKeyService) Create(ctx context.Context, key *models.APIKey) (string, error) {
+	if key.OrganizationID == 0 {
+		org, err := tenant.ID(ctx)
+		if err != nil {
+			return "", err
+		}
+		key.OrganizationID = org
+	}
+	if err := validateAPIKey(key); err != nil {
+		return "", err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	key.Prefix = secret[:apiKeyVisible]
+	key.KeyHash = hashAPIKey(secret)
+	key.CreatedBy = ActorFromContext(ctx).Name
+	key.RevokedAt, key.LastUsedAt = nil, nil
+
+	err := s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		if err := repos.APIKey.Create(ctx, key); err != nil {
+			return organizationRefError(err)
+		}
+		return audit(ctx, repos, models.AuditEntityAPIKey, key.ID, models.AuditActionCreate, nil, key)
+	})
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, id int64) (*models.APIKey, error) {
+	var revoked *models.APIKey
+	err := s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		before, err := repos.APIKey.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := repos.APIKey.Revoke(ctx, id, time.Now()); err != nil {
+			return err
+		}
+		if revoked, err = repos.APIKey.GetByID(ctx, id); err != nil {
+			return err
+		}
+		return audit(ctx, repos, models.AuditEntityAPIKey, id, models.AuditActionRevoke, before, revoked)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	delete(s.limiters, id)
+	s.mu.Unlock()
+	return revoked, nil
+}
+
+func (s *apiKeyService) Authenticate(ctx context.Context, secret string) (Actor, error) {
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		return Actor{}, ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.GetByHash(ctx, hashAPIKey(secret))
+	if errors.Is(err, repository.ErrNotFound) {
+		return Actor{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return Actor{}, err
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !now.Before(*key.ExpiresAt)) {
+		return Actor{}, ErrInvalidAPIKey
+	}
+
+	if r := s.limiter(key).ReserveN(now, 1); r.DelayFrom(now) > 0 {
+		r.CancelAt(now)
+		return Actor{}, &RateLimitError{RetryAfter: r.DelayFrom(now)}
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedPrecision {
+		if err := s.repo.TouchLastUsed(ctx, key.ID, now); err != nil {
+			return Actor{}, err
+		}
+	}
+
+	actor := Actor{
+		Name:           apiKeyActorPrefix + key.Name,
+		KeyID:          key.ID,
+		Scopes:         make([]Scope, len(key.Scopes)),
+		OrganizationID: key.OrganizationID,
+	}
+	for i, sc := range key.Scopes {
+		actor.Scopes[i] = Scope(sc)
+	}
+	return actor, nil
+}
+
+// limiter возвращает ограничитель ключа: RateLimit запросов в минуту
+// с запасом на всплеск в тот же RateLimit
+func (s *apiKeyService) limiter(key *models.APIKey) *rate.Limiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.limiters[key.ID]
+	if !ok {
+		l = rate.NewLimiter(rate.Limit(float64(key.RateLimit)/time.Minute.Seconds()), key.RateLimit)
+		s.limiters[key.ID] = l
+	}
+	return l
+}
+
+// validateAPIKey проверяет имя, области доступа, лимит и срок действия ключа
+func validateAPIKey(key *models.APIKey) error {
+	var details []apperr.FieldError
+
+	key.Name = strings.TrimSpace(key.Name)
+	if key.Name == "" {
+		details = append(details, apperr.Field("name", "is required"))
+	} else if tooLong(key.Name, models.MaxAPIKeyNameLength) {
+		details = append(details, apperr.Field("name", fmt.Sprintf("must be at most %d characters", models.MaxAPIKeyNameLength)))
+	}
+
+	if len(key.Scopes) == 0 {
+		details = append(details, apperr.Field("scopes", "at least one scope is required"))
+	}
+	for i, sc := range key.Scopes {
+		if !Scope(sc).Valid() {
+			details = append(details, apperr.Field(fmt.Sprintf("scopes[%d]", i), fmt.Sprintf("unknown scope %q", sc)))
+		}
+	}
+
+	if key.RateLimit == 0 {
+		key.RateLimit = DefaultAPIKeyRateLimit
+	} else if key.RateLimit < 0 || key.RateLimit > maxAPIKeyRateLimit {
+		details = append(details, apperr.Field("rate_limit", fmt.Sprintf("must be between 1 and %d", maxAPIKeyRateLimit)))
+	}
+
+	if key.OrganizationID < 0 {
+		details = append(details, apperr.Field("organization_id", "must be positive"))
+	}
+
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now()) {
+		details = append(details, apperr.Field("expires_at", "must be in the future"))
+	}
+
+	if len(details) > 0 {
+		return ErrValidation.WithDetails(details...)
+	}
+	return nil
+}
+
+// hashAPIKey возвращает хеш ключа для хранения. Ключ случаен и длинен,
+// поэтому медленный хеш, как для паролей, не нужен.
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+)
+
+func TestAPIKeyAuthenticate(t *testing.T) {
+	s, repos, ctx := newTestServices(t)
+
+	key := &models.APIKey{Name: "erp", Scopes: []string{string(ScopeReadOrders), string(ScopeWriteOrders)}}
+	secret, err := s.APIKey.Create(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, apiKeyPrefix) || !strings.HasPrefix(secret, key.Prefix) {
+		t.Errorf("secret %q does not start with %q and the visible prefix %q", secret, apiKeyPrefix, key.Prefix)
+	}
+
+	// Хранится только хеш значения
+	stored, err := repos.APIKey.GetByID(ctx, key.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.KeyHash != hashAPIKey(secret) || strings.Contains(stored.KeyHash, secret) {
+		t.Errorf("stored hash %q is not the SHA-256 of the secret", stored.KeyHash)
+	}
+
+	actor, err := s.APIKey.Authenticate(ctx, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actor.Name != "api-key:erp" || actor.KeyID != key.ID || actor.OrganizationID != tenant.Default {
+		t.Errorf("actor = %+v", actor)
+	}
+	if used, _ := repos.APIKey.GetByID(ctx, key.ID); used.LastUsedAt == nil {
+		t.Error("last use time is not recorded")
+	}
+
+	for name, bad := range map[string]string{
+		"empty":          "",
+		"without prefix": strings.TrimPrefix(secret, apiKeyPrefix),
+		"unknown":        secret + "x",
+		"other prefix":   "ofx_" + strings.TrimPrefix(secret, apiKeyPrefix),
+	} {
+		if _, err := s.APIKey.Authenticate(ctx, bad); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("%s key: err = %v, want ErrInvalidAPIKey", name, err)
+		}
+	}
+}
+
+func TestAPIKeyExpiry(t *testing.T) {
+	s, repos, ctx := newTestServices(t)
+
+	// Ключ с истекшим сроком нельзя выпустить, поэтому он создается в хранилище
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	tests := []struct {
+		name    string
+		expires *time.Time
+		valid   bool
+	}{
+		{"expired", &past, false},
+		{"not expired", &future, true},
+		{"without expiry", nil, true},
+	}
+
+	for i, tt := range tests {
+		secret := apiKeyPrefix + "expiry-test-" + string(rune('a'+i))
+		key := &models.APIKey{
+			Name: tt.name, KeyHash: hashAPIKey(secret), Scopes: []string{string(ScopeReadOrders)},
+			OrganizationID: tenant.Default, RateLimit: DefaultAPIKeyRateLimit, ExpiresAt: tt.expires,
+		}
+		if err := repos.APIKey.Create(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		_, err := s.APIKey.Authenticate(ctx, secret)
+		if tt.valid && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("%s: err = %v, want ErrInvalidAPIKey", tt.name, err)
+		}
+	}
+
+	if _, err := s.APIKey.Create(ctx, &models.APIKey{Name: "late", Scopes: []string{string(ScopeReadOrders)}, ExpiresAt: &past}); !errors.Is(err, ErrValidation) {
+		t.Errorf("create expired key: err = %v, want ErrValidation", err)
+	}
+}
+
+func TestAPIKeyRevoke(t *testing.T) {
+	s, _, ctx := newTestServices(t)
+
+	key := &models.APIKey{Name: "warehouse", Scopes: []string{string(ScopeFulfilOrders)}}
+	secret, err := s.APIKey.Create(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.APIKey.Authenticate(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := s.APIKey.Revoke(ctx, key.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked.RevokedAt == nil {
+		t.Error("revoked key has no revocation time")
+	}
+	if _, err := s.APIKey.Authenticate(ctx, secret); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("revoked key: err = %v, want ErrInvalidAPIKey", err)
+	}
+	if keys, _ := s.APIKey.List(ctx); len(keys) != 1 {
+		t.Errorf("list has %d keys, want the revoked key to stay", len(keys))
+	}
+}
+
+// TestAPIKeyScopes проверяет, что права ключа определяют области доступа, а не роли
+func TestAPIKeyScopes(t *testing.T) {
+	s, _, ctx := newTestServices(t)
+
+	secret, err := s.APIKey.Create(ctx, &models.APIKey{Name: "reader", Scopes: []string{string(ScopeReadClients)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actor, err := s.APIKey.Authenticate(ctx, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		scope Scope
+		roles []Role
+		want  bool
+	}{
+		{ScopeReadClients, nil, true},
+		{ScopeReadClients, []Role{RoleManager}, true},
+		{ScopeWriteClients, nil, false},
+		{ScopeWriteClients, []Role{RoleManager, RoleAdmin}, false},
+		{ScopeConfirmOrders, []Role{RoleHeadOfSales}, false},
+	}
+	for _, tt := range tests {
+		if got := actor.Can(tt.scope, tt.roles...); got != tt.want {
+			t.Errorf("Can(%s, %v) = %t, want %t", tt.scope, tt.roles, got, tt.want)
+		}
+	}
+	if actor.IsAdmin() {
+		t.Error("key actor is a deployment administrator")
+	}
+
+	// Подтвердить заказ ключ без confirm:orders не может
+	order := newTestOrder(t, s, ctx, "1", "1")
+	if _, err := s.Order.Transition(WithActor(ctx, actor), order.ID, models.OrderActionConfirm); !errors.Is(err, ErrForbidden) {
+		t.Errorf("confirm without scope: err = %v, want ErrForbidden", err)
+	}
+
+	_, err = s.APIKey.Create(ctx, &models.APIKey{Name: "bad", Scopes: []string{"delete:everything"}})
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("unknown scope: err = %v, want ErrValidation", err)
+	}
+}
+
+func TestAPIKeyRateLimit(t *testing.T) {
+	s, _, ctx := newTestServices(t)
+
+	key := &models.APIKey{Name: "script", Scopes: []string{string(ScopeReadOrders)}, RateLimit: 3}
+	secret, err := s.APIKey.Create(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.APIKey.Create(ctx, &models.APIKey{Name: "other", Scopes: []string{string(ScopeReadOrders)}, RateLimit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Запас на всплеск равен лимиту в минуту
+	for i := 0; i < key.RateLimit; i++ {
+		if _, err := s.APIKey.Authenticate(ctx, secret); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+	}
+	_, err = s.APIKey.Authenticate(ctx, secret)
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("request over the limit: err = %v, want RateLimitError", err)
+	}
+	if limited.RetryAfter <= 0 || limited.RetryAfter > time.Minute {
+		t.Errorf("retry after %s, want up to a minute", limited.RetryAfter)
+	}
+
+	// Лимит считается для каждого ключа отдельно
+	if _, err := s.APIKey.Authenticate(ctx, other); err != nil {
+		t.Errorf("other key: %v", err)
+	}
+}
+
+// TestAPIKeyNameLength проверяет, что имя исполнителя ключа помещается в
+// столбцы actor журналов
+func TestAPIKeyNameLength(t *testing.T) {
+	s, _, ctx := newTestServices(t)
+
+	if got := len(apiKeyActorPrefix) + models.MaxAPIKeyNameLength; got > models.MaxActorLength {
+		t.Fatalf("actor of a key with the longest name has %d characters, column allows %d", got, models.MaxActorLength)
+	}
+
+	longest := strings.Repeat("к", models.MaxAPIKeyNameLength)
+	secret, err := s.APIKey.Create(ctx, &models.APIKey{Name: longest, Scopes: []string{string(ScopeReadOrders)}})
+	if err != nil {
+		t.Fatalf("key with a %d-character name: %v", models.MaxAPIKeyNameLength, err)
+	}
+	actor, err := s.APIKey.Authenticate(ctx, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len([]rune(actor.Name)); n > models.MaxActorLength {
+		t.Errorf("actor name has %d characters, want at most %d", n, models.MaxActorLength)
+	}
+
+	_, err = s.APIKey.Create(ctx, &models.APIKey{Name: longest + "к", Scopes: []string{string(ScopeReadOrders)}})
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("key with a %d-character name: err = %v, want ErrValidation", models.MaxAPIKeyNameLength+1, err)
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+// RequestInfo — сведения о запросе, которые попадают в журнал аудита
+type RequestInfo struct {
+	ID string
+	// Reason — причина изменения, указанная пользователем
+	Reason string
+}
+
+type requestInfoKey struct{}
+
+// WithRequestInfo возвращает контекст запроса со сведениями для журнала аудита
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFromContext возвращает сведения о запросе; вне HTTP-запроса они пусты
+func RequestInfoFromContext(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
+
+// audit пишет в журнал снимки сущности до и после изменения. Вызывается
+// в транзакции изменения, чтобы изменение и запись журнала не расходились.
+// before или after равны nil при создании и удалении.
+func audit(ctx context.Context, repos *repository.Repositories, entity string, id int64, action string, before, after interface{}) error {
+	info := RequestInfoFromContext(ctx)
+	entry := &models.AuditEntry{
+		Entity:    entity,
+		EntityID:  id,
+		Action:    action,
+		Actor:     ActorFromContext(ctx).Name,
+		RequestID: info.ID,
+		Reason:    info.Reason,
+	}
+
+	var err error
+	if entry.Before, err = snapshot(before); err != nil {
+		return err
+	}
+	if entry.After, err = snapshot(after); err != nil {
+		return err
+	}
+
+	return repos.Audit.Append(ctx, entry)
+}
+
+func snapshot(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// history возвращает журнал аудита сущности. Журнал удаленной сущности
+// сохраняется, поэтому "не найдено" означает, что о ней нет ни одной записи.
+func history(ctx context.Context, repo repository.AuditRepository, entity string, id int64) ([]models.AuditEntry, error) {
+	entries, err := repo.List(ctx, entity, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrNotFound
+	}
+	return entries, nil
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+// snapshotField возвращает поле снимка записи в журнале; пустой снимок — nil
+func snapshotField(t *testing.T, raw json.RawMessage, field string) interface{} {
+	t.Helper()
+	if raw == nil {
+		return nil
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		t.Fatalf("snapshot %s: %v", raw, err)
+	}
+	return v[field]
+}
+
+// TestAuditClient проверяет, что каждое изменение клиента пишет в журнал
+// исполнителя, запрос и снимки до и после, а неудачное — ничего
+func TestAuditClient(t *testing.T) {
+	s, _, ctx := newTestServices(t)
+	ctx = WithActor(ctx, Actor{Name: "ivanov", Roles: []Role{RoleManager}})
+	ctx = WithRequestInfo(ctx, RequestInfo{ID: "req-1", Reason: "сверка с 1С"})
+
+	client := &models.Client{Name: "ООО Ромашка"}
+	if err := s.Client.Create(ctx, client); err != nil {
+		t.Fatal(err)
+	}
+	stale := *client
+	client.Name = "АО Ромашка"
+	if err := s.Client.Update(ctx, client); err != nil {
+		t.Fatal(err)
+	}
+	stale.Name = "ЗАО Ромашка"
+	if err := s.Client.Update(ctx, &stale); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Fatalf("stale update: err = %v, want ErrVersionMismatch", err)
+	}
+	if err := s.Client.Delete(ctx, client.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Журнал удаленного клиента сохраняется
+	entries, err := s.Client.GetHistory(ctx, client.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		action        string
+		before, after interface{}
+	}{
+		{models.AuditActionCreate, nil, "ООО Ромашка"},
+		{models.AuditActionUpdate, "ООО Ромашка", "АО Ромашка"},
+		{models.AuditActionDelete, "АО Ромашка", nil},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("%d audit entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, e := range entries {
+		if e.Entity != models.AuditEntityClient || e.EntityID != client.ID || e.Action != want[i].action {
+			t.Errorf("entry %d: %s %d %s, want client %d %s", i, e.Entity, e.EntityID, e.Action, client.ID, want[i].action)
+		}
+		if e.Actor != "ivanov" || e.RequestID != "req-1" || e.Reason != "сверка с 1С" {
+			t.Errorf("entry %d: actor %q, request %q, reason %q", i, e.Actor, e.RequestID, e.Reason)
+		}
+		if got := snapshotField(t, e.Before, "name"); got != want[i].before {
+			t.Errorf("entry %d: before name = %v, want %v", i, got, want[i].before)
+		}
+		if got := snapshotField(t, e.After, "name"); got != want[i].after {
+			t.Errorf("entry %d: after name = %v, want %v", i, got, want[i].after)
+		}
+	}
+}
+
+// TestAuditOrderTransition проверяет запись перехода статуса заказа и
+// исполнителя по умолчанию вне HTTP-запроса
+func TestAuditOrderTransition(t *testing.T) {
+	s, _, ctx := newTestServices(t)
+
+	order := newTestOrder(t, s, ctx, "2", "10")
+	if _, err := s.Order.Transition(ctx, order.ID, models.OrderActionConfirm); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := s.Order.GetHistory(ctx, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("%d audit entries, want create and confirm: %+v", len(entries), entries)
+	}
+	if got := snapshotField(t, entries[0].After, "total_amount"); got != "20.00" {
+		t.Errorf("created order total = %v, want 20.00", got)
+	}
+	confirm := entries[1]
+	if confirm.Action != string(models.OrderActionConfirm) || confirm.Actor != Anonymous.Name || confirm.RequestID != "" {
+		t.Errorf("confirm entry = %+v", confirm)
+	}
+	before, after := snapshotField(t, confirm.Before, "status"), snapshotField(t, confirm.After, "status")
+	if before != string(models.OrderStatusDraft) || after != string(models.OrderStatusConfirmed) {
+		t.Errorf("confirm status %v -> %v, want draft -> confirmed", before, after)
+	}
+}
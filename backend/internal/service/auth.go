@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrUnauthorized       = apperr.New(apperr.KindUnauthorized, "unauthorized", "authentication required")
+	ErrInvalidCredentials = apperr.New(apperr.KindUnauthorized, "invalid_credentials", "invalid login or password")
+	ErrInvalidToken       = apperr.New(apperr.KindUnauthorized, "invalid_token", "token is invalid or expired")
+)
+
+// AuthConfig задает выпуск и проверку токенов
+type AuthConfig struct {
+	// Enabled включает аутентификацию. Без нее запросы выполняются от имени Anonymous.
+	Enabled bool
+	// Secret — ключ подписи токенов (HS256)
+	Secret     []byte
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// Tokens — пара токенов, выдаваемая при входе и обновлении
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	// ExpiresIn — время жизни токена доступа в секундах
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+type AuthService interface {
+	Enabled() bool
+	Login(ctx context.Context, login, password string) (*Tokens, error)
+	// Refresh выдает новую пару токенов по токену обновления. Токен обновления
+	// перестает действовать после любого изменения пользователя.
+	Refresh(ctx context.Context, refreshToken string) (*Tokens, error)
+	// Authenticate проверяет токен доступа и возвращает его владельца
+	Authenticate(ctx context.Context, accessToken string) (Actor, error)
+}
+
+const (
+	tokenIssuer  = "orderflow"
+	tokenAccess  = "access"
+	tokenRefresh = "refresh"
+)
+
+// claims — содержимое токена. Роли и организация хранятся в токене доступа,
+// поэтому его проверка не обращается к базе; токен обновления несет версию
+// пользователя и проверяется по базе.
+type claims struct {
+	jwt.RegisteredClaims
+	Type         string   `json:"typ"`
+	Login        string   `json:"login"`
+	Roles        []string `json:"roles,omitempty"`
+	Organization int64    `json:"org,omitempty"`
+	Version      int64    `json:"ver,omitempty"`
+}
+
+// AuthService implementation
+type authService struct {
+	users repository.UserRepository
+	cfg   AuthConfig
+}
+
+func NewAuthService(users repository.UserRepository, cfg AuthConfig) AuthService {
+	return &authService{users: users, cfg: cfg}
+}
+
+func (s *authService) Enabled() bool {
+	return s.cfg.Enabled
+}
+
+func (s *authService) Login(ctx context.Context, login, password string) (*Tokens, error) {
+	user, err := s.users.GetByLogin(ctx, login)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Хешируем впустую, чтобы время ответа не выдавало существование логина
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil || !user.Active {
+		return nil, ErrInvalidCredentials
+	}
+	return s.issue(user)
+}
+
+func (s *authService) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	c, err := s.parse(refreshToken, tokenRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.ParseInt(c.Subject, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	user, err := s.users.GetByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.Active || user.Version != c.Version {
+		return nil, ErrInvalidToken
+	}
+	return s.issue(user)
+}
+
+func (s *authService) Authenticate(ctx context.Context, accessToken string) (Actor, error) {
+	c, err := s.parse(accessToken, tokenAccess)
+	if err != nil {
+		return Actor{}, err
+	}
+
+	actor := Actor{Name: c.Login, Roles: make([]Role, len(c.Roles)), OrganizationID: c.Organization}
+	for i, r := range c.Roles {
+		actor.Roles[i] = Role(r)
+	}
+	return actor, nil
+}
+
+func (s *authService) issue(user *models.User) (*Tokens, error) {
+	now := time.Now()
+	subject := strconv.FormatInt(user.ID, 10)
+	var org int64
+	if user.OrganizationID != nil {
+		org = *user.OrganizationID
+	}
+
+	access, err := s.sign(claims{
+		RegisteredClaims: registered(subject, now, s.cfg.AccessTTL),
+		Type:             tokenAccess,
+		Login:            user.Login,
+		Roles:            user.Roles,
+		Organization:     org,
+	})
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := s.sign(claims{
+		RegisteredClaims: registered(subject, now, s.cfg.RefreshTTL),
+		Type:             tokenRefresh,
+		Login:            user.Login,
+		Version:          user.Version,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.cfg.AccessTTL / time.Second),
+	}, nil
+}
+
+func registered(subject string, now time.Time, ttl time.Duration) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Issuer:    tokenIssuer,
+		Subject:   subject,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+}
+
+func (s *authService) sign(c claims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(s.cfg.Secret)
+}
+
+// parse проверяет подпись, срок действия и назначение токена
+func (s *authService) parse(token, typ string) (*claims, error) {
+	c := &claims{}
+	_, err := jwt.ParseWithClaims(token, c, func(*jwt.Token) (interface{}, error) {
+		return s.cfg.Secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || c.Type != typ {
+		return nil, ErrInvalidToken
+	}
+	return c, nil
+}
+
+// dummyHash — хеш для сравнения при неизвестном логине; вычисляется при первом обращении
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("orderflow"), bcrypt.DefaultCost)
+	return hash
+})
+
+// hashPassword хеширует пароль для хранения
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testAuthConfig = AuthConfig{
+	Enabled:    true,
+	Secret:     []byte("test-secret"),
+	AccessTTL:  15 * time.Minute,
+	RefreshTTL: time.Hour,
+}
+
+// newTestAuth создает службу аутентификации и активного менеджера с паролем secret123
+func newTestAuth(t *testing.T) (*authService, *Services, *models.User, context.Context) {
+	t.Helper()
+	s, repos, ctx := newTestServices(t)
+	user := &models.User{Login: "manager", Roles: []string{string(RoleManager)}, Active: true}
+	if err := s.User.Create(ctx, user, "secret123"); err != nil {
+		t.Fatal(err)
+	}
+	return NewAuthService(repos.User, testAuthConfig).(*authService), s, user, ctx
+}
+
+func TestAuthLogin(t *testing.T) {
+	auth, s, user, ctx := newTestAuth(t)
+
+	tokens, err := auth.Login(ctx, "manager", "secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.TokenType != "Bearer" || tokens.ExpiresIn != int64(testAuthConfig.AccessTTL/time.Second) {
+		t.Errorf("tokens = %+v", tokens)
+	}
+	actor, err := auth.Authenticate(ctx, tokens.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actor.Name != "manager" || len(actor.Roles) != 1 || actor.Roles[0] != RoleManager || actor.OrganizationID != tenant.Default {
+		t.Errorf("actor = %+v", actor)
+	}
+
+	for _, tt := range []struct{ name, login, password string }{
+		{"wrong password", "manager", "secret124"},
+		{"empty password", "manager", ""},
+		{"unknown login", "nobody", "secret123"},
+	} {
+		if _, err := auth.Login(ctx, tt.login, tt.password); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: err = %v, want ErrInvalidCredentials", tt.name, err)
+		}
+	}
+
+	user.Active = false
+	if err := s.User.Update(ctx, user, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Login(ctx, "manager", "secret123"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("deactivated user: err = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+// TestAuthInvalidAccessToken проверяет, что токен доступа принимается только
+// неистекшим, подписанным HS256 ключом сервера и выданным для доступа
+func TestAuthInvalidAccessToken(t *testing.T) {
+	auth, _, _, ctx := newTestAuth(t)
+
+	tokens, err := auth.Login(ctx, "manager", "secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	access := func(mutate func(c *claims)) claims {
+		c := claims{RegisteredClaims: registered("1", now, time.Minute), Type: tokenAccess, Login: "manager",
+			Roles: []string{string(RoleAdmin)}}
+		mutate(&c)
+		return c
+	}
+	sign := func(method jwt.SigningMethod, key interface{}, c claims) string {
+		token, err := jwt.NewWithClaims(method, c).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	// Подмененное содержимое с подписью настоящего токена
+	issued := strings.Split(tokens.AccessToken, ".")
+	forged := strings.Split(sign(jwt.SigningMethodHS256, []byte("other"), access(func(*claims) {})), ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"garbage", "not-a-jwt"},
+		{"refresh token", tokens.RefreshToken},
+		{"expired", sign(jwt.SigningMethodHS256, testAuthConfig.Secret, access(func(c *claims) {
+			c.IssuedAt, c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Hour)), jwt.NewNumericDate(now.Add(-time.Minute))
+		}))},
+		{"without expiry", sign(jwt.SigningMethodHS256, testAuthConfig.Secret, access(func(c *claims) { c.ExpiresAt = nil }))},
+		{"other issuer", sign(jwt.SigningMethodHS256, testAuthConfig.Secret, access(func(c *claims) { c.Issuer = "evil" }))},
+		{"other secret", strings.Join(forged, ".")},
+		{"tampered payload", issued[0] + "." + forged[1] + "." + issued[2]},
+		{"HS512", sign(jwt.SigningMethodHS512, testAuthConfig.Secret, access(func(*claims) {}))},
+		{"alg none", sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, access(func(*claims) {}))},
+	}
+	for _, tt := range tests {
+		if actor, err := auth.Authenticate(ctx, tt.token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: actor = %+v, err = %v, want ErrInvalidToken", tt.name, actor, err)
+		}
+	}
+}
+
+func TestAuthRefresh(t *testing.T) {
+	auth, s, user, ctx := newTestAuth(t)
+
+	tokens, err := auth.Login(ctx, "manager", "secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Refresh(ctx, tokens.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("access token as refresh token: err = %v, want ErrInvalidToken", err)
+	}
+	refreshed, err := auth.Refresh(ctx, tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Authenticate(ctx, refreshed.AccessToken); err != nil {
+		t.Errorf("refreshed access token: %v", err)
+	}
+
+	// После изменения пользователя выданные токены обновления не действуют
+	user.Active = false
+	if err := s.User.Update(ctx, user, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Refresh(ctx, refreshed.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("deactivated user: err = %v, want ErrInvalidToken", err)
+	}
+
+	// Повторная активация меняет версию, поэтому старый токен не оживает
+	user.Active = true
+	if err := s.User.Update(ctx, user, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Refresh(ctx, refreshed.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token issued before reactivation: err = %v, want ErrInvalidToken", err)
+	}
+}
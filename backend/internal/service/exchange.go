@@ -0,0 +1,989 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/commerceml"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+)
+
+// ExchangeSystem — система внешних идентификаторов (models.ExternalRef),
+// в которой хранятся Ид объектов из обмена CommerceML: GUID ссылок 1С
+const ExchangeSystem = "1c"
+
+// MaxExchangeFileSize — наибольший размер документа обмена
+const MaxExchangeFileSize = 100 << 20
+
+// ErrExchangeFileTooLarge: документ обмена больше MaxExchangeFileSize
+var ErrExchangeFileTooLarge = apperr.New(apperr.KindTooLarge, "file_too_large",
+	fmt.Sprintf("exchange file must be at most %d MB", MaxExchangeFileSize>>20))
+
+// ExchangeConfig — параметры обмена с 1С
+type ExchangeConfig struct {
+	// Dir — каталог файлов, которые узел 1С передает по протоколу обмена
+	// до их загрузки; по умолчанию — во временном каталоге системы
+	Dir string
+}
+
+// ExchangeCounts — число загруженных записей одной сущности
+type ExchangeCounts struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Skipped   int `json:"skipped"`
+}
+
+// ExchangeReport — результат загрузки документа CommerceML
+type ExchangeReport struct {
+	Clients  ExchangeCounts `json:"clients"`
+	Products ExchangeCounts `json:"products"`
+	Orders   ExchangeCounts `json:"orders"`
+	// Skipped — документы, которые не загружены, с причиной
+	Skipped []ExchangeSkip `json:"skipped"`
+}
+
+// ExchangeSkip — незагруженный документ
+type ExchangeSkip struct {
+	ID     string `json:"id"`
+	Number string `json:"number"`
+	Reason string `json:"reason"`
+}
+
+// ExchangeService обменивается с 1С документами CommerceML 2. Контрагенты
+// становятся клиентами, номенклатура — товарами, документы «Заказ товара» —
+// заказами. Ид объектов 1С сохраняются (ExchangeSystem), поэтому повторный
+// обмен изменяет те же записи, а не создает новые. Записи OrderFlow при
+// выгрузке получают постоянные Ид, по которым 1С узнает их при загрузке.
+//
+// Заказ загружается, пока он черновик: статусом заказа дальше управляет
+// OrderFlow, и изменения из 1С в нем пропускаются. Статус из документа не
+// загружается, дата заказа — дата его создания в OrderFlow.
+type ExchangeService interface {
+	// Import загружает контрагентов, товары и заказы документа в одной транзакции
+	Import(ctx context.Context, r io.Reader) (*ExchangeReport, error)
+	// ExportCatalog записывает всех клиентов и все товары как import.xml
+	ExportCatalog(ctx context.Context, w io.Writer) error
+	// ExportOrders записывает заказы отбора q с позициями как orders.xml
+	ExportOrders(ctx context.Context, q repository.OrderQuery, w io.Writer) error
+
+	// Файлы протокола обмена 1С хранятся до загрузки отдельно для каждого
+	// пользователя в каждой организации.
+
+	// StartSession начинает сеанс обмена: удаляет файлы прошлого сеанса
+	StartSession(ctx context.Context) error
+	// ReceiveFile дописывает r в конец файла name: 1С передает большие файлы
+	// частями. Файлы во вложенных каталогах (картинки товаров) не сохраняются.
+	ReceiveFile(ctx context.Context, name string, r io.Reader) error
+	// ImportFile загружает файл name, как Import, и удаляет его
+	ImportFile(ctx context.Context, name string) (*ExchangeReport, error)
+	// QueryOrders записывает как orders.xml заказы, кроме черновиков,
+	// которые 1С еще не получила или которые изменились после получения,
+	// и запоминает в сеансе выгруженные версии заказов
+	QueryOrders(ctx context.Context, w io.Writer) error
+	// ConfirmOrders отмечает заказы последней выгрузки QueryOrders сеанса
+	// полученными: следующий сеанс выгрузит их, только если они изменятся
+	ConfirmOrders(ctx context.Context) error
+}
+
+// ExchangeService implementation
+type exchangeService struct {
+	clients  repository.ClientRepository
+	products repository.ProductRepository
+	orders   repository.OrderRepository
+	refs     repository.ExternalRefRepository
+	uow      repository.UnitOfWork
+	dir      string
+}
+
+func NewExchangeService(clients repository.ClientRepository, products repository.ProductRepository, orders repository.OrderRepository, refs repository.ExternalRefRepository, uow repository.UnitOfWork, cfg ExchangeConfig) ExchangeService {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "orderflow-exchange")
+	}
+	return &exchangeService{clients: clients, products: products, orders: orders, refs: refs, uow: uow, dir: dir}
+}
+
+func (s *exchangeService) Import(ctx context.Context, r io.Reader) (*ExchangeReport, error) {
+	doc, err := commerceml.Read(r)
+	// Ошибки приложения приходят от r, например превышение размера файла
+	var aerr *apperr.Error
+	if errors.As(err, &aerr) {
+		return nil, aerr
+	}
+	if err != nil {
+		return nil, ErrValidation.WithMessage("invalid CommerceML document").WithDetails(apperr.Field("file", err.Error()))
+	}
+
+	counterparties := doc.Counterparties
+	if doc.Classifier != nil {
+		counterparties = append(counterparties, doc.Classifier.Counterparties...)
+	}
+	var products []commerceml.Product
+	if doc.Catalog != nil {
+		products = doc.Catalog.Products
+	}
+
+	report := &ExchangeReport{Skipped: []ExchangeSkip{}}
+	err = s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		imp := &exchangeImport{
+			ctx: ctx, repos: repos, report: report,
+			clients: map[string]int64{}, products: map[string]int64{},
+		}
+		for i, c := range counterparties {
+			if _, err := imp.client(c); err != nil {
+				return elementError(fmt.Sprintf("counterparties[%d]", i), err)
+			}
+		}
+		for i, p := range products {
+			if _, err := imp.product(p); err != nil {
+				return elementError(fmt.Sprintf("products[%d]", i), err)
+			}
+		}
+		for i, o := range doc.Orders {
+			if err := imp.order(o); err != nil {
+				return elementError(fmt.Sprintf("orders[%d]", i), err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// exchangeImport загружает документ в транзакции repos. clients и products
+// запоминают уже загруженные объекты по Ид: документы заказов повторяют
+// одних и тех же контрагентов и товары.
+type exchangeImport struct {
+	ctx      context.Context
+	repos    *repository.Repositories
+	report   *ExchangeReport
+	clients  map[string]int64
+	products map[string]int64
+	// linked — записи, уже сопоставленные с Ид, по сущностям; читаются,
+	// когда запись ищется по реквизитам
+	linked map[string]map[int64]string
+}
+
+// client загружает контрагента и возвращает id клиента. Контрагент без
+// сопоставления связывается с клиентом с тем же ИНН, если тот еще не
+// сопоставлен с другим контрагентом, иначе создается новый клиент.
+func (imp *exchangeImport) client(c commerceml.Counterparty) (int64, error) {
+	externalID, err := exchangeID(c.ID)
+	if err != nil {
+		return 0, err
+	}
+	if id, ok := imp.clients[externalID]; ok {
+		return id, nil
+	}
+
+	client := models.Client{Name: c.Name, INN: c.INN}
+	if strings.TrimSpace(client.Name) == "" {
+		client.Name = c.FullName
+	}
+	if err := validateClient(&client); err != nil {
+		return 0, err
+	}
+
+	ctx, repos := imp.ctx, imp.repos
+	existing, linked, err := imp.findClient(externalID, client.INN)
+	if err != nil {
+		return 0, err
+	}
+	// Контрагенты документов часто передаются без реквизитов: ИНН без
+	// элемента ИНН не стирается
+	if existing != nil && client.INN == "" {
+		client.INN = existing.INN
+	}
+	switch {
+	case existing == nil:
+		if err := repos.Client.Create(ctx, &client); err != nil {
+			return 0, err
+		}
+		if err := audit(ctx, repos, models.AuditEntityClient, client.ID, models.AuditActionCreate, nil, &client); err != nil {
+			return 0, err
+		}
+		imp.report.Clients.Created++
+	case existing.Name == client.Name && existing.INN == client.INN:
+		client = *existing
+		imp.report.Clients.Unchanged++
+	default:
+		client.ID = existing.ID
+		if err := repos.Client.Update(ctx, &client); err != nil {
+			return 0, err
+		}
+		after, err := repos.Client.GetByID(ctx, client.ID)
+		if err != nil {
+			return 0, err
+		}
+		if err := audit(ctx, repos, models.AuditEntityClient, client.ID, models.AuditActionUpdate, existing, after); err != nil {
+			return 0, err
+		}
+		imp.report.Clients.Updated++
+	}
+
+	if !linked {
+		if err := imp.link(models.AuditEntityClient, client.ID, externalID, ""); err != nil {
+			return 0, err
+		}
+	}
+	imp.clients[externalID] = client.ID
+	return client.ID, nil
+}
+
+// findClient возвращает клиента, сопоставленного с Ид, а без него —
+// несопоставленного клиента с ИНН inn; linked сообщает, что клиент найден по Ид
+func (imp *exchangeImport) findClient(externalID, inn string) (client *models.Client, linked bool, err error) {
+	id, found, err := imp.find(models.AuditEntityClient, externalID)
+	if err != nil {
+		return nil, false, err
+	}
+	if found {
+		client, err = imp.repos.Client.GetByID(imp.ctx, id)
+		if err == nil {
+			return client, true, nil
+		}
+		// Сопоставленный клиент удален: контрагент загружается заново
+		if !errors.Is(err, repository.ErrNotFound) {
+			return nil, false, err
+		}
+	}
+
+	if inn == "" {
+		return nil, false, nil
+	}
+	client, err = imp.repos.Client.GetByINN(imp.ctx, inn)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	taken, err := imp.isLinked(models.AuditEntityClient, client.ID)
+	if err != nil || taken {
+		return nil, false, err
+	}
+	return client, false, nil
+}
+
+// product загружает товар и возвращает его id. Товар без сопоставления
+// связывается с несопоставленным товаром с тем же наименованием и единицей
+// или создается.
+func (imp *exchangeImport) product(p commerceml.Product) (int64, error) {
+	externalID, err := exchangeID(p.ID)
+	if err != nil {
+		return 0, err
+	}
+	if id, ok := imp.products[externalID]; ok {
+		return id, nil
+	}
+
+	product := models.Product{Name: p.Name, Unit: p.Unit.Title()}
+	if err := validateProduct(&product); err != nil {
+		return 0, err
+	}
+
+	ctx, repos := imp.ctx, imp.repos
+	existing, linked, err := imp.findProduct(externalID, product.Name, product.Unit)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case existing == nil:
+		if err := repos.Product.Create(ctx, &product); err != nil {
+			return 0, err
+		}
+		if err := audit(ctx, repos, models.AuditEntityProduct, product.ID, models.AuditActionCreate, nil, &product); err != nil {
+			return 0, err
+		}
+		imp.report.Products.Created++
+	case existing.Name == product.Name && existing.Unit == product.Unit:
+		product = *existing
+		imp.report.Products.Unchanged++
+	default:
+		product.ID = existing.ID
+		if err := repos.Product.Update(ctx, &product); err != nil {
+			return 0, err
+		}
+		after, err := repos.Product.GetByID(ctx, product.ID)
+		if err != nil {
+			return 0, err
+		}
+		if err := audit(ctx, repos, models.AuditEntityProduct, product.ID, models.AuditActionUpdate, existing, after); err != nil {
+			return 0, err
+		}
+		imp.report.Products.Updated++
+	}
+
+	if !linked {
+		if err := imp.link(models.AuditEntityProduct, product.ID, externalID, ""); err != nil {
+			return 0, err
+		}
+	}
+	imp.products[externalID] = product.ID
+	return product.ID, nil
+}
+
+// findProduct — findClient для товаров, которые ищутся по наименованию и единице
+func (imp *exchangeImport) findProduct(externalID, name, unit string) (product *models.Product, linked bool, err error) {
+	id, found, err := imp.find(models.AuditEntityProduct, externalID)
+	if err != nil {
+		return nil, false, err
+	}
+	if found {
+		product, err = imp.repos.Product.GetByID(imp.ctx, id)
+		if err == nil {
+			return product, true, nil
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return nil, false, err
+		}
+	}
+
+	product, err = imp.repos.Product.GetByNameUnit(imp.ctx, name, unit)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	taken, err := imp.isLinked(models.AuditEntityProduct, product.ID)
+	if err != nil || taken {
+		return nil, false, err
+	}
+	return product, false, nil
+}
+
+// order загружает документ заказа вместе с его контрагентом и товарами
+func (imp *exchangeImport) order(o commerceml.Order) error {
+	externalID, err := exchangeID(o.ID)
+	if err != nil {
+		return err
+	}
+	if op := strings.TrimSpace(o.Operation); op != "" && op != commerceml.OperationOrder {
+		imp.skip(o, fmt.Sprintf("operation %q is not an order", op))
+		return nil
+	}
+
+	buyer, ok := o.Buyer()
+	if !ok {
+		return ErrValidation.WithDetails(apperr.Field("counterparties", "buyer is required"))
+	}
+	clientID, err := imp.client(buyer)
+	if err != nil {
+		return elementError("buyer", err)
+	}
+
+	items := make([]models.OrderItem, 0, len(o.Items))
+	for i, it := range o.Items {
+		item, err := imp.item(it)
+		if err != nil {
+			return elementError(fmt.Sprintf("items[%d]", i), err)
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return ErrOrderHasNoItems
+	}
+
+	order := models.Order{ClientID: clientID, Number: o.Number}
+	if err := validateOrder(&order, items); err != nil {
+		return err
+	}
+
+	ctx, repos := imp.ctx, imp.repos
+	id, found, err := imp.find(models.AuditEntityOrder, externalID)
+	if err != nil {
+		return err
+	}
+	var current *models.Order
+	if found {
+		current, err = repos.Order.GetForUpdate(ctx, id)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+	}
+
+	switch {
+	case current == nil:
+		if err := repos.Order.Create(ctx, &order, items); err != nil {
+			return orderWriteError(err)
+		}
+		after, err := repos.Order.GetByID(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		if err := audit(ctx, repos, models.AuditEntityOrder, order.ID, models.AuditActionCreate, nil, after); err != nil {
+			return err
+		}
+		imp.report.Orders.Created++
+		return imp.link(models.AuditEntityOrder, order.ID, externalID, strings.TrimSpace(o.Number))
+	case current.Status != models.OrderStatusDraft:
+		imp.skip(o, fmt.Sprintf("order %s is %s and can no longer be changed", current.Number, current.Status))
+		return nil
+	case sameOrder(current, &order, items):
+		imp.report.Orders.Unchanged++
+		return nil
+	}
+
+	// Пустой номер в документе не меняет номер заказа
+	if order.Number == "" {
+		order.Number = current.Number
+	}
+	order.ID = current.ID
+	if err := repos.Order.Update(ctx, &order, items); err != nil {
+		return orderWriteError(err)
+	}
+	after, err := repos.Order.GetByID(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	imp.report.Orders.Updated++
+	return audit(ctx, repos, models.AuditEntityOrder, order.ID, models.AuditActionUpdate, current, after)
+}
+
+// item загружает товар строки документа и возвращает позицию заказа
+func (imp *exchangeImport) item(it commerceml.Item) (models.OrderItem, error) {
+	var item models.OrderItem
+	var err error
+	item.ProductID, err = imp.product(commerceml.Product{ID: it.ID, Name: it.Name, Unit: it.Unit})
+	if err != nil {
+		return item, err
+	}
+
+	var details []apperr.FieldError
+	if item.Quantity, err = money.ParseQuantity(strings.TrimSpace(it.Quantity)); err != nil {
+		details = append(details, apperr.Field("quantity", "must be a decimal number"))
+	}
+	if item.Price, err = money.ParseMoney(strings.TrimSpace(it.Price)); err != nil {
+		details = append(details, apperr.Field("price", "must be a decimal number"))
+	}
+	if len(details) > 0 {
+		return item, ErrValidation.WithDetails(details...)
+	}
+	return item, nil
+}
+
+func (imp *exchangeImport) skip(o commerceml.Order, reason string) {
+	imp.report.Orders.Skipped++
+	imp.report.Skipped = append(imp.report.Skipped, ExchangeSkip{ID: o.ID, Number: o.Number, Reason: reason})
+}
+
+// find возвращает id записи entity, сопоставленной с Ид
+func (imp *exchangeImport) find(entity, externalID string) (int64, bool, error) {
+	id, err := imp.repos.ExternalRef.Find(imp.ctx, entity, ExchangeSystem, externalID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return id, true, nil
+}
+
+// isLinked сообщает, сопоставлена ли запись с каким-либо Ид
+func (imp *exchangeImport) isLinked(entity string, id int64) (bool, error) {
+	if imp.linked == nil {
+		imp.linked = map[string]map[int64]string{}
+	}
+	refs, ok := imp.linked[entity]
+	if !ok {
+		var err error
+		if refs, err = imp.repos.ExternalRef.List(imp.ctx, entity, ExchangeSystem); err != nil {
+			return false, err
+		}
+		imp.linked[entity] = refs
+	}
+	_, taken := refs[id]
+	return taken, nil
+}
+
+// link сопоставляет запись с Ид; code — номер документа в 1С
+func (imp *exchangeImport) link(entity string, id int64, externalID, code string) error {
+	ref := &models.ExternalRef{Entity: entity, EntityID: id, System: ExchangeSystem, ExternalID: externalID, Code: code}
+	if err := imp.repos.ExternalRef.Put(imp.ctx, ref); err != nil {
+		return err
+	}
+	if refs, ok := imp.linked[entity]; ok {
+		refs[id] = externalID
+	}
+	return nil
+}
+
+// exchangeID проверяет Ид объекта документа
+func exchangeID(id string) (string, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return "", ErrValidation.WithDetails(apperr.Field("id", "is required"))
+	}
+	if tooLong(id, models.MaxExternalIDLength) {
+		return "", ErrValidation.WithDetails(apperr.Field("id", fmt.Sprintf("must be at most %d characters", models.MaxExternalIDLength)))
+	}
+	return id, nil
+}
+
+// elementError относит ошибки полей к элементу документа path, например
+// orders[2].items[0].quantity
+func elementError(path string, err error) error {
+	var aerr *apperr.Error
+	if !errors.As(err, &aerr) || aerr.Kind == apperr.KindInternal {
+		return err
+	}
+	e := *aerr
+	e.Details = make([]apperr.FieldError, 0, len(aerr.Details))
+	for _, d := range aerr.Details {
+		field := path
+		if d.Field != "" {
+			field += "." + d.Field
+		}
+		e.Details = append(e.Details, apperr.Field(field, d.Message))
+	}
+	if len(e.Details) == 0 {
+		e.Details = append(e.Details, apperr.Field(path, aerr.Message))
+	}
+	return &e
+}
+
+func (s *exchangeService) ExportCatalog(ctx context.Context, w io.Writer) error {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+	var clientQuery repository.ClientQuery
+	if err := normalizeExport(&clientQuery.ListParams, repository.ClientSortKeys, false); err != nil {
+		return err
+	}
+	var productQuery repository.ProductQuery
+	if err := normalizeExport(&productQuery.ListParams, repository.ProductSortKeys, false); err != nil {
+		return err
+	}
+
+	refs, err := s.loadRefs(ctx, models.AuditEntityClient, models.AuditEntityProduct)
+	if err != nil {
+		return err
+	}
+	err = s.clients.Each(ctx, clientQuery, func(c models.Client) error {
+		refs.need(models.AuditEntityClient, c.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = s.products.Each(ctx, productQuery, func(p models.Product) error {
+		refs.need(models.AuditEntityProduct, p.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.saveRefs(ctx, refs); err != nil {
+		return err
+	}
+
+	cw, err := commerceml.NewWriter(w, time.Now())
+	if err != nil {
+		return err
+	}
+	classifierID := nameGUID(fmt.Sprintf("orderflow/%d/classifier", org))
+	if err := cw.StartClassifier(classifierID, "Классификатор OrderFlow"); err != nil {
+		return err
+	}
+	err = s.clients.Each(ctx, clientQuery, func(c models.Client) error {
+		// Записи, созданные после назначения Ид, попадут в следующую выгрузку
+		id, ok := refs.ids[models.AuditEntityClient][c.ID]
+		if !ok {
+			return nil
+		}
+		return cw.WriteCounterparty(commerceml.Counterparty{ID: id, Name: c.Name, FullName: c.Name, INN: c.INN})
+	})
+	if err != nil {
+		return err
+	}
+	if err := cw.StartCatalog(nameGUID(fmt.Sprintf("orderflow/%d/catalog", org)), classifierID, "Каталог товаров OrderFlow"); err != nil {
+		return err
+	}
+	err = s.products.Each(ctx, productQuery, func(p models.Product) error {
+		id, ok := refs.ids[models.AuditEntityProduct][p.ID]
+		if !ok {
+			return nil
+		}
+		return cw.WriteProduct(commerceml.Product{ID: id, Name: p.Name, Unit: commerceml.Unit{Name: p.Unit}})
+	})
+	if err != nil {
+		return err
+	}
+	return cw.Close()
+}
+
+// exchangeStatusTitles — статусы заказа в реквизите «Статус заказа»
+var exchangeStatusTitles = map[models.OrderStatus]string{
+	models.OrderStatusDraft:        "Черновик",
+	models.OrderStatusConfirmed:    "Подтвержден",
+	models.OrderStatusInFulfilment: "В исполнении",
+	models.OrderStatusShipped:      "Отгружен",
+	models.OrderStatusClosed:       "Закрыт",
+	models.OrderStatusCancelled:    "Отменен",
+}
+
+func (s *exchangeService) ExportOrders(ctx context.Context, q repository.OrderQuery, w io.Writer) error {
+	if err := normalizeExport(&q.ListParams, repository.OrderSortKeys, false); err != nil {
+		return err
+	}
+	if err := validateOrderQuery(q); err != nil {
+		return err
+	}
+	_, err := s.exportOrders(ctx, q, w, nil)
+	return err
+}
+
+// exportOrders записывает заказы отбора q, кроме тех, для которых skip
+// истинно, и возвращает версии записанных заказов по id
+func (s *exchangeService) exportOrders(ctx context.Context, q repository.OrderQuery, w io.Writer, skip func(models.Order) bool) (map[int64]int64, error) {
+	refs, err := s.loadRefs(ctx, models.AuditEntityOrder, models.AuditEntityClient, models.AuditEntityProduct)
+	if err != nil {
+		return nil, err
+	}
+	err = s.orders.Each(ctx, q, func(o models.Order) error {
+		if skip != nil && skip(o) {
+			return nil
+		}
+		refs.need(models.AuditEntityOrder, o.ID)
+		refs.need(models.AuditEntityClient, o.ClientID)
+		for _, item := range o.Items {
+			refs.need(models.AuditEntityProduct, item.ProductID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saveRefs(ctx, refs); err != nil {
+		return nil, err
+	}
+
+	cw, err := commerceml.NewWriter(w, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	written := map[int64]int64{}
+	err = s.orders.Each(ctx, q, func(o models.Order) error {
+		if skip != nil && skip(o) {
+			return nil
+		}
+		doc, ok := exchangeOrder(o, refs)
+		if !ok {
+			return nil
+		}
+		written[o.ID] = o.Version
+		return cw.WriteOrder(doc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return written, cw.Close()
+}
+
+// exchangeOrder строит документ заказа; ok ложно, если заказ или его
+// позиции появились после назначения Ид
+func exchangeOrder(o models.Order, refs *exchangeRefs) (commerceml.Order, bool) {
+	id, ok := refs.ids[models.AuditEntityOrder][o.ID]
+	clientID, clientOK := refs.ids[models.AuditEntityClient][o.ClientID]
+	if !ok || !clientOK {
+		return commerceml.Order{}, false
+	}
+
+	doc := commerceml.Order{
+		ID:        id,
+		Number:    o.Number,
+		Date:      o.Date.Format(commerceml.DateLayout),
+		Time:      o.Date.Format(commerceml.TimeLayout),
+		Operation: commerceml.OperationOrder,
+		Role:      commerceml.RoleSeller,
+		Currency:  "руб",
+		Rate:      "1",
+		Amount:    o.TotalAmount.String(),
+		Counterparties: []commerceml.Counterparty{{
+			ID: clientID, Name: o.Client.Name, FullName: o.Client.Name, INN: o.Client.INN, Role: commerceml.RoleBuyer,
+		}},
+		Items: make([]commerceml.Item, 0, len(o.Items)),
+		Properties: []commerceml.Property{
+			{Name: commerceml.PropertyStatus, Value: exchangeStatusTitles[o.Status]},
+			{Name: commerceml.PropertyCancelled, Value: strconv.FormatBool(o.Status == models.OrderStatusCancelled)},
+		},
+	}
+	for _, item := range o.Items {
+		productID, ok := refs.ids[models.AuditEntityProduct][item.ProductID]
+		if !ok {
+			return commerceml.Order{}, false
+		}
+		doc.Items = append(doc.Items, commerceml.Item{
+			ID:       productID,
+			Name:     item.Product.Name,
+			Unit:     commerceml.Unit{Name: item.Product.Unit},
+			Price:    item.Price.String(),
+			Quantity: item.Quantity.String(),
+			Amount:   item.LineAmount.String(),
+		})
+	}
+	return doc, true
+}
+
+// exchangeRefs — Ид записей для выгрузки по сущностям и id записей.
+// Записям, которые еще не участвовали в обмене, need назначает новые GUID,
+// а saveRefs сохраняет их до записи документа: по ним 1С узнает записи
+// при следующем обмене.
+type exchangeRefs struct {
+	ids     map[string]map[int64]string
+	missing []models.ExternalRef
+}
+
+func (s *exchangeService) loadRefs(ctx context.Context, entities ...string) (*exchangeRefs, error) {
+	refs := &exchangeRefs{ids: map[string]map[int64]string{}}
+	for _, entity := range entities {
+		ids, err := s.refs.List(ctx, entity, ExchangeSystem)
+		if err != nil {
+			return nil, err
+		}
+		refs.ids[entity] = ids
+	}
+	return refs, nil
+}
+
+func (r *exchangeRefs) need(entity string, id int64) {
+	if _, ok := r.ids[entity][id]; ok {
+		return
+	}
+	guid := newGUID()
+	r.ids[entity][id] = guid
+	r.missing = append(r.missing, models.ExternalRef{Entity: entity, EntityID: id, System: ExchangeSystem, ExternalID: guid})
+}
+
+func (s *exchangeService) saveRefs(ctx context.Context, refs *exchangeRefs) error {
+	if len(refs.missing) == 0 {
+		return nil
+	}
+	return s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		for i := range refs.missing {
+			if err := repos.ExternalRef.Put(ctx, &refs.missing[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// newGUID возвращает случайный UUID версии 4 — в таком виде 1С хранит ссылки
+func newGUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return formatGUID(b, 4)
+}
+
+// nameGUID возвращает постоянный UUID версии 5 для имени name
+func nameGUID(name string) string {
+	sum := sha1.Sum([]byte(name))
+	var b [16]byte
+	copy(b[:], sum[:16])
+	return formatGUID(b, 5)
+}
+
+func formatGUID(b [16]byte, version byte) string {
+	b[6] = b[6]&0x0f | version<<4
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func (s *exchangeService) StartSession(ctx context.Context) error {
+	dir, err := s.sessionDir(ctx)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.MkdirAll(dir, 0o700)
+}
+
+func (s *exchangeService) ReceiveFile(ctx context.Context, name string, r io.Reader) error {
+	file, nested, err := s.sessionFile(ctx, name)
+	if err != nil {
+		return err
+	}
+	if nested {
+		_, err := io.Copy(io.Discard, r)
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	limit := MaxExchangeFileSize - info.Size()
+	n, err := io.Copy(f, io.LimitReader(r, limit+1))
+	if err != nil {
+		return err
+	}
+	if n > limit {
+		f.Close()
+		os.Remove(file)
+		return ErrExchangeFileTooLarge
+	}
+	return f.Close()
+}
+
+func (s *exchangeService) ImportFile(ctx context.Context, name string) (*ExchangeReport, error) {
+	file, nested, err := s.sessionFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if nested {
+		return nil, ErrValidation.WithDetails(apperr.Field("filename", "is not an exchange document"))
+	}
+
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrValidation.WithDetails(apperr.Field("filename", "was not received in this session"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file)
+	defer f.Close()
+
+	return s.Import(ctx, f)
+}
+
+// sentOrdersFile — файл сеанса с версиями заказов последней выгрузки
+// QueryOrders. Он во вложенном каталоге, поэтому ReceiveFile его не заменит.
+const sentOrdersFile = "sent/orders.json"
+
+func (s *exchangeService) QueryOrders(ctx context.Context, w io.Writer) error {
+	dir, err := s.sessionDir(ctx)
+	if err != nil {
+		return err
+	}
+	exported, err := s.refs.ListExported(ctx, models.AuditEntityOrder, ExchangeSystem)
+	if err != nil {
+		return err
+	}
+
+	// Черновики еще не переданы в работу и в 1С не выгружаются
+	var q repository.OrderQuery
+	for _, st := range models.OrderStatuses {
+		if st != models.OrderStatusDraft {
+			q.Statuses = append(q.Statuses, st)
+		}
+	}
+	if err := normalizeExport(&q.ListParams, repository.OrderSortKeys, false); err != nil {
+		return err
+	}
+	sent, err := s.exportOrders(ctx, q, w, func(o models.Order) bool {
+		return o.Version <= exported[o.ID]
+	})
+	if err != nil {
+		return err
+	}
+
+	// Выгрузка уже отправлена: если версии не сохранятся, заказы без
+	// подтверждения придут в 1С еще раз, и она узнает их по Ид
+	data, err := json.Marshal(sent)
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(dir, filepath.FromSlash(sentOrdersFile))
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0o600)
+}
+
+func (s *exchangeService) ConfirmOrders(ctx context.Context) error {
+	dir, err := s.sessionDir(ctx)
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(dir, filepath.FromSlash(sentOrdersFile))
+	data, err := os.ReadFile(file)
+	// Заказы в сеансе не выгружались или уже подтверждены
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var sent map[int64]int64
+	if err := json.Unmarshal(data, &sent); err != nil {
+		return err
+	}
+
+	err = s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		for id, version := range sent {
+			if err := repos.ExternalRef.MarkExported(ctx, models.AuditEntityOrder, id, ExchangeSystem, version); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
+
+// sessionDir — каталог файлов сеанса пользователя в организации
+func (s *exchangeService) sessionDir(ctx context.Context) (string, error) {
+	org, err := tenant.ID(ctx)
+	if err != nil {
+		return "", err
+	}
+	user := sha256.Sum256([]byte(ActorFromContext(ctx).Name))
+	return filepath.Join(s.dir, strconv.FormatInt(org, 10), hex.EncodeToString(user[:8])), nil
+}
+
+// sessionFile возвращает путь файла сеанса name. nested сообщает, что файл
+// во вложенном каталоге: такие файлы не хранятся.
+func (s *exchangeService) sessionFile(ctx context.Context, name string) (file string, nested bool, err error) {
+	name = path.Clean("/" + strings.ReplaceAll(name, `\`, "/"))[1:]
+	if name == "" {
+		return "", false, ErrValidation.WithDetails(apperr.Field("filename", "is required"))
+	}
+	if strings.Contains(name, "/") {
+		return "", true, nil
+	}
+	dir, err := s.sessionDir(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	return filepath.Join(dir, name), false, nil
+}
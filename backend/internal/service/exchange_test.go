@@ -0,0 +1,187 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/commerceml"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+// importExchange загружает документ и проверяет отчет
+func importExchange(t *testing.T, s *Services, ctx context.Context, data []byte, clients, products, orders ExchangeCounts) {
+	t.Helper()
+	report, err := s.Exchange.Import(ctx, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if report.Clients != clients || report.Products != products || report.Orders != orders {
+		t.Errorf("import report: clients %+v, products %+v, orders %+v; want %+v, %+v, %+v",
+			report.Clients, report.Products, report.Orders, clients, products, orders)
+	}
+}
+
+// exportedOrders возвращает номера заказов документа orders.xml
+func exportedOrders(t *testing.T, data []byte) []string {
+	t.Helper()
+	doc, err := commerceml.Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("read exported document: %v", err)
+	}
+	numbers := []string{}
+	for _, o := range doc.Orders {
+		numbers = append(numbers, o.Number)
+	}
+	return numbers
+}
+
+func TestExchangeCatalogRoundTrip(t *testing.T) {
+	src, _, ctx := newTestServices(t)
+	for _, c := range []*models.Client{{Name: "ООО Ромашка", INN: "7707083893"}, {Name: `ИП "Иванов" & сын`}} {
+		if err := src.Client.Create(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []*models.Product{{Name: "Гвозди", Unit: "кг"}, {Name: "Шайба <М8>", Unit: "шт"}} {
+		if err := src.Product.Create(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var catalog bytes.Buffer
+	if err := src.Exchange.ExportCatalog(ctx, &catalog); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, _, _ := newTestServices(t)
+	importExchange(t, dst, ctx, catalog.Bytes(), ExchangeCounts{Created: 2}, ExchangeCounts{Created: 2}, ExchangeCounts{})
+	// Повторная загрузка узнает записи по Ид и ничего не меняет
+	importExchange(t, dst, ctx, catalog.Bytes(), ExchangeCounts{Unchanged: 2}, ExchangeCounts{Unchanged: 2}, ExchangeCounts{})
+	// Выгрузка, загруженная обратно, сопоставляется с исходными записями
+	importExchange(t, src, ctx, catalog.Bytes(), ExchangeCounts{Unchanged: 2}, ExchangeCounts{Unchanged: 2}, ExchangeCounts{})
+
+	params := repository.ListParams{Limit: repository.DefaultPageLimit, Sort: "id"}
+	clients, err := dst.Client.List(ctx, repository.ClientQuery{ListParams: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inns := map[string]string{}
+	for _, c := range clients.Items {
+		inns[c.Name] = c.INN
+	}
+	if want := map[string]string{"ООО Ромашка": "7707083893", `ИП "Иванов" & сын`: ""}; !reflect.DeepEqual(inns, want) {
+		t.Errorf("imported clients = %+v", clients.Items)
+	}
+	products, err := dst.Product.List(ctx, repository.ProductQuery{ListParams: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	units := map[string]string{}
+	for _, p := range products.Items {
+		units[p.Name] = p.Unit
+	}
+	if want := map[string]string{"Гвозди": "кг", "Шайба <М8>": "шт"}; !reflect.DeepEqual(units, want) {
+		t.Errorf("imported products = %+v", products.Items)
+	}
+}
+
+func TestExchangeOrdersRoundTrip(t *testing.T) {
+	src, _, ctx := newTestServices(t)
+	order := newTestOrder(t, src, ctx, "2.5", "1250.50")
+	var data bytes.Buffer
+	if err := src.Exchange.ExportOrders(ctx, repository.OrderQuery{}, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, _, _ := newTestServices(t)
+	importExchange(t, dst, ctx, data.Bytes(), ExchangeCounts{Created: 1}, ExchangeCounts{Created: 1}, ExchangeCounts{Created: 1})
+	importExchange(t, dst, ctx, data.Bytes(), ExchangeCounts{Unchanged: 1}, ExchangeCounts{Unchanged: 1}, ExchangeCounts{Unchanged: 1})
+	importExchange(t, src, ctx, data.Bytes(), ExchangeCounts{Unchanged: 1}, ExchangeCounts{Unchanged: 1}, ExchangeCounts{Unchanged: 1})
+
+	page, err := dst.Order.List(ctx, repository.OrderQuery{ListParams: repository.ListParams{Limit: repository.DefaultPageLimit, Sort: "id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 {
+		t.Fatalf("imported %d orders, want 1", len(page.Items))
+	}
+	imported, err := dst.Order.GetByID(ctx, page.Items[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Number != order.Number || imported.Status != models.OrderStatusDraft || imported.TotalAmount != order.TotalAmount ||
+		len(imported.Items) != 1 || imported.Items[0].Quantity.String() != "2.500" || imported.Items[0].Price.String() != "1250.50" {
+		t.Errorf("imported order = %+v, want a draft like %+v", imported, order)
+	}
+
+	// Заказ, который уже не черновик, загрузка не меняет
+	if _, err := dst.Order.Transition(ctx, imported.ID, models.OrderActionConfirm); err != nil {
+		t.Fatal(err)
+	}
+	report, err := dst.Exchange.Import(ctx, bytes.NewReader(data.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Orders.Skipped != 1 || len(report.Skipped) != 1 {
+		t.Errorf("import into a confirmed order: orders %+v, skipped %+v", report.Orders, report.Skipped)
+	}
+}
+
+func TestExchangeQuerySession(t *testing.T) {
+	s, _, ctx := newTestServices(t)
+	newTestOrder(t, s, ctx, "1", "10")
+	order := orderInStatus(t, s, ctx, models.OrderStatusConfirmed)
+
+	query := func(name string, want ...string) {
+		t.Helper()
+		var data bytes.Buffer
+		if err := s.Exchange.QueryOrders(ctx, &data); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got := exportedOrders(t, data.Bytes())
+		if len(want) == 0 {
+			want = []string{}
+		}
+		if len(got) != len(want) || (len(got) > 0 && got[0] != want[0]) {
+			t.Errorf("%s: exported orders %v, want %v", name, got, want)
+		}
+	}
+	start := func() {
+		t.Helper()
+		if err := s.Exchange.StartSession(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	confirm := func() {
+		t.Helper()
+		if err := s.Exchange.ConfirmOrders(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start()
+	confirm() // без выгрузки подтверждать нечего
+	query("first query", order.Number)
+	query("repeated query", order.Number)
+	confirm()
+	confirm()
+
+	start()
+	query("after success")
+
+	// Изменение статуса выгружает заказ снова
+	if _, err := s.Order.Transition(ctx, order.ID, models.OrderActionStartFulfilment); err != nil {
+		t.Fatal(err)
+	}
+	start()
+	query("after change", order.Number)
+
+	// Без подтверждения заказ выгружается и в следующем сеансе
+	start()
+	query("next session without success", order.Number)
+	confirm()
+	start()
+	query("after second success")
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+// ExportService читает записи для выгрузки в файлы. Отбор и сортировка те
+// же, что у списков, но выгружается весь отбор: параметры страницы (limit,
+// offset, cursor) не учитываются. Записи передаются fn по мере чтения из
+// базы, ошибка fn прекращает выгрузку.
+type ExportService interface {
+	Clients(ctx context.Context, q repository.ClientQuery, fn func(models.Client) error) error
+	Products(ctx context.Context, q repository.ProductQuery, fn func(models.Product) error) error
+	// Orders передает заказы вместе с позициями
+	Orders(ctx context.Context, q repository.OrderQuery, fn func(models.Order) error) error
+	// OrdersByClient передает текущие остатки регистра по клиентам
+	OrdersByClient(ctx context.Context, fn func(models.OrdersByClient) error) error
+}
+
+// ExportService implementation
+type exportService struct {
+	clients        repository.ClientRepository
+	products       repository.ProductRepository
+	orders         repository.OrderRepository
+	ordersByClient repository.OrdersByClientRepository
+}
+
+func NewExportService(clients repository.ClientRepository, products repository.ProductRepository, orders repository.OrderRepository, ordersByClient repository.OrdersByClientRepository) ExportService {
+	return &exportService{clients: clients, products: products, orders: orders, ordersByClient: ordersByClient}
+}
+
+func (s *exportService) Clients(ctx context.Context, q repository.ClientQuery, fn func(models.Client) error) error {
+	if err := normalizeExport(&q.ListParams, repository.ClientSortKeys, false); err != nil {
+		return err
+	}
+	return s.clients.Each(ctx, q, fn)
+}
+
+func (s *exportService) Products(ctx context.Context, q repository.ProductQuery, fn func(models.Product) error) error {
+	if err := normalizeExport(&q.ListParams, repository.ProductSortKeys, false); err != nil {
+		return err
+	}
+	return s.products.Each(ctx, q, fn)
+}
+
+func (s *exportService) Orders(ctx context.Context, q repository.OrderQuery, fn func(models.Order) error) error {
+	if err := normalizeExport(&q.ListParams, repository.OrderSortKeys, true); err != nil {
+		return err
+	}
+	if err := validateOrderQuery(q); err != nil {
+		return err
+	}
+	return s.orders.Each(ctx, q, fn)
+}
+
+// OrdersByClient читает остатки целиком: в регистре не больше одной строки
+// на клиента, как и в списке /api/orders-by-client
+func (s *exportService) OrdersByClient(ctx context.Context, fn func(models.OrdersByClient) error) error {
+	balances, err := s.ordersByClient.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, b := range balances {
+		if err := fn(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeExport проверяет сортировку выгрузки, как у списка, без параметров страницы
+func normalizeExport(p *repository.ListParams, keys []string, defaultDesc bool) error {
+	p.Limit, p.Offset, p.Cursor = 0, 0, nil
+	return normalizeList(p, keys, defaultDesc)
+}
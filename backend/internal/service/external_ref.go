@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+// ErrExternalIDTaken: внешний идентификатор уже сопоставлен с другой записью
+var ErrExternalIDTaken = apperr.New(apperr.KindConflict, "external_id_taken", "external id is assigned to another record")
+
+// ExternalRefService ведет идентификаторы клиентов, товаров и заказов во
+// внешних системах (models.ExternalRef), например GUID ссылок и коды 1С при
+// переносе данных. Поиск и загрузка записей по внешнему идентификатору —
+// методы GetByExternalID и Upsert сервисов этих сущностей.
+type ExternalRefService interface {
+	// List возвращает идентификаторы записи entity во всех системах
+	List(ctx context.Context, entity string, id int64) ([]models.ExternalRef, error)
+	// Link сопоставляет существующую запись с внешним идентификатором.
+	// Прежний идентификатор записи в этой системе заменяется; идентификатор
+	// другой записи дает ErrExternalIDTaken.
+	Link(ctx context.Context, ref *models.ExternalRef) error
+	// Unlink удаляет идентификатор записи в системе system
+	Unlink(ctx context.Context, entity string, id int64, system string) error
+}
+
+// ExternalRefService implementation
+type externalRefService struct {
+	clients  repository.ClientRepository
+	products repository.ProductRepository
+	orders   repository.OrderRepository
+	refs     repository.ExternalRefRepository
+	uow      repository.UnitOfWork
+}
+
+func NewExternalRefService(clients repository.ClientRepository, products repository.ProductRepository, orders repository.OrderRepository, refs repository.ExternalRefRepository, uow repository.UnitOfWork) ExternalRefService {
+	return &externalRefService{clients: clients, products: products, orders: orders, refs: refs, uow: uow}
+}
+
+func (s *externalRefService) List(ctx context.Context, entity string, id int64) ([]models.ExternalRef, error) {
+	if err := recordExists(ctx, s.clients, s.products, s.orders, entity, id); err != nil {
+		return nil, err
+	}
+	return s.refs.ListByEntity(ctx, entity, id)
+}
+
+func (s *externalRefService) Link(ctx context.Context, ref *models.ExternalRef) error {
+	if err := validateExternalRef(ref); err != nil {
+		return err
+	}
+	return s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		if err := recordExists(ctx, repos.Client, repos.Product, repos.Order, ref.Entity, ref.EntityID); err != nil {
+			return err
+		}
+
+		// Идентификатор удаленной записи можно сопоставить заново
+		id, err := repos.ExternalRef.Find(ctx, ref.Entity, ref.System, ref.ExternalID)
+		switch {
+		case err == nil && id != ref.EntityID:
+			err = recordExists(ctx, repos.Client, repos.Product, repos.Order, ref.Entity, id)
+			if err == nil {
+				return ErrExternalIDTaken.WithDetails(apperr.Field("external_id", fmt.Sprintf("is assigned to %s %d", ref.Entity, id)))
+			}
+			if !errors.Is(err, repository.ErrNotFound) {
+				return err
+			}
+		case err != nil && !errors.Is(err, repository.ErrNotFound):
+			return err
+		}
+
+		return repos.ExternalRef.Put(ctx, ref)
+	})
+}
+
+func (s *externalRefService) Unlink(ctx context.Context, entity string, id int64, system string) error {
+	return s.refs.Delete(ctx, entity, id, system)
+}
+
+// recordExists проверяет, что запись entity существует
+func recordExists(ctx context.Context, clients repository.ClientRepository, products repository.ProductRepository, orders repository.OrderRepository, entity string, id int64) error {
+	var err error
+	switch entity {
+	case models.AuditEntityClient:
+		_, err = clients.GetByID(ctx, id)
+	case models.AuditEntityProduct:
+		_, err = products.GetByID(ctx, id)
+	case models.AuditEntityOrder:
+		_, err = orders.GetByID(ctx, id)
+	default:
+		err = repository.ErrNotFound
+	}
+	return err
+}
+
+// validateExternalRef проверяет систему, внешний идентификатор и код
+func validateExternalRef(ref *models.ExternalRef) error {
+	var details []apperr.FieldError
+
+	ref.System = strings.TrimSpace(ref.System)
+	if ref.System == "" {
+		details = append(details, apperr.Field("system", "is required"))
+	} else if tooLong(ref.System, models.MaxExternalSystemLength) {
+		details = append(details, apperr.Field("system", fmt.Sprintf("must be at most %d characters", models.MaxExternalSystemLength)))
+	}
+
+	ref.ExternalID = strings.TrimSpace(ref.ExternalID)
+	if ref.ExternalID == "" {
+		details = append(details, apperr.Field("external_id", "is required"))
+	} else if tooLong(ref.ExternalID, models.MaxExternalIDLength) {
+		details = append(details, apperr.Field("external_id", fmt.Sprintf("must be at most %d characters", models.MaxExternalIDLength)))
+	}
+
+	ref.Code = strings.TrimSpace(ref.Code)
+	if tooLong(ref.Code, models.MaxExternalCodeLength) {
+		details = append(details, apperr.Field("code", fmt.Sprintf("must be at most %d characters", models.MaxExternalCodeLength)))
+	}
+
+	if len(details) > 0 {
+		return ErrValidation.WithDetails(details...)
+	}
+	return nil
+}
+
+// Поиск и загрузка по внешнему идентификатору. Upsert повторяет проверки
+// Create и Update и пишет журнал аудита так же, как они, но запись с теми
+// же данными не изменяется: повторная загрузка не увеличивает версию и не
+// добавляет записей в журнал. Версия записи при этом не проверяется.
+
+func (s *clientService) GetByExternalID(ctx context.Context, system, externalID string) (*models.Client, error) {
+	id, err := s.refs.Find(ctx, models.AuditEntityClient, system, externalID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *clientService) Upsert(ctx context.Context, ref *models.ExternalRef, client *models.Client) (bool, error) {
+	ref.Entity = models.AuditEntityClient
+	if err := validateExternalRef(ref); err != nil {
+		return false, err
+	}
+	if err := validateClient(client); err != nil {
+		return false, err
+	}
+
+	var created bool
+	err := s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		update := func(id int64) error {
+			before, err := repos.Client.GetByID(ctx, id)
+			if err != nil {
+				return err
+			}
+			if before.Name == client.Name && before.INN == client.INN {
+				*client = *before
+				return nil
+			}
+			client.ID, client.Version = id, 0
+			if err := repos.Client.Update(ctx, client); err != nil {
+				return err
+			}
+			after, err := repos.Client.GetByID(ctx, id)
+			if err != nil {
+				return err
+			}
+			*client = *after
+			return audit(ctx, repos, models.AuditEntityClient, id, models.AuditActionUpdate, before, after)
+		}
+		create := func() (int64, error) {
+			if err := repos.Client.Create(ctx, client); err != nil {
+				return 0, err
+			}
+			return client.ID, audit(ctx, repos, models.AuditEntityClient, client.ID, models.AuditActionCreate, nil, client)
+		}
+
+		var err error
+		created, err = repository.UpsertByExternalID(ctx, repos.ExternalRef, ref, update, create)
+		return err
+	})
+	return created, err
+}
+
+func (s *productService) GetByExternalID(ctx context.Context, system, externalID string) (*models.Product, error) {
+	id, err := s.refs.Find(ctx, models.AuditEntityProduct, system, externalID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *productService) Upsert(ctx context.Context, ref *models.ExternalRef, product *models.Product) (bool, error) {
+	ref.Entity = models.AuditEntityProduct
+	if err := validateExternalRef(ref); err != nil {
+		return false, err
+	}
+	if err := validateProduct(product); err != nil {
+		return false, err
+	}
+
+	var created bool
+	err := s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		update := func(id int64) error {
+			before, err := repos.Product.GetByID(ctx, id)
+			if err != nil {
+				return err
+			}
+			if before.Name == product.Name && before.Unit == product.Unit {
+				*product = *before
+				return nil
+			}
+			product.ID, product.Version = id, 0
+			if err := repos.Product.Update(ctx, product); err != nil {
+				return err
+			}
+			after, err := repos.Product.GetByID(ctx, id)
+			if err != nil {
+				return err
+			}
+			*product = *after
+			return audit(ctx, repos, models.AuditEntityProduct, id, models.AuditActionUpdate, before, after)
+		}
+		create := func() (int64, error) {
+			if err := repos.Product.Create(ctx, product); err != nil {
+				return 0, err
+			}
+			return product.ID, audit(ctx, repos, models.AuditEntityProduct, product.ID, models.AuditActionCreate, nil, product)
+		}
+
+		var err error
+		created, err = repository.UpsertByExternalID(ctx, repos.ExternalRef, ref, update, create)
+		return err
+	})
+	return created, err
+}
+
+func (s *orderService) GetByExternalID(ctx context.Context, system, externalID string) (*models.Order, error) {
+	id, err := s.refs.Find(ctx, models.AuditEntityOrder, system, externalID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *orderService) Upsert(ctx context.Context, ref *models.ExternalRef, order *models.Order, items []models.OrderItem) (bool, error) {
+	ref.Entity = models.AuditEntityOrder
+	if err := validateExternalRef(ref); err != nil {
+		return false, err
+	}
+	if len(items) == 0 {
+		return false, ErrOrderHasNoItems
+	}
+	if err := validateOrder(order, items); err != nil {
+		return false, err
+	}
+
+	var created bool
+	err := s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		update := func(id int64) error {
+			current, err := repos.Order.GetForUpdate(ctx, id)
+			if err != nil {
+				return err
+			}
+			if sameOrder(current, order, items) {
+				*order = *current
+				return nil
+			}
+			if current.Status != models.OrderStatusDraft {
+				return ErrOrderNotEditable
+			}
+
+			// Пустой номер означает, что номер заказа не меняется
+			if order.Number == "" {
+				order.Number = current.Number
+			}
+			order.ID, order.Version = id, 0
+			if err := repos.Order.Update(ctx, order, items); err != nil {
+				return orderWriteError(err)
+			}
+			after, err := repos.Order.GetByID(ctx, id)
+			if err != nil {
+				return err
+			}
+			*order = *after
+			return audit(ctx, repos, models.AuditEntityOrder, id, models.AuditActionUpdate, current, after)
+		}
+		create := func() (int64, error) {
+			if err := repos.Order.Create(ctx, order, items); err != nil {
+				return 0, orderWriteError(err)
+			}
+			after, err := repos.Order.GetByID(ctx, order.ID)
+			if err != nil {
+				return 0, err
+			}
+			*order = *after
+			return order.ID, audit(ctx, repos, models.AuditEntityOrder, order.ID, models.AuditActionCreate, nil, after)
+		}
+
+		var err error
+		created, err = repository.UpsertByExternalID(ctx, repos.ExternalRef, ref, update, create)
+		return err
+	})
+	return created, err
+}
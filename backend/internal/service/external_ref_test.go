@@ -0,0 +1,118 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+)
+
+// TestClientUpsertIdempotent проверяет, что повторная загрузка тех же данных
+// не меняет версию клиента и не пишет журнал аудита
+func TestClientUpsertIdempotent(t *testing.T) {
+	s, repos, ctx := newTestServices(t)
+
+	upsert := func(name, code string) (*models.Client, bool) {
+		t.Helper()
+		ref := &models.ExternalRef{System: "1c", ExternalID: "guid-1", Code: code}
+		client := &models.Client{Name: name, INN: "7707083893"}
+		created, err := s.Client.Upsert(ctx, ref, client)
+		if err != nil {
+			t.Fatalf("upsert %s: %v", name, err)
+		}
+		return client, created
+	}
+	auditEntries := func(id int64) int {
+		t.Helper()
+		entries, err := repos.Audit.List(ctx, models.AuditEntityClient, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(entries)
+	}
+
+	first, created := upsert("ООО Ромашка", "000001")
+	if !created || first.Version != 1 {
+		t.Fatalf("first upsert: created = %t, version %d", created, first.Version)
+	}
+
+	tests := []struct {
+		name, code string
+		version    int64
+		audit      int
+	}{
+		{"ООО Ромашка", "000001", 1, 1},
+		{"ООО Ромашка", "000001", 1, 1},
+		{"ООО Ромашка", "000002", 1, 1},
+		{"АО Ромашка", "000002", 2, 2},
+		{"АО Ромашка", "000002", 2, 2},
+	}
+	for i, tt := range tests {
+		client, created := upsert(tt.name, tt.code)
+		if created || client.ID != first.ID {
+			t.Errorf("upsert %d: created = %t, id %d, want client %d", i+1, created, client.ID, first.ID)
+		}
+		if client.Version != tt.version || client.Name != tt.name {
+			t.Errorf("upsert %d: client = %+v, want version %d", i+1, client, tt.version)
+		}
+		if n := auditEntries(first.ID); n != tt.audit {
+			t.Errorf("upsert %d: %d audit entries, want %d", i+1, n, tt.audit)
+		}
+		refs, err := s.ExternalRef.List(ctx, models.AuditEntityClient, first.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(refs) != 1 || refs[0].Code != tt.code {
+			t.Errorf("upsert %d: refs = %+v, want one with code %s", i+1, refs, tt.code)
+		}
+	}
+
+	if got, err := s.Client.GetByExternalID(ctx, "1c", "guid-1"); err != nil || got.ID != first.ID {
+		t.Errorf("GetByExternalID = %+v, %v; want client %d", got, err, first.ID)
+	}
+}
+
+// TestExternalIDTaken проверяет, что внешний идентификатор одной записи
+// нельзя сопоставить с другой, а загрузка по нему меняет исходную запись
+func TestExternalIDTaken(t *testing.T) {
+	s, _, ctx := newTestServices(t)
+
+	owner := &models.Client{Name: "ООО Ромашка"}
+	if _, err := s.Client.Upsert(ctx, &models.ExternalRef{System: "1c", ExternalID: "guid-1"}, owner); err != nil {
+		t.Fatal(err)
+	}
+	other := &models.Client{Name: "ООО Лютик"}
+	if err := s.Client.Create(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	product := &models.Product{Name: "Гвозди", Unit: "кг"}
+	if err := s.Product.Create(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+
+	ref := &models.ExternalRef{Entity: models.AuditEntityClient, EntityID: other.ID, System: "1c", ExternalID: "guid-1"}
+	if err := s.ExternalRef.Link(ctx, ref); !errors.Is(err, ErrExternalIDTaken) {
+		t.Errorf("link another client's external id: err = %v, want ErrExternalIDTaken", err)
+	}
+
+	// Тот же идентификатор в другой системе или у товара свободен
+	for _, ref := range []*models.ExternalRef{
+		{Entity: models.AuditEntityClient, EntityID: other.ID, System: "erp", ExternalID: "guid-1"},
+		{Entity: models.AuditEntityProduct, EntityID: product.ID, System: "1c", ExternalID: "guid-1"},
+	} {
+		if err := s.ExternalRef.Link(ctx, ref); err != nil {
+			t.Errorf("link %s in %s: %v", ref.Entity, ref.System, err)
+		}
+	}
+
+	updated := &models.Client{Name: "ООО Ромашка и Ко"}
+	if _, err := s.Client.Upsert(ctx, &models.ExternalRef{System: "1c", ExternalID: "guid-1"}, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.ID != owner.ID {
+		t.Errorf("upsert changed client %d, want %d", updated.ID, owner.ID)
+	}
+	if got, _ := s.Client.GetByID(ctx, other.ID); got.Name != "ООО Лютик" {
+		t.Errorf("other client renamed to %q", got.Name)
+	}
+}
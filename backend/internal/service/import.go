@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+// MaxImportRows — наибольшее число строк данных в одном файле импорта
+const MaxImportRows = 10000
+
+// Действия со строкой импорта
+const (
+	ImportCreate    = "create"
+	ImportUpdate    = "update"
+	ImportUnchanged = "unchanged"
+	ImportError     = "error"
+)
+
+// ImportOptions — параметры загрузки
+type ImportOptions struct {
+	// Columns сопоставляет поля сущности (name, inn, unit) колонкам файла:
+	// заголовку колонки или ее номеру с 1. Поля без сопоставления ищутся по
+	// заголовкам на русском и английском языках.
+	Columns map[string]string
+	// DryRun проверяет файл и сообщает, что было бы сделано, ничего не сохраняя
+	DryRun bool
+	// Atomic загружает файл в одной транзакции: ошибка в любой строке
+	// отменяет загрузку целиком. Без него загружаются все строки без ошибок,
+	// а строки с ошибками хранилища отмечаются в отчете.
+	Atomic bool
+}
+
+// ImportReport — результат загрузки с отчетом по каждой строке
+type ImportReport struct {
+	Entity string `json:"entity"`
+	DryRun bool   `json:"dry_run"`
+	Atomic bool   `json:"atomic"`
+	// Applied: изменения сохранены. В режиме Atomic при ошибках и при DryRun — нет.
+	Applied bool `json:"applied"`
+	// Columns — колонки файла, из которых взяты поля
+	Columns   map[string]string `json:"columns"`
+	Total     int               `json:"total"`
+	Created   int               `json:"created"`
+	Updated   int               `json:"updated"`
+	Unchanged int               `json:"unchanged"`
+	Failed    int               `json:"failed"`
+	Rows      []ImportRow       `json:"rows"`
+}
+
+// ImportRow — результат загрузки строки файла
+type ImportRow struct {
+	// Row — номер строки файла; заголовок — строка 1
+	Row    int                 `json:"row"`
+	Action string              `json:"action"`
+	ID     int64               `json:"id,omitempty"`
+	Errors []apperr.FieldError `json:"errors,omitempty"`
+}
+
+func (r *ImportReport) add(row ImportRow) {
+	r.Rows = append(r.Rows, row)
+	switch row.Action {
+	case ImportCreate:
+		r.Created++
+	case ImportUpdate:
+		r.Updated++
+	case ImportUnchanged:
+		r.Unchanged++
+	case ImportError:
+		r.Failed++
+	}
+}
+
+// ImportService загружает справочники из табличных файлов. rows — строки
+// файла, первая из них — заголовок.
+type ImportService interface {
+	// ImportClients создает клиентов и обновляет наименование клиентов с тем же ИНН
+	ImportClients(ctx context.Context, rows [][]string, opts ImportOptions) (*ImportReport, error)
+	// ImportProducts создает товары, которых еще нет с тем же наименованием и единицей
+	ImportProducts(ctx context.Context, rows [][]string, opts ImportOptions) (*ImportReport, error)
+}
+
+// ImportService implementation
+type importService struct {
+	uow repository.UnitOfWork
+}
+
+func NewImportService(uow repository.UnitOfWork) ImportService {
+	return &importService{uow: uow}
+}
+
+// importField — поле сущности, загружаемое из колонки файла
+type importField struct {
+	name     string
+	required bool
+	// headers — заголовки колонки, по которым поле находится без сопоставления
+	headers []string
+}
+
+var (
+	clientImportFields = []importField{
+		{name: "name", required: true, headers: []string{"name", "наименование", "название", "клиент", "контрагент"}},
+		{name: "inn", headers: []string{"inn", "инн"}},
+	}
+	productImportFields = []importField{
+		{name: "name", required: true, headers: []string{"name", "наименование", "название", "товар", "номенклатура"}},
+		{name: "unit", required: true, headers: []string{"unit", "единица", "ед. изм.", "ед.изм.", "единица измерения"}},
+	}
+)
+
+// importRecord — строка файла, разобранная по полям
+type importRecord struct {
+	row    int
+	values map[string]string
+}
+
+// importApply загружает одну запись и возвращает действие и id сущности
+type importApply func(ctx context.Context, repos *repository.Repositories, rec importRecord) (string, int64, error)
+
+// errImportRollback отменяет транзакцию пробной или неудавшейся загрузки
+var errImportRollback = errors.New("import rolled back")
+
+func (s *importService) ImportClients(ctx context.Context, rows [][]string, opts ImportOptions) (*ImportReport, error) {
+	return s.run(ctx, "client", clientImportFields, rows, opts, importClient)
+}
+
+func (s *importService) ImportProducts(ctx context.Context, rows [][]string, opts ImportOptions) (*ImportReport, error) {
+	return s.run(ctx, "product", productImportFields, rows, opts, importProduct)
+}
+
+func (s *importService) run(ctx context.Context, entity string, fields []importField, rows [][]string, opts ImportOptions, apply importApply) (*ImportReport, error) {
+	if len(rows) == 0 {
+		return nil, ErrValidation.WithDetails(apperr.Field("file", "is empty"))
+	}
+	columns, err := resolveColumns(fields, rows[0], opts.Columns)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []importRecord
+	for i, row := range rows[1:] {
+		rec := importRecord{row: i + 2, values: map[string]string{}}
+		blank := true
+		for name, col := range columns {
+			if col < len(row) {
+				rec.values[name] = strings.TrimSpace(row[col])
+				blank = blank && rec.values[name] == ""
+			}
+		}
+		// Пустые строки, например в конце листа, не загружаются
+		if !blank {
+			records = append(records, rec)
+		}
+	}
+	if len(records) > MaxImportRows {
+		return nil, ErrValidation.WithDetails(apperr.Field("file", fmt.Sprintf("must have at most %d rows", MaxImportRows)))
+	}
+
+	report := &ImportReport{
+		Entity: entity, DryRun: opts.DryRun, Atomic: opts.Atomic,
+		Columns: map[string]string{}, Total: len(records), Rows: []ImportRow{},
+	}
+	for name, col := range columns {
+		report.Columns[name] = columnTitle(rows[0], col)
+	}
+
+	// Построчная загрузка: каждая строка в своей транзакции. Строки до
+	// ошибки хранилища уже сохранены, поэтому такая ошибка попадает в отчет
+	// по строке, а загрузка продолжается.
+	if !opts.Atomic && !opts.DryRun {
+		for _, rec := range records {
+			var row ImportRow
+			err := s.uow.Do(ctx, func(repos *repository.Repositories) error {
+				var err error
+				row, err = applyRecord(ctx, repos, rec, apply)
+				return err
+			})
+			if err != nil {
+				row = ImportRow{Row: rec.row, Action: ImportError,
+					Errors: []apperr.FieldError{apperr.Field("", apperr.From(err).Message)}}
+			}
+			report.add(row)
+		}
+		report.Applied = true
+		return report, nil
+	}
+
+	err = s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		for _, rec := range records {
+			row, err := applyRecord(ctx, repos, rec, apply)
+			if err != nil {
+				return err
+			}
+			report.add(row)
+		}
+		if opts.DryRun || report.Failed > 0 {
+			return errImportRollback
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportRollback) {
+		return nil, err
+	}
+	report.Applied = err == nil
+	if !report.Applied {
+		// id созданных в отмененной транзакции записей не существуют
+		for i := range report.Rows {
+			if report.Rows[i].Action == ImportCreate {
+				report.Rows[i].ID = 0
+			}
+		}
+	}
+	return report, nil
+}
+
+// applyRecord загружает запись. Ошибки проверки данных попадают в отчет по
+// строке, остальные ошибки возвращаются вызывающему.
+func applyRecord(ctx context.Context, repos *repository.Repositories, rec importRecord, apply importApply) (ImportRow, error) {
+	row := ImportRow{Row: rec.row}
+	action, id, err := apply(ctx, repos, rec)
+	var aerr *apperr.Error
+	if err != nil && errors.As(err, &aerr) && aerr.Kind == apperr.KindValidation {
+		row.Action, row.Errors = ImportError, aerr.Details
+		if len(row.Errors) == 0 {
+			row.Errors = []apperr.FieldError{apperr.Field("", aerr.Message)}
+		}
+		return row, nil
+	}
+	if err != nil {
+		return row, fmt.Errorf("row %d: %w", rec.row, err)
+	}
+	row.Action, row.ID = action, id
+	return row, nil
+}
+
+func importClient(ctx context.Context, repos *repository.Repositories, rec importRecord) (string, int64, error) {
+	client := models.Client{Name: rec.values["name"], INN: rec.values["inn"]}
+	if err := validateClient(&client); err != nil {
+		return "", 0, err
+	}
+
+	// Клиенты без ИНН не сопоставляются с существующими
+	if client.INN != "" {
+		existing, err := repos.Client.GetByINN(ctx, client.INN)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return "", 0, err
+		}
+		if existing != nil {
+			if existing.Name == client.Name {
+				return ImportUnchanged, existing.ID, nil
+			}
+			client.ID, client.Version = existing.ID, existing.Version
+			if err := repos.Client.Update(ctx, &client); err != nil {
+				return "", 0, err
+			}
+			after, err := repos.Client.GetByID(ctx, client.ID)
+			if err != nil {
+				return "", 0, err
+			}
+			return ImportUpdate, client.ID, audit(ctx, repos, models.AuditEntityClient, client.ID, models.AuditActionUpdate, existing, after)
+		}
+	}
+
+	if err := repos.Client.Create(ctx, &client); err != nil {
+		return "", 0, err
+	}
+	return ImportCreate, client.ID, audit(ctx, repos, models.AuditEntityClient, client.ID, models.AuditActionCreate, nil, &client)
+}
+
+func importProduct(ctx context.Context, repos *repository.Repositories, rec importRecord) (string, int64, error) {
+	product := models.Product{Name: rec.values["name"], Unit: rec.values["unit"]}
+	if err := validateProduct(&product); err != nil {
+		return "", 0, err
+	}
+
+	existing, err := repos.Product.GetByNameUnit(ctx, product.Name, product.Unit)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return "", 0, err
+	}
+	if existing != nil {
+		return ImportUnchanged, existing.ID, nil
+	}
+
+	if err := repos.Product.Create(ctx, &product); err != nil {
+		return "", 0, err
+	}
+	return ImportCreate, product.ID, audit(ctx, repos, models.AuditEntityProduct, product.ID, models.AuditActionCreate, nil, &product)
+}
+
+// resolveColumns находит колонку файла для каждого поля: по сопоставлению
+// mapping, а без него — по известным заголовкам. Возвращает номера колонок с нуля.
+func resolveColumns(fields []importField, header []string, mapping map[string]string) (map[string]int, error) {
+	var details []apperr.FieldError
+	known := map[string]bool{}
+	for _, f := range fields {
+		known[f.name] = true
+	}
+	for name := range mapping {
+		if !known[name] {
+			details = append(details, apperr.Field("columns."+name, "is not a field of the entity"))
+		}
+	}
+
+	columns := map[string]int{}
+	for _, f := range fields {
+		if ref, ok := mapping[f.name]; ok {
+			col, found := findColumn(header, ref)
+			if !found {
+				details = append(details, apperr.Field("columns."+f.name, fmt.Sprintf("column %q not found", ref)))
+				continue
+			}
+			columns[f.name] = col
+			continue
+		}
+		for _, h := range f.headers {
+			if col, found := findColumn(header, h); found {
+				columns[f.name] = col
+				break
+			}
+		}
+		if _, ok := columns[f.name]; !ok && f.required {
+			details = append(details, apperr.Field("columns."+f.name, "column not found; map it explicitly"))
+		}
+	}
+
+	if len(details) > 0 {
+		return nil, ErrValidation.WithMessage("cannot map file columns").WithDetails(details...)
+	}
+	return columns, nil
+}
+
+// findColumn ищет колонку по заголовку без учета регистра или по номеру с 1
+func findColumn(header []string, ref string) (int, bool) {
+	ref = strings.TrimSpace(ref)
+	for i, h := range header {
+		if strings.EqualFold(strings.TrimSpace(h), ref) {
+			return i, true
+		}
+	}
+	if n, err := strconv.Atoi(ref); err == nil && n >= 1 {
+		return n - 1, true
+	}
+	return 0, false
+}
+
+func columnTitle(header []string, col int) string {
+	if col < len(header) && strings.TrimSpace(header[col]) != "" {
+		return strings.TrimSpace(header[col])
+	}
+	return strconv.Itoa(col + 1)
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+// importRows — файл клиентов: две верные строки и строка с неверным ИНН
+var importRows = [][]string{
+	{"Наименование", "ИНН"},
+	{"ООО Ромашка", "7707083893"},
+	{"ООО Лютик", "5001007329"},
+	{"ООО Василек", "1234567890"},
+}
+
+// storedClients возвращает ИНН сохраненных клиентов по наименованию
+func storedClients(t *testing.T, s *Services, ctx context.Context) map[string]string {
+	t.Helper()
+	page, err := s.Client.List(ctx, repository.ClientQuery{ListParams: repository.ListParams{Limit: repository.DefaultPageLimit, Sort: "id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := map[string]string{}
+	for _, c := range page.Items {
+		clients[c.Name] = c.INN
+	}
+	return clients
+}
+
+// actions возвращает действия по строкам отчета
+func actions(report *ImportReport) []string {
+	var got []string
+	for _, row := range report.Rows {
+		got = append(got, row.Action)
+	}
+	return got
+}
+
+func TestImportDryRun(t *testing.T) {
+	s, _, ctx := newTestServices(t)
+
+	report, err := s.Import.ImportClients(ctx, importRows[:3], ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Applied || report.Created != 2 || report.Failed != 0 {
+		t.Errorf("report = %+v, want 2 rows to create and nothing applied", report)
+	}
+	for _, row := range report.Rows {
+		if row.ID != 0 {
+			t.Errorf("row %d has id %d of a rolled back record", row.Row, row.ID)
+		}
+	}
+	if clients := storedClients(t, s, ctx); len(clients) != 0 {
+		t.Errorf("dry run stored clients %v", clients)
+	}
+}
+
+func TestImportAtomic(t *testing.T) {
+	s, _, ctx := newTestServices(t)
+
+	report, err := s.Import.ImportClients(ctx, importRows, ImportOptions{Atomic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Applied || report.Failed != 1 {
+		t.Errorf("report = %+v, want one failed row and nothing applied", report)
+	}
+	if want := []string{ImportCreate, ImportCreate, ImportError}; !reflect.DeepEqual(actions(report), want) {
+		t.Errorf("actions = %v, want %v", actions(report), want)
+	}
+	if errs := report.Rows[2].Errors; len(errs) != 1 || errs[0].Field != "inn" {
+		t.Errorf("row 4 errors = %+v, want an inn error", errs)
+	}
+	if clients := storedClients(t, s, ctx); len(clients) != 0 {
+		t.Errorf("atomic import with an invalid row stored clients %v", clients)
+	}
+}
+
+func TestImportPartial(t *testing.T) {
+	s, _, ctx := newTestServices(t)
+
+	report, err := s.Import.ImportClients(ctx, importRows, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Applied || report.Created != 2 || report.Failed != 1 {
+		t.Errorf("report = %+v, want 2 created, 1 failed and applied", report)
+	}
+	want := map[string]string{"ООО Ромашка": "7707083893", "ООО Лютик": "5001007329"}
+	if clients := storedClients(t, s, ctx); !reflect.DeepEqual(clients, want) {
+		t.Errorf("stored clients = %v, want %v", clients, want)
+	}
+}
+
+// TestImportPartialStorageError проверяет, что ошибка хранилища в строке
+// не теряет отчет о строках, сохраненных до нее
+func TestImportPartialStorageError(t *testing.T) {
+	s, repos, ctx := newTestServices(t)
+	imports := &importService{uow: repos.UnitOfWork}
+
+	failing := func(ctx context.Context, repos *repository.Repositories, rec importRecord) (string, int64, error) {
+		if rec.row == 3 {
+			return "", 0, errors.New("connection reset by peer")
+		}
+		return importClient(ctx, repos, rec)
+	}
+	rows := [][]string{importRows[0], importRows[1], importRows[2], {"ООО Одуванчик", ""}}
+	report, err := imports.run(ctx, "client", clientImportFields, rows, ImportOptions{}, failing)
+	if err != nil {
+		t.Fatalf("storage error in a row: %v, want a partial report", err)
+	}
+	if !report.Applied || report.Created != 2 || report.Failed != 1 {
+		t.Errorf("report = %+v, want 2 created, 1 failed and applied", report)
+	}
+	if want := []string{ImportCreate, ImportError, ImportCreate}; !reflect.DeepEqual(actions(report), want) {
+		t.Errorf("actions = %v, want %v", actions(report), want)
+	}
+	if errs := report.Rows[1].Errors; len(errs) != 1 || errs[0].Message != "internal server error" {
+		t.Errorf("row 3 errors = %+v, want the error without internal details", errs)
+	}
+	want := map[string]string{"ООО Ромашка": "7707083893", "ООО Одуванчик": ""}
+	if clients := storedClients(t, s, ctx); !reflect.DeepEqual(clients, want) {
+		t.Errorf("stored clients = %v, want %v", clients, want)
+	}
+}
+
+// TestImportUpsert проверяет сопоставление клиентов по ИНН и товаров по
+// наименованию и единице
+func TestImportUpsert(t *testing.T) {
+	s, _, ctx := newTestServices(t)
+
+	first, err := s.Import.ImportClients(ctx, importRows[:2], ImportOptions{Atomic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := first.Rows[0].ID
+
+	tests := []struct {
+		name   string
+		rows   [][]string
+		action string
+	}{
+		{"same name", [][]string{{"name", "inn"}, {"ООО Ромашка", "7707083893"}}, ImportUnchanged},
+		{"new name", [][]string{{"name", "inn"}, {"АО Ромашка", "7707083893"}}, ImportUpdate},
+		{"without inn", [][]string{{"name", "inn"}, {"АО Ромашка", ""}}, ImportCreate},
+	}
+	for _, tt := range tests {
+		report, err := s.Import.ImportClients(ctx, tt.rows, ImportOptions{Atomic: true})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		row := report.Rows[0]
+		if row.Action != tt.action {
+			t.Errorf("%s: action = %s, want %s", tt.name, row.Action, tt.action)
+		}
+		if tt.action != ImportCreate && row.ID != id {
+			t.Errorf("%s: id = %d, want the existing client %d", tt.name, row.ID, id)
+		}
+	}
+	if client, err := s.Client.GetByID(ctx, id); err != nil || client.Name != "АО Ромашка" {
+		t.Errorf("client = %+v, %v; want the name updated by INN", client, err)
+	}
+
+	products := [][]string{{"Наименование", "Ед. изм."}, {"Гвозди", "кг"}, {"Гвозди", "шт"}}
+	for i, want := range [][]string{{ImportCreate, ImportCreate}, {ImportUnchanged, ImportUnchanged}} {
+		report, err := s.Import.ImportProducts(ctx, products, ImportOptions{Atomic: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actions(report), want) {
+			t.Errorf("product import %d: actions = %v, want %v", i+1, actions(report), want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+// Transition — переход заказа между статусами: из каких статусов он возможен,
+// в какой статус переводит, кому разрешен и что делает помимо смены статуса.
+// Пользователю переход разрешают роли Roles, ключу API — область Scope.
+type Transition struct {
+	Name  string               `json:"name"`
+	From  []models.OrderStatus `json:"from"`
+	To    models.OrderStatus   `json:"to"`
+	Roles []Role               `json:"roles"`
+	Scope Scope                `json:"scope"`
+
+	// guard проверяет, можно ли перевести заказ; nil — без проверок
+	guard func(order *models.Order) error
+	// effect выполняет побочные действия в транзакции перехода, получая заказ
+	// в статусе до перехода; nil — без побочных действий
+	effect func(ctx context.Context, repos *repository.Repositories, order *models.Order) error
+}
+
+// allows сообщает, возможен ли переход из статуса status
+func (t *Transition) allows(status models.OrderStatus) bool {
+	return slices.Contains(t.From, status)
+}
+
+// Workflow — конечный автомат статусов заказа
+type Workflow struct {
+	transitions []Transition
+}
+
+// NewOrderWorkflow описывает жизненный цикл заказа:
+//
+//	draft → confirmed → in_fulfilment → shipped → closed
+//
+// Подтвержденный заказ можно вернуть в черновик (только руководителю продаж),
+// а до отгрузки — отменить.
+// Подтверждение проводит заказ по регистру «ЗаказыПоКонтрагентам», возврат в
+// черновик удаляет его движения, как отмена проведения в 1С, а отмена
+// проведенного заказа сторнирует приход расходом на дату отмены.
+func NewOrderWorkflow() *Workflow {
+	return &Workflow{transitions: []Transition{
+		{
+			Name:   models.OrderActionConfirm,
+			From:   []models.OrderStatus{models.OrderStatusDraft},
+			To:     models.OrderStatusConfirmed,
+			Roles:  []Role{RoleManager, RoleHeadOfSales},
+			Scope:  ScopeConfirmOrders,
+			guard:  requireItems,
+			effect: postOrder,
+		},
+		{
+			Name:   models.OrderActionUnconfirm,
+			From:   []models.OrderStatus{models.OrderStatusConfirmed},
+			To:     models.OrderStatusDraft,
+			Roles:  []Role{RoleHeadOfSales},
+			Scope:  ScopeConfirmOrders,
+			effect: unpostOrder,
+		},
+		{
+			Name:  models.OrderActionStartFulfilment,
+			From:  []models.OrderStatus{models.OrderStatusConfirmed},
+			To:    models.OrderStatusInFulfilment,
+			Roles: []Role{RoleWarehouse},
+			Scope: ScopeFulfilOrders,
+		},
+		{
+			Name:  models.OrderActionShip,
+			From:  []models.OrderStatus{models.OrderStatusInFulfilment},
+			To:    models.OrderStatusShipped,
+			Roles: []Role{RoleWarehouse},
+			Scope: ScopeFulfilOrders,
+		},
+		{
+			Name:  models.OrderActionClose,
+			From:  []models.OrderStatus{models.OrderStatusShipped},
+			To:    models.OrderStatusClosed,
+			Roles: []Role{RoleManager, RoleHeadOfSales},
+			Scope: ScopeWriteOrders,
+		},
+		{
+			Name: models.OrderActionCancel,
+			From: []models.OrderStatus{
+				models.OrderStatusDraft, models.OrderStatusConfirmed, models.OrderStatusInFulfilment,
+			},
+			To:     models.OrderStatusCancelled,
+			Roles:  []Role{RoleManager, RoleHeadOfSales},
+			Scope:  ScopeConfirmOrders,
+			effect: reverseOrder,
+		},
+	}}
+}
+
+// Find возвращает переход по имени
+func (w *Workflow) Find(name string) (*Transition, bool) {
+	for i := range w.transitions {
+		if w.transitions[i].Name == name {
+			return &w.transitions[i], true
+		}
+	}
+	return nil, false
+}
+
+// Names возвращает имена всех переходов
+func (w *Workflow) Names() []string {
+	names := make([]string, len(w.transitions))
+	for i, t := range w.transitions {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// Available возвращает переходы, которые исполнитель может выполнить
+// из статуса status
+func (w *Workflow) Available(status models.OrderStatus, actor Actor) []Transition {
+	available := []Transition{}
+	for _, t := range w.transitions {
+		if t.allows(status) && actor.Can(t.Scope, t.Roles...) {
+			available = append(available, t)
+		}
+	}
+	return available
+}
+
+func requireItems(order *models.Order) error {
+	if len(order.Items) == 0 {
+		return ErrOrderHasNoItems
+	}
+	return nil
+}
+
+// postOrder формирует движения заказа по регистру «ЗаказыПоКонтрагентам»
+func postOrder(ctx context.Context, repos *repository.Repositories, order *models.Order) error {
+	return repos.OrdersByClient.WriteMovements(ctx, order.ID, orderMovements(order))
+}
+
+// unpostOrder удаляет движения заказа по регистру
+func unpostOrder(ctx context.Context, repos *repository.Repositories, order *models.Order) error {
+	return repos.OrdersByClient.DeleteMovements(ctx, order.ID)
+}
+
+// reverseOrder сторнирует приход проведенного заказа расходом на дату отмены,
+// сохраняя остатки регистра на прошлые даты
+func reverseOrder(ctx context.Context, repos *repository.Repositories, order *models.Order) error {
+	if !order.Status.Posted() {
+		return nil
+	}
+	movements := append(orderMovements(order), models.OrdersByClientMovement{
+		Period:     time.Now(),
+		LineNumber: 2,
+		RecordKind: models.MovementExpense,
+		ClientID:   order.ClientID,
+		Amount:     order.TotalAmount,
+	})
+	return repos.OrdersByClient.WriteMovements(ctx, order.ID, movements)
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+)
+
+// Таблицы ниже повторяют жизненный цикл заказа из NewOrderWorkflow
+// независимо от него: изменение автомата должно менять и тест.
+
+// allowedFrom — статусы, из которых возможен каждый переход, и его результат
+var allowedFrom = map[string]struct {
+	from []models.OrderStatus
+	to   models.OrderStatus
+}{
+	models.OrderActionConfirm:         {[]models.OrderStatus{models.OrderStatusDraft}, models.OrderStatusConfirmed},
+	models.OrderActionUnconfirm:       {[]models.OrderStatus{models.OrderStatusConfirmed}, models.OrderStatusDraft},
+	models.OrderActionStartFulfilment: {[]models.OrderStatus{models.OrderStatusConfirmed}, models.OrderStatusInFulfilment},
+	models.OrderActionShip:            {[]models.OrderStatus{models.OrderStatusInFulfilment}, models.OrderStatusShipped},
+	models.OrderActionClose:           {[]models.OrderStatus{models.OrderStatusShipped}, models.OrderStatusClosed},
+	models.OrderActionCancel: {[]models.OrderStatus{
+		models.OrderStatusDraft, models.OrderStatusConfirmed, models.OrderStatusInFulfilment,
+	}, models.OrderStatusCancelled},
+}
+
+// allowedRoles — роли, кроме администратора, и область ключа API для каждого перехода
+var allowedRoles = map[string]struct {
+	roles []Role
+	scope Scope
+}{
+	models.OrderActionConfirm:         {[]Role{RoleManager, RoleHeadOfSales}, ScopeConfirmOrders},
+	models.OrderActionUnconfirm:       {[]Role{RoleHeadOfSales}, ScopeConfirmOrders},
+	models.OrderActionStartFulfilment: {[]Role{RoleWarehouse}, ScopeFulfilOrders},
+	models.OrderActionShip:            {[]Role{RoleWarehouse}, ScopeFulfilOrders},
+	models.OrderActionClose:           {[]Role{RoleManager, RoleHeadOfSales}, ScopeWriteOrders},
+	models.OrderActionCancel:          {[]Role{RoleManager, RoleHeadOfSales}, ScopeConfirmOrders},
+}
+
+// pathTo — переходы, которые приводят новый черновик в статус
+var pathTo = map[models.OrderStatus][]string{
+	models.OrderStatusDraft:        nil,
+	models.OrderStatusConfirmed:    {models.OrderActionConfirm},
+	models.OrderStatusInFulfilment: {models.OrderActionConfirm, models.OrderActionStartFulfilment},
+	models.OrderStatusShipped:      {models.OrderActionConfirm, models.OrderActionStartFulfilment, models.OrderActionShip},
+	models.OrderStatusClosed: {models.OrderActionConfirm, models.OrderActionStartFulfilment,
+		models.OrderActionShip, models.OrderActionClose},
+	models.OrderStatusCancelled: {models.OrderActionCancel},
+}
+
+// orderInStatus создает заказ и переводит его в status от имени администратора
+func orderInStatus(t *testing.T, s *Services, ctx context.Context, status models.OrderStatus) *models.Order {
+	t.Helper()
+	order := newTestOrder(t, s, ctx, "2", "10.25")
+	for _, name := range pathTo[status] {
+		var err error
+		if order, err = s.Order.Transition(ctx, order.ID, name); err != nil {
+			t.Fatalf("%s on the way to %s: %v", name, status, err)
+		}
+	}
+	if order.Status != status {
+		t.Fatalf("order is in %s, want %s", order.Status, status)
+	}
+	return order
+}
+
+func TestWorkflowTable(t *testing.T) {
+	w := NewOrderWorkflow()
+	if got, want := len(w.Names()), len(allowedFrom); got != want {
+		t.Fatalf("workflow has %d transitions, test covers %d", got, want)
+	}
+	for _, name := range w.Names() {
+		tr, _ := w.Find(name)
+		want, ok := allowedFrom[name]
+		if !ok {
+			t.Errorf("transition %s is not covered by the test", name)
+			continue
+		}
+		if tr.To != want.to {
+			t.Errorf("%s leads to %s, want %s", name, tr.To, want.to)
+		}
+		for _, status := range models.OrderStatuses {
+			if got := tr.allows(status); got != slices.Contains(want.from, status) {
+				t.Errorf("%s from %s allowed = %v, want %v", name, status, got, !got)
+			}
+		}
+		perm := allowedRoles[name]
+		if !slices.Equal(tr.Roles, perm.roles) || tr.Scope != perm.scope {
+			t.Errorf("%s is allowed to %v and %s, want %v and %s", name, tr.Roles, tr.Scope, perm.roles, perm.scope)
+		}
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	for name, want := range allowedFrom {
+		for _, from := range models.OrderStatuses {
+			t.Run(name+"/"+string(from), func(t *testing.T) {
+				s, _, ctx := newTestServices(t)
+				order := orderInStatus(t, s, ctx, from)
+
+				got, err := s.Order.Transition(ctx, order.ID, name)
+				if !slices.Contains(want.from, from) {
+					if !errors.Is(err, ErrInvalidTransition) {
+						t.Fatalf("err = %v, want ErrInvalidTransition", err)
+					}
+					current, err := s.Order.GetByID(ctx, order.ID)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if current.Status != from || current.Version != order.Version {
+						t.Errorf("rejected transition changed the order to %s version %d", current.Status, current.Version)
+					}
+					return
+				}
+
+				if err != nil {
+					t.Fatalf("err = %v, want success", err)
+				}
+				if got.Status != want.to {
+					t.Errorf("status = %s, want %s", got.Status, want.to)
+				}
+				if got.Version != order.Version+1 {
+					t.Errorf("version = %d, want %d", got.Version, order.Version+1)
+				}
+				history, err := s.Order.GetStatusHistory(ctx, order.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				last := history[len(history)-1]
+				if last.Action != name || last.FromStatus != from || last.ToStatus != want.to || last.Actor != Anonymous.Name {
+					t.Errorf("history records %+v", last)
+				}
+			})
+		}
+	}
+}
+
+func TestUnknownTransition(t *testing.T) {
+	s, _, ctx := newTestServices(t)
+	order := orderInStatus(t, s, ctx, models.OrderStatusDraft)
+	if _, err := s.Order.Transition(ctx, order.ID, "approve"); !errors.Is(err, ErrValidation) {
+		t.Errorf("err = %v, want ErrValidation", err)
+	}
+}
+
+func TestTransitionPermissions(t *testing.T) {
+	for name, perm := range allowedRoles {
+		for _, role := range Roles {
+			actor := Actor{Name: "user", Roles: []Role{role}}
+			allowed := role == RoleAdmin || slices.Contains(perm.roles, role)
+			checkPermission(t, name, "role "+string(role), actor, allowed)
+		}
+		for _, scope := range Scopes {
+			// Роли ключа API не учитываются, даже роль администратора
+			actor := Actor{Name: "key", Roles: []Role{RoleAdmin}, KeyID: 1, Scopes: []Scope{scope}}
+			checkPermission(t, name, "scope "+string(scope), actor, scope == perm.scope)
+		}
+	}
+}
+
+// checkPermission выполняет переход name от имени actor в статусе, из
+// которого он возможен, и проверяет, что переход выполнен или запрещен и
+// что список доступных переходов с этим согласован
+func checkPermission(t *testing.T, name, test string, actor Actor, allowed bool) {
+	t.Run(name+"/"+test, func(t *testing.T) {
+		s, _, ctx := newTestServices(t)
+		order := orderInStatus(t, s, ctx, allowedFrom[name].from[0])
+		as := WithActor(ctx, actor)
+
+		available, err := s.Order.Transitions(as, order.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		listed := slices.ContainsFunc(available, func(tr Transition) bool { return tr.Name == name })
+		if listed != allowed {
+			t.Errorf("available transitions list %s = %v, want %v", name, listed, allowed)
+		}
+
+		_, err = s.Order.Transition(as, order.ID, name)
+		if allowed && err != nil {
+			t.Errorf("err = %v, want success", err)
+		}
+		if !allowed && !errors.Is(err, ErrForbidden) {
+			t.Errorf("err = %v, want ErrForbidden", err)
+		}
+	})
+}
+
+func TestConfirmRequiresItems(t *testing.T) {
+	s, repos, ctx := newTestServices(t)
+	client := &models.Client{Name: "ООО Ромашка"}
+	if err := s.Client.Create(ctx, client); err != nil {
+		t.Fatal(err)
+	}
+	// Сервис не создает заказ без позиций, поэтому черновик создается в хранилище
+	order := &models.Order{ClientID: client.ID}
+	if err := repos.Order.Create(ctx, order, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Order.Transition(ctx, order.ID, models.OrderActionConfirm); !errors.Is(err, ErrOrderHasNoItems) {
+		t.Fatalf("err = %v, want ErrOrderHasNoItems", err)
+	}
+	current, err := s.Order.GetByID(ctx, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Status != models.OrderStatusDraft {
+		t.Errorf("status = %s after a failed guard, want draft", current.Status)
+	}
+	if history, _ := s.Order.GetStatusHistory(ctx, order.ID); len(history) != 0 {
+		t.Errorf("failed guard left %d history records", len(history))
+	}
+}
+
+// registerState возвращает движения заказа и остаток клиента по регистру
+func registerState(t *testing.T, repos *repository.Repositories, ctx context.Context, order *models.Order) ([]models.OrdersByClientMovement, money.Money) {
+	t.Helper()
+	movements, err := repos.OrdersByClient.GetMovements(ctx, repository.MovementFilter{RecorderID: order.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	balance, err := repos.OrdersByClient.GetByID(ctx, order.ClientID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return movements, 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return movements, balance.OrdersSum
+}
+
+func TestPostingEffects(t *testing.T) {
+	total := money.MustParseMoney("20.50")
+	receipt := models.MovementReceipt
+	expense := models.MovementExpense
+
+	tests := []struct {
+		from    models.OrderStatus
+		action  string
+		kinds   []models.MovementKind
+		balance money.Money
+	}{
+		{models.OrderStatusDraft, models.OrderActionConfirm, []models.MovementKind{receipt}, total},
+		{models.OrderStatusConfirmed, models.OrderActionUnconfirm, nil, 0},
+		{models.OrderStatusConfirmed, models.OrderActionStartFulfilment, []models.MovementKind{receipt}, total},
+		{models.OrderStatusInFulfilment, models.OrderActionShip, []models.MovementKind{receipt}, total},
+		{models.OrderStatusShipped, models.OrderActionClose, []models.MovementKind{receipt}, total},
+		{models.OrderStatusDraft, models.OrderActionCancel, nil, 0},
+		{models.OrderStatusConfirmed, models.OrderActionCancel, []models.MovementKind{receipt, expense}, 0},
+		{models.OrderStatusInFulfilment, models.OrderActionCancel, []models.MovementKind{receipt, expense}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action+"/"+string(tt.from), func(t *testing.T) {
+			s, repos, ctx := newTestServices(t)
+			order := orderInStatus(t, s, ctx, tt.from)
+			if order.TotalAmount != total {
+				t.Fatalf("order total = %s, want %s", order.TotalAmount, total)
+			}
+
+			if _, err := s.Order.Transition(ctx, order.ID, tt.action); err != nil {
+				t.Fatal(err)
+			}
+			movements, balance := registerState(t, repos, ctx, order)
+
+			if len(movements) != len(tt.kinds) {
+				t.Fatalf("%d movements, want %d: %+v", len(movements), len(tt.kinds), movements)
+			}
+			for i, m := range movements {
+				if m.RecordKind != tt.kinds[i] || m.Amount != total || m.ClientID != order.ClientID {
+					t.Errorf("movement %d = %s %s for client %d, want %s %s for client %d",
+						i+1, m.RecordKind, m.Amount, m.ClientID, tt.kinds[i], total, order.ClientID)
+				}
+			}
+			if balance != tt.balance {
+				t.Errorf("client balance = %s, want %s", balance, tt.balance)
+			}
+		})
+	}
+}
+
+func TestDeletePostedOrder(t *testing.T) {
+	s, repos, ctx := newTestServices(t)
+	order := orderInStatus(t, s, ctx, models.OrderStatusConfirmed)
+
+	manager := WithActor(ctx, Actor{Name: "manager", Roles: []Role{RoleManager}})
+	if err := s.Order.Delete(manager, order.ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("manager delete: err = %v, want ErrForbidden", err)
+	}
+
+	head := WithActor(ctx, Actor{Name: "head", Roles: []Role{RoleHeadOfSales}})
+	if err := s.Order.Delete(head, order.ID); err != nil {
+		t.Fatalf("head of sales delete: %v", err)
+	}
+	if movements, balance := registerState(t, repos, ctx, order); len(movements) != 0 || balance != 0 {
+		t.Errorf("deleted order left %d movements and balance %s", len(movements), balance)
+	}
+}
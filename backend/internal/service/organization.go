@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+)
+
+var (
+	ErrDuplicateOrganization = apperr.New(apperr.KindConflict, "duplicate_organization", "organization code is already taken")
+	ErrUnknownOrganization   = apperr.New(apperr.KindBadRequest, "unknown_organization", "organization does not exist")
+	ErrOrganizationForbidden = apperr.New(apperr.KindForbidden, "organization_forbidden", "access to the organization is not allowed")
+)
+
+// organizationCode — допустимый код организации: латиница, цифры, '-' и '_'
+var organizationCode = regexp.MustCompile(`^[A-Za-z0-9_-]{1,50}$`)
+
+type OrganizationService interface {
+	List(ctx context.Context) ([]models.Organization, error)
+	GetByID(ctx context.Context, id int64) (*models.Organization, error)
+	Create(ctx context.Context, org *models.Organization) error
+	// Resolve определяет организацию, с данными которой работает запрос.
+	// ref — id или код организации из заголовка запроса. Выбирать организацию
+	// может только администратор развертывания (Actor.IsAdmin): он работает
+	// в организации ref или, без него, в tenant.Default. Остальные исполнители
+	// работают только в своей организации, а без нее не получают доступа.
+	Resolve(ctx context.Context, actor Actor, ref string) (int64, error)
+}
+
+// OrganizationService implementation
+type organizationService struct {
+	repo repository.OrganizationRepository
+	// uow записывает организацию и журнал аудита в одной транзакции
+	uow repository.UnitOfWork
+}
+
+func NewOrganizationService(repo repository.OrganizationRepository, uow repository.UnitOfWork) OrganizationService {
+	return &organizationService{repo: repo, uow: uow}
+}
+
+func (s *organizationService) List(ctx context.Context) ([]models.Organization, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *organizationService) GetByID(ctx context.Context, id int64) (*models.Organization, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *organizationService) Create(ctx context.Context, org *models.Organization) error {
+	if err := validateOrganization(org); err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		if err := repos.Organization.Create(ctx, org); err != nil {
+			if errors.Is(err, repository.ErrConflict) {
+				return ErrDuplicateOrganization.WithDetails(apperr.Field("code", "already exists")).Wrap(err)
+			}
+			return err
+		}
+		// История организации начинается в ее собственном журнале
+		return audit(tenant.WithID(ctx, org.ID), repos, models.AuditEntityOrganization, org.ID, models.AuditActionCreate, nil, org)
+	})
+}
+
+func (s *organizationService) Resolve(ctx context.Context, actor Actor, ref string) (int64, error) {
+	ref = strings.TrimSpace(ref)
+	if !actor.IsAdmin() {
+		// Пользователь без организации, кроме администратора, появиться не
+		// может (см. validateUser); если он все же есть, доступ закрыт
+		if actor.OrganizationID == 0 {
+			return 0, ErrOrganizationForbidden
+		}
+		if ref == "" {
+			return actor.OrganizationID, nil
+		}
+	} else if ref == "" {
+		return tenant.Default, nil
+	}
+
+	var org *models.Organization
+	var err error
+	if id, perr := strconv.ParseInt(ref, 10, 64); perr == nil {
+		org, err = s.repo.GetByID(ctx, id)
+	} else {
+		org, err = s.repo.GetByCode(ctx, ref)
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		// Исполнителю, привязанному к организации, не сообщаем, существует ли чужая
+		if !actor.IsAdmin() {
+			return 0, ErrOrganizationForbidden
+		}
+		return 0, ErrUnknownOrganization.WithDetails(apperr.Field("organization", "not found"))
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if !actor.IsAdmin() && actor.OrganizationID != org.ID {
+		return 0, ErrOrganizationForbidden
+	}
+	return org.ID, nil
+}
+
+// validateOrganization проверяет код и наименование организации
+func validateOrganization(org *models.Organization) error {
+	var details []apperr.FieldError
+
+	org.Code = strings.TrimSpace(org.Code)
+	if !organizationCode.MatchString(org.Code) {
+		details = append(details, apperr.Field("code", "must be 1-50 latin letters, digits, '-' or '_'"))
+	}
+
+	org.Name = strings.TrimSpace(org.Name)
+	if org.Name == "" {
+		details = append(details, apperr.Field("name", "is required"))
+	} else if tooLong(org.Name, models.MaxNameLength) {
+		details = append(details, apperr.Field("name", fmt.Sprintf("must be at most %d characters", models.MaxNameLength)))
+	}
+
+	if len(details) > 0 {
+		return ErrValidation.WithDetails(details...)
+	}
+	return nil
+}
+
+// organizationRefError сводит нарушение ссылки на организацию к ошибке проверки
+func organizationRefError(err error) error {
+	if errors.Is(err, repository.ErrForeignKey) {
+		return ErrValidation.WithDetails(apperr.Field("organization_id", "organization does not exist")).Wrap(err)
+	}
+	return err
+}
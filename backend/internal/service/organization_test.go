@@ -0,0 +1,96 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+)
+
+func TestResolveOrganization(t *testing.T) {
+	s, _, ctx := newTestServices(t)
+	acme := &models.Organization{Code: "acme", Name: "Acme"}
+	if err := s.Organization.Create(ctx, acme); err != nil {
+		t.Fatal(err)
+	}
+
+	admin := Actor{Name: "admin", Roles: []Role{RoleAdmin}}
+	unbound := Actor{Name: "manager", Roles: []Role{RoleManager}}
+	bound := Actor{Name: "manager", Roles: []Role{RoleManager}, OrganizationID: tenant.Default}
+	orgAdmin := Actor{Name: "org-admin", Roles: []Role{RoleAdmin}, OrganizationID: tenant.Default}
+	key := Actor{Name: "key", KeyID: 1, Scopes: []Scope{ScopeReadClients}, OrganizationID: acme.ID}
+
+	tests := []struct {
+		name  string
+		actor Actor
+		ref   string
+		want  int64
+		err   error
+	}{
+		{"admin default", admin, "", tenant.Default, nil},
+		{"admin by code", admin, "acme", acme.ID, nil},
+		{"admin by id", admin, "2", acme.ID, nil},
+		{"admin unknown", admin, "nope", 0, ErrUnknownOrganization},
+		{"anonymous switches", Anonymous, "acme", acme.ID, nil},
+		{"unbound user without header", unbound, "", 0, ErrOrganizationForbidden},
+		{"unbound user switches", unbound, "acme", 0, ErrOrganizationForbidden},
+		{"bound user own", bound, "", tenant.Default, nil},
+		{"bound user own by code", bound, "default", tenant.Default, nil},
+		{"bound user switches", bound, "acme", 0, ErrOrganizationForbidden},
+		{"bound user probes unknown", bound, "nope", 0, ErrOrganizationForbidden},
+		{"organization admin switches", orgAdmin, "acme", 0, ErrOrganizationForbidden},
+		{"key own", key, "", acme.ID, nil},
+		{"key switches", key, "default", 0, ErrOrganizationForbidden},
+	}
+	for _, tt := range tests {
+		got, err := s.Organization.Resolve(ctx, tt.actor, tt.ref)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: organization = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUserOrganization(t *testing.T) {
+	s, _, ctx := newTestServices(t)
+	acme := &models.Organization{Code: "acme", Name: "Acme"}
+	if err := s.Organization.Create(ctx, acme); err != nil {
+		t.Fatal(err)
+	}
+
+	// Пользователь без организации, кроме администратора, привязывается к
+	// организации запроса
+	manager := &models.User{Login: "manager", Roles: []string{string(RoleManager)}, Active: true}
+	if err := s.User.Create(tenant.WithID(ctx, acme.ID), manager, "password1"); err != nil {
+		t.Fatal(err)
+	}
+	if manager.OrganizationID == nil || *manager.OrganizationID != acme.ID {
+		t.Errorf("manager organization = %v, want %d", manager.OrganizationID, acme.ID)
+	}
+
+	admin := &models.User{Login: "root", Roles: []string{string(RoleAdmin)}, Active: true}
+	if err := s.User.Create(ctx, admin, "password1"); err != nil {
+		t.Fatal(err)
+	}
+	if admin.OrganizationID != nil {
+		t.Errorf("admin organization = %d, want none", *admin.OrganizationID)
+	}
+
+	// Снятие роли администратора привязывает пользователя к организации
+	admin.Roles = []string{string(RoleAccountant)}
+	admin.Version = 0
+	if err := s.User.Update(ctx, admin, ""); err != nil {
+		t.Fatal(err)
+	}
+	if admin.OrganizationID == nil || *admin.OrganizationID != tenant.Default {
+		t.Errorf("former admin organization = %v, want %d", admin.OrganizationID, tenant.Default)
+	}
+
+	if err := validateUser(&models.User{Login: "x", Roles: []string{string(RoleManager)}}, "password1", true); !errors.Is(err, ErrValidation) {
+		t.Errorf("validate user without organization: err = %v, want ErrValidation", err)
+	}
+}
@@ -4,54 +4,92 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
 	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
 	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
 )
 
+// Ошибки сервисов. Код ошибки (второй аргумент) входит в контракт API.
 var (
-	ErrNotFound         = errors.New("not found")
-	ErrAlreadyConfirmed = errors.New("order already confirmed")
-	ErrValidation       = errors.New("validation error")
-	ErrOrderHasNoItems  = errors.New("order has no items")
-	ErrInvalidQuantity  = errors.New("invalid quantity")
-	ErrInvalidPrice     = errors.New("invalid price")
-	ErrClientHasOrders  = errors.New("client has associated orders")
-	ErrProductHasOrders = errors.New("product has associated orders")
-	ErrDuplicateNumber  = errors.New("order number already exists")
-	ErrConfirmedNoEdit  = errors.New("confirmed order cannot be edited")
+	ErrNotFound          = repository.ErrNotFound
+	ErrVersionMismatch   = repository.ErrVersionMismatch
+	ErrValidation        = apperr.New(apperr.KindValidation, "validation_failed", "validation error")
+	ErrOrderHasNoItems   = apperr.New(apperr.KindValidation, "order_has_no_items", "order has no items")
+	ErrInvalidQuantity   = apperr.New(apperr.KindValidation, "invalid_quantity", "invalid quantity")
+	ErrInvalidPrice      = apperr.New(apperr.KindValidation, "invalid_price", "invalid price")
+	ErrAmountOutOfRange  = apperr.New(apperr.KindValidation, "amount_out_of_range", "order amount does not fit DECIMAL(15,2)")
+	ErrUnknownReference  = apperr.New(apperr.KindValidation, "unknown_reference", "order references a missing client or product")
+	ErrClientHasOrders   = apperr.New(apperr.KindConflict, "client_has_orders", "client has associated orders")
+	ErrProductHasOrders  = apperr.New(apperr.KindConflict, "product_has_orders", "product has associated orders")
+	ErrDuplicateNumber   = apperr.New(apperr.KindConflict, "duplicate_order_number", "order number already exists")
+	ErrOrderNotEditable  = apperr.New(apperr.KindConflict, "order_not_editable", "only draft orders can be edited")
+	ErrInvalidTransition = apperr.New(apperr.KindConflict, "invalid_transition", "transition is not allowed from the current order status")
+	ErrForbidden         = apperr.New(apperr.KindForbidden, "forbidden", "action is not allowed for the current user")
 )
 
 type ClientService interface {
 	Create(ctx context.Context, client *models.Client) error
 	GetByID(ctx context.Context, id int64) (*models.Client, error)
-	GetAll(ctx context.Context) ([]models.Client, error)
+	List(ctx context.Context, q repository.ClientQuery) (repository.Page[models.Client], error)
 	Update(ctx context.Context, client *models.Client) error
 	Delete(ctx context.Context, id int64) error
 	GetClientOrders(ctx context.Context, id int64) ([]models.Order, error)
+	GetHistory(ctx context.Context, id int64) ([]models.AuditEntry, error)
+	// GetByExternalID возвращает клиента по его идентификатору во внешней системе
+	GetByExternalID(ctx context.Context, system, externalID string) (*models.Client, error)
+	// Upsert изменяет клиента с внешним идентификатором ref или создает его
+	// (created), см. repository.UpsertByExternalID
+	Upsert(ctx context.Context, ref *models.ExternalRef, client *models.Client) (created bool, err error)
 }
 
 type ProductService interface {
 	Create(ctx context.Context, product *models.Product) error
 	GetByID(ctx context.Context, id int64) (*models.Product, error)
-	GetAll(ctx context.Context) ([]models.Product, error)
+	List(ctx context.Context, q repository.ProductQuery) (repository.Page[models.Product], error)
 	Update(ctx context.Context, product *models.Product) error
 	Delete(ctx context.Context, id int64) error
 	GetProductOrderItems(ctx context.Context, id int64) ([]models.OrderItem, error)
+	GetHistory(ctx context.Context, id int64) ([]models.AuditEntry, error)
+	// GetByExternalID возвращает товар по его идентификатору во внешней системе
+	GetByExternalID(ctx context.Context, system, externalID string) (*models.Product, error)
+	// Upsert изменяет товар с внешним идентификатором ref или создает его (created)
+	Upsert(ctx context.Context, ref *models.ExternalRef, product *models.Product) (created bool, err error)
 }
 
 type OrderService interface {
 	Create(ctx context.Context, order *models.Order, items []models.OrderItem) error
 	GetByID(ctx context.Context, id int64) (*models.Order, error)
-	GetAll(ctx context.Context) ([]models.Order, error)
+	List(ctx context.Context, q repository.OrderQuery) (repository.Page[models.Order], error)
 	Update(ctx context.Context, order *models.Order, items []models.OrderItem) error
 	Delete(ctx context.Context, id int64) error
-	Confirm(ctx context.Context, id int64) error
+	// Transition выполняет переход статуса заказа от имени исполнителя из ctx
+	Transition(ctx context.Context, id int64, name string) (*models.Order, error)
+	// Transitions возвращает переходы, доступные исполнителю из ctx
+	Transitions(ctx context.Context, id int64) ([]Transition, error)
+	// GetHistory возвращает журнал аудита заказа
+	GetHistory(ctx context.Context, id int64) ([]models.AuditEntry, error)
+	// GetStatusHistory возвращает историю статусов заказа
+	GetStatusHistory(ctx context.Context, id int64) ([]models.OrderHistory, error)
+	// GetByExternalID возвращает заказ по его идентификатору во внешней системе
+	GetByExternalID(ctx context.Context, system, externalID string) (*models.Order, error)
+	// Upsert изменяет заказ с внешним идентификатором ref или создает его
+	// (created). Заказ после черновика не изменяется, но повторная загрузка
+	// тех же данных проходит без ошибки.
+	Upsert(ctx context.Context, ref *models.ExternalRef, order *models.Order, items []models.OrderItem) (created bool, err error)
 }
 
 type OrdersByClientService interface {
 	GetByID(ctx context.Context, clientID int64) (*models.OrdersByClient, error)
 	GetAll(ctx context.Context) ([]models.OrdersByClient, error)
+	GetMovements(ctx context.Context, filter repository.MovementFilter) ([]models.OrdersByClientMovement, error)
+	GetBalance(ctx context.Context, at time.Time) ([]models.OrdersByClient, error)
+	GetTurnovers(ctx context.Context, from, to time.Time) ([]models.OrdersByClientTurnover, error)
 }
 
 type Services struct {
@@ -59,58 +97,161 @@ type Services struct {
 	Product        ProductService
 	Order          OrderService
 	OrdersByClient OrdersByClientService
+	Auth           AuthService
+	User           UserService
+	APIKey         APIKeyService
+	Organization   OrganizationService
+	Import         ImportService
+	Export         ExportService
+	Exchange       ExchangeService
+	ExternalRef    ExternalRefService
 }
 
-func NewServices(repos *repository.PostgresRepositories) *Services {
+func NewServices(repos *repository.Repositories, auth AuthConfig, exchange ExchangeConfig) *Services {
 	return &Services{
-		Client:         NewClientService(repos.Client),
-		Product:        NewProductService(repos.Product),
-		Order:          NewOrderService(repos.Order, repos.OrdersByClient),
+		Client:         NewClientService(repos.Client, repos.ExternalRef, repos.Audit, repos.UnitOfWork),
+		Product:        NewProductService(repos.Product, repos.ExternalRef, repos.Audit, repos.UnitOfWork),
+		Order:          NewOrderService(repos.Order, repos.ExternalRef, repos.Audit, repos.UnitOfWork),
 		OrdersByClient: NewOrdersByClientService(repos.OrdersByClient),
+		Auth:           NewAuthService(repos.User, auth),
+		User:           NewUserService(repos.User, repos.UnitOfWork),
+		APIKey:         NewAPIKeyService(repos.APIKey, repos.UnitOfWork),
+		Organization:   NewOrganizationService(repos.Organization, repos.UnitOfWork),
+		Import:         NewImportService(repos.UnitOfWork),
+		Export:         NewExportService(repos.Client, repos.Product, repos.Order, repos.OrdersByClient),
+		Exchange:       NewExchangeService(repos.Client, repos.Product, repos.Order, repos.ExternalRef, repos.UnitOfWork, exchange),
+		ExternalRef:    NewExternalRefService(repos.Client, repos.Product, repos.Order, repos.ExternalRef, repos.UnitOfWork),
 	}
 }
 
+// normalizeList проверяет параметры страницы и подставляет значения по умолчанию.
+// Курсор несет сортировку страницы, с которой он получен, и она имеет приоритет.
+// Без явной сортировки список упорядочивается по первому ключу из keys.
+func normalizeList(p *repository.ListParams, keys []string, defaultDesc bool) error {
+	var details []apperr.FieldError
+
+	if p.Cursor != nil {
+		p.Sort, p.Desc = p.Cursor.Sort, p.Cursor.Desc
+		if _, err := p.Cursor.Arg(); err != nil {
+			details = append(details, apperr.Field("cursor", "is invalid"))
+		}
+	}
+	if p.Sort == "" {
+		p.Sort, p.Desc = keys[0], defaultDesc
+	} else if !slices.Contains(keys, p.Sort) {
+		details = append(details, apperr.Field("sort", "must be one of "+strings.Join(keys, ", ")))
+	}
+
+	if p.Limit == 0 {
+		p.Limit = repository.DefaultPageLimit
+	} else if p.Limit < 0 || p.Limit > repository.MaxPageLimit {
+		details = append(details, apperr.Field("limit", fmt.Sprintf("must be between 1 and %d", repository.MaxPageLimit)))
+	}
+	if p.Offset < 0 {
+		details = append(details, apperr.Field("offset", "must not be negative"))
+	}
+
+	if len(details) > 0 {
+		return ErrValidation.WithDetails(details...)
+	}
+	return nil
+}
+
 // ClientService implementation
 type clientService struct {
-	repo repository.ClientRepository
+	repo  repository.ClientRepository
+	refs  repository.ExternalRefRepository
+	audit repository.AuditRepository
+	// uow записывает изменение клиента и журнал аудита в одной транзакции
+	uow repository.UnitOfWork
 }
 
-func NewClientService(repo repository.ClientRepository) ClientService {
-	return &clientService{repo: repo}
+func NewClientService(repo repository.ClientRepository, refs repository.ExternalRefRepository, audit repository.AuditRepository, uow repository.UnitOfWork) ClientService {
+	return &clientService{repo: repo, refs: refs, audit: audit, uow: uow}
 }
 
 func (s *clientService) Create(ctx context.Context, client *models.Client) error {
+	if err := validateClient(client); err != nil {
+		return err
+	}
+	return s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		if err := repos.Client.Create(ctx, client); err != nil {
+			return err
+		}
+		return audit(ctx, repos, models.AuditEntityClient, client.ID, models.AuditActionCreate, nil, client)
+	})
+}
+
+// validateClient проверяет наименование и ИНН клиента. ИНН необязателен.
+func validateClient(client *models.Client) error {
+	var details []apperr.FieldError
+
+	client.Name = strings.TrimSpace(client.Name)
 	if client.Name == "" {
-		return fmt.Errorf("%w: name is required", ErrValidation)
+		details = append(details, apperr.Field("name", "is required"))
+	} else if tooLong(client.Name, models.MaxNameLength) {
+		details = append(details, apperr.Field("name", fmt.Sprintf("must be at most %d characters", models.MaxNameLength)))
+	}
+
+	client.INN = strings.TrimSpace(client.INN)
+	if client.INN != "" && !models.ValidINN(client.INN) {
+		details = append(details, apperr.Field("inn", "must be 10 or 12 digits with valid check digits"))
+	}
+
+	if len(details) > 0 {
+		return ErrValidation.WithDetails(details...)
 	}
-	return s.repo.Create(ctx, client)
+	return nil
 }
 
 func (s *clientService) GetByID(ctx context.Context, id int64) (*models.Client, error) {
 	return s.repo.GetByID(ctx, id)
 }
 
-func (s *clientService) GetAll(ctx context.Context) ([]models.Client, error) {
-	return s.repo.GetAll(ctx)
+func (s *clientService) List(ctx context.Context, q repository.ClientQuery) (repository.Page[models.Client], error) {
+	if err := normalizeList(&q.ListParams, repository.ClientSortKeys, false); err != nil {
+		return repository.Page[models.Client]{}, err
+	}
+	return s.repo.List(ctx, q)
 }
 
 func (s *clientService) Update(ctx context.Context, client *models.Client) error {
-	if client.Name == "" {
-		return fmt.Errorf("%w: name is required", ErrValidation)
+	if err := validateClient(client); err != nil {
+		return err
 	}
-	return s.repo.Update(ctx, client)
+	return s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		before, err := repos.Client.GetByID(ctx, client.ID)
+		if err != nil {
+			return err
+		}
+		if err := repos.Client.Update(ctx, client); err != nil {
+			return err
+		}
+		after, err := repos.Client.GetByID(ctx, client.ID)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repos, models.AuditEntityClient, client.ID, models.AuditActionUpdate, before, after)
+	})
 }
 
 func (s *clientService) Delete(ctx context.Context, id int64) error {
-	// Сначала получаем клиента, чтобы проверить его существование
-	client, err := s.repo.GetByID(ctx, id)
-	if err != nil {
-		return err
-	}
-	if client == nil {
-		return ErrNotFound
-	}
-	return s.repo.Delete(ctx, id)
+	return s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		// Сначала получаем клиента, чтобы проверить его существование
+		client, err := repos.Client.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if client == nil {
+			return ErrNotFound
+		}
+		if err := repos.Client.Delete(ctx, id); errors.Is(err, repository.ErrForeignKey) {
+			return ErrClientHasOrders
+		} else if err != nil {
+			return err
+		}
+		return audit(ctx, repos, models.AuditEntityClient, id, models.AuditActionDelete, client, nil)
+	})
 }
 
 func (s *clientService) GetClientOrders(ctx context.Context, id int64) ([]models.Order, error) {
@@ -126,47 +267,104 @@ func (s *clientService) GetClientOrders(ctx context.Context, id int64) ([]models
 	return s.repo.GetClientOrders(ctx, id)
 }
 
+func (s *clientService) GetHistory(ctx context.Context, id int64) ([]models.AuditEntry, error) {
+	return history(ctx, s.audit, models.AuditEntityClient, id)
+}
+
 // ProductService implementation
 type productService struct {
-	repo repository.ProductRepository
+	repo  repository.ProductRepository
+	refs  repository.ExternalRefRepository
+	audit repository.AuditRepository
+	// uow записывает изменение товара и журнал аудита в одной транзакции
+	uow repository.UnitOfWork
 }
 
-func NewProductService(repo repository.ProductRepository) ProductService {
-	return &productService{repo: repo}
+func NewProductService(repo repository.ProductRepository, refs repository.ExternalRefRepository, audit repository.AuditRepository, uow repository.UnitOfWork) ProductService {
+	return &productService{repo: repo, refs: refs, audit: audit, uow: uow}
 }
 
 func (s *productService) Create(ctx context.Context, product *models.Product) error {
-	if product.Name == "" || product.Unit == "" {
-		return fmt.Errorf("%w: name and unit are required", ErrValidation)
+	if err := validateProduct(product); err != nil {
+		return err
+	}
+	return s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		if err := repos.Product.Create(ctx, product); err != nil {
+			return err
+		}
+		return audit(ctx, repos, models.AuditEntityProduct, product.ID, models.AuditActionCreate, nil, product)
+	})
+}
+
+// validateProduct проверяет обязательные поля товара и их длину
+func validateProduct(product *models.Product) error {
+	var details []apperr.FieldError
+	product.Name = strings.TrimSpace(product.Name)
+	if product.Name == "" {
+		details = append(details, apperr.Field("name", "is required"))
+	} else if tooLong(product.Name, models.MaxNameLength) {
+		details = append(details, apperr.Field("name", fmt.Sprintf("must be at most %d characters", models.MaxNameLength)))
+	}
+	product.Unit = strings.TrimSpace(product.Unit)
+	if product.Unit == "" {
+		details = append(details, apperr.Field("unit", "is required"))
+	} else if tooLong(product.Unit, models.MaxUnitLength) {
+		details = append(details, apperr.Field("unit", fmt.Sprintf("must be at most %d characters", models.MaxUnitLength)))
 	}
-	return s.repo.Create(ctx, product)
+	if len(details) > 0 {
+		return ErrValidation.WithDetails(details...)
+	}
+	return nil
 }
 
 func (s *productService) GetByID(ctx context.Context, id int64) (*models.Product, error) {
 	return s.repo.GetByID(ctx, id)
 }
 
-func (s *productService) GetAll(ctx context.Context) ([]models.Product, error) {
-	return s.repo.GetAll(ctx)
+func (s *productService) List(ctx context.Context, q repository.ProductQuery) (repository.Page[models.Product], error) {
+	if err := normalizeList(&q.ListParams, repository.ProductSortKeys, false); err != nil {
+		return repository.Page[models.Product]{}, err
+	}
+	return s.repo.List(ctx, q)
 }
 
 func (s *productService) Update(ctx context.Context, product *models.Product) error {
-	if product.Name == "" || product.Unit == "" {
-		return fmt.Errorf("%w: name and unit are required", ErrValidation)
+	if err := validateProduct(product); err != nil {
+		return err
 	}
-	return s.repo.Update(ctx, product)
+	return s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		before, err := repos.Product.GetByID(ctx, product.ID)
+		if err != nil {
+			return err
+		}
+		if err := repos.Product.Update(ctx, product); err != nil {
+			return err
+		}
+		after, err := repos.Product.GetByID(ctx, product.ID)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repos, models.AuditEntityProduct, product.ID, models.AuditActionUpdate, before, after)
+	})
 }
 
 func (s *productService) Delete(ctx context.Context, id int64) error {
-	// Сначала получаем продукт, чтобы проверить его существование
-	product, err := s.repo.GetByID(ctx, id)
-	if err != nil {
-		return err
-	}
-	if product == nil {
-		return ErrNotFound
-	}
-	return s.repo.Delete(ctx, id)
+	return s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		// Сначала получаем продукт, чтобы проверить его существование
+		product, err := repos.Product.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if product == nil {
+			return ErrNotFound
+		}
+		if err := repos.Product.Delete(ctx, id); errors.Is(err, repository.ErrForeignKey) {
+			return ErrProductHasOrders
+		} else if err != nil {
+			return err
+		}
+		return audit(ctx, repos, models.AuditEntityProduct, id, models.AuditActionDelete, product, nil)
+	})
 }
 
 func (s *productService) GetProductOrderItems(ctx context.Context, id int64) ([]models.OrderItem, error) {
@@ -182,16 +380,28 @@ func (s *productService) GetProductOrderItems(ctx context.Context, id int64) ([]
 	return s.repo.GetProductOrderItems(ctx, id)
 }
 
+func (s *productService) GetHistory(ctx context.Context, id int64) ([]models.AuditEntry, error) {
+	return history(ctx, s.audit, models.AuditEntityProduct, id)
+}
+
 // OrderService implementation
 type orderService struct {
-	repo         repository.OrderRepository
-	ordersByRepo repository.OrdersByClientRepository
+	repo  repository.OrderRepository
+	refs  repository.ExternalRefRepository
+	audit repository.AuditRepository
+	// uow выполняет изменения заказа в одной транзакции вместе с движениями
+	// по регистру и журналом аудита
+	uow      repository.UnitOfWork
+	workflow *Workflow
 }
 
-func NewOrderService(repo repository.OrderRepository, ordersByRepo repository.OrdersByClientRepository) OrderService {
+func NewOrderService(repo repository.OrderRepository, refs repository.ExternalRefRepository, audit repository.AuditRepository, uow repository.UnitOfWork) OrderService {
 	return &orderService{
-		repo:         repo,
-		ordersByRepo: ordersByRepo,
+		repo:     repo,
+		refs:     refs,
+		audit:    audit,
+		uow:      uow,
+		workflow: NewOrderWorkflow(),
 	}
 }
 
@@ -200,86 +410,279 @@ func (s *orderService) Create(ctx context.Context, order *models.Order, items []
 		return ErrOrderHasNoItems
 	}
 
-	// Валидация
-	for _, item := range items {
-		if item.Quantity <= 0 {
-			return ErrInvalidQuantity
-		}
-		if item.Price <= 0 {
-			return ErrInvalidPrice
-		}
+	if err := validateOrder(order, items); err != nil {
+		return err
 	}
 
-	return s.repo.Create(ctx, order, items)
+	return s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		if err := repos.Order.Create(ctx, order, items); err != nil {
+			return orderWriteError(err)
+		}
+		after, err := repos.Order.GetByID(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repos, models.AuditEntityOrder, order.ID, models.AuditActionCreate, nil, after)
+	})
 }
 
 func (s *orderService) GetByID(ctx context.Context, id int64) (*models.Order, error) {
 	return s.repo.GetByID(ctx, id)
 }
 
-func (s *orderService) GetAll(ctx context.Context) ([]models.Order, error) {
-	return s.repo.GetAll(ctx)
+func (s *orderService) List(ctx context.Context, q repository.OrderQuery) (repository.Page[models.Order], error) {
+	// По умолчанию новые заказы идут первыми
+	if err := normalizeList(&q.ListParams, repository.OrderSortKeys, true); err != nil {
+		return repository.Page[models.Order]{}, err
+	}
+	if err := validateOrderQuery(q); err != nil {
+		return repository.Page[models.Order]{}, err
+	}
+
+	return s.repo.List(ctx, q)
+}
+
+// validateOrderQuery проверяет отбор заказов
+func validateOrderQuery(q repository.OrderQuery) error {
+	var details []apperr.FieldError
+	if !q.From.IsZero() && !q.To.IsZero() && q.From.After(q.To) {
+		details = append(details, apperr.Field("from", "must not be after to"))
+	}
+	for _, st := range q.Statuses {
+		if !st.Valid() {
+			details = append(details, apperr.Field("status", fmt.Sprintf("unknown status %q", st)))
+		}
+	}
+	if q.MinAmount != nil && q.MaxAmount != nil && *q.MinAmount > *q.MaxAmount {
+		details = append(details, apperr.Field("min_amount", "must not exceed max_amount"))
+	}
+	if len(details) > 0 {
+		return ErrValidation.WithDetails(details...)
+	}
+	return nil
 }
 
 func (s *orderService) Update(ctx context.Context, order *models.Order, items []models.OrderItem) error {
-	if order.IsConfirmed {
-		return ErrConfirmedNoEdit
+	if err := validateOrder(order, items); err != nil {
+		return err
 	}
 
-	if len(items) > 0 {
-		for _, item := range items {
-			if item.Quantity <= 0 {
-				return ErrInvalidQuantity
-			}
-			if item.Price <= 0 {
-				return ErrInvalidPrice
-			}
+	return s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		current, err := repos.Order.GetForUpdate(ctx, order.ID)
+		if err != nil {
+			return err
 		}
-		order.TotalAmount = 0
-		for _, item := range items {
-			order.TotalAmount += item.Quantity * item.Price
+
+		// Устаревшая версия важнее остальных проверок: клиент видит не то состояние
+		if order.Version != 0 && order.Version != current.Version {
+			return ErrVersionMismatch
+		}
+		if current.Status != models.OrderStatusDraft {
+			return ErrOrderNotEditable
 		}
-	}
 
-	return s.repo.Update(ctx, order, items)
+		// Пустой номер означает, что номер заказа не меняется
+		if order.Number == "" {
+			order.Number = current.Number
+		}
+
+		if err := repos.Order.Update(ctx, order, items); err != nil {
+			return orderWriteError(err)
+		}
+		order.Date = current.Date
+		order.CreatedAt = current.CreatedAt
+
+		after, err := repos.Order.GetByID(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repos, models.AuditEntityOrder, order.ID, models.AuditActionUpdate, current, after)
+	})
 }
 
 func (s *orderService) Delete(ctx context.Context, id int64) error {
-	order, err := s.repo.GetByID(ctx, id)
-	if err != nil {
-		return err
-	}
+	return s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		order, err := repos.Order.GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		// Удалить заказ после черновика может только руководитель продаж, а ключ
+		// API — с правом проведения. Движения такого заказа по регистру удаляются
+		// вместе с итогами.
+		if order.Status != models.OrderStatusDraft {
+			if !ActorFromContext(ctx).Can(ScopeConfirmOrders, RoleHeadOfSales) {
+				return ErrForbidden.WithMessage("only the head of sales can delete an order that is not a draft")
+			}
+			if err := repos.OrdersByClient.DeleteMovements(ctx, order.ID); err != nil {
+				return err
+			}
+		}
 
-	// Если заказ подтвержден, корректируем суммы в OrdersByClient
-	if order.IsConfirmed {
-		if err := s.ordersByRepo.UpdateSum(ctx, order.ClientID, -order.TotalAmount); err != nil {
+		if err := repos.Order.Delete(ctx, id); err != nil {
 			return err
 		}
+		return audit(ctx, repos, models.AuditEntityOrder, id, models.AuditActionDelete, order, nil)
+	})
+}
+
+func (s *orderService) Transition(ctx context.Context, id int64, name string) (*models.Order, error) {
+	t, ok := s.workflow.Find(name)
+	if !ok {
+		return nil, ErrValidation.WithDetails(
+			apperr.Field("transition", "must be one of "+strings.Join(s.workflow.Names(), ", ")))
+	}
+
+	actor := ActorFromContext(ctx)
+	if !actor.Can(t.Scope, t.Roles...) {
+		return nil, ErrForbidden.WithMessage("transition " + name + " is not allowed for the current user")
 	}
 
-	return s.repo.Delete(ctx, id)
+	var result *models.Order
+	err := s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		order, err := repos.Order.GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if !t.allows(order.Status) {
+			return ErrInvalidTransition.WithMessage(
+				fmt.Sprintf("cannot %s an order in status %s", t.Name, order.Status))
+		}
+		if t.guard != nil {
+			if err := t.guard(order); err != nil {
+				return err
+			}
+		}
+
+		change := &models.OrderHistory{
+			OrderID:    order.ID,
+			Action:     t.Name,
+			FromStatus: order.Status,
+			ToStatus:   t.To,
+			Actor:      actor.Name,
+		}
+		if err := repos.Order.SetStatus(ctx, change); err != nil {
+			return err
+		}
+
+		if t.effect != nil {
+			if err := t.effect(ctx, repos, order); err != nil {
+				return err
+			}
+		}
+
+		result, err = repos.Order.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, repos, models.AuditEntityOrder, id, t.Name, order, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-func (s *orderService) Confirm(ctx context.Context, id int64) error {
+func (s *orderService) Transitions(ctx context.Context, id int64) ([]Transition, error) {
 	order, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return s.workflow.Available(order.Status, ActorFromContext(ctx)), nil
+}
+
+func (s *orderService) GetHistory(ctx context.Context, id int64) ([]models.AuditEntry, error) {
+	return history(ctx, s.audit, models.AuditEntityOrder, id)
+}
 
-	if order.IsConfirmed {
-		return ErrAlreadyConfirmed
+func (s *orderService) GetStatusHistory(ctx context.Context, id int64) ([]models.OrderHistory, error) {
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return nil, err
 	}
+	return s.repo.GetHistory(ctx, id)
+}
 
-	if len(order.Items) == 0 {
-		return ErrOrderHasNoItems
+// validateOrder проверяет номер заказа, количество и цену позиций
+func validateOrder(order *models.Order, items []models.OrderItem) error {
+	order.Number = strings.TrimSpace(order.Number)
+	if tooLong(order.Number, models.MaxOrderNumberLength) {
+		return ErrValidation.WithDetails(apperr.Field("number", fmt.Sprintf("must be at most %d characters", models.MaxOrderNumberLength)))
 	}
+	for i, item := range items {
+		if item.Quantity <= 0 {
+			return ErrInvalidQuantity.WithDetails(apperr.Field(fmt.Sprintf("items[%d].quantity", i), "must be positive"))
+		}
+		if item.Price <= 0 {
+			return ErrInvalidPrice.WithDetails(apperr.Field(fmt.Sprintf("items[%d].price", i), "must be positive"))
+		}
+	}
+	// Суммы пересчитывает репозиторий; здесь они проверяются, чтобы
+	// переполнение было ошибкой запроса, а не ошибкой записи
+	if _, err := models.CalculateAmounts(slices.Clone(items)); err != nil {
+		return amountError(err)
+	}
+	return nil
+}
 
-	// Обновляем сумму заказов клиента
-	if err := s.ordersByRepo.UpdateSum(ctx, order.ClientID, order.TotalAmount); err != nil {
-		return err
+// amountError переводит ошибку расчета сумм заказа в ErrAmountOutOfRange
+// с полем, сумма которого не помещается в DECIMAL(15,2)
+func amountError(err error) error {
+	var ae *models.AmountError
+	if !errors.As(err, &ae) {
+		return ErrAmountOutOfRange.Wrap(err)
 	}
+	field := "total_amount"
+	if ae.Line >= 0 {
+		field = fmt.Sprintf("items[%d].line_amount", ae.Line)
+	}
+	return ErrAmountOutOfRange.WithDetails(apperr.Field(field, "exceeds 9999999999999.99")).Wrap(err)
+}
+
+// sameOrder сообщает, совпадает ли заказ с новыми данными с текущим
+func sameOrder(current, order *models.Order, items []models.OrderItem) bool {
+	if current.ClientID != order.ClientID || (order.Number != "" && current.Number != order.Number) {
+		return false
+	}
+	if len(current.Items) != len(items) {
+		return false
+	}
+	for i, item := range items {
+		c := current.Items[i]
+		if c.ProductID != item.ProductID || c.Quantity != item.Quantity || c.Price != item.Price {
+			return false
+		}
+	}
+	return true
+}
+
+// tooLong сообщает, длиннее ли строка max символов
+func tooLong(s string, max int) bool {
+	return utf8.RuneCountInString(s) > max
+}
+
+// orderWriteError уточняет ошибки ограничений при записи заказа
+func orderWriteError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrConflict):
+		return ErrDuplicateNumber.WithDetails(apperr.Field("number", "already exists")).Wrap(err)
+	case errors.Is(err, repository.ErrForeignKey):
+		return ErrUnknownReference.Wrap(err)
+	case errors.Is(err, money.ErrOutOfRange):
+		return amountError(err)
+	}
+	return err
+}
 
-	return s.repo.Confirm(ctx, id)
+// orderMovements строит набор движений заказа по регистру «ЗаказыПоКонтрагентам»
+func orderMovements(order *models.Order) []models.OrdersByClientMovement {
+	return []models.OrdersByClientMovement{{
+		Period:     order.Date,
+		LineNumber: 1,
+		RecordKind: models.MovementReceipt,
+		ClientID:   order.ClientID,
+		Amount:     order.TotalAmount,
+	}}
 }
 
 // OrdersByClientService implementation
@@ -298,3 +701,24 @@ func (s *ordersByClientService) GetByID(ctx context.Context, clientID int64) (*m
 func (s *ordersByClientService) GetAll(ctx context.Context) ([]models.OrdersByClient, error) {
 	return s.repo.GetAll(ctx)
 }
+
+func (s *ordersByClientService) GetMovements(ctx context.Context, filter repository.MovementFilter) ([]models.OrdersByClientMovement, error) {
+	return s.repo.GetMovements(ctx, filter)
+}
+
+func (s *ordersByClientService) GetBalance(ctx context.Context, at time.Time) ([]models.OrdersByClient, error) {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return s.repo.GetBalance(ctx, at)
+}
+
+func (s *ordersByClientService) GetTurnovers(ctx context.Context, from, to time.Time) ([]models.OrdersByClientTurnover, error) {
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.After(to) {
+		return nil, ErrValidation.WithDetails(apperr.Field("from", "must not be after to"))
+	}
+	return s.repo.GetTurnovers(ctx, from, to)
+}
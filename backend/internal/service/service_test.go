@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/money"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/numbering"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository/memory"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+)
+
+// newTestServices создает сервисы над хранилищем в памяти и контекст
+// организации по умолчанию от имени администратора
+func newTestServices(t *testing.T) (*Services, *repository.Repositories, context.Context) {
+	t.Helper()
+	repos := memory.NewRepositories(numbering.DefaultOrderFormat)
+	ctx := tenant.WithID(context.Background(), tenant.Default)
+	return NewServices(repos, AuthConfig{}, ExchangeConfig{Dir: t.TempDir()}), repos, ctx
+}
+
+// newTestOrder создает клиента, товар и черновик заказа с одной позицией
+func newTestOrder(t *testing.T, s *Services, ctx context.Context, quantity, price string) *models.Order {
+	t.Helper()
+	client := &models.Client{Name: "ООО Ромашка"}
+	if err := s.Client.Create(ctx, client); err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	product := &models.Product{Name: "Гвозди", Unit: "кг"}
+	if err := s.Product.Create(ctx, product); err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	order := &models.Order{ClientID: client.ID}
+	items := []models.OrderItem{{
+		ProductID: product.ID,
+		Quantity:  money.MustParseQuantity(quantity),
+		Price:     money.MustParseMoney(price),
+	}}
+	if err := s.Order.Create(ctx, order, items); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+	return order
+}
+
+func TestOrderAmountOutOfRange(t *testing.T) {
+	s, _, ctx := newTestServices(t)
+	order := newTestOrder(t, s, ctx, "1", "1")
+
+	tests := []struct {
+		name  string
+		items []models.OrderItem
+		field string
+	}{
+		{"line", []models.OrderItem{
+			{Quantity: money.MustParseQuantity("999999999999.999"), Price: money.MustParseMoney("9999999999999.99")},
+		}, "items[0].line_amount"},
+		{"total", []models.OrderItem{
+			{Quantity: money.MustParseQuantity("100000"), Price: money.MustParseMoney("99999999.99")},
+			{Quantity: money.MustParseQuantity("100000"), Price: money.MustParseMoney("99999999.99")},
+		}, "total_amount"},
+	}
+	for _, tt := range tests {
+		current, err := s.Order.GetByID(ctx, order.ID)
+		if err != nil {
+			t.Fatalf("get order: %v", err)
+		}
+		for i := range tt.items {
+			tt.items[i].ProductID = current.Items[0].ProductID
+		}
+
+		for op, err := range map[string]error{
+			"create": s.Order.Create(ctx, &models.Order{ClientID: current.ClientID}, tt.items),
+			"update": s.Order.Update(ctx, current, tt.items),
+		} {
+			if !errors.Is(err, ErrAmountOutOfRange) {
+				t.Errorf("%s %s: err = %v, want ErrAmountOutOfRange", tt.name, op, err)
+				continue
+			}
+			e := apperr.From(err)
+			if e.Kind.Status() != http.StatusUnprocessableEntity {
+				t.Errorf("%s %s: status = %d, want 422", tt.name, op, e.Kind.Status())
+			}
+			if len(e.Details) != 1 || e.Details[0].Field != tt.field {
+				t.Errorf("%s %s: details = %v, want field %s", tt.name, op, e.Details, tt.field)
+			}
+		}
+	}
+}
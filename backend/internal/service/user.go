@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/domain/models"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/repository"
+	"github.com/1C-Migration-Lab/OrderFlow/internal/tenant"
+)
+
+var ErrDuplicateLogin = apperr.New(apperr.KindConflict, "duplicate_login", "login is already taken")
+
+// Ограничения пароля; bcrypt учитывает не больше 72 байт
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+type UserService interface {
+	List(ctx context.Context) ([]models.User, error)
+	GetByID(ctx context.Context, id int64) (*models.User, error)
+	Create(ctx context.Context, user *models.User, password string) error
+	// Update изменяет логин, роли, организацию и активность пользователя;
+	// непустой password заменяет пароль
+	Update(ctx context.Context, user *models.User, password string) error
+	// EnsureAdmin создает администратора с логином login, если такого
+	// пользователя еще нет
+	EnsureAdmin(ctx context.Context, login, password string) error
+}
+
+// UserService implementation
+type userService struct {
+	repo repository.UserRepository
+	// uow записывает изменение пользователя и журнал аудита в одной транзакции
+	uow repository.UnitOfWork
+}
+
+func NewUserService(repo repository.UserRepository, uow repository.UnitOfWork) UserService {
+	return &userService{repo: repo, uow: uow}
+}
+
+func (s *userService) List(ctx context.Context) ([]models.User, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *userService) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *userService) Create(ctx context.Context, user *models.User, password string) error {
+	bindOrganization(ctx, user)
+	if err := validateUser(user, password, true); err != nil {
+		return err
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+
+	return s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		if err := repos.User.Create(ctx, user); err != nil {
+			return userWriteError(err)
+		}
+		return audit(ctx, repos, models.AuditEntityUser, user.ID, models.AuditActionCreate, nil, user)
+	})
+}
+
+func (s *userService) Update(ctx context.Context, user *models.User, password string) error {
+	bindOrganization(ctx, user)
+	if err := validateUser(user, password, false); err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(repos *repository.Repositories) error {
+		before, err := repos.User.GetByID(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		user.PasswordHash = before.PasswordHash
+		if password != "" {
+			if user.PasswordHash, err = hashPassword(password); err != nil {
+				return err
+			}
+		}
+
+		if err := repos.User.Update(ctx, user); err != nil {
+			return userWriteError(err)
+		}
+		user.CreatedAt = before.CreatedAt
+		return audit(ctx, repos, models.AuditEntityUser, user.ID, models.AuditActionUpdate, before, user)
+	})
+}
+
+func (s *userService) EnsureAdmin(ctx context.Context, login, password string) error {
+	_, err := s.repo.GetByLogin(ctx, login)
+	if !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+
+	admin := &models.User{Login: login, Roles: []string{string(RoleAdmin)}, Active: true}
+	return s.Create(ctx, admin, password)
+}
+
+// bindOrganization привязывает пользователя без организации к организации
+// запроса. Без организации работает только администратор развертывания:
+// иначе пользователь получил бы доступ к данным всех организаций.
+func bindOrganization(ctx context.Context, user *models.User) {
+	if user.OrganizationID != nil || slices.Contains(user.Roles, string(RoleAdmin)) {
+		return
+	}
+	org, ok := tenant.FromContext(ctx)
+	if !ok {
+		org = tenant.Default
+	}
+	user.OrganizationID = &org
+}
+
+// validateUser проверяет логин, роли и пароль; при создании пароль обязателен.
+// Организация обязательна всем, кроме администраторов.
+func validateUser(user *models.User, password string, create bool) error {
+	var details []apperr.FieldError
+
+	user.Login = strings.TrimSpace(user.Login)
+	if user.Login == "" {
+		details = append(details, apperr.Field("login", "is required"))
+	} else if tooLong(user.Login, models.MaxLoginLength) {
+		details = append(details, apperr.Field("login", fmt.Sprintf("must be at most %d characters", models.MaxLoginLength)))
+	}
+
+	if user.Roles == nil {
+		user.Roles = []string{}
+	}
+	for i, r := range user.Roles {
+		if !Role(r).Valid() {
+			details = append(details, apperr.Field(fmt.Sprintf("roles[%d]", i), fmt.Sprintf("unknown role %q", r)))
+		}
+	}
+
+	switch {
+	case user.OrganizationID == nil && !slices.Contains(user.Roles, string(RoleAdmin)):
+		details = append(details, apperr.Field("organization_id", "is required for users without the admin role"))
+	case user.OrganizationID != nil && *user.OrganizationID <= 0:
+		details = append(details, apperr.Field("organization_id", "must be positive"))
+	}
+
+	switch {
+	case password == "" && create:
+		details = append(details, apperr.Field("password", "is required"))
+	case password == "":
+	case len(password) < minPasswordLength || len(password) > maxPasswordLength:
+		details = append(details, apperr.Field("password",
+			fmt.Sprintf("must be between %d and %d bytes long", minPasswordLength, maxPasswordLength)))
+	}
+
+	if len(details) > 0 {
+		return ErrValidation.WithDetails(details...)
+	}
+	return nil
+}
+
+// userWriteError уточняет ошибки ограничений при записи пользователя
+func userWriteError(err error) error {
+	if errors.Is(err, repository.ErrConflict) {
+		return ErrDuplicateLogin.WithDetails(apperr.Field("login", "already exists")).Wrap(err)
+	}
+	return organizationRefError(err)
+}
@@ -0,0 +1,55 @@
+// Package tenant передает организацию (юридическое лицо), в рамках которой
+// выполняется запрос, от API до репозиториев. Все данные учета — клиенты,
+// товары, заказы и регистр — принадлежат одной организации, и репозитории
+// отбирают и создают записи только в организации из контекста.
+package tenant
+
+import (
+	"context"
+
+	"github.com/1C-Migration-Lab/OrderFlow/internal/apperr"
+)
+
+// Default — организация, которой принадлежат данные, созданные до разделения
+// на организации
+const Default int64 = 1
+
+// ErrMissing: организация не передана в контексте. Репозитории не выполняют
+// такие запросы, чтобы ошибка в цепочке вызовов не открыла данные всех организаций.
+var ErrMissing = apperr.New(apperr.KindInternal, "organization_missing", "organization is not set for the request")
+
+type contextKey struct{}
+
+// WithID возвращает контекст, в котором запросы выполняются в организации id
+func WithID(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext возвращает организацию из контекста
+func FromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(contextKey{}).(int64)
+	return id, ok && id != 0
+}
+
+// ID возвращает организацию из контекста или ErrMissing
+func ID(ctx context.Context) (int64, error) {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return 0, ErrMissing
+	}
+	return id, nil
+}
+
+type userKey struct{}
+
+// WithUser возвращает контекст с именем исполнителя запроса. Хранилище
+// передает его базе вместе с организацией (см. repository.NewDB).
+func WithUser(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, userKey{}, name)
+}
+
+// User возвращает имя исполнителя из контекста или пустую строку
+func User(ctx context.Context) string {
+	name, _ := ctx.Value(userKey{}).(string)
+	return name
+}
@@ -0,0 +1,318 @@
+// Package xlsx читает и записывает книги Office Open XML (.xlsx) без
+// сторонних зависимостей. Поддерживается то, что нужно для обмена табличными
+// данными: при чтении — значения ячеек первого листа (строки, числа и
+// логические значения; формулы не вычисляются, берется сохраненное значение,
+// стили и форматы чисел не учитываются), при записи — один лист со строками,
+// числами и датами, который формируется потоком.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxPartSize ограничивает распакованный размер части книги, чтобы
+// маленький архив не развернулся в гигабайты
+var maxPartSize int64 = 256 << 20
+
+var (
+	ErrNotWorkbook = errors.New("xlsx: file is not an xlsx workbook")
+	errPartTooBig  = errors.New("xlsx: workbook part is too large")
+)
+
+// ReadRows возвращает строки первого листа книги. Индекс строки совпадает с
+// номером строки листа минус один: пропущенные в файле строки возвращаются
+// пустыми. Пустые ячейки в конце строки не возвращаются.
+func ReadRows(r io.ReaderAt, size int64) ([][]string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, ErrNotWorkbook
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	if files["xl/workbook.xml"] == nil {
+		return nil, ErrNotWorkbook
+	}
+
+	sheet, err := firstSheet(files)
+	if err != nil {
+		return nil, err
+	}
+	var shared []string
+	if f := files["xl/sharedStrings.xml"]; f != nil {
+		if shared, err = readSharedStrings(f); err != nil {
+			return nil, err
+		}
+	}
+	f := files[sheet]
+	if f == nil {
+		return nil, fmt.Errorf("xlsx: worksheet %s is missing", sheet)
+	}
+	return readSheet(f, shared)
+}
+
+// firstSheet находит часть первого листа книги по workbook.xml и его связям
+func firstSheet(files map[string]*zip.File) (string, error) {
+	var wb struct {
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodePart(files["xl/workbook.xml"], &wb); err != nil {
+		return "", err
+	}
+
+	const fallback = "xl/worksheets/sheet1.xml"
+	rels := files["xl/_rels/workbook.xml.rels"]
+	if len(wb.Sheets) == 0 || rels == nil {
+		return fallback, nil
+	}
+	var rs struct {
+		Rels []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodePart(rels, &rs); err != nil {
+		return "", err
+	}
+	for _, rel := range rs.Rels {
+		if rel.ID != wb.Sheets[0].ID {
+			continue
+		}
+		// Цель связи указывается относительно xl/ или от корня архива
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return fallback, nil
+}
+
+func decodePart(f *zip.File, v interface{}) error {
+	rc, err := openPart(f)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("xlsx: %s: %w", f.Name, err)
+	}
+	return nil
+}
+
+type limitedPart struct {
+	io.ReadCloser
+	left int64
+}
+
+func (p *limitedPart) Read(b []byte) (int, error) {
+	if p.left <= 0 {
+		return 0, errPartTooBig
+	}
+	if int64(len(b)) > p.left {
+		b = b[:p.left]
+	}
+	n, err := p.ReadCloser.Read(b)
+	p.left -= int64(n)
+	return n, err
+}
+
+func openPart(f *zip.File) (io.ReadCloser, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("xlsx: %s: %w", f.Name, err)
+	}
+	return &limitedPart{ReadCloser: rc, left: maxPartSize}, nil
+}
+
+// readSharedStrings читает таблицу общих строк. Элемент может состоять из
+// нескольких фрагментов форматированного текста; фонетические подсказки
+// (rPh) в значение не входят.
+func readSharedStrings(f *zip.File) ([]string, error) {
+	rc, err := openPart(f)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var out []string
+	var b strings.Builder
+	depthRPh := 0
+	d := xml.NewDecoder(rc)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("xlsx: %s: %w", f.Name, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				b.Reset()
+			case "rPh":
+				depthRPh++
+			case "t":
+				if depthRPh > 0 {
+					continue
+				}
+				var s string
+				if err := d.DecodeElement(&s, &t); err != nil {
+					return nil, fmt.Errorf("xlsx: %s: %w", f.Name, err)
+				}
+				b.WriteString(s)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				out = append(out, b.String())
+			case "rPh":
+				depthRPh--
+			}
+		}
+	}
+}
+
+// cell — ячейка листа в том виде, в каком она хранится в sheetN.xml
+type cell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Value  string `xml:"v"`
+	Inline struct {
+		Text []string `xml:"t"`
+		Runs []struct {
+			Text string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"is"`
+}
+
+func readSheet(f *zip.File, shared []string) ([][]string, error) {
+	rc, err := openPart(f)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var rows [][]string
+	var row []string
+	rowNum := 0
+	d := xml.NewDecoder(rc)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("xlsx: %s: %w", f.Name, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				rowNum++
+				for _, a := range t.Attr {
+					if a.Name.Local == "r" {
+						if n, err := strconv.Atoi(a.Value); err == nil && n >= rowNum {
+							rowNum = n
+						}
+					}
+				}
+				row = nil
+			case "c":
+				var c cell
+				if err := d.DecodeElement(&c, &t); err != nil {
+					return nil, fmt.Errorf("xlsx: %s: %w", f.Name, err)
+				}
+				col := len(row)
+				if c.Ref != "" {
+					if col, err = columnIndex(c.Ref); err != nil {
+						return nil, err
+					}
+				}
+				for len(row) <= col {
+					row = append(row, "")
+				}
+				if row[col], err = c.value(shared); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "row" {
+				for len(rows) < rowNum-1 {
+					rows = append(rows, nil)
+				}
+				rows = append(rows, trimRow(row))
+			}
+		}
+	}
+}
+
+func (c *cell) value(shared []string) (string, error) {
+	switch c.Type {
+	case "s":
+		i, err := strconv.Atoi(strings.TrimSpace(c.Value))
+		if err != nil || i < 0 || i >= len(shared) {
+			return "", fmt.Errorf("xlsx: cell %s refers to a missing shared string", c.Ref)
+		}
+		return shared[i], nil
+	case "inlineStr":
+		s := strings.Join(c.Inline.Text, "")
+		for _, r := range c.Inline.Runs {
+			s += r.Text
+		}
+		return s, nil
+	case "b":
+		if c.Value == "1" {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case "", "n":
+		return formatNumber(c.Value), nil
+	}
+	// str (результат формулы), e (ошибка), d (дата ISO 8601) — как записано
+	return c.Value, nil
+}
+
+// formatNumber записывает число без экспоненты: Excel хранит 7707083893
+// как 7707083893, но большие и дробные значения — как 7.7070838930E9
+func formatNumber(v string) string {
+	if !strings.ContainsAny(v, "eE") {
+		return v
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// columnIndex возвращает номер колонки (с нуля) по адресу ячейки: "C7" → 2
+func columnIndex(ref string) (int, error) {
+	col := 0
+	i := 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		col = col*26 + int(ref[i]-'A'+1)
+	}
+	if i == 0 || col > 16384 {
+		return 0, fmt.Errorf("xlsx: invalid cell reference %q", ref)
+	}
+	return col - 1, nil
+}
+
+func trimRow(row []string) []string {
+	for len(row) > 0 && row[len(row)-1] == "" {
+		row = row[:len(row)-1]
+	}
+	return row
+}
@@ -0,0 +1,193 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testWorkbook = `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+ xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Data" sheetId="1" r:id="rId7"/><sheet name="Other" sheetId="2" r:id="rId8"/></sheets>
+</workbook>`
+	testWorkbookRels = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId8" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId7" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/data.xml"/>
+</Relationships>`
+	testSharedStrings = `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="3" uniqueCount="3">
+<si><t>Наименование</t></si>
+<si><r><t>ООО </t></r><r><rPr><b/></rPr><t>Ромашка</t></r><rPh sb="0" eb="3"><t>ロマシカ</t></rPh></si>
+<si><t xml:space="preserve"> ИНН </t></si>
+</sst>`
+)
+
+// testSheet оборачивает строки листа в sheetData
+func testSheet(rows string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+		rows + `</sheetData></worksheet>`
+}
+
+// testZip упаковывает части книги в архив
+func testZip(t *testing.T, parts map[string]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func readTestRows(t *testing.T, parts map[string]string) ([][]string, error) {
+	t.Helper()
+	r := testZip(t, parts)
+	return ReadRows(r, r.Size())
+}
+
+func TestReadRows(t *testing.T) {
+	sheet := testSheet(`
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>2</v></c><c r="C1" t="inlineStr"><is><t>Цена</t></is></c></row>
+<row r="2"><c r="A2" t="s"><v>1</v></c><c r="B2"><v>7707083893</v></c><c r="C2"><v>1.25E3</v></c></row>
+<row r="3"><c r="A3" t="inlineStr"><is><r><t>ИП </t></r><r><t>Иванов</t></r></is></c><c r="B3" t="b"><v>1</v></c><c r="C3" t="str"><v>=A1</v></c></row>`)
+
+	rows, err := readTestRows(t, map[string]string{
+		"[Content_Types].xml":        `<Types/>`,
+		"xl/workbook.xml":            testWorkbook,
+		"xl/_rels/workbook.xml.rels": testWorkbookRels,
+		"xl/sharedStrings.xml":       testSharedStrings,
+		"xl/worksheets/data.xml":     sheet,
+		"xl/worksheets/sheet1.xml":   testSheet(`<row r="1"><c r="A1" t="inlineStr"><is><t>second sheet</t></is></c></row>`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Наименование", " ИНН ", "Цена"},
+		{"ООО Ромашка", "7707083893", "1250"},
+		{"ИП Иванов", "TRUE", "=A1"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+}
+
+func TestReadRowsSparse(t *testing.T) {
+	tests := []struct {
+		name  string
+		sheet string
+		want  [][]string
+	}{
+		{"skipped rows", `<row r="2"><c r="A2"><v>1</v></c></row><row r="5"><c r="B5"><v>2</v></c></row>`,
+			[][]string{nil, {"1"}, nil, nil, {"", "2"}}},
+		{"skipped cells", `<row r="1"><c r="C1"><v>3</v></c><c r="AA1"><v>27</v></c></row>`,
+			[][]string{append(append([]string{"", "", "3"}, make([]string, 23)...), "27")}},
+		{"no references", `<row><c><v>1</v></c><c><v>2</v></c></row><row><c><v>3</v></c></row>`,
+			[][]string{{"1", "2"}, {"3"}}},
+		{"trailing empty cells", `<row r="1"><c r="A1"><v>1</v></c><c r="B1" t="inlineStr"><is><t></t></is></c><c r="C1"/></row>`,
+			[][]string{{"1"}}},
+	}
+	for _, tt := range tests {
+		rows, err := readTestRows(t, map[string]string{
+			"xl/workbook.xml":          `<workbook/>`,
+			"xl/worksheets/sheet1.xml": testSheet(tt.sheet),
+		})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(rows, tt.want) {
+			t.Errorf("%s: rows = %q, want %q", tt.name, rows, tt.want)
+		}
+	}
+}
+
+func TestReadRowsErrors(t *testing.T) {
+	workbook := func(sheet string) map[string]string {
+		return map[string]string{"xl/workbook.xml": `<workbook/>`, "xl/worksheets/sheet1.xml": testSheet(sheet)}
+	}
+
+	tests := []struct {
+		name  string
+		parts map[string]string
+		err   error
+		text  string
+	}{
+		{"zip without workbook", map[string]string{"word/document.xml": `<document/>`}, ErrNotWorkbook, ""},
+		{"missing worksheet", map[string]string{"xl/workbook.xml": `<workbook/>`}, nil, "worksheet xl/worksheets/sheet1.xml is missing"},
+		{"corrupt workbook xml", map[string]string{"xl/workbook.xml": `<workbook><sheets>`}, nil, "xl/workbook.xml"},
+		{"corrupt worksheet xml", workbook(`<row><c><v>1</c></row>`), nil, "xl/worksheets/sheet1.xml"},
+		{"missing shared string", workbook(`<row><c r="A1" t="s"><v>3</v></c></row>`), nil, "cell A1 refers to a missing shared string"},
+		{"invalid cell reference", workbook(`<row><c r="1A"><v>1</v></c></row>`), nil, `invalid cell reference "1A"`},
+		{"column out of range", workbook(`<row><c r="XFE1"><v>1</v></c></row>`), nil, `invalid cell reference "XFE1"`},
+	}
+	for _, tt := range tests {
+		_, err := readTestRows(t, tt.parts)
+		switch {
+		case err == nil:
+			t.Errorf("%s: no error", tt.name)
+		case tt.err != nil && !errors.Is(err, tt.err):
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err)
+		case !strings.Contains(err.Error(), tt.text):
+			t.Errorf("%s: err = %v, want it to mention %s", tt.name, err, tt.text)
+		}
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"csv":       []byte("name;inn\nООО Ромашка;7707083893\n"),
+		"truncated": truncated(t),
+	} {
+		if _, err := ReadRows(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrNotWorkbook) {
+			t.Errorf("%s: err = %v, want ErrNotWorkbook", name, err)
+		}
+	}
+}
+
+// truncated возвращает книгу без конца архива с центральным каталогом
+func truncated(t *testing.T) []byte {
+	t.Helper()
+	r := testZip(t, map[string]string{"xl/workbook.xml": `<workbook/>`, "xl/worksheets/sheet1.xml": testSheet("")})
+	data := make([]byte, r.Size()/2)
+	if _, err := r.ReadAt(data, 0); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestReadRowsPartSize(t *testing.T) {
+	defer func(size int64) { maxPartSize = size }(maxPartSize)
+	maxPartSize = 1 << 10
+
+	// Сжатая строка в несколько сотен байт распаковывается в килобайты
+	big := testSheet(`<row><c t="inlineStr"><is><t>` + strings.Repeat("x", 4<<10) + `</t></is></c></row>`)
+	for name, parts := range map[string]map[string]string{
+		"worksheet":      {"xl/workbook.xml": `<workbook/>`, "xl/worksheets/sheet1.xml": big},
+		"shared strings": {"xl/workbook.xml": `<workbook/>`, "xl/worksheets/sheet1.xml": testSheet(""), "xl/sharedStrings.xml": `<sst><si><t>` + strings.Repeat("x", 4<<10) + `</t></si></sst>`},
+		"workbook":       {"xl/workbook.xml": `<workbook>` + strings.Repeat(" ", 4<<10) + `</workbook>`},
+	} {
+		if _, err := readTestRows(t, parts); !errors.Is(err, errPartTooBig) {
+			t.Errorf("%s: err = %v, want errPartTooBig", name, err)
+		}
+	}
+
+	if _, err := readTestRows(t, map[string]string{"xl/workbook.xml": `<workbook/>`, "xl/worksheets/sheet1.xml": testSheet(`<row><c><v>1</v></c></row>`)}); err != nil {
+		t.Errorf("small workbook: %v", err)
+	}
+}
@@ -0,0 +1,270 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Вид значения ячейки
+const (
+	kindString = iota
+	kindNumber
+	kindDate
+)
+
+// Стили ячеек из styles.xml: индексы в cellXfs
+const (
+	styleDefault = iota
+	styleHeader
+	styleDecimal2
+	styleDecimal3
+	styleDateTime
+)
+
+// DateTimeLayout — представление даты и времени в тексте ячейки (Cell.Text)
+const DateTimeLayout = "2006-01-02 15:04:05"
+
+// Cell — значение ячейки для Writer
+type Cell struct {
+	kind  int
+	text  string
+	style int
+	time  time.Time
+}
+
+// String — текстовая ячейка
+func String(v string) Cell {
+	return Cell{kind: kindString, text: v}
+}
+
+// Number — числовая ячейка. v — десятичная запись числа, как ее выводят
+// money.Money и money.Quantity; places — знаков после запятой в формате
+// ячейки (2 или 3), при другом значении формат общий.
+func Number(v string, places int) Cell {
+	c := Cell{kind: kindNumber, text: v}
+	switch places {
+	case 2:
+		c.style = styleDecimal2
+	case 3:
+		c.style = styleDecimal3
+	}
+	return c
+}
+
+// Int — целочисленная ячейка
+func Int(v int64) Cell {
+	return Cell{kind: kindNumber, text: strconv.FormatInt(v, 10)}
+}
+
+// Date — ячейка с датой и временем. Excel не хранит часовой пояс, поэтому
+// записывается время t в его собственном поясе.
+func Date(t time.Time) Cell {
+	return Cell{kind: kindDate, text: t.Format(DateTimeLayout), style: styleDateTime, time: t}
+}
+
+// Text возвращает значение ячейки в текстовом виде, например для CSV
+func (c Cell) Text() string {
+	return c.text
+}
+
+// IsNumber сообщает, что ячейка числовая
+func (c Cell) IsNumber() bool {
+	return c.kind == kindNumber
+}
+
+// excelEpoch — нулевой день последовательной нумерации дат Excel (с учетом
+// ошибки 1900 года, для дат после 1 марта 1900)
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+func serial(t time.Time) string {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	days := wall.Sub(excelEpoch).Hours() / 24
+	return strconv.FormatFloat(days, 'f', -1, 64)
+}
+
+var errClosed = errors.New("xlsx: writer is closed")
+
+// Writer записывает книгу из одного листа построчно. Строки сразу сжимаются
+// в выходной поток, поэтому память не зависит от числа строк: книгу можно
+// отдавать в ответ HTTP по мере чтения выборки из базы. Строки записываются
+// как встроенные (inlineStr), без таблицы общих строк, которую пришлось бы
+// копить до конца. Первая строка листа закреплена: в ней ожидаются
+// заголовки колонок (WriteHeader).
+type Writer struct {
+	zw     *zip.Writer
+	sheet  *bufio.Writer
+	row    int
+	closed bool
+}
+
+// NewWriter начинает книгу с листом sheetName и записывает служебные части
+// архива. Закрыть книгу нужно методом Close; w он не закрывает.
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", contentTypes},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", workbookStart + escape(sheetTitle(sheetName)) + workbookEnd},
+		{"xl/_rels/workbook.xml.rels", workbookRels},
+		{"xl/styles.xml", styles},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, p.body); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	wr := &Writer{zw: zw, sheet: bufio.NewWriter(f)}
+	if _, err := wr.sheet.WriteString(sheetStart); err != nil {
+		return nil, err
+	}
+	return wr, nil
+}
+
+// WriteHeader записывает строку заголовков колонок полужирным шрифтом
+func (w *Writer) WriteHeader(titles []string) error {
+	cells := make([]Cell, len(titles))
+	for i, t := range titles {
+		cells[i] = Cell{kind: kindString, text: t, style: styleHeader}
+	}
+	return w.WriteRow(cells)
+}
+
+// WriteRow записывает следующую строку листа
+func (w *Writer) WriteRow(cells []Cell) error {
+	if w.closed {
+		return errClosed
+	}
+	w.row++
+	rowNum := strconv.Itoa(w.row)
+
+	b := w.sheet
+	b.WriteString(`<row r="` + rowNum + `">`)
+	for i, c := range cells {
+		ref := columnName(i) + rowNum
+		b.WriteString(`<c r="` + ref + `"`)
+		if c.style != styleDefault {
+			b.WriteString(` s="` + strconv.Itoa(c.style) + `"`)
+		}
+		switch c.kind {
+		case kindNumber:
+			b.WriteString(`><v>` + escape(c.text) + `</v></c>`)
+		case kindDate:
+			b.WriteString(`><v>` + serial(c.time) + `</v></c>`)
+		default:
+			b.WriteString(` t="inlineStr"><is><t xml:space="preserve">` + escape(c.text) + `</t></is></c>`)
+		}
+	}
+	_, err := b.WriteString(`</row>`)
+	return err
+}
+
+// Close завершает лист и архив
+func (w *Writer) Close() error {
+	if w.closed {
+		return errClosed
+	}
+	w.closed = true
+	if _, err := w.sheet.WriteString(sheetEnd); err != nil {
+		return err
+	}
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+// columnName возвращает буквенное имя колонки по номеру с нуля: 27 → "AB"
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// sheetTitle приводит имя листа к ограничениям Excel: до 31 символа,
+// без символов []:*?/\
+func sheetTitle(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	if name == "" {
+		name = "Sheet1"
+	}
+	return name
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+const contentTypes = xmlHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const rootRels = xmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const workbookStart = xmlHeader + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`
+
+const workbookEnd = `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const workbookRels = xmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+// styles задает стили cellXfs в порядке констант style*: обычный,
+// заголовок, числа с 2 и 3 знаками после запятой, дата и время
+const styles = xmlHeader + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="2"><numFmt numFmtId="164" formatCode="0.000"/><numFmt numFmtId="165" formatCode="yyyy\-mm\-dd\ hh:mm:ss"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="5">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="2" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+const sheetStart = xmlHeader + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+	`<sheetData>`
+
+const sheetEnd = `</sheetData></worksheet>`
@@ -0,0 +1,100 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, `Заказы & <остатки>: 2024/03`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]Cell{
+		{String(`<b>"ООО" & 'Ромашка'</b>`), String("  пробелы по краям  "), String("строка 1\nстрока 2")},
+		{String("日本語 ✓ 😀"), Number("1250.50", 2), Number("-2.500", 3), Int(7707083893)},
+		{},
+		{Date(time.Date(2024, 3, 1, 12, 0, 0, 0, time.FixedZone("MSK", 3*3600))), String(""), String("]]>")},
+	}
+	if err := w.WriteHeader([]string{"Наименование", "Сумма, ₽", "Кол-во"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow(rows[0]); err != errClosed {
+		t.Errorf("WriteRow after Close: err = %v, want errClosed", err)
+	}
+
+	got, err := ReadRows(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Наименование", "Сумма, ₽", "Кол-во"},
+		{`<b>"ООО" & 'Ромашка'</b>`, "  пробелы по краям  ", "строка 1\nстрока 2"},
+		{"日本語 ✓ 😀", "1250.50", "-2.500", "7707083893"},
+		nil,
+		// 1 марта 2024 — день 45352 от эпохи Excel; время берется без пояса
+		{"45352.5", "", "]]>"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+
+	// Имя листа приводится к ограничениям Excel и экранируется
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if f.Name != "xl/workbook.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		if !strings.Contains(string(data), `name="Заказы &amp; &lt;остатки&gt;_ 2024_03"`) {
+			t.Errorf("workbook.xml = %s, want escaped sheet name", data)
+		}
+	}
+}
+
+func TestSheetTitle(t *testing.T) {
+	tests := map[string]string{
+		"":                      "Sheet1",
+		"orders":                "orders",
+		`a[b]c:d*e?f/g\h`:       "a_b_c_d_e_f_g_h",
+		strings.Repeat("я", 40): strings.Repeat("я", 31),
+	}
+	for in, want := range tests {
+		if got := sheetTitle(in); got != want {
+			t.Errorf("sheetTitle(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA", 16383: "XFD"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %s, want %s", i, got, want)
+		}
+		if back, err := columnIndex(want + "1"); err != nil || back != i {
+			t.Errorf("columnIndex(%s1) = %d, %v; want %d", want, back, err, i)
+		}
+	}
+}
@@ -3,4 +3,5 @@ DROP TABLE IF EXISTS order_items;
 DROP TABLE IF EXISTS orders;
 DROP TABLE IF EXISTS products;
 DROP TABLE IF EXISTS clients;
-DROP FUNCTION IF EXISTS calculate_line_amount(); 
\ No newline at end of file
+DROP FUNCTION IF EXISTS update_order_total();
+DROP FUNCTION IF EXISTS calculate_line_amount();
@@ -0,0 +1,12 @@
+CREATE TABLE orders_by_client (
+    client_id INTEGER PRIMARY KEY REFERENCES clients(id),
+    orders_sum DECIMAL(15,2) NOT NULL DEFAULT 0
+);
+
+INSERT INTO orders_by_client (client_id, orders_sum)
+SELECT client_id, SUM(amount)
+FROM orders_by_client_totals
+GROUP BY client_id;
+
+DROP TABLE IF EXISTS orders_by_client_totals;
+DROP TABLE IF EXISTS orders_by_client_movements;
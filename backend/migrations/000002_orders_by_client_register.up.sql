@@ -0,0 +1,33 @@
+-- Movements of the "ЗаказыПоКонтрагентам" accumulation register
+CREATE TABLE orders_by_client_movements (
+    recorder_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    period TIMESTAMP NOT NULL,
+    line_number INTEGER NOT NULL,
+    record_kind VARCHAR(10) NOT NULL CHECK (record_kind IN ('receipt', 'expense')),
+    client_id INTEGER NOT NULL REFERENCES clients(id),
+    amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (recorder_id, line_number)
+);
+
+CREATE INDEX idx_orders_by_client_movements_period ON orders_by_client_movements (period, client_id);
+
+-- Monthly totals (net turnover per client) of the register
+CREATE TABLE orders_by_client_totals (
+    period DATE NOT NULL,
+    client_id INTEGER NOT NULL REFERENCES clients(id),
+    amount DECIMAL(15,2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (period, client_id)
+);
+
+-- Every confirmed order becomes a receipt movement
+INSERT INTO orders_by_client_movements (recorder_id, period, line_number, record_kind, client_id, amount)
+SELECT id, date, 1, 'receipt', client_id, total_amount
+FROM orders
+WHERE is_confirmed;
+
+INSERT INTO orders_by_client_totals (period, client_id, amount)
+SELECT date_trunc('month', period)::date, client_id, SUM(amount)
+FROM orders_by_client_movements
+GROUP BY date_trunc('month', period)::date, client_id;
+
+DROP TABLE orders_by_client;
@@ -0,0 +1,9 @@
+-- Posting history of customer orders (Проведение / Отмена проведения)
+CREATE TABLE order_history (
+    id SERIAL PRIMARY KEY,
+    order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    action VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_order_history_order_id ON order_history (order_id);
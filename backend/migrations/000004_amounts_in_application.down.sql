@@ -0,0 +1,31 @@
+CREATE OR REPLACE FUNCTION calculate_line_amount()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.line_amount = NEW.quantity * NEW.price;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION update_order_total()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE orders
+    SET total_amount = (
+        SELECT COALESCE(SUM(line_amount), 0)
+        FROM order_items
+        WHERE order_id = NEW.order_id
+    )
+    WHERE id = NEW.order_id;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER calc_line_amount
+    BEFORE INSERT OR UPDATE ON order_items
+    FOR EACH ROW
+    EXECUTE FUNCTION calculate_line_amount();
+
+CREATE TRIGGER update_order_total
+    AFTER INSERT OR UPDATE OR DELETE ON order_items
+    FOR EACH ROW
+    EXECUTE FUNCTION update_order_total();
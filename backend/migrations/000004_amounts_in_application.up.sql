@@ -0,0 +1,7 @@
+-- Line and order amounts are calculated by the application with the configured
+-- rounding mode (see money.Quantity.Mul), so the database triggers are dropped
+-- to keep every storage backend consistent to the kopeck.
+DROP TRIGGER IF EXISTS calc_line_amount ON order_items;
+DROP TRIGGER IF EXISTS update_order_total ON order_items;
+DROP FUNCTION IF EXISTS calculate_line_amount();
+DROP FUNCTION IF EXISTS update_order_total();
@@ -0,0 +1,8 @@
+-- Counters of automatic document numbering, one row per document and period
+-- (see internal/numbering). "never" reset uses the 0001-01-01 period.
+CREATE TABLE number_sequences (
+    document VARCHAR(50) NOT NULL,
+    period DATE NOT NULL,
+    last_value BIGINT NOT NULL,
+    PRIMARY KEY (document, period)
+);
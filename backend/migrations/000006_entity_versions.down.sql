@@ -0,0 +1,3 @@
+ALTER TABLE orders DROP COLUMN version;
+ALTER TABLE products DROP COLUMN version;
+ALTER TABLE clients DROP COLUMN version;
@@ -0,0 +1,5 @@
+-- Row versions for optimistic concurrency control: every change increments
+-- version, and clients send the version they read back in If-Match.
+ALTER TABLE clients ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE products ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE orders ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
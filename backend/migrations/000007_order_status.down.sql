@@ -0,0 +1,11 @@
+-- Orders past confirmation are treated as confirmed; cancelled orders become
+-- drafts, but their reversal movements stay in the register.
+ALTER TABLE order_history
+    DROP COLUMN actor,
+    DROP COLUMN to_status,
+    DROP COLUMN from_status;
+
+ALTER TABLE orders ADD COLUMN is_confirmed BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE orders SET is_confirmed = status IN ('confirmed', 'in_fulfilment', 'shipped', 'closed');
+DROP INDEX idx_orders_status;
+ALTER TABLE orders DROP COLUMN status;
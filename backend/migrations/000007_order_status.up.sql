@@ -0,0 +1,16 @@
+-- Order status workflow replaces the is_confirmed flag:
+-- draft -> confirmed -> in_fulfilment -> shipped -> closed, plus cancelled.
+ALTER TABLE orders ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'draft'
+    CHECK (status IN ('draft', 'confirmed', 'in_fulfilment', 'shipped', 'closed', 'cancelled'));
+UPDATE orders SET status = 'confirmed' WHERE is_confirmed;
+ALTER TABLE orders DROP COLUMN is_confirmed;
+
+CREATE INDEX idx_orders_status ON orders (status);
+
+-- Every status transition is recorded with its source, target and actor
+ALTER TABLE order_history
+    ADD COLUMN from_status VARCHAR(20) NOT NULL DEFAULT '',
+    ADD COLUMN to_status VARCHAR(20) NOT NULL DEFAULT '',
+    ADD COLUMN actor VARCHAR(100) NOT NULL DEFAULT '';
+UPDATE order_history SET from_status = 'draft', to_status = 'confirmed' WHERE action = 'confirm';
+UPDATE order_history SET from_status = 'confirmed', to_status = 'draft' WHERE action = 'unconfirm';
@@ -0,0 +1,13 @@
+-- API users. Passwords are stored as bcrypt hashes; roles are checked by the
+-- service layer (admin, manager, head_of_sales, accountant, warehouse)
+CREATE TABLE users (
+    id BIGSERIAL PRIMARY KEY,
+    login VARCHAR(100) NOT NULL,
+    password_hash VARCHAR(100) NOT NULL,
+    roles TEXT[] NOT NULL DEFAULT '{}',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    version BIGINT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_users_login ON users (lower(login));
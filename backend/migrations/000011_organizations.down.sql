@@ -0,0 +1,49 @@
+DROP INDEX IF EXISTS idx_audit_log_organization;
+
+ALTER TABLE number_sequences
+    DROP CONSTRAINT number_sequences_pkey,
+    ADD PRIMARY KEY (document, period);
+
+ALTER TABLE orders
+    DROP CONSTRAINT uq_orders_number,
+    ADD CONSTRAINT orders_number_key UNIQUE (number);
+
+ALTER TABLE orders_by_client_totals
+    DROP CONSTRAINT fk_totals_client,
+    ADD CONSTRAINT orders_by_client_totals_client_id_fkey FOREIGN KEY (client_id) REFERENCES clients(id);
+
+ALTER TABLE orders_by_client_movements
+    DROP CONSTRAINT fk_movements_recorder,
+    DROP CONSTRAINT fk_movements_client,
+    ADD CONSTRAINT orders_by_client_movements_recorder_id_fkey FOREIGN KEY (recorder_id)
+        REFERENCES orders(id) ON DELETE CASCADE,
+    ADD CONSTRAINT orders_by_client_movements_client_id_fkey FOREIGN KEY (client_id) REFERENCES clients(id);
+
+ALTER TABLE order_history
+    DROP CONSTRAINT fk_order_history_order,
+    ADD CONSTRAINT order_history_order_id_fkey FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE;
+
+ALTER TABLE order_items
+    DROP CONSTRAINT fk_order,
+    DROP CONSTRAINT fk_product,
+    ADD CONSTRAINT fk_order FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+    ADD CONSTRAINT fk_product FOREIGN KEY (product_id) REFERENCES products(id);
+
+ALTER TABLE orders
+    DROP CONSTRAINT fk_client,
+    ADD CONSTRAINT fk_client FOREIGN KEY (client_id) REFERENCES clients(id),
+    ADD CONSTRAINT orders_client_id_fkey FOREIGN KEY (client_id) REFERENCES clients(id);
+
+ALTER TABLE users DROP COLUMN organization_id;
+ALTER TABLE api_keys DROP COLUMN organization_id;
+ALTER TABLE audit_log DROP COLUMN organization_id;
+ALTER TABLE number_sequences DROP COLUMN organization_id;
+ALTER TABLE orders_by_client_totals DROP COLUMN organization_id;
+ALTER TABLE orders_by_client_movements DROP COLUMN organization_id;
+ALTER TABLE order_history DROP COLUMN organization_id;
+ALTER TABLE order_items DROP COLUMN organization_id;
+ALTER TABLE orders DROP COLUMN organization_id;
+ALTER TABLE products DROP COLUMN organization_id;
+ALTER TABLE clients DROP COLUMN organization_id;
+
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,88 @@
+-- Organizations (legal entities) served by one deployment. Every accounting
+-- record belongs to exactly one organization; existing data moves to the
+-- default organization 1.
+CREATE TABLE organizations (
+    id BIGSERIAL PRIMARY KEY,
+    code VARCHAR(50) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_organizations_code ON organizations (lower(code));
+
+INSERT INTO organizations (id, code, name) VALUES (1, 'default', 'Default organization');
+SELECT setval(pg_get_serial_sequence('organizations', 'id'), 1);
+
+-- The default only backfills existing rows; new rows must name their organization
+ALTER TABLE clients ADD COLUMN organization_id BIGINT NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE products ADD COLUMN organization_id BIGINT NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE orders ADD COLUMN organization_id BIGINT NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE order_items ADD COLUMN organization_id BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE order_history ADD COLUMN organization_id BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE orders_by_client_movements ADD COLUMN organization_id BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE orders_by_client_totals ADD COLUMN organization_id BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE number_sequences ADD COLUMN organization_id BIGINT NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE audit_log ADD COLUMN organization_id BIGINT NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE api_keys ADD COLUMN organization_id BIGINT NOT NULL DEFAULT 1 REFERENCES organizations(id);
+-- Users without an organization (administrators) may work in any of them
+ALTER TABLE users ADD COLUMN organization_id BIGINT REFERENCES organizations(id);
+
+ALTER TABLE clients ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE products ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE orders ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE order_items ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE order_history ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE orders_by_client_movements ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE orders_by_client_totals ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE number_sequences ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE audit_log ALTER COLUMN organization_id DROP DEFAULT;
+ALTER TABLE api_keys ALTER COLUMN organization_id DROP DEFAULT;
+
+-- References carry the organization, so a record can only point to records
+-- of its own organization
+ALTER TABLE clients ADD CONSTRAINT uq_clients_organization UNIQUE (organization_id, id);
+ALTER TABLE products ADD CONSTRAINT uq_products_organization UNIQUE (organization_id, id);
+ALTER TABLE orders ADD CONSTRAINT uq_orders_organization UNIQUE (organization_id, id);
+
+ALTER TABLE orders
+    DROP CONSTRAINT fk_client,
+    DROP CONSTRAINT orders_client_id_fkey,
+    ADD CONSTRAINT fk_client FOREIGN KEY (organization_id, client_id)
+        REFERENCES clients (organization_id, id);
+
+ALTER TABLE order_items
+    DROP CONSTRAINT fk_order,
+    DROP CONSTRAINT fk_product,
+    ADD CONSTRAINT fk_order FOREIGN KEY (organization_id, order_id)
+        REFERENCES orders (organization_id, id) ON DELETE CASCADE,
+    ADD CONSTRAINT fk_product FOREIGN KEY (organization_id, product_id)
+        REFERENCES products (organization_id, id);
+
+ALTER TABLE order_history
+    DROP CONSTRAINT order_history_order_id_fkey,
+    ADD CONSTRAINT fk_order_history_order FOREIGN KEY (organization_id, order_id)
+        REFERENCES orders (organization_id, id) ON DELETE CASCADE;
+
+ALTER TABLE orders_by_client_movements
+    DROP CONSTRAINT orders_by_client_movements_recorder_id_fkey,
+    DROP CONSTRAINT orders_by_client_movements_client_id_fkey,
+    ADD CONSTRAINT fk_movements_recorder FOREIGN KEY (organization_id, recorder_id)
+        REFERENCES orders (organization_id, id) ON DELETE CASCADE,
+    ADD CONSTRAINT fk_movements_client FOREIGN KEY (organization_id, client_id)
+        REFERENCES clients (organization_id, id);
+
+ALTER TABLE orders_by_client_totals
+    DROP CONSTRAINT orders_by_client_totals_client_id_fkey,
+    ADD CONSTRAINT fk_totals_client FOREIGN KEY (organization_id, client_id)
+        REFERENCES clients (organization_id, id);
+
+-- Order numbers and their counters are kept per organization
+ALTER TABLE orders
+    DROP CONSTRAINT orders_number_key,
+    ADD CONSTRAINT uq_orders_number UNIQUE (organization_id, number);
+
+ALTER TABLE number_sequences
+    DROP CONSTRAINT number_sequences_pkey,
+    ADD PRIMARY KEY (organization_id, document, period);
+
+CREATE INDEX idx_audit_log_organization ON audit_log (organization_id, entity, entity_id, id);
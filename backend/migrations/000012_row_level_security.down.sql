@@ -0,0 +1,37 @@
+DROP POLICY IF EXISTS tenant_isolation ON audit_log;
+ALTER TABLE audit_log NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE audit_log DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON number_sequences;
+ALTER TABLE number_sequences NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE number_sequences DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON orders_by_client_totals;
+ALTER TABLE orders_by_client_totals NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE orders_by_client_totals DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON orders_by_client_movements;
+ALTER TABLE orders_by_client_movements NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE orders_by_client_movements DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON order_history;
+ALTER TABLE order_history NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE order_history DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON order_items;
+ALTER TABLE order_items NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE order_items DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON orders;
+ALTER TABLE orders NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE orders DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON products;
+ALTER TABLE products NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE products DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON clients;
+ALTER TABLE clients NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE clients DISABLE ROW LEVEL SECURITY;
+
+DROP FUNCTION IF EXISTS orderflow_organization_id();
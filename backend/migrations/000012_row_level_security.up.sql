@@ -0,0 +1,59 @@
+-- Row-level security as a second line of defence behind the application's
+-- organization filter. The server sets orderflow.organization_id (and
+-- orderflow.user) on every connection before each statement; a row of an
+-- accounting table is visible and writable only when it belongs to that
+-- organization. Without the setting no accounting rows are visible at all.
+--
+-- Policies are FORCEd so that they apply to the table owner too. Superusers
+-- and roles with BYPASSRLS still bypass them: the server must connect as an
+-- ordinary role (see TestRLSIsolation in internal/repository).
+
+-- Organization of the current statement, NULL when it is not set
+CREATE FUNCTION orderflow_organization_id() RETURNS BIGINT
+    LANGUAGE sql STABLE
+    AS $$ SELECT NULLIF(current_setting('orderflow.organization_id', true), '')::bigint $$;
+
+ALTER TABLE clients ENABLE ROW LEVEL SECURITY;
+ALTER TABLE clients FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON clients
+    USING (organization_id = orderflow_organization_id());
+
+ALTER TABLE products ENABLE ROW LEVEL SECURITY;
+ALTER TABLE products FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON products
+    USING (organization_id = orderflow_organization_id());
+
+ALTER TABLE orders ENABLE ROW LEVEL SECURITY;
+ALTER TABLE orders FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON orders
+    USING (organization_id = orderflow_organization_id());
+
+ALTER TABLE order_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE order_items FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON order_items
+    USING (organization_id = orderflow_organization_id());
+
+ALTER TABLE order_history ENABLE ROW LEVEL SECURITY;
+ALTER TABLE order_history FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON order_history
+    USING (organization_id = orderflow_organization_id());
+
+ALTER TABLE orders_by_client_movements ENABLE ROW LEVEL SECURITY;
+ALTER TABLE orders_by_client_movements FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON orders_by_client_movements
+    USING (organization_id = orderflow_organization_id());
+
+ALTER TABLE orders_by_client_totals ENABLE ROW LEVEL SECURITY;
+ALTER TABLE orders_by_client_totals FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON orders_by_client_totals
+    USING (organization_id = orderflow_organization_id());
+
+ALTER TABLE number_sequences ENABLE ROW LEVEL SECURITY;
+ALTER TABLE number_sequences FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON number_sequences
+    USING (organization_id = orderflow_organization_id());
+
+ALTER TABLE audit_log ENABLE ROW LEVEL SECURITY;
+ALTER TABLE audit_log FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON audit_log
+    USING (organization_id = orderflow_organization_id());
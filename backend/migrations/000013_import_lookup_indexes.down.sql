@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_products_name_unit;
+DROP INDEX IF EXISTS idx_clients_inn;
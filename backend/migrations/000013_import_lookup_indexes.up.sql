@@ -0,0 +1,3 @@
+-- Bulk import matches existing clients by INN and products by name and unit
+CREATE INDEX idx_clients_inn ON clients (organization_id, inn);
+CREATE INDEX idx_products_name_unit ON products (organization_id, name, unit);
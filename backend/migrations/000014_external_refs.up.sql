@@ -0,0 +1,22 @@
+-- Identifiers of accounting records in external systems, e.g. 1C reference
+-- GUIDs received in CommerceML exchange. An external identifier points to
+-- one record and a record has at most one identifier in each system.
+-- entity_id is not a foreign key: one table serves clients, products and
+-- orders, and a reference left behind by a deleted record is replaced on
+-- the next exchange.
+CREATE TABLE external_refs (
+    organization_id BIGINT NOT NULL REFERENCES organizations(id),
+    entity VARCHAR(50) NOT NULL,
+    system VARCHAR(50) NOT NULL,
+    external_id VARCHAR(100) NOT NULL,
+    entity_id BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, entity, system, external_id)
+);
+
+CREATE UNIQUE INDEX idx_external_refs_entity ON external_refs (organization_id, entity, system, entity_id);
+
+ALTER TABLE external_refs ENABLE ROW LEVEL SECURITY;
+ALTER TABLE external_refs FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON external_refs
+    USING (organization_id = orderflow_organization_id());
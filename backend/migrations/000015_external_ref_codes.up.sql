@@ -0,0 +1,4 @@
+-- Code or number of the record in the external system, e.g. the code of a
+-- 1C catalog item or the number of a 1C document. Informational only:
+-- records are matched by external_id.
+ALTER TABLE external_refs ADD COLUMN code VARCHAR(50) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE users DROP CONSTRAINT chk_users_organization;
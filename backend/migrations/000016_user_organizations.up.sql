@@ -0,0 +1,8 @@
+-- Only deployment administrators may work without an organization: they
+-- choose one per request. Other users created before organizations existed
+-- move to the default organization 1, as their data did in 000011.
+UPDATE users SET organization_id = 1
+WHERE organization_id IS NULL AND NOT ('admin' = ANY (roles));
+
+ALTER TABLE users ADD CONSTRAINT chk_users_organization
+    CHECK (organization_id IS NOT NULL OR 'admin' = ANY (roles));
@@ -0,0 +1 @@
+ALTER TABLE external_refs DROP COLUMN exported_version;
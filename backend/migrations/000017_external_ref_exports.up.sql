@@ -0,0 +1,4 @@
+-- Version of the record that the external system confirmed receiving, e.g.
+-- the order version 1C acknowledged with mode=success of the CommerceML
+-- exchange. Orders changed after that version are exported again.
+ALTER TABLE external_refs ADD COLUMN exported_version BIGINT NOT NULL DEFAULT 0;
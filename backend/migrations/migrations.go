@@ -0,0 +1,8 @@
+// Package migrations встраивает SQL-миграции схемы Postgres в бинарный файл.
+// Файлы именуются NNNNNN_описание.up.sql и NNNNNN_описание.down.sql.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
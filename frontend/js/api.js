@@ -2,20 +2,42 @@
 const API = {
     baseUrl: 'http://localhost:8080/api',
 
-    // Generic request method
-    async request(endpoint, options = {}) {
+    // Tokens from POST /auth/login; unused when the server runs without auth
+    tokens: JSON.parse(localStorage.getItem('orderflow.tokens') || 'null'),
+
+    setTokens(tokens) {
+        this.tokens = tokens;
+        if (tokens) {
+            localStorage.setItem('orderflow.tokens', JSON.stringify(tokens));
+        } else {
+            localStorage.removeItem('orderflow.tokens');
+        }
+    },
+
+    // Generic request method. An expired access token is renewed once
+    // with the refresh token and the request is repeated.
+    async request(endpoint, options = {}, retry = true) {
         try {
             const response = await fetch(`${this.baseUrl}${endpoint}`, {
                 ...options,
                 headers: {
                     'Content-Type': 'application/json',
+                    ...(this.tokens ? { 'Authorization': `Bearer ${this.tokens.access_token}` } : {}),
                     ...options.headers,
                 },
             });
 
+            if (response.status === 401 && retry && this.tokens && await this.refresh()) {
+                return this.request(endpoint, options, false);
+            }
+
             if (!response.ok) {
                 const error = await response.json().catch(() => ({}));
-                throw new Error(error.error || `HTTP error! status: ${response.status}`);
+                const err = new Error(error.message || `HTTP error! status: ${response.status}`);
+                err.status = response.status;
+                // On 412 the server returns the record as it is now
+                err.current = error.current;
+                throw err;
             }
 
             // Для DELETE запросов может не быть тела ответа
@@ -30,9 +52,57 @@ const API = {
         }
     },
 
+    // Builds a query string from list parameters (limit, cursor, sort, filters)
+    query(params = {}) {
+        const search = new URLSearchParams();
+        Object.entries(params).forEach(([key, value]) => {
+            if (value !== undefined && value !== null && value !== '') {
+                search.append(key, value);
+            }
+        });
+        const str = search.toString();
+        return str ? `?${str}` : '';
+    },
+
+    // Headers for PUT: records carry a version, and the server rejects the
+    // update with 412 if someone else changed the record in the meantime
+    ifMatch(record) {
+        return record && record.version ? { 'If-Match': `"${record.version}"` } : {};
+    },
+
+    // Auth
+    async login(login, password) {
+        this.setTokens(null);
+        const tokens = await this.request('/auth/login', {
+            method: 'POST',
+            body: JSON.stringify({ login, password }),
+        });
+        this.setTokens(tokens);
+        return tokens;
+    },
+
+    async refresh() {
+        try {
+            const response = await fetch(`${this.baseUrl}/auth/refresh`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ refresh_token: this.tokens.refresh_token }),
+            });
+            this.setTokens(response.ok ? await response.json() : null);
+        } catch (error) {
+            this.setTokens(null);
+        }
+        return this.tokens !== null;
+    },
+
+    logout() {
+        this.setTokens(null);
+    },
+
     // Clients
-    async getClients() {
-        return this.request('/clients');
+    // List endpoints return a page: { items, total, next_cursor }
+    async getClients(params) {
+        return this.request('/clients' + this.query(params));
     },
 
     async createClient(client) {
@@ -45,6 +115,7 @@ const API = {
     async updateClient(id, client) {
         return this.request(`/clients/${id}`, {
             method: 'PUT',
+            headers: this.ifMatch(client),
             body: JSON.stringify(client),
         });
     },
@@ -56,8 +127,8 @@ const API = {
     },
 
     // Products
-    async getProducts() {
-        return this.request('/products');
+    async getProducts(params) {
+        return this.request('/products' + this.query(params));
     },
 
     async createProduct(product) {
@@ -70,6 +141,7 @@ const API = {
     async updateProduct(id, product) {
         return this.request(`/products/${id}`, {
             method: 'PUT',
+            headers: this.ifMatch(product),
             body: JSON.stringify(product),
         });
     },
@@ -81,8 +153,8 @@ const API = {
     },
 
     // Orders
-    async getOrders() {
-        return this.request('/orders');
+    async getOrders(params) {
+        return this.request('/orders' + this.query(params));
     },
 
     async createOrder(order) {
@@ -95,6 +167,7 @@ const API = {
     async updateOrder(id, order) {
         return this.request(`/orders/${id}`, {
             method: 'PUT',
+            headers: this.ifMatch(order),
             body: JSON.stringify(order),
         });
     },
@@ -105,12 +178,18 @@ const API = {
         });
     },
 
-    async confirmOrder(id) {
-        return this.request(`/orders/${id}/confirm`, {
+    // Moves an order along its status workflow (confirm, ship, cancel, ...)
+    async transitionOrder(id, transition) {
+        return this.request(`/orders/${id}/transitions`, {
             method: 'POST',
+            body: JSON.stringify({ transition }),
         });
     },
 
+    async getOrderTransitions(id) {
+        return this.request(`/orders/${id}/transitions`);
+    },
+
     // Orders by client
     async getOrdersByClient() {
         return this.request('/orders-by-client');
@@ -5,13 +5,43 @@ document.addEventListener('DOMContentLoaded', () => {
         clients: [],
         products: [],
         orders: [],
+        ordersTotal: 0,
+        ordersCursor: null,
         ordersByClient: [],
         currentSection: 'clients'
     };
 
+    // Order status workflow, mirrors service.NewOrderWorkflow on the backend.
+    // The server still checks the user's roles for every transition.
+    const statusLabels = {
+        draft: 'Draft',
+        confirmed: 'Confirmed',
+        in_fulfilment: 'In fulfilment',
+        shipped: 'Shipped',
+        closed: 'Closed',
+        cancelled: 'Cancelled',
+    };
+    const transitionLabels = {
+        confirm: 'Confirm',
+        unconfirm: 'Unconfirm',
+        start_fulfilment: 'Start fulfilment',
+        ship: 'Ship',
+        close: 'Close',
+        cancel: 'Cancel',
+    };
+    const orderTransitions = {
+        draft: ['confirm', 'cancel'],
+        confirmed: ['unconfirm', 'start_fulfilment', 'cancel'],
+        in_fulfilment: ['ship', 'cancel'],
+        shipped: ['close'],
+    };
+
     // Initialize application
     const app = {
         async init() {
+            if (API.tokens) {
+                document.getElementById('logout-button').style.display = '';
+            }
             await this.loadInitialData();
             this.setupEventListeners();
             this.showSection(state.currentSection);
@@ -20,27 +50,59 @@ document.addEventListener('DOMContentLoaded', () => {
         async loadInitialData() {
             try {
                 const [clients, products, orders, ordersByClient] = await Promise.all([
-                    API.getClients(),
-                    API.getProducts(),
+                    // Clients and products feed the order form selects, so load them in full
+                    API.getClients({ limit: 500 }),
+                    API.getProducts({ limit: 500 }),
                     API.getOrders(),
                     API.getOrdersByClient()
                 ]);
 
-                state.clients = clients || [];
-                state.products = products || [];
-                state.orders = orders || [];
+                state.clients = clients?.items || [];
+                state.products = products?.items || [];
+                state.orders = orders?.items || [];
+                state.ordersTotal = orders?.total || 0;
+                state.ordersCursor = orders?.next_cursor || null;
                 state.ordersByClient = ordersByClient || [];
 
                 this.renderAll();
                 this.renderClientSelect();
             } catch (error) {
                 console.error('Failed to load data:', error);
+                // The server requires a login (auth is enabled)
+                if (error.status === 401) {
+                    this.showModal('login-modal');
+                    return;
+                }
                 alert('Failed to load data. Please try again later.');
             }
         },
 
+        async handleLoginSubmit(event) {
+            event.preventDefault();
+            const form = event.target;
+            try {
+                await API.login(form.login.value, form.password.value);
+                this.hideModal('login-modal');
+                document.getElementById('logout-button').style.display = '';
+                await this.loadInitialData();
+            } catch (error) {
+                alert('Login failed: ' + error.message);
+            }
+        },
+
+        logout() {
+            API.logout();
+            document.getElementById('logout-button').style.display = 'none';
+            state.clients = [];
+            state.products = [];
+            state.orders = [];
+            this.renderAll();
+            this.showModal('login-modal');
+        },
+
         setupEventListeners() {
             // Form submissions
+            document.getElementById('login-form').addEventListener('submit', this.handleLoginSubmit.bind(this));
             document.getElementById('client-form').addEventListener('submit', this.handleClientSubmit.bind(this));
             document.getElementById('product-form').addEventListener('submit', this.handleProductSubmit.bind(this));
             document.getElementById('order-form').addEventListener('submit', this.handleOrderSubmit.bind(this));
@@ -146,21 +208,37 @@ document.addEventListener('DOMContentLoaded', () => {
                                 <td>${state.clients.find(c => c.id === order.client_id)?.name || 'Unknown'}</td>
                                 <td>${new Date(order.date).toLocaleDateString()}</td>
                                 <td>${order.total_amount}</td>
-                                <td>${order.is_confirmed ? 'Confirmed' : 'Draft'}</td>
+                                <td>${statusLabels[order.status] || order.status}</td>
                                 <td>
-                                    ${!order.is_confirmed ? `
+                                    ${order.status === 'draft' ? `
                                         <button onclick="app.editOrder(${order.id})">Edit</button>
-                                        <button onclick="app.confirmOrder(${order.id})">Confirm</button>
                                     ` : ''}
+                                    ${(orderTransitions[order.status] || []).map(t => `
+                                        <button onclick="app.transitionOrder(${order.id}, '${t}')">${transitionLabels[t]}</button>
+                                    `).join('')}
                                     <button class="delete" onclick="app.deleteOrder(${order.id})">Delete</button>
                                 </td>
                             </tr>
                         `).join('')}
                     </tbody>
                 </table>
+                ${state.ordersCursor ? `
+                    <button onclick="app.loadMoreOrders()">Load more (${state.orders.length} of ${state.ordersTotal})</button>
+                ` : ''}
             `;
         },
 
+        async loadMoreOrders() {
+            try {
+                const page = await API.getOrders({ cursor: state.ordersCursor });
+                state.orders = state.orders.concat(page.items);
+                state.ordersCursor = page.next_cursor || null;
+                this.renderOrders();
+            } catch (error) {
+                alert('Failed to load orders: ' + error.message);
+            }
+        },
+
         // Event Handlers
         async handleClientSubmit(event) {
             event.preventDefault();
@@ -215,8 +293,8 @@ document.addEventListener('DOMContentLoaded', () => {
                     number: formData.get('number'),
                     items: Array.from(document.querySelectorAll('.order-item')).map(item => ({
                         product_id: parseInt(item.querySelector('[name="product_id"]').value),
-                        quantity: parseFloat(item.querySelector('[name="quantity"]').value),
-                        price: parseFloat(item.querySelector('[name="price"]').value)
+                        quantity: item.querySelector('[name="quantity"]').value,
+                        price: item.querySelector('[name="price"]').value
                     }))
                 };
 
@@ -270,17 +348,17 @@ document.addEventListener('DOMContentLoaded', () => {
             }
         },
 
-        async confirmOrder(id) {
+        async transitionOrder(id, transition) {
             try {
-                const result = await API.confirmOrder(id);
+                const result = await API.transitionOrder(id, transition);
                 const index = state.orders.findIndex(o => o.id === id);
                 if (index !== -1) {
                     state.orders[index] = result;
                 }
                 this.renderOrders();
             } catch (error) {
-                console.error('Failed to confirm order:', error);
-                alert('Failed to confirm order. Please try again.');
+                console.error(`Failed to ${transition} order:`, error);
+                alert(`Failed to ${transitionLabels[transition].toLowerCase()} order: ${error.message}`);
             }
         },
 